@@ -2,21 +2,24 @@ module github.com/javanhut/vem
 
 go 1.25.3
 
-require gioui.org v0.9.0
+require (
+	gioui.org v0.9.0
+	github.com/UserExistsError/conpty v0.1.4
+	github.com/alecthomas/chroma/v2 v2.20.0
+	github.com/creack/pty v1.1.24
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/hinshun/vt10x v0.0.0-20220301184237-5011da428d02
+	golang.design/x/clipboard v0.7.1
+	golang.org/x/sys v0.38.0
+)
 
 require (
 	gioui.org/shader v1.0.8 // indirect
-	github.com/UserExistsError/conpty v0.1.4 // indirect
-	github.com/alecthomas/chroma/v2 v2.20.0 // indirect
-	github.com/creack/pty v1.1.24 // indirect
 	github.com/dlclark/regexp2 v1.11.5 // indirect
 	github.com/go-text/typesetting v0.3.0 // indirect
-	github.com/hinshun/vt10x v0.0.0-20220301184237-5011da428d02 // indirect
-	golang.design/x/clipboard v0.7.1 // indirect
 	golang.org/x/exp/shiny v0.0.0-20250606033433-dcc06ee1d476 // indirect
 	golang.org/x/image v0.28.0 // indirect
 	golang.org/x/mobile v0.0.0-20250606033058-a2a15c67f36f // indirect
-	golang.org/x/sys v0.38.0 // indirect
 	golang.org/x/term v0.37.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
 	mvdan.cc/sh/v3 v3.12.0 // indirect
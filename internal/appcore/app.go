@@ -34,6 +34,7 @@ import (
 	"github.com/javanhut/vem/internal/panes"
 	"github.com/javanhut/vem/internal/syntax"
 	"github.com/javanhut/vem/internal/terminal"
+	"github.com/javanhut/vem/internal/textwidth"
 )
 
 type mode string
@@ -56,6 +57,7 @@ type FuzzyMatch struct {
 	FilePath string
 	Score    int
 	Indices  []int
+	Line     int // 1-based line to show and jump to, 0 if none
 }
 
 const (
@@ -92,7 +94,12 @@ var (
 type appState struct {
 	theme                *material.Theme
 	bufferMgr            *editor.BufferManager
-	paneManager          *panes.PaneManager
+	paneManager          *panes.PaneManager // Pane layout of the current tab page
+	tabs                 *panes.TabPages
+	paneViews            map[*panes.Pane]*paneView // State of the panes that aren't active
+	livePane             *panes.Pane               // Pane the live cursor, selection and search belong to
+	paneArea             image.Point               // Size of the area the panes share, in pixels
+	paneCell             image.Point               // Size of an editor column and line, in pixels
 	fileTree             *filesystem.FileTree
 	mode                 mode
 	status               string
@@ -101,7 +108,9 @@ type appState struct {
 	pendingCount         int
 	pendingGoto          bool
 	pendingScroll        bool
+	pendingIndentOp      rune // >, < or = waiting for its motion, or 0
 	pendingPaneCmd       bool
+	pendingWindowCmd     bool
 	visualMode           visualModeType
 	visualStartLine      int
 	visualStartCol       int
@@ -110,12 +119,16 @@ type appState struct {
 	skipNextSearchEdit   bool
 	skipNextFuzzyEdit    bool
 	skipNextTerminalEdit bool
+	windowFocused        bool // The window has the keyboard focus
 	caretVisible         bool
 	nextBlink            time.Time
 	caretReset           bool
 	clipLines            []string
 	clipboardIsLine      bool
 	cmdText              string
+	cmdCursor            int // Byte offset of the cursor in cmdText
+	cmdCompletion        cmdCompletion
+	cmdHistory           cmdHistory
 	window               *app.Window
 
 	// Explorer state
@@ -123,6 +136,7 @@ type appState struct {
 	explorerWidth        int
 	explorerFocused      bool
 	explorerListPosition layout.List
+	explorerWatcher      *filesystem.Watcher // Keeps expanded directories in sync with disk
 
 	// File operation state
 	fileOpMode         string
@@ -142,6 +156,21 @@ type appState struct {
 	fuzzyFinderFiles       []string
 	fuzzyFinderMatches     []FuzzyMatch
 	fuzzyFinderSelectedIdx int
+	fuzzyFinderAllMatches  []FuzzyMatch // Every match for fuzzyFinderQuery, used to narrow longer queries
+	fuzzyFinderQuery       string       // Input that fuzzyFinderAllMatches was computed for
+	fuzzyFinderVersion     uint64       // Index version fuzzyFinderFiles belongs to
+	fuzzyFinderScanning    bool         // Index is still streaming in files
+	fuzzyFinderLine        int          // Line requested with a ":<line>" suffix
+	fuzzyFinderMarked      []FuzzyMatch // Results marked with Tab, in marking order
+	fuzzyPreviewEnabled    bool
+	fuzzyPreview           *fuzzyPreview
+	fuzzyPreviewLoader     fuzzyPreviewLoader
+	fuzzyPreviewList       layout.List
+	fileIndex              *filesystem.FileIndex
+
+	// Quickfix list
+	quickfixList []QuickfixEntry
+	quickfixIdx  int
 
 	// Modifier tracking (some platforms don't report modifiers correctly)
 	ctrlPressed  bool
@@ -162,14 +191,29 @@ type appState struct {
 	listPosition      layout.List
 
 	// Syntax highlighting state
-	syntaxHighlighters map[int]*syntax.Highlighter // Map from buffer index to highlighter
+	syntaxHighlighters map[int]*syntax.Highlighter // Map from buffer ID to highlighter
 	syntaxEnabled      bool                        // Global toggle for syntax highlighting
 
 	// Terminal state
-	terminals          map[int]*terminal.Terminal // Map from buffer index to terminal
-	lastWindowSize     image.Point                // Track window size for terminal resize
-	terminalViewports  map[int]int                // Map from buffer index to viewportTopLine for terminals
-	terminalAutoScroll map[int]bool               // Map from buffer index to auto-scroll enabled
+	terminals          map[int]*terminal.Terminal   // Map from buffer ID to terminal
+	lastWindowSize     image.Point                  // Track window size for terminal resize
+	terminalViewports  map[int]int                  // Map from buffer ID to viewportTopLine for terminals
+	terminalAutoScroll map[int]bool                 // Map from buffer ID to auto-scroll enabled
+	terminalHistory    map[int]*terminalHistoryView // Map from buffer ID to scrollback view
+	terminalScrollback int                          // Scrollback lines kept for new terminals
+	terminalPalette    string                       // Name of the terminal palette, see options.go
+	terminalJobs       map[int]*terminalJob         // Map from buffer ID to the command it runs
+	terminalDirs       map[int]string               // Map from buffer ID to the shell's last seen directory
+	termSendTarget     int                          // Buffer ID :TermSend and gs sent to last
+	linkClick          *terminalLinkClick           // Terminal link clicked on the last frame
+	confirmLinkURL     string                       // Hyperlink target shown on the last open, opened on the next
+
+	// :make settings
+	makeprg       string               // Build command run by :make
+	errorformat   string               // Patterns for parsing :make output
+	errorPatterns []errorformatPattern // errorformat compiled when :make ran
+
+	autoPairs bool // Close brackets and quotes as they are typed in INSERT mode
 }
 
 func Run(w *app.Window, filePaths []string) error {
@@ -179,6 +223,11 @@ func Run(w *app.Window, filePaths []string) error {
 
 func (s *appState) run(w *app.Window) error {
 	s.window = w
+	s.startExplorerWatcher()
+	if s.fileTree != nil {
+		// Warm the fuzzy finder index in the background
+		s.ensureFileIndex()
+	}
 	defer s.cleanup()
 	var ops op.Ops
 	for {
@@ -195,15 +244,48 @@ func (s *appState) run(w *app.Window) error {
 				s.handleWindowResize(newSize)
 			}
 		case app.FrameEvent:
+			if s.fileTree != nil {
+				s.fileTree.ApplyWatchChanges()
+			}
+			s.syncFuzzyFinderWithIndex()
+			s.applyTerminalJobs()
+			s.applyTerminalCwd()
+			s.applyTerminalLinkClick()
 			gtx := app.NewContext(&ops, e)
 			s.layout(gtx)
+			s.applyTerminalFocus()
 			e.Frame(gtx.Ops)
 		}
 	}
 }
 
+// startExplorerWatcher attaches a filesystem watcher to the file tree so the
+// explorer picks up files created, deleted or renamed outside of Vem.
+func (s *appState) startExplorerWatcher() {
+	if s.fileTree == nil {
+		return
+	}
+	watcher, err := filesystem.NewWatcher(func() {
+		// Changes are applied on the UI goroutine at the next frame
+		s.window.Invalidate()
+	})
+	if err != nil {
+		// Watching is best effort; manual refresh still works
+		return
+	}
+	s.explorerWatcher = watcher
+	s.fileTree.SetWatcher(watcher)
+}
+
 // cleanup performs shutdown tasks, including closing all terminals
 func (s *appState) cleanup() {
+	if s.explorerWatcher != nil {
+		s.fileTree.SetWatcher(nil)
+		s.explorerWatcher.Close()
+	}
+	if s.fileIndex != nil {
+		s.fileIndex.Close()
+	}
 	for _, term := range s.terminals {
 		if term != nil {
 			if err := term.Close(); err != nil {
@@ -268,8 +350,8 @@ func newAppState(filePaths []string) *appState {
 		bufferMgr = editor.NewBufferManagerWithBuffer(buf)
 	}
 
-	// Initialize pane manager with the initial buffer (index 0)
-	paneManager := panes.NewPaneManager(0)
+	// Initialize the first tab page's panes with the first buffer
+	tabs := panes.NewTabPages(bufferMgr.Buffers()[0].ID())
 
 	// Initialize file tree from current directory
 	workDir, err := os.Getwd()
@@ -286,7 +368,8 @@ func newAppState(filePaths []string) *appState {
 	return &appState{
 		theme:                theme,
 		bufferMgr:            bufferMgr,
-		paneManager:          paneManager,
+		paneManager:          tabs.Active(),
+		tabs:                 tabs,
 		fileTree:             fileTree,
 		mode:                 modeNormal,
 		status:               "Ready",
@@ -299,6 +382,8 @@ func newAppState(filePaths []string) *appState {
 		explorerWidth:        275,
 		explorerFocused:      false,
 		explorerListPosition: layout.List{Axis: layout.Vertical},
+		fuzzyPreviewEnabled:  true,
+		fuzzyPreviewList:     layout.List{Axis: layout.Vertical},
 		currentWindowMode:    app.Windowed,
 		wasFullscreen:        false,
 		viewportTopLine:      0,
@@ -309,7 +394,17 @@ func newAppState(filePaths []string) *appState {
 		terminals:            make(map[int]*terminal.Terminal),
 		terminalViewports:    make(map[int]int),
 		terminalAutoScroll:   make(map[int]bool),
+		terminalHistory:      make(map[int]*terminalHistoryView),
+		terminalScrollback:   terminal.DefaultScrollbackLines,
+		terminalPalette:      "vem",
+		terminalJobs:         make(map[int]*terminalJob),
+		terminalDirs:         make(map[int]string),
+		termSendTarget:       -1,
+		makeprg:              defaultMakeprg,
+		errorformat:          defaultErrorformat,
+		cmdHistory:           loadCommandHistory(),
 		lastWindowSize:       image.Point{},
+		windowFocused:        true,
 	}
 }
 
@@ -349,14 +444,7 @@ func openFileOrCreateEmpty(path string) (*editor.Buffer, error) {
 		return nil, fmt.Errorf("invalid path: %w", err)
 	}
 
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		buf := editor.NewBuffer("")
-		buf.SetFilePath(absPath)
-		buf.SetModified(false)
-		return buf, nil
-	}
-
-	return editor.NewBufferFromFile(absPath)
+	return editor.OpenFileBuffer(absPath)
 }
 
 // activeBuffer returns the buffer for the active pane.
@@ -370,7 +458,7 @@ func (s *appState) activeBuffer() *editor.Buffer {
 		return nil
 	}
 
-	return s.bufferMgr.GetBuffer(activePane.BufferIndex)
+	return s.bufferMgr.GetBuffer(activePane.BufferID)
 }
 
 // getOrCreateHighlighter returns the syntax highlighter for the active buffer,
@@ -390,10 +478,10 @@ func (s *appState) getOrCreateHighlighter() *syntax.Highlighter {
 		return syntax.NewPlainHighlighter()
 	}
 
-	bufferIndex := activePane.BufferIndex
+	bufferID := activePane.BufferID
 
 	// Check if we already have a highlighter for this buffer
-	if highlighter, exists := s.syntaxHighlighters[bufferIndex]; exists {
+	if highlighter, exists := s.syntaxHighlighters[bufferID]; exists {
 		return highlighter
 	}
 
@@ -402,13 +490,13 @@ func (s *appState) getOrCreateHighlighter() *syntax.Highlighter {
 	if filePath == "" || !syntax.ShouldHighlight(filePath) {
 		// No file path or shouldn't highlight - use plain highlighter
 		highlighter := syntax.NewPlainHighlighter()
-		s.syntaxHighlighters[bufferIndex] = highlighter
+		s.syntaxHighlighters[bufferID] = highlighter
 		return highlighter
 	}
 
 	// Create syntax highlighter for this file
 	highlighter := syntax.NewHighlighter(filePath)
-	s.syntaxHighlighters[bufferIndex] = highlighter
+	s.syntaxHighlighters[bufferID] = highlighter
 	return highlighter
 }
 
@@ -416,7 +504,7 @@ func (s *appState) getOrCreateHighlighter() *syntax.Highlighter {
 // This should be called when the buffer content changes.
 func (s *appState) invalidateSyntaxCache() {
 	if activePane := s.paneManager.ActivePane(); activePane != nil {
-		if highlighter, exists := s.syntaxHighlighters[activePane.BufferIndex]; exists {
+		if highlighter, exists := s.syntaxHighlighters[activePane.BufferID]; exists {
 			highlighter.InvalidateAll()
 		}
 	}
@@ -460,6 +548,9 @@ func (s *appState) layout(gtx layout.Context) layout.Dimensions {
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			return s.drawHeader(gtx)
 		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return s.drawTabLine(gtx)
+		}),
 		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
 			if s.explorerVisible && s.fileTree != nil {
 				// Horizontal split: explorer | panes
@@ -510,6 +601,7 @@ func (s *appState) handleEvents(gtx layout.Context) {
 		}
 		switch e := ev.(type) {
 		case key.FocusEvent:
+			s.windowFocused = e.Focus
 			if e.Focus {
 				s.status = "Ready"
 			}
@@ -540,7 +632,7 @@ func (s *appState) handleEvents(gtx layout.Context) {
 
 			if s.mode == modeNormal || s.mode == modeCommand || s.mode == modeExplorer || s.mode == modeSearch || s.mode == modeFuzzyFinder {
 				// In non-insert modes, reset modifiers after command keys unless waiting for pane command
-				shouldResetModifiers = !s.pendingPaneCmd
+				shouldResetModifiers = !s.pendingPaneCmd && !s.pendingWindowCmd
 			} else if s.mode == modeInsert {
 				// In INSERT mode, only reset for special keys (Escape, arrow keys, function keys)
 				// Don't reset for: printable keys, Tab (needs Shift state), or when pending pane command
@@ -548,7 +640,7 @@ func (s *appState) handleEvents(gtx layout.Context) {
 					e.Name == key.NameLeftArrow || e.Name == key.NameRightArrow ||
 					e.Name == key.NameUpArrow || e.Name == key.NameDownArrow ||
 					e.Name == key.NameDeleteBackward || e.Name == key.NameDeleteForward)
-				shouldResetModifiers = isSpecialKey && !s.pendingPaneCmd
+				shouldResetModifiers = isSpecialKey && !s.pendingPaneCmd && !s.pendingWindowCmd
 			}
 
 			if shouldResetModifiers {
@@ -584,8 +676,9 @@ func (s *appState) handleEvents(gtx layout.Context) {
 				continue
 			}
 
-			// Check for colon to enter command mode (except in INSERT, COMMAND, and TERMINAL modes)
-			if e.Text == ":" && s.mode != modeInsert && s.mode != modeCommand && s.mode != modeTerminal {
+			// Check for colon to enter command mode (except in INSERT, COMMAND, TERMINAL and
+			// FUZZY_FINDER modes; the finder accepts "file:line")
+			if e.Text == ":" && s.mode != modeInsert && s.mode != modeCommand && s.mode != modeTerminal && s.mode != modeFuzzyFinder {
 				s.enterCommandMode()
 				continue
 			}
@@ -678,6 +771,9 @@ func (s *appState) drawBuffer(gtx layout.Context) layout.Dimensions {
 	// Ensure cursor is visible in viewport
 	s.ensureCursorVisible(linesPerPage)
 
+	// Brackets further away than a page aren't shown anyway
+	pair := s.matchingPair(linesPerPage)
+
 	// Set scroll position to viewport top line
 	s.listPosition.Position.First = s.viewportTopLine
 	s.listPosition.Position.Offset = 0
@@ -703,15 +799,17 @@ func (s *appState) drawBuffer(gtx layout.Context) layout.Dimensions {
 
 	dims := inset.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
 		return s.listPosition.Layout(gtx, lines, func(gtx layout.Context, index int) layout.Dimensions {
-			return s.drawBufferLine(gtx, index, cursorLine, cursorCol, selStart, selEnd, hasSel)
+			return s.drawBufferLine(gtx, index, cursorLine, cursorCol, selStart, selEnd, hasSel, pair)
 		})
 	})
 
 	return dims
 }
 
-// drawBufferLine renders a single line with syntax highlighting.
-func (s *appState) drawBufferLine(gtx layout.Context, index int, cursorLine int, cursorCol int, selStart int, selEnd int, hasSel bool) layout.Dimensions {
+// drawBufferLine renders a single line with syntax highlighting. pair is
+// the bracket under the cursor and its match, highlighted when they are on
+// the line.
+func (s *appState) drawBufferLine(gtx layout.Context, index int, cursorLine int, cursorCol int, selStart int, selEnd int, hasSel bool, pair []editor.Cursor) layout.Dimensions {
 	// Get the line text
 	lineText := s.activeBuffer().Line(index)
 	gutter := fmt.Sprintf("%4d  ", index+1)
@@ -721,7 +819,7 @@ func (s *appState) drawBufferLine(gtx layout.Context, index int, cursorLine int,
 	tokens := highlighter.HighlightLine(index, lineText)
 
 	// Expand tabs in gutter
-	gutterExpanded := expandTabs(gutter, 4)
+	gutterExpanded := expandTabs(gutter, s.tabWidth())
 
 	// Create flex children for gutter + tokens
 	var flexChildren []layout.FlexChild
@@ -738,7 +836,7 @@ func (s *appState) drawBufferLine(gtx layout.Context, index int, cursorLine int,
 	for _, token := range tokens {
 		// Capture token in closure
 		t := token
-		tokenText := expandTabs(t.Text, 4)
+		tokenText := expandTabs(t.Text, s.tabWidth())
 
 		flexChildren = append(flexChildren, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			label := material.Body1(s.theme, tokenText)
@@ -772,6 +870,10 @@ func (s *appState) drawBufferLine(gtx layout.Context, index int, cursorLine int,
 		s.drawSearchHighlights(gtx, index, dims.Size.Y)
 	}
 
+	if len(pair) > 0 {
+		s.drawPairHighlight(gtx, index, dims.Size.Y, pair)
+	}
+
 	// Draw the text on top
 	call.Add(gtx.Ops)
 
@@ -987,10 +1089,15 @@ func (s *appState) drawFuzzyFinder(gtx layout.Context) layout.Dimensions {
 	paint.Fill(gtx.Ops, overlayBg)
 	overlayRect.Pop()
 
-	// Calculate centered fuzzy finder dimensions
+	// Calculate centered fuzzy finder dimensions (wider when previewing)
 	finderWidth := gtx.Constraints.Max.X * 3 / 4
-	if finderWidth > 800 {
-		finderWidth = 800
+	maxWidth := 800
+	if s.fuzzyPreviewEnabled {
+		finderWidth = gtx.Constraints.Max.X * 9 / 10
+		maxWidth = 1400
+	}
+	if finderWidth > maxWidth {
+		finderWidth = maxWidth
 	}
 	finderHeight := gtx.Constraints.Max.Y * 2 / 3
 	if finderHeight > 600 {
@@ -1045,53 +1152,71 @@ func (s *appState) drawFuzzyFinder(gtx layout.Context) layout.Dimensions {
 			// Match count
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				matchInfo := fmt.Sprintf("%d matches", len(s.fuzzyFinderMatches))
+				if len(s.fuzzyFinderMarked) > 0 {
+					matchInfo += fmt.Sprintf(", %d marked", len(s.fuzzyFinderMarked))
+				}
 				label := material.Body2(s.theme, matchInfo)
 				label.Font.Typeface = "JetBrainsMono"
 				label.Color = color.NRGBA{R: 0xa1, G: 0xc6, B: 0xff, A: 0xff}
 				return layout.Inset{Bottom: unit.Dp(8)}.Layout(gtx, label.Layout)
 			}),
-			// Results list
+			// Results list, with the preview to its right
 			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
-				list := layout.List{Axis: layout.Vertical}
-				return list.Layout(gtx, len(s.fuzzyFinderMatches), func(gtx layout.Context, index int) layout.Dimensions {
-					match := s.fuzzyFinderMatches[index]
-
-					// Highlight selected item
-					if index == s.fuzzyFinderSelectedIdx {
-						selectedBg := color.NRGBA{R: 0x2b, G: 0x50, B: 0x8a, A: 0x88}
-						rect := clip.Rect{Max: image.Pt(gtx.Constraints.Max.X, gtx.Dp(unit.Dp(24)))}.Push(gtx.Ops)
-						paint.Fill(gtx.Ops, selectedBg)
-						rect.Pop()
-					}
-
-					// Draw file path with highlighted matched characters
-					label := material.Body2(s.theme, match.FilePath)
-					label.Font.Typeface = "JetBrainsMono"
-					if index == s.fuzzyFinderSelectedIdx {
-						label.Color = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
-					} else {
-						label.Color = color.NRGBA{R: 0xdf, G: 0xe7, B: 0xff, A: 0xff}
-					}
-
-					return layout.Inset{
-						Top:    unit.Dp(2),
-						Bottom: unit.Dp(2),
-						Left:   unit.Dp(4),
-					}.Layout(gtx, label.Layout)
-				})
+				if !s.fuzzyPreviewEnabled || gtx.Constraints.Max.X < gtx.Dp(unit.Dp(500)) {
+					return s.drawFuzzyResults(gtx)
+				}
+				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+					layout.Flexed(0.4, s.drawFuzzyResults),
+					layout.Flexed(0.6, s.drawFuzzyPreview),
+				)
 			}),
 		)
 	})
 }
 
-func (s *appState) drawCommandBar(gtx layout.Context) layout.Dimensions {
-	prompt := ":" + s.cmdText
-	label := material.Body2(s.theme, prompt)
-	label.Font.Typeface = "JetBrainsMono"
-	label.Color = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+// drawFuzzyResults draws the list of fuzzy finder matches.
+func (s *appState) drawFuzzyResults(gtx layout.Context) layout.Dimensions {
+	list := layout.List{Axis: layout.Vertical}
+	return list.Layout(gtx, len(s.fuzzyFinderMatches), func(gtx layout.Context, index int) layout.Dimensions {
+		match := s.fuzzyFinderMatches[index]
+
+		// Highlight selected item
+		if index == s.fuzzyFinderSelectedIdx {
+			selectedBg := color.NRGBA{R: 0x2b, G: 0x50, B: 0x8a, A: 0x88}
+			rect := clip.Rect{Max: image.Pt(gtx.Constraints.Max.X, gtx.Dp(unit.Dp(24)))}.Push(gtx.Ops)
+			paint.Fill(gtx.Ops, selectedBg)
+			rect.Pop()
+		}
+
+		// Draw file path with highlighted matched characters
+		text := match.FilePath
+		if len(s.fuzzyFinderMarked) > 0 {
+			// Keep paths aligned once anything is marked
+			if s.fuzzyFinderIsMarked(match.FilePath) {
+				text = "+ " + text
+			} else {
+				text = "  " + text
+			}
+		}
+		label := material.Body2(s.theme, text)
+		label.Font.Typeface = "JetBrainsMono"
+		if index == s.fuzzyFinderSelectedIdx {
+			label.Color = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+		} else {
+			label.Color = color.NRGBA{R: 0xdf, G: 0xe7, B: 0xff, A: 0xff}
+		}
+
+		return layout.Inset{
+			Top:    unit.Dp(2),
+			Bottom: unit.Dp(2),
+			Left:   unit.Dp(4),
+		}.Layout(gtx, label.Layout)
+	})
+}
 
+func (s *appState) drawCommandBar(gtx layout.Context) layout.Dimensions {
 	macro := op.Record(gtx.Ops)
-	dims := layout.UniformInset(unit.Dp(8)).Layout(gtx, label.Layout)
+	dims := layout.UniformInset(unit.Dp(8)).Layout(gtx, s.drawCommandPrompt)
 	call := macro.Stop()
 
 	rect := clip.Rect{Max: image.Pt(gtx.Constraints.Max.X, dims.Size.Y)}.Push(gtx.Ops)
@@ -1099,6 +1224,7 @@ func (s *appState) drawCommandBar(gtx layout.Context) layout.Dimensions {
 	rect.Pop()
 
 	call.Add(gtx.Ops)
+	s.drawWildmenu(gtx)
 	return layout.Dimensions{
 		Size: image.Pt(gtx.Constraints.Max.X, dims.Size.Y),
 	}
@@ -1222,6 +1348,7 @@ func (s *appState) handleKey(ev key.Event) {
 		return
 	}
 	s.lastKey = describeKey(ev)
+	s.syncPaneView()
 
 	// Clear skipNextEdit at the start of each KeyEvent to prevent stale state
 	// This ensures we only skip EditEvents that correspond to THIS KeyEvent
@@ -1255,15 +1382,33 @@ func (s *appState) handleKey(ev key.Event) {
 		return
 	}
 
-	// Phase 1: Try mode-specific keybindings first for COMMAND mode
-	// (COMMAND mode keys should take priority over global shortcuts)
-	if s.mode == modeCommand {
+	// Handle Ctrl+W prefix for Vim's window resizing commands
+	if s.mode == modeNormal && s.ctrlPressed && strings.ToLower(string(ev.Name)) == "w" && !s.pendingWindowCmd {
+		s.pendingWindowCmd = true
+		s.status = "Window: + - taller/shorter  < > narrower/wider  _ | maximize  = equalize"
+		return
+	}
+
+	if s.pendingWindowCmd {
+		s.handleWindowCommand(ev)
+		s.pendingWindowCmd = false
+		return
+	}
+
+	// Phase 1: Try mode-specific keybindings first for COMMAND and FUZZY modes
+	// (their keys, e.g. Ctrl+T/Ctrl+X in the finder, take priority over global shortcuts)
+	if s.mode == modeCommand || s.mode == modeFuzzyFinder {
 		if action := s.matchModeKeybinding(s.mode, ev); action != ActionNone {
 			s.executeAction(action, ev)
 			return
 		}
 	}
 
+	// Terminal buffers in NORMAL mode scroll their history instead of the buffer
+	if s.mode == modeNormal && s.handleTerminalHistoryKey(ev) {
+		return
+	}
+
 	// Phase 2: Try global keybindings (highest priority for other modes)
 	if action := s.matchGlobalKeybinding(ev); action != ActionNone {
 		s.executeAction(action, ev)
@@ -1362,6 +1507,10 @@ func (s *appState) handleNormalModeSpecial(ev key.Event) bool {
 			}
 			s.pendingScroll = false
 		}
+		if s.pendingIndentOp != 0 {
+			s.handleIndentMotion(r)
+			return true
+		}
 		switch r {
 		case 'G':
 			s.gotoLineWithCount()
@@ -1372,6 +1521,12 @@ func (s *appState) handleNormalModeSpecial(ev key.Event) bool {
 		case 'z':
 			s.startScrollSequence()
 			return true
+		case '>', '<', '=':
+			s.startIndentOperator(r)
+			return true
+		case '%':
+			s.jumpToMatchingBracket()
+			return true
 		}
 	}
 	return false
@@ -1449,6 +1604,12 @@ func (s *appState) handleVisualModeSpecial(ev key.Event) bool {
 		case 'z':
 			s.startScrollSequence()
 			return true
+		case '>', '<', '=':
+			s.indentVisualSelection(r)
+			return true
+		case '%':
+			s.jumpToMatchingBracket()
+			return true
 		}
 	}
 	return false
@@ -1512,13 +1673,18 @@ func (s *appState) enterInsertMode() {
 	s.caretReset = true
 }
 
+// getCharAtCursor returns the character under the cursor with its
+// combining marks, or a blank past the end of the line.
 func (s *appState) getCharAtCursor(lineIdx, col int) string {
-	line := s.activeBuffer().Line(lineIdx)
-	if col >= len([]rune(line)) {
+	runes := []rune(s.activeBuffer().Line(lineIdx))
+	if col >= len(runes) {
 		return " "
 	}
-	runes := []rune(line)
-	return string(runes[col])
+	end := col + 1
+	for end < len(runes) && textwidth.IsCombining(runes[end]) {
+		end++
+	}
+	return string(runes[col:end])
 }
 
 func (s *appState) drawCursor(gtx layout.Context, gutter, prefix, charUnder string, height int) {
@@ -1549,7 +1715,7 @@ func (s *appState) drawCursor(gtx layout.Context, gutter, prefix, charUnder stri
 		// Expand tab character for display (tabs should render as spaces)
 		displayChar := charUnder
 		if charUnder == "\t" {
-			displayChar = expandTabs("\t", 4)
+			displayChar = expandTabs("\t", s.tabWidth())
 		}
 
 		charWidth := s.measureTextWidth(gtx, charUnder)
@@ -1573,7 +1739,7 @@ func (s *appState) drawCursor(gtx layout.Context, gutter, prefix, charUnder stri
 
 func (s *appState) measureTextWidth(gtx layout.Context, txt string) int {
 	// Expand tabs to spaces before measuring so measurements match visual rendering
-	expandedTxt := expandTabs(txt, 4)
+	expandedTxt := expandTabs(txt, s.tabWidth())
 
 	label := material.Body1(s.theme, expandedTxt)
 	label.Font.Typeface = "JetBrainsMono"
@@ -1710,7 +1876,7 @@ func (s *appState) scrollLineDown() {
 }
 
 // ensureTerminalCursorVisible ensures terminal cursor is visible in viewport with auto-scroll.
-func (s *appState) ensureTerminalCursorVisible(bufferIndex int, linesPerPage int, screen *terminal.ScreenBuffer) {
+func (s *appState) ensureTerminalCursorVisible(bufferID int, linesPerPage int, screen *terminal.ScreenBuffer) {
 	if screen == nil {
 		return
 	}
@@ -1719,17 +1885,17 @@ func (s *appState) ensureTerminalCursorVisible(bufferIndex int, linesPerPage int
 	_, rows := screen.Dimensions()
 
 	// Get or initialize viewport for this terminal
-	viewportTop, exists := s.terminalViewports[bufferIndex]
+	viewportTop, exists := s.terminalViewports[bufferID]
 	if !exists {
 		viewportTop = 0
-		s.terminalViewports[bufferIndex] = 0
+		s.terminalViewports[bufferID] = 0
 	}
 
 	// Check if auto-scroll is enabled (default: true)
-	autoScroll, exists := s.terminalAutoScroll[bufferIndex]
+	autoScroll, exists := s.terminalAutoScroll[bufferID]
 	if !exists {
 		autoScroll = true
-		s.terminalAutoScroll[bufferIndex] = true
+		s.terminalAutoScroll[bufferID] = true
 	}
 
 	// If auto-scroll disabled, don't adjust viewport (user is browsing history)
@@ -1764,7 +1930,7 @@ func (s *appState) ensureTerminalCursorVisible(bufferIndex int, linesPerPage int
 		viewportTop = maxTop
 	}
 
-	s.terminalViewports[bufferIndex] = viewportTop
+	s.terminalViewports[bufferID] = viewportTop
 }
 
 func (s *appState) handleCountDigit(d int) bool {
@@ -1792,6 +1958,7 @@ func (s *appState) resetCount() {
 	s.pendingCount = 0
 	s.pendingGoto = false
 	s.pendingScroll = false
+	s.pendingIndentOp = 0
 }
 
 func (s *appState) gotoLine(target int) {
@@ -1870,6 +2037,22 @@ func (s *appState) handleGotoSequence(r rune) bool {
 		}
 		s.gotoLine(target)
 		return true
+	case 's':
+		// Send the selection, or the paragraph, to a terminal
+		if s.mode == modeVisual {
+			code := s.visualSelectionText()
+			s.exitVisualMode()
+			s.sendToTerminal(code, "")
+		} else {
+			s.sendParagraphToTerminal()
+		}
+		return true
+	case 't':
+		s.gotoTab(true)
+		return true
+	case 'T':
+		s.gotoTab(false)
+		return true
 	default:
 		return false
 	}
@@ -1928,7 +2111,9 @@ func (s *appState) enterCommandMode() {
 		return
 	}
 	s.mode = modeCommand
-	s.cmdText = ""
+	s.setCommandText("")
+	s.cmdCompletion = cmdCompletion{}
+	s.cmdHistory.restart()
 	s.status = "COMMAND (:...)"
 }
 
@@ -1945,7 +2130,8 @@ func (s *appState) exitCommandMode() {
 	if s.mode == modeCommand {
 		s.mode = modeNormal
 	}
-	s.cmdText = ""
+	s.setCommandText("")
+	s.cmdCompletion = cmdCompletion{}
 }
 
 func (s *appState) enterExplorerMode() {
@@ -2058,7 +2244,7 @@ func (s *appState) openSelectedNode() {
 	if s.paneManager != nil {
 		activePane := s.paneManager.ActivePane()
 		if activePane != nil {
-			activePane.SetBufferIndex(s.bufferMgr.ActiveIndex())
+			activePane.SetBufferID(s.bufferMgr.ActiveID())
 		}
 	}
 
@@ -2334,28 +2520,31 @@ func (s *appState) appendCommandText(text string) {
 	if text == "" {
 		return
 	}
-	for _, r := range text {
-		if r == '\n' || r == '\r' {
-			continue
-		}
-		s.cmdText += string(r)
-	}
+	text = strings.NewReplacer("\n", "", "\r", "").Replace(text)
+	s.cmdText = s.cmdText[:s.cmdCursor] + text + s.cmdText[s.cmdCursor:]
+	s.cmdCursor += len(text)
 }
 
 func (s *appState) deleteCommandChar() {
-	if s.cmdText == "" {
-		return
-	}
-	runes := []rune(s.cmdText)
-	if len(runes) == 0 {
+	if s.cmdCursor == 0 {
 		return
 	}
-	s.cmdText = string(runes[:len(runes)-1])
+	_, size := utf8.DecodeLastRuneInString(s.cmdText[:s.cmdCursor])
+	s.cmdText = s.cmdText[:s.cmdCursor-size] + s.cmdText[s.cmdCursor:]
+	s.cmdCursor -= size
 }
 
 func (s *appState) executeCommandLine() {
-	cmd := strings.TrimSpace(s.cmdText)
+	cmd := s.cmdText
 	s.exitCommandMode()
+	s.addCommandHistory(cmd)
+	s.runCommand(cmd)
+}
+
+// runCommand runs one Ex command, typed on the command line or run for
+// each buffer or pane by :bufdo and :windo.
+func (s *appState) runCommand(cmd string) {
+	cmd = strings.TrimSpace(cmd)
 	if cmd == "" {
 		s.status = "No command"
 		return
@@ -2373,52 +2562,22 @@ func (s *appState) executeCommandLine() {
 	if len(fields) > 1 {
 		args = strings.Join(fields[1:], " ")
 	}
-	switch name {
-	case "q", "quit":
-		s.handleQuitCommand(false)
-	case "q!":
-		s.handleQuitCommand(true)
-	case "qa", "qall":
-		s.handleQuitAll(false)
-	case "qa!":
-		s.handleQuitAll(true)
-	case "w", "write":
-		s.handleWriteCommand(strings.TrimSpace(args), false)
-	case "wq":
-		s.handleWriteCommand(strings.TrimSpace(args), true)
-	case "e", "edit":
-		s.handleEditCommand(strings.TrimSpace(args))
-	case "bn", "bnext":
-		if s.bufferMgr.NextBuffer() {
-			s.status = "Switched to next buffer"
-		} else {
-			s.status = "Already at last buffer"
-		}
-	case "bp", "bprev":
-		if s.bufferMgr.PrevBuffer() {
-			s.status = "Switched to previous buffer"
-		} else {
-			s.status = "Already at first buffer"
-		}
-	case "bd", "bdelete":
-		s.handleBufferDeleteCommand(false)
-	case "bd!":
-		s.handleBufferDeleteCommand(true)
-	case "ls", "buffers":
-		s.handleListBuffersCommand()
-	case "ex", "explore":
-		s.toggleExplorer()
-	case "cd":
-		s.handleChangeDirectoryCommand(strings.TrimSpace(args))
-	case "pwd":
-		s.handlePrintWorkingDirectoryCommand()
-	case "term", "terminal":
-		s.handleOpenTerminal()
-	case "help", "h":
-		s.handleHelpCommand(strings.TrimSpace(args))
-	default:
+	// Shell commands keep their spacing
+	rawArgs := strings.TrimSpace(cmd[len(fields[0]):])
+	if s.handleSubstituteCommand(cmd) {
+		return
+	}
+	bang := strings.HasSuffix(name, "!")
+	command, ok := lookupExCommand(strings.TrimSuffix(name, "!"))
+	if !ok {
 		s.status = fmt.Sprintf("Unknown command: %s", name)
+		return
 	}
+	if bang && !command.bang {
+		s.status = fmt.Sprintf("E477: No ! allowed: %s", name)
+		return
+	}
+	command.run(s, exCall{bang: bang, args: args, raw: rawArgs})
 }
 
 func (s *appState) handleQuitCommand(force bool) {
@@ -2433,15 +2592,15 @@ func (s *appState) handleQuitCommand(force bool) {
 	}
 
 	// Get the buffer for this pane
-	buf := s.bufferMgr.GetBuffer(activePane.BufferIndex)
+	buf := s.bufferMgr.GetBuffer(activePane.BufferID)
 	if buf == nil {
 		// No buffer, just close the pane
 		if s.paneManager.PaneCount() > 1 {
 			s.paneManager.ClosePane()
 			s.status = fmt.Sprintf("Pane closed - %d panes remaining", s.paneManager.PaneCount())
 		} else {
-			// Last pane with no buffer - switch to buffer 0 (sample buffer)
-			activePane.SetBufferIndex(0)
+			// Last pane with no buffer - show the active one
+			activePane.SetBufferID(s.bufferMgr.ActiveID())
 			s.status = "No buffer to close"
 		}
 		return
@@ -2453,10 +2612,10 @@ func (s *appState) handleQuitCommand(force bool) {
 		return
 	}
 
-	bufferIndex := activePane.BufferIndex
+	bufferID := activePane.BufferID
 
 	// Close terminal if this buffer has one
-	s.closeTerminal(bufferIndex)
+	s.closeTerminal(bufferID)
 
 	// Multiple panes - close this pane and buffer
 	if s.paneManager.PaneCount() > 1 {
@@ -2464,32 +2623,30 @@ func (s *appState) handleQuitCommand(force bool) {
 			s.status = fmt.Sprintf("Error closing pane: %v", err)
 			return
 		}
-		s.bufferMgr.CloseBuffer(bufferIndex, force)
+		// The check above covers unsaved changes
+		_ = s.closeBuffer(bufferID, force)
 		s.status = fmt.Sprintf("Pane closed - %d panes remaining", s.paneManager.PaneCount())
 		return
 	}
 
-	// Last pane - close buffer but keep editor open
-	s.bufferMgr.CloseBuffer(bufferIndex, force)
-
-	// Ensure we have at least one buffer (switch to buffer 0 - sample buffer)
-	if s.bufferMgr.BufferCount() == 0 || s.bufferMgr.GetBuffer(0) == nil {
-		// This shouldn't happen, but handle gracefully
-		activePane.SetBufferIndex(0)
-		s.status = "Buffer closed"
-	} else {
-		// Switch to buffer 0 (sample buffer)
-		activePane.SetBufferIndex(0)
-		s.status = "Buffer closed"
+	// The last pane of a tab page takes the tab with it
+	if s.tabs.Count() > 1 {
+		_ = s.closeBuffer(bufferID, force)
+		s.handleTabCloseCommand()
+		return
 	}
+
+	// Last pane - close buffer but keep editor open; the pane shows the
+	// buffer that became active
+	_ = s.closeBuffer(bufferID, force)
+	s.status = "Buffer closed"
 }
 
 func (s *appState) handleQuitAll(force bool) {
 	// Check all buffers for unsaved changes (unless force)
 	if !force {
-		for i := 0; i < s.bufferMgr.BufferCount(); i++ {
-			buf := s.bufferMgr.GetBuffer(i)
-			if buf != nil && buf.Modified() && !buf.IsTerminal() {
+		for _, buf := range s.bufferMgr.Buffers() {
+			if buf.Modified() && !buf.IsTerminal() {
 				s.status = "Some buffers have unsaved changes (use :qa! to force)"
 				return
 			}
@@ -2497,8 +2654,8 @@ func (s *appState) handleQuitAll(force bool) {
 	}
 
 	// Close all terminals
-	for bufIdx := range s.terminals {
-		s.closeTerminal(bufIdx)
+	for bufID := range s.terminals {
+		s.closeTerminal(bufID)
 	}
 
 	// Actually close the application
@@ -2541,6 +2698,10 @@ func (s *appState) handleEditCommand(path string) {
 		s.status = "E471: Argument required"
 		return
 	}
+	if path == "#" {
+		s.editAlternateBuffer()
+		return
+	}
 
 	_, err := s.bufferMgr.OpenFile(path)
 	if err != nil {
@@ -2549,26 +2710,29 @@ func (s *appState) handleEditCommand(path string) {
 	}
 
 	// Update the active pane to display the newly opened buffer
-	if s.paneManager != nil {
-		activePane := s.paneManager.ActivePane()
-		if activePane != nil {
-			activePane.SetBufferIndex(s.bufferMgr.ActiveIndex())
-		}
-	}
+	s.showBufferInActivePane(s.bufferMgr.ActiveID())
 
 	s.status = fmt.Sprintf("Opened %s", path)
 }
 
 func (s *appState) handleBufferDeleteCommand(force bool) {
-	if err := s.bufferMgr.CloseActiveBuffer(force); err != nil {
+	if err := s.closeBuffer(s.activeBufferID(), force); err != nil {
 		s.status = fmt.Sprintf("Error: %v", err)
 	} else {
 		s.status = "Buffer deleted"
 	}
 }
 
-func (s *appState) handleListBuffersCommand() {
-	buffers := s.bufferMgr.ListBuffers()
+// handleListBuffersCommand implements :ls, and :ls! which also lists the
+// unlisted buffers such as help and scratch buffers.
+func (s *appState) handleListBuffersCommand(all bool) {
+	shown := make(map[int]bool)
+	for _, pm := range s.tabs.All() {
+		for _, pane := range pm.AllPanes() {
+			shown[pane.BufferID] = true
+		}
+	}
+	buffers := s.bufferMgr.ListBuffers(all, shown)
 	s.status = fmt.Sprintf("Buffers: %s", strings.Join(buffers, " | "))
 }
 
@@ -2630,26 +2794,32 @@ func (s *appState) handlePrintWorkingDirectoryCommand() {
 	s.status = fmt.Sprintf("Current directory: %s", s.fileTree.CurrentPath())
 }
 
-// handleHelpCommand opens the help buffer showing all keybindings
+// handleHelpCommand opens the help buffer showing all keybindings, at
+// the section or command topic names if given
 func (s *appState) handleHelpCommand(topic string) {
 	// Generate help text
 	helpText := generateHelpText()
+	line := 0
+	if topic != "" {
+		if line = helpTopicLine(strings.Split(helpText, "\n"), topic); line < 0 {
+			s.status = fmt.Sprintf("E149: Sorry, no help for %s", topic)
+			return
+		}
+	}
 
 	// Create a new buffer with help content
-	bufIdx := s.bufferMgr.CreateBufferWithContent(helpText)
+	bufID := s.bufferMgr.CreateBufferWithContent(helpText)
 
 	// Mark buffer as read-only (prevent editing)
-	if buf := s.bufferMgr.GetBuffer(bufIdx); buf != nil {
+	if buf := s.bufferMgr.GetBuffer(bufID); buf != nil {
 		buf.SetFilePath("[Help]")
 		buf.SetReadOnly(true)
+		buf.SetListed(false)
+		buf.SetCursor(editor.Cursor{Line: line})
 	}
 
 	// Update active pane to show help buffer
-	if s.paneManager != nil {
-		if activePane := s.paneManager.ActivePane(); activePane != nil {
-			activePane.SetBufferIndex(bufIdx)
-		}
-	}
+	s.showBufferInActivePane(bufID)
 
 	s.status = "Help: Press / to search, :q to close"
 }
@@ -2851,7 +3021,11 @@ func (s *appState) insertText(text string) {
 		return
 	}
 	buf := s.activeBuffer()
-	buf.InsertText(text)
+	if s.autoPairs {
+		buf.TypePaired(text)
+	} else {
+		buf.TypeText(text)
+	}
 
 	// Debug: Log buffer content and cursor position after insertion
 	s.setCursorStatus(fmt.Sprintf("Insert %q", text))
@@ -2903,12 +3077,16 @@ func (s *appState) printableKey(ev key.Event) (rune, bool) {
 	}
 
 	// Gio reports punctuation keys like Shift+; as ';' with ModShift; normalize to ':'.
-	if r == ';' && ev.Modifiers.Contain(key.ModShift) {
-		r = ':'
+	if shifted, ok := shiftedPunctuation[r]; ok && ev.Modifiers.Contain(key.ModShift) {
+		r = shifted
 	}
 	return r, true
 }
 
+// shiftedPunctuation maps punctuation keys to what they type with Shift on
+// a US layout, for the keys Vem binds.
+var shiftedPunctuation = map[rune]rune{';': ':', '.': '>', ',': '<', '5': '%'}
+
 func describeKey(ev key.Event) string {
 	if ev.Name != "" {
 		return string(ev.Name)
@@ -2938,8 +3116,19 @@ func isPrintableKey(keyName key.Name) bool {
 	return keyName == key.NameSpace
 }
 
+// tabWidth returns how many columns a tab takes up in the active buffer,
+// its tabstop.
+func (s *appState) tabWidth() int {
+	if buf := s.activeBuffer(); buf != nil {
+		return buf.IndentOptions().Tab()
+	}
+	return editor.DefaultIndentOptions().Tab()
+}
+
 // expandTabs converts tab characters to spaces.
-// tabWidth specifies how many spaces each tab should expand to.
+// tabWidth specifies how many spaces each tab should expand to. Tab stops
+// count display columns, so wide characters take two and combining marks
+// none.
 func expandTabs(s string, tabWidth int) string {
 	if !strings.Contains(s, "\t") {
 		return s
@@ -2953,7 +3142,7 @@ func expandTabs(s string, tabWidth int) string {
 			col += spaces
 		} else {
 			result.WriteRune(r)
-			col++
+			col += textwidth.Rune(r)
 		}
 	}
 	return result.String()
@@ -2985,6 +3174,13 @@ func (s *appState) executeSearch() {
 		return
 	}
 
+	// Terminal buffers search their scrollback and screen instead
+	if buf := s.activeBuffer(); buf != nil && buf.IsTerminal() {
+		s.mode = modeNormal
+		s.searchTerminalHistory(s.searchPattern)
+		return
+	}
+
 	s.searchMatches = s.findAllMatches(s.searchPattern)
 
 	if len(s.searchMatches) == 0 {
@@ -3133,22 +3329,154 @@ func (s *appState) enterFuzzyFinder() {
 		return
 	}
 
-	// Discover all files in the workspace
-	workDir := s.fileTree.CurrentPath()
-	files, err := filesystem.FindAllFiles(workDir)
-	if err != nil {
-		s.status = fmt.Sprintf("Error discovering files: %v", err)
-		return
-	}
+	// Files come from the background index, which may still be scanning
+	s.ensureFileIndex()
+	s.fileIndex.Refresh()
+	files, version, scanning := s.fileIndex.Snapshot()
 
 	s.mode = modeFuzzyFinder
 	s.fuzzyFinderActive = true
 	s.fuzzyFinderInput = ""
 	s.fuzzyFinderFiles = files
-	s.fuzzyFinderMatches = PerformFuzzyMatch("", files, 50)
+	s.fuzzyFinderVersion = version
+	s.fuzzyFinderScanning = scanning
+	s.fuzzyFinderQuery = ""
+	s.fuzzyFinderLine = 0
+	s.fuzzyFinderMarked = nil
+	s.fuzzyFinderAllMatches = FilterFuzzyMatches("", files)
+	s.fuzzyFinderMatches = topFuzzyMatches("", s.fuzzyFinderAllMatches, 50)
 	s.fuzzyFinderSelectedIdx = 0
 	s.skipNextFuzzyEdit = true
-	s.status = fmt.Sprintf("Fuzzy Finder: %d files", len(files))
+	s.updateFuzzyFinderStatus()
+}
+
+// ensureFileIndex (re)creates the fuzzy finder index when there is none or
+// the explorer root has changed.
+func (s *appState) ensureFileIndex() {
+	root := s.fileTree.CurrentPath()
+	if s.fileIndex != nil && s.fileIndex.Root() == root {
+		return
+	}
+	s.resetFileIndex()
+}
+
+// resetFileIndex discards the current index and starts a new scan.
+func (s *appState) resetFileIndex() {
+	if s.fileIndex != nil {
+		s.fileIndex.Close()
+	}
+	s.fileIndex = filesystem.NewFileIndex(s.fileTree.CurrentPath(), s.fileTree.IgnoreMatcher(), func() {
+		// Results are picked up on the UI goroutine at the next frame
+		if s.window != nil {
+			s.window.Invalidate()
+		}
+	})
+}
+
+// syncFuzzyFinderWithIndex merges files the index found since the last
+// frame into the current results. Only new files are scored unless the
+// index dropped files, in which case everything is rescored.
+func (s *appState) syncFuzzyFinderWithIndex() {
+	if !s.fuzzyFinderActive || s.fileIndex == nil {
+		return
+	}
+
+	files, version, scanning := s.fileIndex.Snapshot()
+	if version == s.fuzzyFinderVersion && len(files) == len(s.fuzzyFinderFiles) && scanning == s.fuzzyFinderScanning {
+		return
+	}
+
+	if version != s.fuzzyFinderVersion {
+		s.fuzzyFinderFiles = files
+		s.fuzzyFinderVersion = version
+		s.fuzzyFinderScanning = scanning
+		s.rescoreFuzzyMatches(s.fuzzyFinderFiles)
+		s.clampFuzzySelection()
+		s.updateFuzzyFinderStatus()
+		return
+	}
+
+	added := files[len(s.fuzzyFinderFiles):]
+	s.fuzzyFinderFiles = files
+	s.fuzzyFinderScanning = scanning
+	if len(added) > 0 {
+		newMatches := FilterFuzzyMatches(s.fuzzyFinderQuery, added)
+		s.fuzzyFinderAllMatches = append(s.fuzzyFinderAllMatches, newMatches...)
+		merged := append(append([]FuzzyMatch(nil), s.fuzzyFinderMatches...), topFuzzyMatches(s.fuzzyFinderQuery, newMatches, 50)...)
+		s.setFuzzyTopMatches(topFuzzyMatches(s.fuzzyFinderQuery, merged, 50))
+	}
+	s.updateFuzzyFinderStatus()
+}
+
+// rescoreFuzzyMatches scores candidates against the current input.
+func (s *appState) rescoreFuzzyMatches(candidates []string) {
+	s.fuzzyFinderQuery, s.fuzzyFinderLine = splitFuzzyQueryLine(s.fuzzyFinderInput)
+	s.fuzzyFinderAllMatches = FilterFuzzyMatches(s.fuzzyFinderQuery, candidates)
+	s.setFuzzyTopMatches(topFuzzyMatches(s.fuzzyFinderQuery, s.fuzzyFinderAllMatches, 50))
+}
+
+// setFuzzyTopMatches stores the displayed matches, tagging them with the
+// requested line.
+func (s *appState) setFuzzyTopMatches(matches []FuzzyMatch) {
+	for i := range matches {
+		matches[i].Line = s.fuzzyFinderLine
+	}
+	s.fuzzyFinderMatches = matches
+}
+
+func (s *appState) clampFuzzySelection() {
+	if s.fuzzyFinderSelectedIdx >= len(s.fuzzyFinderMatches) {
+		s.fuzzyFinderSelectedIdx = len(s.fuzzyFinderMatches) - 1
+	}
+	if s.fuzzyFinderSelectedIdx < 0 {
+		s.fuzzyFinderSelectedIdx = 0
+	}
+}
+
+func (s *appState) updateFuzzyFinderStatus() {
+	if s.fuzzyFinderScanning {
+		s.status = fmt.Sprintf("Fuzzy Finder: %d files (scanning...)", len(s.fuzzyFinderFiles))
+	} else {
+		s.status = fmt.Sprintf("Fuzzy Finder: %d files", len(s.fuzzyFinderFiles))
+	}
+	if s.fileIndex != nil {
+		if err := s.fileIndex.WatchErr(); err != nil {
+			s.status += fmt.Sprintf(" (not watching every directory: %v)", err)
+		}
+	}
+}
+
+// toggleHiddenFiles shows or hides ignored files in both the
+// explorer and the fuzzy finder.
+func (s *appState) toggleHiddenFiles() {
+	if s.fileTree == nil {
+		s.status = "File tree not available"
+		return
+	}
+
+	show := !s.fileTree.ShowHidden()
+	if err := s.fileTree.SetShowHidden(show); err != nil {
+		s.status = "Refresh error: " + err.Error()
+		return
+	}
+
+	// The index has to be rebuilt with the new visibility
+	if s.fileIndex != nil {
+		s.resetFileIndex()
+	}
+	if s.mode == modeFuzzyFinder {
+		files, version, scanning := s.fileIndex.Snapshot()
+		s.fuzzyFinderFiles = files
+		s.fuzzyFinderVersion = version
+		s.fuzzyFinderScanning = scanning
+		s.updateFuzzyMatches()
+	}
+
+	if show {
+		s.status = "Showing ignored files"
+	} else {
+		s.status = "Hiding ignored files"
+	}
 }
 
 func (s *appState) exitFuzzyFinder() {
@@ -3157,12 +3485,35 @@ func (s *appState) exitFuzzyFinder() {
 	s.fuzzyFinderInput = ""
 	s.fuzzyFinderFiles = nil
 	s.fuzzyFinderMatches = nil
+	s.fuzzyFinderAllMatches = nil
+	s.fuzzyFinderQuery = ""
+	s.fuzzyFinderLine = 0
+	s.fuzzyFinderMarked = nil
+	s.fuzzyPreview = nil
+	s.fuzzyPreviewLoader.reset()
 	s.fuzzyFinderSelectedIdx = 0
 	s.status = "Fuzzy finder cancelled"
 }
 
 func (s *appState) updateFuzzyMatches() {
-	s.fuzzyFinderMatches = PerformFuzzyMatch(s.fuzzyFinderInput, s.fuzzyFinderFiles, 50)
+	pattern, line := splitFuzzyQueryLine(s.fuzzyFinderInput)
+	if pattern == s.fuzzyFinderQuery {
+		// Only the ":<line>" suffix changed
+		s.fuzzyFinderLine = line
+		s.setFuzzyTopMatches(s.fuzzyFinderMatches)
+		return
+	}
+
+	// A longer query can only match a subset of the previous matches
+	if s.fuzzyFinderQuery != "" && strings.HasPrefix(pattern, s.fuzzyFinderQuery) {
+		candidates := make([]string, len(s.fuzzyFinderAllMatches))
+		for i, m := range s.fuzzyFinderAllMatches {
+			candidates[i] = m.FilePath
+		}
+		s.rescoreFuzzyMatches(candidates)
+	} else {
+		s.rescoreFuzzyMatches(s.fuzzyFinderFiles)
+	}
 	s.fuzzyFinderSelectedIdx = 0
 }
 
@@ -3171,7 +3522,7 @@ func (s *appState) appendFuzzyInput(text string) {
 		return
 	}
 	for _, r := range text {
-		if r == '\n' || r == '\r' {
+		if r == '\n' || r == '\r' || r == '\t' {
 			continue
 		}
 		s.fuzzyFinderInput += string(r)
@@ -3203,32 +3554,10 @@ func (s *appState) fuzzyFinderMoveDown() {
 	}
 }
 
+// fuzzyFinderConfirm opens the marked results, or the selected one, in the
+// current pane.
 func (s *appState) fuzzyFinderConfirm() {
-	if s.fuzzyFinderSelectedIdx < 0 || s.fuzzyFinderSelectedIdx >= len(s.fuzzyFinderMatches) {
-		s.exitFuzzyFinder()
-		return
-	}
-
-	match := s.fuzzyFinderMatches[s.fuzzyFinderSelectedIdx]
-	fullPath := filepath.Join(s.fileTree.CurrentPath(), match.FilePath)
-
-	_, err := s.bufferMgr.OpenFile(fullPath)
-	if err != nil {
-		s.status = fmt.Sprintf("Error opening %s: %v", match.FilePath, err)
-		s.exitFuzzyFinder()
-		return
-	}
-
-	// Update the active pane to display the newly opened buffer
-	if s.paneManager != nil {
-		activePane := s.paneManager.ActivePane()
-		if activePane != nil {
-			activePane.SetBufferIndex(s.bufferMgr.ActiveIndex())
-		}
-	}
-
-	s.exitFuzzyFinder()
-	s.status = fmt.Sprintf("Opened %s", match.FilePath)
+	s.fuzzyFinderOpen(fuzzyOpenCurrent)
 }
 
 // handleOpenTerminal creates a new terminal buffer and enters TERMINAL INPUT mode immediately
@@ -3249,11 +3578,20 @@ func (s *appState) handleOpenTerminal() {
 		return
 	}
 
+	s.openTerminal(terminal.Config{})
+}
+
+// openTerminal creates a terminal buffer in the active pane and enters
+// TERMINAL INPUT mode. cfg gets the size, working directory and window
+// filled in; without a shell the default interactive shell runs, and
+// without an OnExit callback the buffer closes when the shell exits. It
+// returns the buffer ID, or -1 when the terminal couldn't be started.
+func (s *appState) openTerminal(cfg terminal.Config) int {
 	// Get working directory for shell
 	workDir := s.getWorkingDirectory()
 
 	// Create terminal buffer
-	bufIdx := s.bufferMgr.CreateTerminalBuffer()
+	bufID := s.bufferMgr.CreateTerminalBuffer()
 
 	// Get terminal dimensions from window size
 	cols, rows := 80, 24
@@ -3269,34 +3607,42 @@ func (s *appState) handleOpenTerminal() {
 	}
 
 	// Create terminal instance with exit callback
-	term, err := terminal.NewTerminal(terminal.Config{
-		Width:      cols,
-		Height:     rows,
-		Shell:      terminal.DefaultShell(),
-		Args:       terminal.DefaultArgs(),
-		WorkingDir: workDir,
-		Window:     s.window,
-		OnExit: func() {
+	cfg.Width = cols
+	cfg.Height = rows
+	cfg.WorkingDir = workDir
+	cfg.Window = s.window
+	cfg.ScrollbackLines = s.terminalScrollbackConfig()
+	palette := s.terminalPaletteColors()
+	cfg.Palette = &palette
+	if cfg.Shell == "" {
+		cfg.Shell = terminal.DefaultShell()
+		if cfg.Args == nil {
+			cfg.Args = terminal.DefaultArgs()
+		}
+	}
+	if cfg.OnExit == nil {
+		cfg.OnExit = func() {
 			// Terminal exited (shell closed) - auto-close the buffer
-			s.handleTerminalAutoClose(bufIdx)
-		},
-	})
+			s.handleTerminalAutoClose(bufID)
+		}
+	}
+	term, err := terminal.NewTerminal(cfg)
 	if err != nil {
 		s.status = fmt.Sprintf("Error creating terminal: %v", err)
-		return
+		return -1
 	}
 
 	// Start terminal
 	if err := term.Start(); err != nil {
 		s.status = fmt.Sprintf("Error starting terminal: %v", err)
-		return
+		return -1
 	}
 
 	// Store terminal instance
-	s.terminals[bufIdx] = term
+	s.terminals[bufID] = term
 
 	// Store terminal reference in buffer
-	newBuf := s.bufferMgr.GetBuffer(bufIdx)
+	newBuf := s.bufferMgr.GetBuffer(bufID)
 	if newBuf != nil {
 		newBuf.SetTerminal(term)
 	}
@@ -3305,7 +3651,7 @@ func (s *appState) handleOpenTerminal() {
 	if s.paneManager != nil {
 		activePane := s.paneManager.ActivePane()
 		if activePane != nil {
-			activePane.SetBufferIndex(bufIdx)
+			activePane.SetBufferID(bufID)
 		}
 	}
 
@@ -3313,6 +3659,7 @@ func (s *appState) handleOpenTerminal() {
 	s.mode = modeTerminal
 	s.status = "TERMINAL INPUT (Esc to navigate, Shift+Tab to switch)"
 	s.skipNextTerminalEdit = true // Prevent backtick from leaking
+	return bufID
 }
 
 // handleTerminalExit exits terminal mode and returns to normal mode
@@ -3324,8 +3671,8 @@ func (s *appState) handleTerminalExit() {
 }
 
 // closeTerminal closes a terminal instance and cleans up
-func (s *appState) closeTerminal(bufIdx int) {
-	term, exists := s.terminals[bufIdx]
+func (s *appState) closeTerminal(bufID int) {
+	term, exists := s.terminals[bufID]
 	if !exists {
 		return
 	}
@@ -3334,24 +3681,30 @@ func (s *appState) closeTerminal(bufIdx int) {
 		// Silently handle terminal close errors
 	}
 
-	delete(s.terminals, bufIdx)
-	delete(s.terminalViewports, bufIdx)
-	delete(s.terminalAutoScroll, bufIdx)
+	delete(s.terminals, bufID)
+	delete(s.terminalViewports, bufID)
+	delete(s.terminalAutoScroll, bufID)
+	delete(s.terminalHistory, bufID)
+	delete(s.terminalJobs, bufID)
+	delete(s.terminalDirs, bufID)
 }
 
 // handleTerminalAutoClose is called when a terminal process exits (shell exits)
 // It automatically closes the terminal buffer
-func (s *appState) handleTerminalAutoClose(bufIdx int) {
+func (s *appState) handleTerminalAutoClose(bufID int) {
 
 	// Clean up terminal instance
-	delete(s.terminals, bufIdx)
-	delete(s.terminalViewports, bufIdx)
-	delete(s.terminalAutoScroll, bufIdx)
+	delete(s.terminals, bufID)
+	delete(s.terminalViewports, bufID)
+	delete(s.terminalAutoScroll, bufID)
+	delete(s.terminalHistory, bufID)
+	delete(s.terminalJobs, bufID)
+	delete(s.terminalDirs, bufID)
 
 	// If we're currently in this terminal buffer, switch to NORMAL mode
 	if s.paneManager != nil {
 		activePane := s.paneManager.ActivePane()
-		if activePane != nil && activePane.BufferIndex == bufIdx {
+		if activePane != nil && activePane.BufferID == bufID {
 			// Switch mode to NORMAL to prevent EditEvent issues
 			if s.mode == modeTerminal {
 				s.mode = modeNormal
@@ -3361,17 +3714,16 @@ func (s *appState) handleTerminalAutoClose(bufIdx int) {
 			// Multiple panes: close the pane
 			if s.paneManager.PaneCount() > 1 {
 				s.paneManager.ClosePane()
-				s.bufferMgr.CloseBuffer(bufIdx, true) // Force close (no unsaved warning for terminals)
+				_ = s.closeBuffer(bufID, true) // Force close (no unsaved warning for terminals)
 				s.status = fmt.Sprintf("Terminal exited - %d panes remaining", s.paneManager.PaneCount())
 			} else {
-				// Last pane: close buffer, switch to buffer 0
-				s.bufferMgr.CloseBuffer(bufIdx, true)
-				activePane.SetBufferIndex(0)
+				// Last pane: close buffer, the pane shows the one that became active
+				_ = s.closeBuffer(bufID, true)
 				s.status = "Terminal exited"
 			}
 		} else {
 			// Terminal is in a background pane/buffer - just close it silently
-			s.bufferMgr.CloseBuffer(bufIdx, true)
+			_ = s.closeBuffer(bufID, true)
 		}
 	}
 
@@ -3383,7 +3735,14 @@ func (s *appState) handleTerminalAutoClose(bufIdx int) {
 
 // getWorkingDirectory determines the working directory for the shell
 func (s *appState) getWorkingDirectory() string {
-	// Try to get directory from file tree first
+	// A terminal knows where its shell has cd'd to, if the shell reports it
+	if bufID, _, _, ok := s.activeTerminalHistory(); ok {
+		if dir := s.terminals[bufID].WorkingDir(); dir != "" {
+			return dir
+		}
+	}
+
+	// Try to get directory from file tree
 	if s.fileTree != nil {
 		path := s.fileTree.CurrentPath()
 		if path != "" {
@@ -3443,16 +3802,28 @@ func (s *appState) handleTerminalKey(ev key.Event) {
 		return
 	}
 
-	term, exists := s.terminals[activePane.BufferIndex]
+	term, exists := s.terminals[activePane.BufferID]
 	if !exists || term == nil {
 		s.status = "Terminal not found"
 		return
 	}
+	if code, exited := term.ExitCode(); exited {
+		s.mode = modeNormal
+		s.status = fmt.Sprintf("Process exited with status %d (:q to close)", code)
+		return
+	}
+
+	if terminalPasteKey(ev) {
+		s.pasteIntoTerminal(activePane.BufferID, term)
+		return
+	}
 
-	// Convert key event to terminal input sequence
-	inputSeq := terminal.KeyToTerminalSequence(ev)
+	// Convert key event to terminal input sequence, following the modes
+	// the application set
+	inputSeq := term.KeySequence(ev)
 
 	if inputSeq != "" {
+		s.followTerminalOutput(activePane.BufferID)
 		if err := term.Write([]byte(inputSeq)); err != nil {
 			// Silently handle terminal write errors
 		}
@@ -3471,12 +3842,13 @@ func (s *appState) handleTerminalEdit(text string) {
 		return
 	}
 
-	term, exists := s.terminals[activePane.BufferIndex]
+	term, exists := s.terminals[activePane.BufferID]
 	if !exists || term == nil {
 		return
 	}
 
 	// Send text to terminal
+	s.followTerminalOutput(activePane.BufferID)
 	if err := term.Write([]byte(text)); err != nil {
 		// Silently handle terminal write errors
 	}
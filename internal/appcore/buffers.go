@@ -0,0 +1,222 @@
+package appcore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/vem/internal/editor"
+)
+
+// Buffers are known by their ID, the number :ls shows. Panes, highlighters
+// and terminals are keyed by it, so they keep pointing at the right buffer
+// when others are closed.
+
+// bufferName returns how a buffer is named in :ls and :buffer, its path
+// relative to the working directory when it is under it.
+func bufferName(buf *editor.Buffer) string {
+	path := buf.FilePath()
+	switch {
+	case buf.IsTerminal():
+		return "[Terminal]"
+	case path == "":
+		return "[No Name]"
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+// handleBufferCommand implements :buffer N and :buffer name, which show
+// buffer N, or the one whose file name matches, in the active pane.
+func (s *appState) handleBufferCommand(args string) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		if buf := s.activeBuffer(); buf != nil {
+			s.status = fmt.Sprintf("Buffer %d: %s", buf.ID(), bufferName(buf))
+		}
+		return
+	}
+
+	var buf *editor.Buffer
+	if id, err := strconv.Atoi(args); err == nil {
+		if buf = s.bufferMgr.GetBuffer(id); buf == nil {
+			s.status = fmt.Sprintf("E86: Buffer %d does not exist", id)
+			return
+		}
+	} else {
+		found, err := s.bufferMgr.FindBuffer(args)
+		if err != nil {
+			s.status = fmt.Sprintf("E94: %v", err)
+			return
+		}
+		buf = found
+	}
+
+	s.bufferMgr.SwitchToBuffer(buf.ID())
+	s.showBufferInActivePane(buf.ID())
+	s.status = fmt.Sprintf("Buffer %d: %s", buf.ID(), bufferName(buf))
+}
+
+// cycleBuffer implements :bnext and :bprevious in the active pane.
+func (s *appState) cycleBuffer(forward bool) {
+	s.bufferMgr.SwitchToBuffer(s.activeBufferID())
+	var moved bool
+	if forward {
+		moved = s.bufferMgr.NextBuffer()
+	} else {
+		moved = s.bufferMgr.PrevBuffer()
+	}
+	if !moved {
+		s.status = "Only one buffer"
+		return
+	}
+	buf := s.bufferMgr.ActiveBuffer()
+	s.showBufferInActivePane(buf.ID())
+	s.status = fmt.Sprintf("Buffer %d: %s", buf.ID(), bufferName(buf))
+}
+
+// activeBufferID returns the ID of the buffer in the active pane.
+func (s *appState) activeBufferID() int {
+	if buf := s.activeBuffer(); buf != nil {
+		return buf.ID()
+	}
+	return s.bufferMgr.ActiveID()
+}
+
+// closeBuffer closes the buffer with the given ID and forgets its
+// highlighter. Panes in any tab that showed it show the buffer that
+// became active instead.
+func (s *appState) closeBuffer(id int, force bool) error {
+	if err := s.bufferMgr.CloseBuffer(id, force); err != nil {
+		return err
+	}
+	delete(s.syntaxHighlighters, id)
+
+	next := s.bufferMgr.ActiveID()
+	for _, pm := range s.tabs.All() {
+		for _, pane := range pm.AllPanes() {
+			if pane.BufferID == id {
+				pane.SetBufferID(next)
+			}
+		}
+	}
+	return nil
+}
+
+// bufferCandidates returns the names of the listed file buffers that
+// contain arg, for completing :buffer.
+func (s *appState) bufferCandidates(arg string) []string {
+	var matches []string
+	for _, buf := range s.bufferMgr.Buffers() {
+		if buf.FilePath() == "" || buf.IsTerminal() || !buf.Listed() {
+			continue
+		}
+		if name := bufferName(buf); strings.Contains(name, arg) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// editAlternateBuffer implements Ctrl+^ and :e #, which go back to the
+// buffer the active pane showed before.
+func (s *appState) editAlternateBuffer() {
+	alt := s.bufferMgr.Alternate()
+	if alt == nil {
+		s.status = "E23: No alternate file"
+		return
+	}
+	s.bufferMgr.SwitchToBuffer(alt.ID())
+	s.showBufferInActivePane(alt.ID())
+	s.status = fmt.Sprintf("Buffer %d: %s", alt.ID(), bufferName(alt))
+}
+
+// handleNewBufferCommand implements :enew, which shows a new empty buffer
+// in the active pane, and :scratch, which shows a new scratch buffer: one
+// that is unlisted and never has unsaved changes, so it is never saved.
+func (s *appState) handleNewBufferCommand(scratch bool) {
+	var id int
+	if scratch {
+		id = s.bufferMgr.CreateScratchBuffer()
+	} else {
+		id = s.bufferMgr.CreateEmptyBuffer()
+	}
+	s.bufferMgr.SwitchToBuffer(id)
+	s.showBufferInActivePane(id)
+	if scratch {
+		s.status = fmt.Sprintf("Scratch buffer %d", id)
+	} else {
+		s.status = fmt.Sprintf("Buffer %d: [No Name]", id)
+	}
+}
+
+// handleUpdateCommand implements :update, which writes the buffer only when
+// it has unsaved changes.
+func (s *appState) handleUpdateCommand() {
+	buf := s.activeBuffer()
+	if buf == nil || !buf.Modified() {
+		s.status = "No changes to write"
+		return
+	}
+	s.handleWriteCommand("", false)
+}
+
+// handleBufdoCommand implements :bufdo, which runs the commands, separated
+// by |, in each listed buffer in turn and ends in the last one. Terminal
+// buffers are skipped.
+func (s *appState) handleBufdoCommand(args string) {
+	cmds := splitCommandBar(args)
+	if len(cmds) == 0 {
+		s.status = "E471: Argument required"
+		return
+	}
+
+	count := 0
+	for _, buf := range s.bufferMgr.Buffers() {
+		if !buf.Listed() || buf.IsTerminal() || s.bufferMgr.GetBuffer(buf.ID()) == nil {
+			// The last check skips buffers an earlier command closed
+			continue
+		}
+		s.bufferMgr.SwitchToBuffer(buf.ID())
+		s.showBufferInActivePane(buf.ID())
+		for _, cmd := range cmds {
+			s.runCommand(cmd)
+		}
+		count++
+	}
+	s.status = fmt.Sprintf("bufdo: %d buffer(s) - %s", count, s.status)
+}
+
+// splitCommandBar splits the argument of :bufdo and :windo into the
+// commands separated by |. A \| stays in the command as |.
+func splitCommandBar(args string) []string {
+	var cmds []string
+	var cmd strings.Builder
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == '\\' && i+1 < len(args) && args[i+1] == '|':
+			cmd.WriteByte('|')
+			i++
+		case args[i] == '|':
+			cmds = append(cmds, cmd.String())
+			cmd.Reset()
+		default:
+			cmd.WriteByte(args[i])
+		}
+	}
+	cmds = append(cmds, cmd.String())
+
+	result := cmds[:0]
+	for _, c := range cmds {
+		if c = strings.TrimSpace(c); c != "" {
+			result = append(result, c)
+		}
+	}
+	return result
+}
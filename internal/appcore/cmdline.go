@@ -0,0 +1,454 @@
+package appcore
+
+import (
+	"image"
+	"image/color"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// The command line is edited at s.cmdCursor, a byte offset into s.cmdText.
+// Tab completes the word before the cursor and Up and Down recall earlier
+// commands that start with what was typed, Vim style.
+
+// wildmenuRows is how many completion candidates the wildmenu shows at once.
+const wildmenuRows = 10
+
+// maxCommandHistory is how many commands the history keeps.
+const maxCommandHistory = 200
+
+// cmdCompletion is the state of Tab completion in the command line: the
+// command line up to the completed argument, the candidates, which one
+// was put in last, and the text after the cursor. Typing anything else
+// starts over.
+type cmdCompletion struct {
+	base    string
+	matches []string
+	index   int
+	tail    string
+	text    string
+}
+
+// cmdHistory holds the commands run from the command line, oldest first.
+// While Up and Down browse it, index is the entry shown, prefix what was
+// typed before browsing started and text the command line as browsing
+// left it.
+type cmdHistory struct {
+	entries []string
+	index   int
+	prefix  string
+	text    string
+}
+
+// restart makes the next Up start from the newest command again.
+func (h *cmdHistory) restart() {
+	h.index, h.prefix, h.text = len(h.entries), "", ""
+}
+
+// setCommandText replaces the command line and puts the cursor at its end.
+func (s *appState) setCommandText(text string) {
+	s.cmdText = text
+	s.cmdCursor = len(text)
+}
+
+// completeCommandLine puts in the next completion of the word before the
+// cursor, or the previous one with back. The candidates are command names
+// for the first word, then file names for :edit, :write and :tabnew,
+// directories for :cd, buffer names for :buffer, help topics for :help and
+// option names for :set.
+func (s *appState) completeCommandLine(back bool) {
+	c := &s.cmdCompletion
+	if c.matches == nil || s.cmdText != c.text {
+		base, matches := s.completionCandidates(s.cmdText[:s.cmdCursor])
+		if len(matches) == 0 {
+			*c = cmdCompletion{}
+			s.status = "No completions"
+			return
+		}
+		*c = cmdCompletion{base: base, matches: matches, index: -1, tail: s.cmdText[s.cmdCursor:]}
+		if back {
+			c.index = 0
+		}
+	}
+
+	if back {
+		c.index = (c.index - 1 + len(c.matches)) % len(c.matches)
+	} else {
+		c.index = (c.index + 1) % len(c.matches)
+	}
+	s.cmdText = c.base + c.matches[c.index] + c.tail
+	s.cmdCursor = len(s.cmdText) - len(c.tail)
+	c.text = s.cmdText
+}
+
+// completionCandidates returns the part of line that completion keeps and
+// the candidates for the rest.
+func (s *appState) completionCandidates(line string) (string, []string) {
+	trimmed := strings.TrimLeft(line, " :")
+	lead := line[:len(line)-len(trimmed)]
+	name, arg, hasArg := strings.Cut(trimmed, " ")
+	if !hasArg {
+		return lead, prefixMatches(exCommands, strings.ToLower(name))
+	}
+
+	rest := strings.TrimLeft(arg, " ")
+	base := lead + name + " " + arg[:len(arg)-len(rest)]
+	command, _ := lookupExCommand(strings.ToLower(name))
+	switch command.name {
+	case "edit", "write", "wq", "tabnew", "tabedit":
+		return base, completePath(rest, false)
+	case "cd":
+		return base, completePath(rest, true)
+	case "buffer":
+		return base, s.bufferCandidates(rest)
+	case "help":
+		return base, prefixMatches(helpTopics(), rest)
+	case "set", "setlocal":
+		i := strings.LastIndex(rest, " ") + 1
+		if strings.Contains(rest[i:], "=") {
+			return base, nil
+		}
+		return base + rest[:i], prefixMatches(optionNames, rest[i:])
+	case "bufdo", "windo":
+		// Complete the command they run
+		cmdBase, matches := s.completionCandidates(rest)
+		return base + cmdBase, matches
+	}
+	return base, nil
+}
+
+// prefixMatches returns the words that start with prefix.
+func prefixMatches(words []string, prefix string) []string {
+	var matches []string
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			matches = append(matches, w)
+		}
+	}
+	return matches
+}
+
+// completePath returns the files and directories arg can be completed to,
+// or only the directories with dirsOnly. Directories end in a separator so
+// another Tab goes on into them. Dot files are left out unless arg's last
+// element starts with a dot.
+func completePath(arg string, dirsOnly bool) []string {
+	dir, prefix := filepath.Split(arg)
+	readDir := dir
+	switch {
+	case dir == "":
+		readDir = "."
+	case strings.HasPrefix(dir, "~/"):
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		readDir = filepath.Join(home, dir[2:])
+	}
+
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+		isDir := entry.IsDir()
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(readDir, name)); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if isDir {
+			name += string(filepath.Separator)
+		} else if dirsOnly {
+			continue
+		}
+		matches = append(matches, dir+name)
+	}
+	return matches
+}
+
+// moveCommandCursor moves the command line cursor one character left or
+// right, or one WORD (a run of non-blanks) with word.
+func (s *appState) moveCommandCursor(forward, word bool) {
+	text, i := s.cmdText, s.cmdCursor
+	switch {
+	case forward && word:
+		for i < len(text) && text[i] != ' ' {
+			i++
+		}
+		for i < len(text) && text[i] == ' ' {
+			i++
+		}
+	case forward && i < len(text):
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	case word:
+		for i > 0 && text[i-1] == ' ' {
+			i--
+		}
+		for i > 0 && text[i-1] != ' ' {
+			i--
+		}
+	case i > 0:
+		_, size := utf8.DecodeLastRuneInString(text[:i])
+		i -= size
+	}
+	s.cmdCursor = i
+}
+
+// deleteCommandWord implements Ctrl+W in the command line: it deletes the
+// blanks before the cursor and then the word before them, either a run of
+// letters, digits and underscores or a run of other characters.
+func (s *appState) deleteCommandWord() {
+	text, end := s.cmdText, s.cmdCursor
+	i := end
+	for i > 0 && text[i-1] == ' ' {
+		i--
+	}
+	if i > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:i])
+		inWord := isKeywordRune(r)
+		for i > 0 {
+			r, size := utf8.DecodeLastRuneInString(text[:i])
+			if r == ' ' || isKeywordRune(r) != inWord {
+				break
+			}
+			i -= size
+		}
+	}
+	s.cmdText = text[:i] + text[end:]
+	s.cmdCursor = i
+}
+
+// isKeywordRune reports whether r belongs in a word for Ctrl+W.
+func isKeywordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// deleteCommandToStart implements Ctrl+U in the command line, which
+// deletes everything before the cursor.
+func (s *appState) deleteCommandToStart() {
+	s.cmdText = s.cmdText[s.cmdCursor:]
+	s.cmdCursor = 0
+}
+
+// deleteCommandCharForward deletes the character under the cursor.
+func (s *appState) deleteCommandCharForward() {
+	if s.cmdCursor >= len(s.cmdText) {
+		return
+	}
+	_, size := utf8.DecodeRuneInString(s.cmdText[s.cmdCursor:])
+	s.cmdText = s.cmdText[:s.cmdCursor] + s.cmdText[s.cmdCursor+size:]
+}
+
+// recallCommandHistory shows the previous (or with forward, the next)
+// command in the history that starts with what was typed before browsing
+// began. Going forward past the newest one brings back what was typed.
+func (s *appState) recallCommandHistory(forward bool) {
+	h := &s.cmdHistory
+	if s.cmdText != h.text {
+		h.index = len(h.entries)
+		h.prefix = s.cmdText
+	}
+
+	i := h.index
+	for {
+		if forward {
+			i++
+		} else {
+			i--
+		}
+		if i < 0 {
+			s.status = "Start of history"
+			return
+		}
+		if i >= len(h.entries) {
+			h.index = len(h.entries)
+			s.setCommandText(h.prefix)
+			h.text = s.cmdText
+			return
+		}
+		if strings.HasPrefix(h.entries[i], h.prefix) {
+			break
+		}
+	}
+	h.index = i
+	s.setCommandText(h.entries[i])
+	h.text = s.cmdText
+}
+
+// addCommandHistory puts cmd at the end of the history, dropping an
+// earlier copy, and saves the history.
+func (s *appState) addCommandHistory(cmd string) {
+	cmd = strings.TrimSpace(cmd)
+	if cmd == "" {
+		return
+	}
+	h := &s.cmdHistory
+	h.entries = slices.DeleteFunc(h.entries, func(e string) bool { return e == cmd })
+	h.entries = append(h.entries, cmd)
+	if len(h.entries) > maxCommandHistory {
+		h.entries = h.entries[len(h.entries)-maxCommandHistory:]
+	}
+
+	// History is a convenience; failing to save it mustn't stop the command
+	_ = saveCommandHistory(h.entries)
+}
+
+// commandHistoryPath returns the file the command history is kept in,
+// under $XDG_STATE_HOME (~/.local/state by default).
+func commandHistoryPath() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "vem", "cmd_history"), nil
+}
+
+// loadCommandHistory reads the saved command history, one command a line.
+// A missing or unreadable file is an empty history.
+func loadCommandHistory() cmdHistory {
+	path, err := commandHistoryPath()
+	if err != nil {
+		return cmdHistory{}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cmdHistory{}
+	}
+	var entries []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			entries = append(entries, line)
+		}
+	}
+	if len(entries) > maxCommandHistory {
+		entries = entries[len(entries)-maxCommandHistory:]
+	}
+	return cmdHistory{entries: entries}
+}
+
+// saveCommandHistory writes the command history.
+func saveCommandHistory(entries []string) error {
+	path, err := commandHistoryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(entries, "\n")+"\n"), 0o600)
+}
+
+// drawCommandPrompt draws the command line with a block cursor on the
+// character at the cursor.
+func (s *appState) drawCommandPrompt(gtx layout.Context) layout.Dimensions {
+	before, after := s.cmdText[:s.cmdCursor], s.cmdText[s.cmdCursor:]
+	under := " "
+	if after != "" {
+		_, size := utf8.DecodeRuneInString(after)
+		under, after = after[:size], after[size:]
+	}
+
+	text := func(str string, fg color.NRGBA) layout.Widget {
+		return func(gtx layout.Context) layout.Dimensions {
+			label := material.Body2(s.theme, str)
+			label.Font.Typeface = "JetBrainsMono"
+			label.Color = fg
+			label.MaxLines = 1
+			return label.Layout(gtx)
+		}
+	}
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+		layout.Rigid(text(":"+before, white)),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			macro := op.Record(gtx.Ops)
+			dims := text(under, statusBg)(gtx)
+			call := macro.Stop()
+			rect := clip.Rect{Max: dims.Size}.Push(gtx.Ops)
+			paint.Fill(gtx.Ops, white)
+			rect.Pop()
+			call.Add(gtx.Ops)
+			return dims
+		}),
+		layout.Rigid(text(after, white)),
+	)
+}
+
+// drawWildmenu draws the completion candidates in a popup whose bottom
+// edge is at the top of the command bar, the one put in highlighted. It
+// shows nothing unless there is more than one candidate and the command
+// line is as the last Tab left it.
+func (s *appState) drawWildmenu(gtx layout.Context) {
+	c := &s.cmdCompletion
+	if len(c.matches) < 2 || c.text != s.cmdText {
+		return
+	}
+	first := max(0, min(c.index-wildmenuRows/2, len(c.matches)-wildmenuRows))
+	last := min(first+wildmenuRows, len(c.matches))
+
+	gtx.Constraints.Min = image.Point{}
+	rows := make([]op.CallOp, 0, last-first)
+	width, rowHeight := 0, 0
+	for i := first; i < last; i++ {
+		label := material.Body2(s.theme, c.matches[i])
+		label.Font.Typeface = "JetBrainsMono"
+		label.MaxLines = 1
+		if i == c.index {
+			label.Color = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+		} else {
+			label.Color = color.NRGBA{R: 0xdf, G: 0xe7, B: 0xff, A: 0xff}
+		}
+		macro := op.Record(gtx.Ops)
+		dims := layout.Inset{
+			Top:    unit.Dp(2),
+			Bottom: unit.Dp(2),
+			Left:   unit.Dp(8),
+			Right:  unit.Dp(8),
+		}.Layout(gtx, label.Layout)
+		rows = append(rows, macro.Stop())
+		width = max(width, dims.Size.X)
+		rowHeight = max(rowHeight, dims.Size.Y)
+	}
+
+	height := rowHeight * len(rows)
+	defer op.Offset(image.Pt(0, -height)).Push(gtx.Ops).Pop()
+	bg := clip.Rect{Max: image.Pt(width, height)}.Push(gtx.Ops)
+	paint.Fill(gtx.Ops, color.NRGBA{R: 0x1a, G: 0x1f, B: 0x2e, A: 0xff})
+	bg.Pop()
+
+	for i, row := range rows {
+		top := i * rowHeight
+		if first+i == c.index {
+			sel := clip.Rect{Min: image.Pt(0, top), Max: image.Pt(width, top+rowHeight)}.Push(gtx.Ops)
+			paint.Fill(gtx.Ops, color.NRGBA{R: 0x2b, G: 0x50, B: 0x8a, A: 0xff})
+			sel.Pop()
+		}
+		offset := op.Offset(image.Pt(0, top)).Push(gtx.Ops)
+		row.Add(gtx.Ops)
+		offset.Pop()
+	}
+}
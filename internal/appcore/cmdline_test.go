@@ -0,0 +1,177 @@
+package appcore
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExCommandTable(t *testing.T) {
+	seen := map[string]string{}
+	var names []string
+	for _, cmd := range exCommandTable() {
+		names = append(names, cmd.name)
+		for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+			if other, ok := seen[name]; ok {
+				t.Errorf("%q names both %s and %s", name, other, cmd.name)
+			}
+			seen[name] = cmd.name
+		}
+	}
+	slices.Sort(names)
+	if !slices.Equal(exCommands, names) {
+		t.Errorf("exCommands got %v want %v", exCommands, names)
+	}
+
+	for name, want := range map[string]string{
+		"bufd": "bufdo", "wind": "windo", "ene": "enew", "q": "quit",
+		"bprev": "bprevious", "cla": "clast", "tls": "tsessions", "vert": "vertical",
+	} {
+		if cmd, ok := lookupExCommand(name); !ok || cmd.name != want {
+			t.Errorf("lookupExCommand(%q) got %q, %v want %q", name, cmd.name, ok, want)
+		}
+	}
+	if _, ok := lookupExCommand("nosuch"); ok {
+		t.Error("lookupExCommand found an unknown command")
+	}
+}
+
+func TestRunCommandBang(t *testing.T) {
+	s := &appState{}
+	s.runCommand("write!")
+	if s.status != "E477: No ! allowed: write!" {
+		t.Errorf("status got %q", s.status)
+	}
+	s.runCommand("nosuch")
+	if s.status != "Unknown command: nosuch" {
+		t.Errorf("status got %q", s.status)
+	}
+}
+
+func TestCompletionCandidates(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, name := range []string{"main.go", "make.txt"} {
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir("mod", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sep := string(filepath.Separator)
+
+	tests := []struct {
+		line     string
+		wantBase string
+		want     []string
+	}{
+		{"tabn", "", []string{"tabnew", "tabnext"}},
+		{":BN", ":", []string{"bnext"}},
+		{"xyz", "", nil},
+		{"e ma", "e ", []string{"main.go", "make.txt"}},
+		{"edit  m", "edit  ", []string{"main.go", "make.txt", "mod" + sep}},
+		{"cd m", "cd ", []string{"mod" + sep}},
+		{"set tabs", "set ", []string{"tabstop"}},
+		{"setl expandtab sh", "setl expandtab ", []string{"shiftwidth"}},
+		{"set tabstop=", "set ", nil},
+		{"bufd e mai", "bufd e ", []string{"main.go"}},
+		{"windo cfi", "windo ", []string{"cfirst"}},
+		{"pwd x", "pwd ", nil},
+	}
+	s := &appState{}
+	for _, tt := range tests {
+		base, got := s.completionCandidates(tt.line)
+		if base != tt.wantBase || !slices.Equal(got, tt.want) {
+			t.Errorf("completionCandidates(%q) got %q, %v want %q, %v", tt.line, base, got, tt.wantBase, tt.want)
+		}
+	}
+}
+
+func TestCompletePath(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, name := range []string{"a.txt", ".hidden", filepath.Join("dir", "inner.txt")} {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sep := string(filepath.Separator)
+
+	tests := []struct {
+		arg      string
+		dirsOnly bool
+		want     []string
+	}{
+		{"", false, []string{"a.txt", "dir" + sep}},
+		{"", true, []string{"dir" + sep}},
+		{".", false, []string{".hidden"}},
+		{"dir" + sep, false, []string{"dir" + sep + "inner.txt"}},
+		{"dir" + sep + "x", false, nil},
+		{"nosuch" + sep, false, nil},
+	}
+	for _, tt := range tests {
+		if got := completePath(tt.arg, tt.dirsOnly); !slices.Equal(got, tt.want) {
+			t.Errorf("completePath(%q, %v) got %v want %v", tt.arg, tt.dirsOnly, got, tt.want)
+		}
+	}
+}
+
+func TestRecallCommandHistory(t *testing.T) {
+	s := &appState{}
+	s.cmdHistory.entries = []string{"e one", "w", "e two"}
+	s.cmdHistory.restart()
+	s.setCommandText("e")
+
+	steps := []struct {
+		forward    bool
+		want       string
+		wantStatus string
+	}{
+		{false, "e two", ""},
+		{false, "e one", ""},
+		{false, "e one", "Start of history"},
+		{true, "e two", ""},
+		{true, "e", ""}, // Past the newest brings back what was typed
+	}
+	for i, step := range steps {
+		s.status = ""
+		s.recallCommandHistory(step.forward)
+		if s.cmdText != step.want || s.cmdCursor != len(step.want) || s.status != step.wantStatus {
+			t.Fatalf("step %d: got %q at %d, status %q want %q, status %q", i, s.cmdText, s.cmdCursor, s.status, step.want, step.wantStatus)
+		}
+	}
+
+	// Typing starts browsing over with the new prefix
+	s.setCommandText("w")
+	s.recallCommandHistory(false)
+	if s.cmdText != "w" || s.cmdHistory.index != 1 {
+		t.Fatalf("after typing got %q at entry %d want %q at 1", s.cmdText, s.cmdHistory.index, "w")
+	}
+}
+
+func TestDeleteCommandWord(t *testing.T) {
+	tests := []struct {
+		text       string
+		cursor     int
+		want       string
+		wantCursor int
+	}{
+		{"e foo", 5, "e ", 2},
+		{"e foo  ", 7, "e ", 2},
+		{"e foo/bar", 9, "e foo/", 6},
+		{"e foo//", 7, "e foo", 5},
+		{"e foo bar", 5, "e  bar", 2},
+		{"héllo", 6, "", 0},
+		{"e", 0, "e", 0},
+	}
+	for _, tt := range tests {
+		s := &appState{cmdText: tt.text, cmdCursor: tt.cursor}
+		s.deleteCommandWord()
+		if s.cmdText != tt.want || s.cmdCursor != tt.wantCursor {
+			t.Errorf("deleteCommandWord(%q at %d) got %q at %d want %q at %d", tt.text, tt.cursor, s.cmdText, s.cmdCursor, tt.want, tt.wantCursor)
+		}
+	}
+}
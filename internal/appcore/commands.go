@@ -0,0 +1,129 @@
+package appcore
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/javanhut/vem/internal/panes"
+)
+
+// exCommand is an Ex command runCommand runs.
+type exCommand struct {
+	name    string   // Full name, the one completion offers
+	aliases []string // Abbreviations and other names it also runs as
+	bang    bool     // It takes a ! after the name
+	run     func(s *appState, c exCall)
+}
+
+// exCall is a command as typed: bang tells whether its name ended in !,
+// args are its arguments joined by single spaces and raw the arguments as
+// typed.
+type exCall struct {
+	bang bool
+	args string
+	raw  string
+}
+
+// exCommandTable returns the commands runCommand runs and completion
+// offers. It is a function rather than a variable because :bufdo and
+// :windo run commands from it. :substitute with a pattern is run before
+// the table is looked at, see handleSubstituteCommand.
+func exCommandTable() []exCommand {
+	quickfix := func(name string) func(s *appState, c exCall) {
+		return func(s *appState, c exCall) { s.handleQuickfixCommand(name, c.args) }
+	}
+	session := func(name string) func(s *appState, c exCall) {
+		return func(s *appState, c exCall) { s.handleSessionCommand(name, c.args) }
+	}
+
+	return []exCommand{
+		{"quit", []string{"q"}, true, func(s *appState, c exCall) { s.handleQuitCommand(c.bang) }},
+		{"qall", []string{"qa"}, true, func(s *appState, c exCall) { s.handleQuitAll(c.bang) }},
+		{"write", []string{"w"}, false, func(s *appState, c exCall) { s.handleWriteCommand(c.args, false) }},
+		{"wq", nil, false, func(s *appState, c exCall) { s.handleWriteCommand(c.args, true) }},
+		{"update", []string{"up"}, false, func(s *appState, c exCall) { s.handleUpdateCommand() }},
+		{"edit", []string{"e"}, false, func(s *appState, c exCall) { s.handleEditCommand(c.args) }},
+		{"enew", []string{"ene"}, false, func(s *appState, c exCall) { s.handleNewBufferCommand(false) }},
+		{"scratch", nil, false, func(s *appState, c exCall) { s.handleNewBufferCommand(true) }},
+		{"buffer", []string{"b"}, false, func(s *appState, c exCall) { s.handleBufferCommand(c.args) }},
+		{"bnext", []string{"bn"}, false, func(s *appState, c exCall) { s.cycleBuffer(true) }},
+		{"bprevious", []string{"bp", "bprev"}, false, func(s *appState, c exCall) { s.cycleBuffer(false) }},
+		{"bdelete", []string{"bd"}, true, func(s *appState, c exCall) { s.handleBufferDeleteCommand(c.bang) }},
+		{"buffers", nil, true, func(s *appState, c exCall) { s.handleListBuffersCommand(c.bang) }},
+		{"ls", nil, true, func(s *appState, c exCall) { s.handleListBuffersCommand(c.bang) }},
+		{"bufdo", []string{"bufd"}, false, func(s *appState, c exCall) { s.handleBufdoCommand(c.raw) }},
+		{"windo", []string{"wind"}, false, func(s *appState, c exCall) { s.handleWindoCommand(c.raw) }},
+		{"explore", []string{"ex"}, false, func(s *appState, c exCall) { s.toggleExplorer() }},
+		{"cd", nil, false, func(s *appState, c exCall) { s.handleChangeDirectoryCommand(c.args) }},
+		{"pwd", nil, false, func(s *appState, c exCall) { s.handlePrintWorkingDirectoryCommand() }},
+		{"terminal", []string{"term"}, false, func(s *appState, c exCall) {
+			if c.args == "" {
+				s.handleOpenTerminal()
+			} else {
+				s.runTerminalCommand(c.raw, false)
+			}
+		}},
+		{"termsend", []string{"tsend"}, false, func(s *appState, c exCall) { s.handleTermSendCommand(c.args) }},
+		{"make", []string{"mak"}, false, func(s *appState, c exCall) { s.handleMakeCommand(c.raw) }},
+		{"compile", nil, false, func(s *appState, c exCall) { s.handleMakeCommand(c.raw) }},
+		{"help", []string{"h"}, false, func(s *appState, c exCall) { s.handleHelpCommand(c.args) }},
+		{"cnext", []string{"cn"}, false, quickfix("cnext")},
+		{"cprevious", []string{"cp", "cprev"}, false, quickfix("cprevious")},
+		{"cfirst", nil, false, quickfix("cfirst")},
+		{"crewind", []string{"cr"}, false, quickfix("crewind")},
+		{"clast", []string{"cla"}, false, quickfix("clast")},
+		{"cc", nil, false, quickfix("cc")},
+		{"clist", []string{"cl"}, false, quickfix("clist")},
+		{"copen", []string{"cope"}, false, quickfix("copen")},
+		{"tattach", []string{"tatt"}, false, session("tattach")},
+		{"tdetach", []string{"tdet"}, false, session("tdetach")},
+		{"tsessions", []string{"tls"}, false, session("tsessions")},
+		{"tkill", nil, false, session("tkill")},
+		{"set", []string{"se"}, false, func(s *appState, c exCall) { s.handleSetCommand(c.raw, false) }},
+		{"setlocal", []string{"setl"}, false, func(s *appState, c exCall) { s.handleSetCommand(c.raw, true) }},
+		{"tabnew", nil, false, func(s *appState, c exCall) { s.handleTabNewCommand(c.args) }},
+		{"tabedit", []string{"tabe"}, false, func(s *appState, c exCall) { s.handleTabNewCommand(c.args) }},
+		{"tabclose", []string{"tabc"}, false, func(s *appState, c exCall) { s.handleTabCloseCommand() }},
+		{"tabnext", []string{"tabn"}, false, func(s *appState, c exCall) { s.handleTabNextCommand(c.args) }},
+		{"tabprevious", []string{"tabp"}, false, func(s *appState, c exCall) { s.gotoTab(false) }},
+		{"tabmove", []string{"tabm"}, false, func(s *appState, c exCall) { s.handleTabMoveCommand(c.args) }},
+		{"resize", []string{"res"}, false, func(s *appState, c exCall) { s.handleResizeCommand(c.args, panes.SplitVertical) }},
+		{"vertical", []string{"vert"}, false, func(s *appState, c exCall) {
+			// Only :vertical resize is supported; it resizes the width instead
+			sub, rest, _ := strings.Cut(c.args, " ")
+			if sub = strings.ToLower(sub); sub == "res" || sub == "resize" {
+				s.handleResizeCommand(rest, panes.SplitHorizontal)
+			} else {
+				s.status = "Usage: :vertical resize [N]"
+			}
+		}},
+		{"substitute", []string{"s"}, false, func(s *appState, c exCall) {
+			s.status = "Usage: :[range]s/pattern/replacement/[flags]"
+		}},
+	}
+}
+
+// exCommands are the full names of the commands, for completing command
+// names.
+var exCommands = exCommandNames()
+
+// exCommandNames returns the full names in exCommandTable, sorted.
+func exCommandNames() []string {
+	var names []string
+	for _, cmd := range exCommandTable() {
+		names = append(names, cmd.name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// lookupExCommand returns the command called name, its full name or one of
+// its aliases.
+func lookupExCommand(name string) (exCommand, bool) {
+	for _, cmd := range exCommandTable() {
+		if cmd.name == name || slices.Contains(cmd.aliases, name) {
+			return cmd, true
+		}
+	}
+	return exCommand{}, false
+}
@@ -0,0 +1,140 @@
+package appcore
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultErrorformat matches "file:line:col: message" and "file:line:
+// message", the format of Go, GCC, Clang, Rust (with --error-format=short)
+// and most linters.
+const defaultErrorformat = `%f:%l:%c: %m,%f:%l: %m`
+
+// errorformatPattern is one compiled pattern of an errorformat.
+type errorformatPattern struct {
+	re     *regexp.Regexp
+	fields []byte // Conversion letter of each capture group
+}
+
+// compileErrorformat compiles a Vim-style errorformat: comma-separated
+// patterns ("\," is a literal comma) where %f is a file name, %l a line,
+// %c a column, %m the message, %t the error type (e.g. "w" for warning)
+// and %% a percent sign. Other characters match literally.
+func compileErrorformat(efm string) ([]errorformatPattern, error) {
+	var patterns []errorformatPattern
+	for _, part := range splitErrorformat(efm) {
+		if part == "" {
+			continue
+		}
+
+		var expr strings.Builder
+		var fields []byte
+		expr.WriteString("^")
+		for i := 0; i < len(part); i++ {
+			if part[i] != '%' {
+				expr.WriteString(regexp.QuoteMeta(part[i : i+1]))
+				continue
+			}
+			if i+1 == len(part) {
+				return nil, fmt.Errorf("errorformat %q ends with %%", part)
+			}
+			i++
+			switch conv := part[i]; conv {
+			case 'f':
+				expr.WriteString(`(.+?)`)
+				fields = append(fields, conv)
+			case 'l', 'c':
+				expr.WriteString(`(\d+)`)
+				fields = append(fields, conv)
+			case 'm':
+				expr.WriteString(`(.*)`)
+				fields = append(fields, conv)
+			case 't':
+				expr.WriteString(`(.)`)
+				fields = append(fields, conv)
+			case '%':
+				expr.WriteString("%")
+			default:
+				return nil, fmt.Errorf("errorformat %q: unsupported %%%c", part, conv)
+			}
+		}
+		expr.WriteString("$")
+
+		re, err := regexp.Compile(expr.String())
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, errorformatPattern{re: re, fields: fields})
+	}
+	return patterns, nil
+}
+
+// splitErrorformat splits an errorformat at commas not escaped as "\,".
+func splitErrorformat(efm string) []string {
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(efm); i++ {
+		switch {
+		case efm[i] == '\\' && i+1 < len(efm) && efm[i+1] == ',':
+			part.WriteByte(',')
+			i++
+		case efm[i] == ',':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(efm[i])
+		}
+	}
+	return append(parts, part.String())
+}
+
+// parseErrorformat returns a quickfix entry for every output line matched
+// by one of the patterns. Relative file names are resolved against dir.
+func parseErrorformat(output string, patterns []errorformatPattern, dir string) []QuickfixEntry {
+	var entries []QuickfixEntry
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, " \t")
+		for _, pattern := range patterns {
+			match := pattern.re.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+
+			var entry QuickfixEntry
+			kind := ""
+			for i, field := range pattern.fields {
+				value := match[i+1]
+				switch field {
+				case 'f':
+					entry.Path = value
+				case 'l':
+					entry.Line, _ = strconv.Atoi(value)
+				case 'c':
+					entry.Col, _ = strconv.Atoi(value)
+				case 'm':
+					entry.Text = strings.TrimSpace(value)
+				case 't':
+					kind = value
+				}
+			}
+			if entry.Path == "" {
+				continue
+			}
+			if !filepath.IsAbs(entry.Path) {
+				entry.Path = filepath.Join(dir, entry.Path)
+			}
+			switch strings.ToLower(kind) {
+			case "e":
+				entry.Text = "error: " + entry.Text
+			case "w":
+				entry.Text = "warning: " + entry.Text
+			}
+			entries = append(entries, entry)
+			break
+		}
+	}
+	return entries
+}
@@ -0,0 +1,119 @@
+package appcore
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitErrorformat(t *testing.T) {
+	tests := []struct {
+		efm  string
+		want []string
+	}{
+		{`%f:%l: %m`, []string{"%f:%l: %m"}},
+		{`%f:%l:%c: %m,%f:%l: %m`, []string{"%f:%l:%c: %m", "%f:%l: %m"}},
+		{`%f(%l\,%c): %m`, []string{"%f(%l,%c): %m"}},
+		{`a\,b,,c`, []string{"a,b", "", "c"}},
+		{`%m\`, []string{`%m\`}},
+	}
+	for _, tt := range tests {
+		if got := splitErrorformat(tt.efm); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitErrorformat(%q) got %q want %q", tt.efm, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrorformat(t *testing.T) {
+	tests := []struct {
+		efm     string
+		want    int // Patterns compiled
+		wantErr bool
+	}{
+		{defaultErrorformat, 2, false},
+		{`%f:%l:%c: %t%*[^:]: %m`, 0, true},
+		{`%f:%l: %m%`, 0, true},
+		{`%f:%l: %m,,`, 1, false},
+		{`100%% %f`, 1, false},
+		{``, 0, false},
+	}
+	for _, tt := range tests {
+		patterns, err := compileErrorformat(tt.efm)
+		if (err != nil) != tt.wantErr || len(patterns) != tt.want {
+			t.Errorf("compileErrorformat(%q) got %d patterns, error %v; want %d, error %v", tt.efm, len(patterns), err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseErrorformat(t *testing.T) {
+	dir := t.TempDir()
+	abs := filepath.Join(dir, "abs.go")
+	tests := []struct {
+		name   string
+		efm    string
+		output string
+		want   []QuickfixEntry
+	}{
+		{
+			"default format with and without a column",
+			defaultErrorformat,
+			"main.go:12:5: undefined: x\npkg/a.go:3: missing return\n",
+			[]QuickfixEntry{
+				{Path: filepath.Join(dir, "main.go"), Line: 12, Col: 5, Text: "undefined: x"},
+				{Path: filepath.Join(dir, "pkg", "a.go"), Line: 3, Text: "missing return"},
+			},
+		},
+		{
+			"absolute paths are kept",
+			defaultErrorformat,
+			abs + ":1:2: boom",
+			[]QuickfixEntry{{Path: abs, Line: 1, Col: 2, Text: "boom"}},
+		},
+		{
+			"lines that don't match are skipped",
+			defaultErrorformat,
+			"# example.com/pkg\nok  \texample.com/pkg\t0.1s\nFAIL\nmain.go:x: not a line\n",
+			nil,
+		},
+		{
+			"escaped comma",
+			`%f(%l\,%c): %m`,
+			"a.cs(10,4): error CS1002",
+			[]QuickfixEntry{{Path: filepath.Join(dir, "a.cs"), Line: 10, Col: 4, Text: "error CS1002"}},
+		},
+		{
+			"error types",
+			`%f:%l: %t: %m`,
+			"a.c:1: w: unused\na.c:2: E: fatal\na.c:3: n: note",
+			[]QuickfixEntry{
+				{Path: filepath.Join(dir, "a.c"), Line: 1, Text: "warning: unused"},
+				{Path: filepath.Join(dir, "a.c"), Line: 2, Text: "error: fatal"},
+				{Path: filepath.Join(dir, "a.c"), Line: 3, Text: "note"},
+			},
+		},
+		{
+			"first matching pattern wins and trailing blanks are dropped",
+			`%f:%l:%c: %m,%f: %m`,
+			"b.go:7:1: first  \nb.go: whole file",
+			[]QuickfixEntry{
+				{Path: filepath.Join(dir, "b.go"), Line: 7, Col: 1, Text: "first"},
+				{Path: filepath.Join(dir, "b.go"), Text: "whole file"},
+			},
+		},
+		{
+			"no file name",
+			`%l: %m`,
+			"3: message",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		patterns, err := compileErrorformat(tt.efm)
+		if err != nil {
+			t.Fatalf("%s: compileErrorformat: %v", tt.name, err)
+		}
+		if got := parseErrorformat(tt.output, patterns, dir); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package appcore
 
 import (
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"unicode"
 )
 
@@ -90,27 +93,61 @@ func FuzzyScore(pattern, target string) (int, []int) {
 	return score, indices
 }
 
+// parallelFuzzyThreshold is the item count above which scoring is split
+// across CPUs.
+const parallelFuzzyThreshold = 4096
+
 // PerformFuzzyMatch performs fuzzy matching on a list of items and returns sorted matches.
 // Items are sorted by score (highest first).
 func PerformFuzzyMatch(pattern string, items []string, maxResults int) []FuzzyMatch {
+	return topFuzzyMatches(pattern, FilterFuzzyMatches(pattern, items), maxResults)
+}
+
+// FilterFuzzyMatches scores every item against pattern and returns all
+// matches in item order. Large inputs are scored in parallel.
+func FilterFuzzyMatches(pattern string, items []string) []FuzzyMatch {
 	if pattern == "" {
-		// Return all items when no pattern
-		var matches []FuzzyMatch
-		for _, item := range items {
-			if len(matches) >= maxResults {
-				break
-			}
-			matches = append(matches, FuzzyMatch{
-				FilePath: item,
-				Score:    0,
-				Indices:  nil,
-			})
+		// Everything matches an empty pattern
+		matches := make([]FuzzyMatch, len(items))
+		for i, item := range items {
+			matches[i] = FuzzyMatch{FilePath: item}
 		}
 		return matches
 	}
 
+	workers := runtime.GOMAXPROCS(0)
+	if len(items) < parallelFuzzyThreshold || workers < 2 {
+		return scoreFuzzyChunk(pattern, items)
+	}
+
+	chunkSize := (len(items) + workers - 1) / workers
+	results := make([][]FuzzyMatch, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start := w * chunkSize
+		if start >= len(items) {
+			break
+		}
+		end := min(start+chunkSize, len(items))
+
+		wg.Add(1)
+		go func(w int, chunk []string) {
+			defer wg.Done()
+			results[w] = scoreFuzzyChunk(pattern, chunk)
+		}(w, items[start:end])
+	}
+	wg.Wait()
+
 	var matches []FuzzyMatch
+	for _, chunk := range results {
+		matches = append(matches, chunk...)
+	}
+	return matches
+}
 
+// scoreFuzzyChunk scores items sequentially.
+func scoreFuzzyChunk(pattern string, items []string) []FuzzyMatch {
+	var matches []FuzzyMatch
 	for _, item := range items {
 		score, indices := FuzzyScore(pattern, item)
 		if score > 0 {
@@ -121,22 +158,35 @@ func PerformFuzzyMatch(pattern string, items []string, maxResults int) []FuzzyMa
 			})
 		}
 	}
+	return matches
+}
 
-	// Sort by score (descending)
-	for i := 0; i < len(matches); i++ {
-		for j := i + 1; j < len(matches); j++ {
-			if matches[j].Score > matches[i].Score {
-				matches[i], matches[j] = matches[j], matches[i]
-			}
+// topFuzzyMatches returns the best maxResults matches, highest score first.
+// Ties keep their input order. With an empty pattern the input order is kept.
+func topFuzzyMatches(pattern string, matches []FuzzyMatch, maxResults int) []FuzzyMatch {
+	if maxResults <= 0 {
+		return nil
+	}
+	if pattern == "" || len(matches) <= 1 {
+		if len(matches) > maxResults {
+			matches = matches[:maxResults]
 		}
+		return append([]FuzzyMatch(nil), matches...)
 	}
 
-	// Limit results
-	if len(matches) > maxResults {
-		matches = matches[:maxResults]
+	top := make([]FuzzyMatch, 0, maxResults)
+	for _, m := range matches {
+		if len(top) == maxResults && m.Score <= top[len(top)-1].Score {
+			continue
+		}
+		i := sort.Search(len(top), func(i int) bool { return top[i].Score < m.Score })
+		if len(top) < maxResults {
+			top = append(top, FuzzyMatch{})
+		}
+		copy(top[i+1:], top[i:len(top)-1])
+		top[i] = m
 	}
-
-	return matches
+	return top
 }
 
 // isWordBoundary checks if a character is a word boundary
@@ -0,0 +1,316 @@
+package appcore
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/javanhut/vem/internal/filesystem"
+	"github.com/javanhut/vem/internal/syntax"
+)
+
+const (
+	previewMaxBytes     = 1 << 20 // Larger files show a placeholder instead of their content
+	previewSniffBytes   = 8000    // Bytes inspected to detect binary files
+	previewContextLines = 5       // Lines shown above the matched line
+)
+
+var (
+	previewBorderColor = color.NRGBA{R: 0x3a, G: 0x44, B: 0x5c, A: 0xff}
+	previewLineColor   = color.NRGBA{R: 0x2b, G: 0x50, B: 0x8a, A: 0x66}
+	previewNoteColor   = color.NRGBA{R: 0x88, G: 0x88, B: 0x88, A: 0xff}
+)
+
+// fuzzyLineSuffix matches a trailing ":<line>" in the fuzzy finder input.
+var fuzzyLineSuffix = regexp.MustCompile(`^(.*):(\d+)$`)
+
+// fuzzyPreview holds the contents of the file shown next to the results.
+type fuzzyPreview struct {
+	path        string
+	line        int // 1-based line to scroll to, 0 for the top
+	lines       []string
+	message     string // Placeholder shown instead of the contents
+	highlighter *syntax.Highlighter
+}
+
+// fuzzyPreviewKey is the file and line a preview is for.
+type fuzzyPreviewKey struct {
+	path string
+	line int
+}
+
+// key returns what the preview was loaded for.
+func (p *fuzzyPreview) key() fuzzyPreviewKey {
+	return fuzzyPreviewKey{path: p.path, line: p.line}
+}
+
+// fuzzyPreviewLoader loads previews off the UI goroutine. The preview last
+// asked for waits in ready until a frame picks it up; previews that were
+// asked for earlier are dropped when they finish.
+type fuzzyPreviewLoader struct {
+	mu    sync.Mutex
+	want  fuzzyPreviewKey
+	ready *fuzzyPreview
+}
+
+// load starts loading the preview for key unless it was the last one asked
+// for, calling done once it is ready.
+func (l *fuzzyPreviewLoader) load(key fuzzyPreviewKey, syntaxEnabled bool, done func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.want == key {
+		return
+	}
+	l.want, l.ready = key, nil
+	go func() {
+		preview := loadFuzzyPreview(key.path, key.line, syntaxEnabled)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.want != key {
+			return
+		}
+		l.ready = preview
+		done()
+	}()
+}
+
+// take returns the preview loaded since the last call, or nil.
+func (l *fuzzyPreviewLoader) take() *fuzzyPreview {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ready := l.ready
+	l.ready = nil
+	return ready
+}
+
+// reset forgets what was asked for, so it is loaded again next time.
+func (l *fuzzyPreviewLoader) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.want, l.ready = fuzzyPreviewKey{}, nil
+}
+
+// splitFuzzyQueryLine splits "pattern:123" into the pattern and the line
+// number. Inputs without a line suffix return line 0.
+func splitFuzzyQueryLine(input string) (string, int) {
+	m := fuzzyLineSuffix.FindStringSubmatch(input)
+	if m == nil {
+		return input, 0
+	}
+	line, err := strconv.Atoi(m[2])
+	if err != nil {
+		return input, 0
+	}
+	return m[1], line
+}
+
+// loadFuzzyPreview reads a file for previewing. Binary, huge and unreadable
+// files get a placeholder message instead of contents.
+func loadFuzzyPreview(path string, line int, syntaxEnabled bool) *fuzzyPreview {
+	preview := &fuzzyPreview{path: path, line: line}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		preview.message = fmt.Sprintf("Cannot preview: %v", err)
+		return preview
+	}
+	if info.IsDir() {
+		preview.message = "Directory"
+		return preview
+	}
+	if info.Size() > previewMaxBytes {
+		preview.message = fmt.Sprintf("File too large to preview (%s)", formatFileSize(info.Size()))
+		return preview
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		preview.message = fmt.Sprintf("Cannot preview: %v", err)
+		return preview
+	}
+	if !filesystem.IsTextFile(path) && looksBinary(data) {
+		preview.message = fmt.Sprintf("Binary file (%s)", formatFileSize(info.Size()))
+		return preview
+	}
+
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	preview.lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	if syntaxEnabled && syntax.ShouldHighlight(path) {
+		preview.highlighter = syntax.NewHighlighter(path)
+	} else {
+		preview.highlighter = syntax.NewPlainHighlighter()
+	}
+	return preview
+}
+
+// looksBinary reports whether data looks like a binary file: it contains a
+// NUL byte or is not valid UTF-8 near the start.
+func looksBinary(data []byte) bool {
+	sniff := data
+	if len(sniff) > previewSniffBytes {
+		sniff = sniff[:previewSniffBytes]
+	}
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+	if utf8.Valid(sniff) {
+		return false
+	}
+	// The sniffed prefix may end in the middle of a rune
+	if len(data) > previewSniffBytes {
+		for i := 1; i < utf8.UTFMax; i++ {
+			if utf8.Valid(sniff[:len(sniff)-i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// formatFileSize formats a byte count for display.
+func formatFileSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// selectedFuzzyPreview returns the preview for the selected match. When
+// the selection changed the new preview is loaded in the background, and
+// the previous one is shown until it is ready.
+func (s *appState) selectedFuzzyPreview() *fuzzyPreview {
+	if s.fuzzyFinderSelectedIdx < 0 || s.fuzzyFinderSelectedIdx >= len(s.fuzzyFinderMatches) || s.fileTree == nil {
+		return nil
+	}
+
+	match := s.fuzzyFinderMatches[s.fuzzyFinderSelectedIdx]
+	key := fuzzyPreviewKey{path: filepath.Join(s.fileTree.CurrentPath(), match.FilePath), line: match.Line}
+	if ready := s.fuzzyPreviewLoader.take(); ready != nil && ready.key() == key {
+		s.fuzzyPreview = ready
+		top := 0
+		if key.line > 0 {
+			top = max(key.line-1-previewContextLines, 0)
+		}
+		s.fuzzyPreviewList.Position = layout.Position{First: top}
+	}
+	if s.fuzzyPreview != nil && s.fuzzyPreview.key() == key {
+		return s.fuzzyPreview
+	}
+
+	s.fuzzyPreviewLoader.load(key, s.syntaxEnabled, func() {
+		// Picked up on the UI goroutine at the next frame
+		if s.window != nil {
+			s.window.Invalidate()
+		}
+	})
+	if s.fuzzyPreview == nil {
+		return &fuzzyPreview{path: key.path, line: key.line, message: "Loading preview"}
+	}
+	return s.fuzzyPreview
+}
+
+// toggleFuzzyPreview shows or hides the fuzzy finder preview pane.
+func (s *appState) toggleFuzzyPreview() {
+	s.fuzzyPreviewEnabled = !s.fuzzyPreviewEnabled
+	if s.fuzzyPreviewEnabled {
+		s.status = "Fuzzy finder preview on"
+	} else {
+		s.status = "Fuzzy finder preview off"
+	}
+}
+
+// drawFuzzyPreview draws the selected file next to the fuzzy finder results.
+func (s *appState) drawFuzzyPreview(gtx layout.Context) layout.Dimensions {
+	size := gtx.Constraints.Max
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+
+	// Left border separating the preview from the results
+	border := clip.Rect{Max: image.Pt(gtx.Dp(unit.Dp(1)), size.Y)}.Push(gtx.Ops)
+	paint.Fill(gtx.Ops, previewBorderColor)
+	border.Pop()
+
+	inset := layout.Inset{Left: unit.Dp(8)}
+	inset.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		preview := s.selectedFuzzyPreview()
+		if preview == nil {
+			return layout.Dimensions{}
+		}
+		if preview.message != "" {
+			label := material.Body2(s.theme, preview.message)
+			label.Font.Typeface = "JetBrainsMono"
+			label.Color = previewNoteColor
+			return label.Layout(gtx)
+		}
+
+		return s.fuzzyPreviewList.Layout(gtx, len(preview.lines), func(gtx layout.Context, index int) layout.Dimensions {
+			return s.drawFuzzyPreviewLine(gtx, preview, index)
+		})
+	})
+
+	return layout.Dimensions{Size: size}
+}
+
+// drawFuzzyPreviewLine draws one highlighted preview line with its number.
+func (s *appState) drawFuzzyPreviewLine(gtx layout.Context, preview *fuzzyPreview, index int) layout.Dimensions {
+	gutter := fmt.Sprintf("%4d  ", index+1)
+	tokens := preview.highlighter.HighlightLine(index, preview.lines[index])
+
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			label := material.Body2(s.theme, gutter)
+			label.Font.Typeface = "JetBrainsMono"
+			label.Color = previewNoteColor
+			label.MaxLines = 1
+			return label.Layout(gtx)
+		}),
+	}
+	for _, token := range tokens {
+		t := token
+		tokenText := expandTabs(t.Text, 4)
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			label := material.Body2(s.theme, tokenText)
+			label.Font.Typeface = "JetBrainsMono"
+			label.Color = syntax.GetTokenColor(t.Type, t.Style)
+			label.MaxLines = 1
+			return label.Layout(gtx)
+		}))
+	}
+
+	if index+1 != preview.line {
+		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, children...)
+	}
+
+	// Highlight the matched line behind its text
+	return layout.Stack{}.Layout(gtx,
+		layout.Expanded(func(gtx layout.Context) layout.Dimensions {
+			rect := clip.Rect{Max: image.Pt(gtx.Constraints.Max.X, gtx.Constraints.Min.Y)}.Push(gtx.Ops)
+			paint.Fill(gtx.Ops, previewLineColor)
+			rect.Pop()
+			return layout.Dimensions{Size: gtx.Constraints.Min}
+		}),
+		layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, children...)
+		}),
+	)
+}
@@ -0,0 +1,181 @@
+package appcore
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// fuzzyOpenTarget says where the fuzzy finder opens the chosen files.
+type fuzzyOpenTarget int
+
+const (
+	fuzzyOpenCurrent fuzzyOpenTarget = iota // Replace the active pane's buffer
+	fuzzyOpenVSplit                         // New pane to the right (left|right)
+	fuzzyOpenHSplit                         // New pane below (top/bottom)
+	fuzzyOpenTab                            // New tab page
+)
+
+// fuzzyFinderIsMarked reports whether a result path is marked.
+func (s *appState) fuzzyFinderIsMarked(path string) bool {
+	for _, m := range s.fuzzyFinderMarked {
+		if m.FilePath == path {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyFinderToggleMark marks or unmarks the selected result and moves the
+// selection down, so repeated Tabs mark consecutive results.
+func (s *appState) fuzzyFinderToggleMark() {
+	if s.fuzzyFinderSelectedIdx < 0 || s.fuzzyFinderSelectedIdx >= len(s.fuzzyFinderMatches) {
+		return
+	}
+
+	match := s.fuzzyFinderMatches[s.fuzzyFinderSelectedIdx]
+	unmarked := false
+	for i, m := range s.fuzzyFinderMarked {
+		if m.FilePath == match.FilePath {
+			s.fuzzyFinderMarked = append(s.fuzzyFinderMarked[:i], s.fuzzyFinderMarked[i+1:]...)
+			unmarked = true
+			break
+		}
+	}
+	if !unmarked {
+		s.fuzzyFinderMarked = append(s.fuzzyFinderMarked, match)
+	}
+
+	s.fuzzyFinderMoveDown()
+	s.status = fmt.Sprintf("Fuzzy Finder: %d marked", len(s.fuzzyFinderMarked))
+}
+
+// fuzzyFinderTargets returns the marked results in marking order, or the
+// selected result when nothing is marked.
+func (s *appState) fuzzyFinderTargets() []FuzzyMatch {
+	if len(s.fuzzyFinderMarked) > 0 {
+		return append([]FuzzyMatch(nil), s.fuzzyFinderMarked...)
+	}
+	if s.fuzzyFinderSelectedIdx < 0 || s.fuzzyFinderSelectedIdx >= len(s.fuzzyFinderMatches) {
+		return nil
+	}
+	return []FuzzyMatch{s.fuzzyFinderMatches[s.fuzzyFinderSelectedIdx]}
+}
+
+// fuzzyFinderOpen opens the marked results (or the selected one) and closes
+// the finder. With splits, every file gets its own pane.
+func (s *appState) fuzzyFinderOpen(target fuzzyOpenTarget) {
+	targets := s.fuzzyFinderTargets()
+	if len(targets) == 0 || s.fileTree == nil {
+		s.exitFuzzyFinder()
+		return
+	}
+	root := s.fileTree.CurrentPath()
+	s.exitFuzzyFinder()
+
+	opened := 0
+	first := -1
+	for _, match := range targets {
+		if _, err := s.bufferMgr.OpenFile(filepath.Join(root, match.FilePath)); err != nil {
+			s.status = fmt.Sprintf("Error opening %s: %v", match.FilePath, err)
+			return
+		}
+		bufID := s.bufferMgr.ActiveID()
+
+		switch target {
+		case fuzzyOpenVSplit, fuzzyOpenHSplit:
+			if s.paneManager == nil {
+				s.status = "Pane manager not initialized"
+				return
+			}
+			var err error
+			if target == fuzzyOpenVSplit {
+				err = s.paneManager.SplitHorizontal(bufID)
+			} else {
+				err = s.paneManager.SplitVertical(bufID)
+			}
+			if err != nil {
+				s.status = fmt.Sprintf("Split failed: %v", err)
+				return
+			}
+			s.syncPaneView()
+			if match.Line > 0 {
+				s.gotoLine(match.Line)
+			}
+		case fuzzyOpenTab:
+			s.tabs.New(bufID)
+			s.syncActiveTab()
+			if match.Line > 0 {
+				s.gotoLine(match.Line)
+			}
+		default:
+			if first < 0 {
+				first = bufID
+				s.showBufferInActivePane(bufID)
+				if match.Line > 0 {
+					s.gotoLine(match.Line)
+				}
+			}
+		}
+		opened++
+	}
+
+	if first >= 0 {
+		// Leave the buffer shown in the pane as the active one
+		s.bufferMgr.SwitchToBuffer(first)
+	}
+
+	switch {
+	case opened == 1 && target == fuzzyOpenCurrent:
+		s.status = fmt.Sprintf("Opened %s", targets[0].FilePath)
+	case target == fuzzyOpenVSplit:
+		s.status = fmt.Sprintf("Opened %d file(s) in vertical splits - %d panes total", opened, s.paneManager.PaneCount())
+	case target == fuzzyOpenHSplit:
+		s.status = fmt.Sprintf("Opened %d file(s) in horizontal splits - %d panes total", opened, s.paneManager.PaneCount())
+	case target == fuzzyOpenTab:
+		s.status = fmt.Sprintf("Opened %d file(s) in tab pages - %d tabs total", opened, s.tabs.Count())
+	default:
+		s.status = fmt.Sprintf("Opened %d file(s) as buffers, showing %s", opened, targets[0].FilePath)
+	}
+}
+
+// fuzzyFinderSendToQuickfix replaces the quickfix list with the marked
+// results, or every listed result when nothing is marked, and jumps to the
+// first entry.
+func (s *appState) fuzzyFinderSendToQuickfix() {
+	matches := s.fuzzyFinderMarked
+	if len(matches) == 0 {
+		matches = s.fuzzyFinderMatches
+	}
+	if len(matches) == 0 || s.fileTree == nil {
+		s.exitFuzzyFinder()
+		return
+	}
+
+	root := s.fileTree.CurrentPath()
+	entries := make([]QuickfixEntry, len(matches))
+	for i, match := range matches {
+		entries[i] = QuickfixEntry{
+			Path: filepath.Join(root, match.FilePath),
+			Line: match.Line,
+			Text: match.FilePath,
+		}
+	}
+
+	s.exitFuzzyFinder()
+	s.setQuickfixList(entries)
+	s.quickfixJump(0)
+}
+
+// showBufferInActivePane displays a buffer in the active pane. The buffer
+// the pane showed before becomes the alternate buffer.
+func (s *appState) showBufferInActivePane(bufID int) {
+	if s.paneManager == nil {
+		return
+	}
+	if activePane := s.paneManager.ActivePane(); activePane != nil {
+		if activePane.BufferID != bufID {
+			s.bufferMgr.SetAlternate(activePane.BufferID)
+		}
+		activePane.SetBufferID(bufID)
+	}
+}
@@ -0,0 +1,81 @@
+package appcore
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPerformFuzzyMatch(t *testing.T) {
+	items := []string{"src/main.go", "cmd/main.go", "main.go", "docs/readme.md", "xmxaxixnx"}
+	tests := []struct {
+		name       string
+		pattern    string
+		maxResults int
+		want       []string
+	}{
+		{"shorter paths first, ties in input order", "main", 10, []string{"main.go", "src/main.go", "cmd/main.go", "xmxaxixnx"}},
+		{"truncated", "main", 2, []string{"main.go", "src/main.go"}},
+		{"one result", "main", 1, []string{"main.go"}},
+		{"no results wanted", "main", 0, nil},
+		{"negative maxResults", "main", -1, nil},
+		{"empty pattern keeps input order", "", 3, []string{"src/main.go", "cmd/main.go", "main.go"}},
+		{"empty pattern with no results wanted", "", 0, nil},
+		{"no match", "zzz", 10, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, m := range PerformFuzzyMatch(tt.pattern, items, tt.maxResults) {
+			got = append(got, m.FilePath)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTopFuzzyMatches(t *testing.T) {
+	matches := []FuzzyMatch{
+		{FilePath: "a", Score: 5},
+		{FilePath: "b", Score: 9},
+		{FilePath: "c", Score: 5},
+		{FilePath: "d", Score: 9},
+		{FilePath: "e", Score: 1},
+		{FilePath: "f", Score: 7},
+	}
+	tests := []struct {
+		maxResults int
+		want       []string
+	}{
+		{0, nil},
+		{1, []string{"b"}},
+		{2, []string{"b", "d"}},
+		{4, []string{"b", "d", "f", "a"}},
+		{10, []string{"b", "d", "f", "a", "c", "e"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, m := range topFuzzyMatches("x", matches, tt.maxResults) {
+			got = append(got, m.FilePath)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("maxResults %d: got %v want %v", tt.maxResults, got, tt.want)
+		}
+	}
+}
+
+func TestFilterFuzzyMatchesParallel(t *testing.T) {
+	items := make([]string, 3*parallelFuzzyThreshold+17)
+	for i := range items {
+		items[i] = fmt.Sprintf("pkg%d/file_%d.go", i%97, i)
+	}
+	for _, pattern := range []string{"f1", "pkg9/file", "go", "nomatch"} {
+		want := scoreFuzzyChunk(pattern, items)
+		if got := FilterFuzzyMatches(pattern, items); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: parallel scoring gave %d matches, serial %d, or a different order", pattern, len(got), len(want))
+		}
+		if got, want := PerformFuzzyMatch(pattern, items, 50), topFuzzyMatches(pattern, want, 50); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: parallel top matches differ from serial", pattern)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package appcore
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"gioui.org/io/key"
@@ -50,6 +51,18 @@ func generateHelpText() string {
 	appendModeKeybindings(&sb, modeTerminal)
 	sb.WriteString("\n")
 
+	sb.WriteString("TERMINAL SCROLLBACK (NORMAL mode in a terminal)\n")
+	sb.WriteString("───────────────────────────────────────────────────────────\n")
+	for _, binding := range terminalHistoryKeybindings {
+		sb.WriteString(fmt.Sprintf("  %-20s %s\n", formatKeybinding(binding), actionDescription(binding.Action)))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("COMMAND LINE\n")
+	sb.WriteString("───────────────────────────────────────────────────────────\n")
+	appendCommandLineKeys(&sb)
+	sb.WriteString("\n")
+
 	sb.WriteString("COMMANDS\n")
 	sb.WriteString("───────────────────────────────────────────────────────────\n")
 	appendCommands(&sb)
@@ -105,32 +118,138 @@ func appendModeKeybindings(sb *strings.Builder, mode mode) {
 	}
 }
 
-// appendCommands adds command help
-func appendCommands(sb *strings.Builder) {
-	commands := []struct {
-		cmd  string
+// helpSections are the :help topics that go to a section of the help,
+// with the section's heading.
+var helpSections = []struct {
+	topic   string
+	heading string
+}{
+	{"global", "GLOBAL KEYBINDINGS"},
+	{"normal", "NORMAL MODE"},
+	{"insert", "INSERT MODE"},
+	{"visual", "VISUAL MODE"},
+	{"explorer", "EXPLORER MODE"},
+	{"terminal", "TERMINAL MODE"},
+	{"scrollback", "TERMINAL SCROLLBACK"},
+	{"cmdline", "COMMAND LINE"},
+	{"commands", "COMMANDS"},
+	{"sequences", "SPECIAL SEQUENCES"},
+}
+
+// helpTopics returns what :help can be given: the sections and the
+// commands, such as :bufdo.
+func helpTopics() []string {
+	var topics []string
+	for _, section := range helpSections {
+		topics = append(topics, section.topic)
+	}
+	for _, c := range helpCommands {
+		if cmd := strings.Fields(c.cmd)[0]; !slices.Contains(topics, cmd) {
+			topics = append(topics, cmd)
+		}
+	}
+	return topics
+}
+
+// helpTopicLine returns the line of the help text topic is about: the
+// heading of a section, the line listing a command or else the first line
+// that mentions topic. It returns -1 if there is none.
+func helpTopicLine(lines []string, topic string) int {
+	for _, section := range helpSections {
+		if section.topic == topic {
+			topic = section.heading
+			break
+		}
+	}
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) > 0 && (fields[0] == topic || strings.HasPrefix(line, topic)) {
+			return i
+		}
+	}
+	lower := strings.ToLower(topic)
+	for i, line := range lines {
+		if strings.Contains(strings.ToLower(line), lower) {
+			return i
+		}
+	}
+	return -1
+}
+
+// appendCommandLineKeys adds the keys that edit the command line
+func appendCommandLineKeys(sb *strings.Builder) {
+	keys := []struct {
+		keys string
 		desc string
 	}{
-		{":q", "Close current pane/buffer"},
-		{":q!", "Force close (discard changes)"},
-		{":qa", "Quit entire application"},
-		{":qa!", "Force quit (discard all changes)"},
-		{":w", "Save current buffer"},
-		{":w <file>", "Save as <file>"},
-		{":wq", "Save and close"},
-		{":e <file>", "Open file for editing"},
-		{":bn", "Next buffer"},
-		{":bp", "Previous buffer"},
-		{":bd", "Delete buffer"},
-		{":ls", "List all buffers"},
-		{":ex", "Toggle file explorer"},
-		{":cd <path>", "Change working directory"},
-		{":pwd", "Print working directory"},
-		{":term", "Open embedded terminal"},
-		{":help", "Show this help"},
+		{"Tab / Shift+Tab", "Next/previous completion"},
+		{"Up / Down", "Older/newer command starting with the typed text"},
+		{"Left / Right", "Move cursor"},
+		{"Shift/Ctrl+Left/Right", "Move one word"},
+		{"Home / Ctrl+B", "Go to start of line"},
+		{"End / Ctrl+E", "Go to end of line"},
+		{"Delete", "Delete character under cursor"},
+		{"Ctrl+W", "Delete word before cursor"},
+		{"Ctrl+U", "Delete to start of line"},
 	}
 
-	for _, c := range commands {
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("  %-20s %s\n", k.keys, k.desc))
+	}
+}
+
+// helpCommands are the commands the help lists, with what they do.
+var helpCommands = []struct {
+	cmd  string
+	desc string
+}{
+	{":q", "Close current pane/buffer"},
+	{":q!", "Force close (discard changes)"},
+	{":qa", "Quit entire application"},
+	{":qa!", "Force quit (discard all changes)"},
+	{":w", "Save current buffer"},
+	{":w <file>", "Save as <file>"},
+	{":wq", "Save and close"},
+	{":e <file>", "Open file for editing"},
+	{":e #", "Edit the alternate buffer (Ctrl+^)"},
+	{":up", "Save only if there are unsaved changes"},
+	{":b N / :b name", "Go to buffer N or by name (Tab completes)"},
+	{":bn", "Next buffer"},
+	{":bp", "Previous buffer"},
+	{":bd", "Delete buffer"},
+	{":ls", "List listed buffers (:ls! shows all)"},
+	{":enew", "Edit a new unnamed buffer"},
+	{":scratch", "Edit an unlisted buffer that is never saved"},
+	{":bufdo <cmd>", "Run <cmd> (| separated) in every buffer"},
+	{":windo <cmd>", "Run <cmd> (| separated) in every pane"},
+	{":[range]s/a/b/[g]", "Substitute a with b (range: %, N,M, ., $)"},
+	{":ex", "Toggle file explorer"},
+	{":cd <path>", "Change working directory"},
+	{":pwd", "Print working directory"},
+	{":term", "Open embedded terminal"},
+	{":term <cmd>", "Run command in a terminal buffer"},
+	{":make [args]", "Run makeprg, fill quickfix list"},
+	{":TermSend [n]", "Send line/selection to a terminal"},
+	{":tabnew [file]", "Open a tab page"},
+	{":tabclose", "Close the tab page"},
+	{":tabmove [n]", "Move the tab page after tab n"},
+	{":resize [n]", "Set pane height (:vertical resize: width)"},
+	{":cn / :cp", "Next/previous quickfix entry"},
+	{":cc [n]", "Jump to quickfix entry n"},
+	{":clist", "List quickfix entries"},
+	{":copen", "Show quickfix list in a buffer"},
+	{":tattach [name]", "Attach to a persistent terminal session"},
+	{":tdetach", "Detach from the session, leaving it running"},
+	{":tsessions", "List terminal sessions"},
+	{":tkill [name]", "End a terminal session"},
+	{":set name=value", "Set an option (scrollback, termpalette, makeprg, errorformat, tabstop, ...)"},
+	{":set [no]name", "Turn an on/off option on or off (autoindent, smartindent, expandtab, autopairs)"},
+	{":setlocal name=value", "Set an indent option for the current buffer only"},
+	{":help [topic]", "Show this help, at a section or command"},
+}
+
+// appendCommands adds command help
+func appendCommands(sb *strings.Builder) {
+	for _, c := range helpCommands {
 		sb.WriteString(fmt.Sprintf("  %-20s %s\n", c.cmd, c.desc))
 	}
 }
@@ -147,13 +266,31 @@ func appendSpecialSequences(sb *strings.Builder) {
 		{"<count>j/k", "Move <count> lines (e.g., 5j)"},
 		{"dd", "Delete current line"},
 		{"<count>dd", "Delete line <count>"},
+		{"%", "Jump to the matching (), [] or {} bracket (<count>% to count percent of the file)"},
+		{">> / <<", "Shift line right/left by shiftwidth (<count>>> for count lines)"},
+		{"==", "Reindent line (<count>== for count lines)"},
+		{">j / >k / >G", "Shift through a motion (also <, =)"},
+		{"> / < / = (visual)", "Shift or reindent selected lines"},
 		{"zz", "Center cursor in viewport"},
 		{"zt", "Cursor to top of viewport"},
 		{"zb", "Cursor to bottom of viewport"},
+		{"gs", "Send paragraph/selection to a terminal"},
+		{"gt / gT", "Next/previous tab page"},
+		{"gf (terminal)", "Open file:line or URL from output"},
+		{"[[ / ]] (terminal)", "Jump to previous/next shell prompt"},
+		{"y (terminal)", "Yank last finished command's output"},
+		{"Ctrl+Shift+V (terminal)", "Paste clipboard into terminal"},
 		{"Ctrl+S v", "Split vertically"},
 		{"Ctrl+S h", "Split horizontally"},
 		{"Ctrl+S =", "Equalize panes"},
 		{"Ctrl+S o", "Zoom/unzoom pane"},
+		{"Ctrl+W + / -", "Make pane taller/shorter"},
+		{"Ctrl+W > / <", "Make pane wider/narrower"},
+		{"Ctrl+W _ / |", "Maximize pane height/width"},
+		{"Ctrl+W x", "Exchange pane with the next one"},
+		{"Ctrl+W r / R", "Rotate panes down/up"},
+		{"Ctrl+W H/J/K/L", "Move pane to the far edge"},
+		{"Ctrl+W T", "Move pane to a new tab page"},
 	}
 
 	for _, s := range sequences {
@@ -216,68 +353,85 @@ func formatKeyName(k key.Name) string {
 // actionDescription returns a human-readable description for an action
 func actionDescription(action Action) string {
 	descriptions := map[Action]string{
-		ActionNone:               "No action",
-		ActionToggleExplorer:     "Toggle file explorer",
-		ActionFocusExplorer:      "Focus explorer",
-		ActionFocusEditor:        "Focus editor",
-		ActionToggleFullscreen:   "Toggle fullscreen",
-		ActionEnterInsert:        "Enter INSERT mode",
-		ActionEnterVisualChar:    "Enter VISUAL (char) mode",
-		ActionEnterVisualLine:    "Enter VISUAL (line) mode",
-		ActionEnterDelete:        "Enter DELETE mode",
-		ActionEnterCommand:       "Enter COMMAND mode",
-		ActionEnterExplorer:      "Enter EXPLORER mode",
-		ActionExitMode:           "Exit current mode",
-		ActionMoveLeft:           "Move cursor left",
-		ActionMoveRight:          "Move cursor right",
-		ActionMoveUp:             "Move cursor up",
-		ActionMoveDown:           "Move cursor down",
-		ActionJumpLineStart:      "Jump to line start",
-		ActionJumpLineEnd:        "Jump to line end",
-		ActionWordForward:        "Move to next word",
-		ActionWordBackward:       "Move to previous word",
-		ActionWordEnd:            "Move to end of word",
-		ActionInsertNewline:      "Insert newline",
-		ActionInsertSpace:        "Insert space",
-		ActionInsertTab:          "Insert tab",
-		ActionDeleteBackward:     "Delete backward",
-		ActionDeleteForward:      "Delete forward",
-		ActionUndo:               "Undo last edit",
-		ActionCopySelection:      "Copy selection",
-		ActionDeleteSelection:    "Delete selection",
-		ActionPasteClipboard:     "Paste clipboard",
-		ActionCopyLine:           "Copy current line",
-		ActionPaste:              "Paste at cursor",
-		ActionOpenNode:           "Open file/folder",
-		ActionCollapseNode:       "Collapse folder",
-		ActionExpandNode:         "Expand folder",
-		ActionRenameFile:         "Rename file",
-		ActionDeleteFile:         "Delete file",
-		ActionCreateFile:         "Create new file",
-		ActionNavigateUp:         "Navigate to parent dir",
-		ActionEnterSearch:        "Enter search mode",
-		ActionNextMatch:          "Next search match",
-		ActionPrevMatch:          "Previous search match",
-		ActionClearSearch:        "Clear search",
-		ActionOpenFuzzyFinder:    "Open fuzzy finder",
-		ActionFuzzyFinderConfirm: "Confirm selection",
-		ActionScrollToCenter:     "Center viewport",
-		ActionScrollToTop:        "Scroll to top",
-		ActionScrollToBottom:     "Scroll to bottom",
-		ActionScrollLineUp:       "Scroll up one line",
-		ActionScrollLineDown:     "Scroll down one line",
-		ActionSplitVertical:      "Split vertically",
-		ActionSplitHorizontal:    "Split horizontally",
-		ActionPaneFocusLeft:      "Focus pane left",
-		ActionPaneFocusRight:     "Focus pane right",
-		ActionPaneFocusUp:        "Focus pane up",
-		ActionPaneFocusDown:      "Focus pane down",
-		ActionPaneCycleNext:      "Cycle to next pane",
-		ActionPaneClose:          "Close pane",
-		ActionPaneEqualize:       "Equalize panes",
-		ActionPaneZoomToggle:     "Toggle pane zoom",
-		ActionOpenTerminal:       "Open terminal",
-		ActionTerminalExit:       "Exit terminal mode",
+		ActionNone:                  "No action",
+		ActionToggleExplorer:        "Toggle file explorer",
+		ActionFocusExplorer:         "Focus explorer",
+		ActionFocusEditor:           "Focus editor",
+		ActionToggleFullscreen:      "Toggle fullscreen",
+		ActionEnterInsert:           "Enter INSERT mode",
+		ActionEnterVisualChar:       "Enter VISUAL (char) mode",
+		ActionEnterVisualLine:       "Enter VISUAL (line) mode",
+		ActionEnterDelete:           "Enter DELETE mode",
+		ActionEnterCommand:          "Enter COMMAND mode",
+		ActionEnterExplorer:         "Enter EXPLORER mode",
+		ActionExitMode:              "Exit current mode",
+		ActionMoveLeft:              "Move cursor left",
+		ActionMoveRight:             "Move cursor right",
+		ActionMoveUp:                "Move cursor up",
+		ActionMoveDown:              "Move cursor down",
+		ActionJumpLineStart:         "Jump to line start",
+		ActionJumpLineEnd:           "Jump to line end",
+		ActionWordForward:           "Move to next word",
+		ActionWordBackward:          "Move to previous word",
+		ActionWordEnd:               "Move to end of word",
+		ActionInsertNewline:         "Insert newline",
+		ActionInsertSpace:           "Insert space",
+		ActionInsertTab:             "Insert tab",
+		ActionDeleteBackward:        "Delete backward",
+		ActionDeleteForward:         "Delete forward",
+		ActionUndo:                  "Undo last edit",
+		ActionCopySelection:         "Copy selection",
+		ActionDeleteSelection:       "Delete selection",
+		ActionPasteClipboard:        "Paste clipboard",
+		ActionCopyLine:              "Copy current line",
+		ActionPaste:                 "Paste at cursor",
+		ActionOpenNode:              "Open file/folder",
+		ActionCollapseNode:          "Collapse folder",
+		ActionExpandNode:            "Expand folder",
+		ActionRenameFile:            "Rename file",
+		ActionDeleteFile:            "Delete file",
+		ActionCreateFile:            "Create new file",
+		ActionNavigateUp:            "Navigate to parent dir",
+		ActionToggleHidden:          "Toggle ignored files",
+		ActionEnterSearch:           "Enter search mode",
+		ActionNextMatch:             "Next search match",
+		ActionPrevMatch:             "Previous search match",
+		ActionClearSearch:           "Clear search",
+		ActionOpenFuzzyFinder:       "Open fuzzy finder",
+		ActionFuzzyFinderConfirm:    "Confirm selection",
+		ActionToggleFuzzyPreview:    "Toggle file preview",
+		ActionFuzzyFinderToggleMark: "Mark/unmark result",
+		ActionFuzzyFinderOpenVSplit: "Open in vertical split",
+		ActionFuzzyFinderOpenHSplit: "Open in horizontal split",
+		ActionFuzzyFinderOpenTab:    "Open as buffers (tab)",
+		ActionFuzzyFinderQuickfix:   "Send to quickfix list",
+		ActionAlternateBuffer:       "Switch to alternate buffer",
+		ActionScrollToCenter:        "Center viewport",
+		ActionScrollToTop:           "Scroll to top",
+		ActionScrollToBottom:        "Scroll to bottom",
+		ActionScrollLineUp:          "Scroll up one line",
+		ActionScrollLineDown:        "Scroll down one line",
+		ActionScrollHalfPageUp:      "Scroll up half a page",
+		ActionScrollHalfPageDown:    "Scroll down half a page",
+		ActionScrollPageUp:          "Scroll up one page",
+		ActionScrollPageDown:        "Scroll down one page",
+		ActionSplitVertical:         "Split vertically",
+		ActionSplitHorizontal:       "Split horizontally",
+		ActionPaneFocusLeft:         "Focus pane left",
+		ActionPaneFocusRight:        "Focus pane right",
+		ActionPaneFocusUp:           "Focus pane up",
+		ActionPaneFocusDown:         "Focus pane down",
+		ActionPaneCycleNext:         "Cycle to next pane",
+		ActionPaneClose:             "Close pane",
+		ActionPaneEqualize:          "Equalize panes",
+		ActionPaneZoomToggle:        "Toggle pane zoom",
+		ActionOpenTerminal:          "Open terminal",
+		ActionTerminalExit:          "Exit terminal mode",
+		ActionCommandCompletePrev:   "Previous completion",
+		ActionCommandWordLeft:       "Move one word left",
+		ActionCommandWordRight:      "Move one word right",
+		ActionCommandDeleteWord:     "Delete word before cursor",
+		ActionCommandDeleteToStart:  "Delete to start of line",
 	}
 
 	if desc, exists := descriptions[action]; exists {
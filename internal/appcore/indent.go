@@ -0,0 +1,184 @@
+package appcore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/vem/internal/editor"
+)
+
+// indentOptionNames maps the indent options, by full and short name, to
+// their full names.
+var indentOptionNames = map[string]string{
+	"autoindent": "autoindent", "ai": "autoindent",
+	"smartindent": "smartindent", "si": "smartindent",
+	"expandtab": "expandtab", "et": "expandtab",
+	"shiftwidth": "shiftwidth", "sw": "shiftwidth",
+	"tabstop": "tabstop", "ts": "tabstop",
+	"softtabstop": "softtabstop", "sts": "softtabstop",
+}
+
+// indentOptionOrder is the order :set shows the indent options in.
+var indentOptionOrder = []string{"autoindent", "smartindent", "expandtab", "shiftwidth", "tabstop", "softtabstop"}
+
+// indentOptionField returns the field of o an indent option is kept in,
+// a flag or a number.
+func indentOptionField(o *editor.IndentOptions, name string) (*bool, *int) {
+	switch name {
+	case "autoindent":
+		return &o.AutoIndent, nil
+	case "smartindent":
+		return &o.SmartIndent, nil
+	case "expandtab":
+		return &o.ExpandTab, nil
+	case "shiftwidth":
+		return nil, &o.ShiftWidth
+	case "tabstop":
+		return nil, &o.TabStop
+	default:
+		return nil, &o.SoftTabStop
+	}
+}
+
+// formatIndentOption returns an indent option as :set shows it: "name" or
+// "noname" for a flag, "name=N" for a number.
+func formatIndentOption(o editor.IndentOptions, name string) string {
+	flag, num := indentOptionField(&o, name)
+	if num != nil {
+		return fmt.Sprintf("%s=%d", name, *num)
+	}
+	return formatFlag(name, *flag)
+}
+
+// setIndentOption handles arg for :set if it names an indent option,
+// reporting whether it did. The options belong to each buffer: :set
+// changes the active buffer's and the ones new buffers get, :setlocal
+// (local) only the active buffer's. It returns the option as :set shows it.
+func (s *appState) setIndentOption(arg string, local bool) (string, bool, error) {
+	name, value, assign := strings.Cut(arg, "=")
+	query := strings.HasSuffix(name, "?")
+	name = strings.TrimSuffix(name, "?")
+
+	on := true
+	full, ok := indentOptionNames[name]
+	if !ok && strings.HasPrefix(name, "no") {
+		full, ok = indentOptionNames[name[2:]]
+		on = false
+	}
+	buf := s.activeBuffer()
+	if !ok || buf == nil {
+		return "", ok, nil
+	}
+
+	current := buf.IndentOptions()
+	flag, _ := indentOptionField(&current, full)
+	var update func(*editor.IndentOptions)
+	switch {
+	case query:
+	case flag != nil && assign, flag == nil && !on:
+		return "", true, fmt.Errorf("E474: Invalid argument: %s", arg)
+	case flag != nil:
+		update = func(o *editor.IndentOptions) {
+			f, _ := indentOptionField(o, full)
+			*f = on
+		}
+	case assign:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", true, fmt.Errorf("E521: Number required after =: %s", arg)
+		}
+		if (full == "tabstop" && n <= 0) || (full == "shiftwidth" && n < 0) || n < -1 {
+			return "", true, fmt.Errorf("E487: Argument must be positive: %s", arg)
+		}
+		update = func(o *editor.IndentOptions) {
+			_, num := indentOptionField(o, full)
+			*num = n
+		}
+	}
+
+	if update != nil {
+		update(&current)
+		buf.SetIndentOptions(current)
+		if !local {
+			global := s.bufferMgr.IndentOptions()
+			update(&global)
+			s.bufferMgr.SetIndentOptions(global)
+		}
+	}
+	return formatIndentOption(current, full), true, nil
+}
+
+// startIndentOperator starts >, < or =, which wait for a motion: the same
+// key again for count lines, j or k for count lines more down or up, or G
+// for the rest of the buffer.
+func (s *appState) startIndentOperator(op rune) {
+	s.pendingIndentOp = op
+	s.status = fmt.Sprintf("%c: awaiting %c, j, k or G", op, op)
+}
+
+// handleIndentMotion runs the pending indent operator over the lines the
+// motion r covers.
+func (s *appState) handleIndentMotion(r rune) {
+	op := s.pendingIndentOp
+	s.pendingIndentOp = 0
+	count := s.consumeCount(0)
+	cur := s.activeBuffer().Cursor().Line
+
+	start, end := cur, cur
+	switch r {
+	case op:
+		end = cur + max(count, 1) - 1
+	case 'j':
+		end = cur + max(count, 1)
+	case 'k':
+		start = cur - max(count, 1)
+	case 'G':
+		end = s.activeBuffer().LineCount() - 1
+		if count > 0 {
+			end = count - 1
+		}
+	default:
+		s.status = fmt.Sprintf("Unknown motion for %c: %c", op, r)
+		return
+	}
+	s.indentLines(op, min(start, end), max(start, end), 1)
+}
+
+// indentVisualSelection runs >, < or = over the lines of the visual
+// selection and leaves visual mode. A count shifts that many levels.
+func (s *appState) indentVisualSelection(op rune) {
+	start, end, ok := s.visualSelectionRange()
+	if !ok {
+		s.status = "No selection"
+		return
+	}
+	levels := s.consumeCount(1)
+	s.exitVisualMode()
+	s.indentLines(op, start, end, levels)
+}
+
+// indentLines shifts lines start through end right (>) or left (<) by
+// levels shiftwidths, or reindents them (=).
+func (s *appState) indentLines(op rune, start, end, levels int) {
+	buf := s.activeBuffer()
+	if buf.IsTerminal() || buf.IsReadOnly() {
+		s.status = "E21: Cannot make changes, buffer is read-only"
+		return
+	}
+	start, end = max(start, 0), min(end, buf.LineCount()-1)
+
+	var done string
+	switch op {
+	case '>':
+		buf.ShiftLines(start, end, levels)
+		done = "shifted right"
+	case '<':
+		buf.ShiftLines(start, end, -levels)
+		done = "shifted left"
+	default:
+		buf.ReindentLines(start, end)
+		done = "reindented"
+	}
+	s.setCursorStatus(fmt.Sprintf("%d line(s) %s", end-start+1, done))
+}
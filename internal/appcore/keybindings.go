@@ -67,6 +67,7 @@ const (
 	ActionRenameFile
 	ActionDeleteFile
 	ActionCreateFile
+	ActionToggleHidden
 
 	// Search
 	ActionEnterSearch
@@ -77,10 +78,17 @@ const (
 	// Fuzzy Finder
 	ActionOpenFuzzyFinder
 	ActionFuzzyFinderConfirm
+	ActionToggleFuzzyPreview
+	ActionFuzzyFinderToggleMark
+	ActionFuzzyFinderOpenVSplit
+	ActionFuzzyFinderOpenHSplit
+	ActionFuzzyFinderOpenTab
+	ActionFuzzyFinderQuickfix
 
 	// Buffer management
 	ActionNextBuffer
 	ActionPrevBuffer
+	ActionAlternateBuffer
 
 	// Viewport scrolling
 	ActionScrollToCenter
@@ -88,6 +96,10 @@ const (
 	ActionScrollToBottom
 	ActionScrollLineUp
 	ActionScrollLineDown
+	ActionScrollHalfPageUp
+	ActionScrollHalfPageDown
+	ActionScrollPageUp
+	ActionScrollPageDown
 
 	// Pane management
 	ActionSplitVertical
@@ -104,6 +116,13 @@ const (
 	// Terminal
 	ActionOpenTerminal
 	ActionTerminalExit
+
+	// Command line editing
+	ActionCommandCompletePrev
+	ActionCommandWordLeft
+	ActionCommandWordRight
+	ActionCommandDeleteWord
+	ActionCommandDeleteToStart
 )
 
 type KeyBinding struct {
@@ -166,6 +185,10 @@ var modeKeybindings = map[mode][]KeyBinding{
 		{Modifiers: key.ModCtrl, Key: "e", Modes: nil, Action: ActionScrollLineDown},
 		{Modifiers: key.ModCtrl, Key: "y", Modes: nil, Action: ActionScrollLineUp},
 		{Modifiers: key.ModShift, Key: key.NameTab, Modes: nil, Action: ActionPaneCycleNext},
+
+		// Ctrl+^ is Ctrl+Shift+6 on most layouts, Ctrl+6 works too
+		{Modifiers: key.ModCtrl, Key: "6", Modes: nil, Action: ActionAlternateBuffer},
+		{Modifiers: key.ModCtrl | key.ModShift, Key: "6", Modes: nil, Action: ActionAlternateBuffer},
 	},
 	modeInsert: {
 		{Modifiers: 0, Key: key.NameEscape, Modes: nil, Action: ActionExitMode},
@@ -211,6 +234,23 @@ var modeKeybindings = map[mode][]KeyBinding{
 		{Modifiers: 0, Key: key.NameReturn, Modes: nil, Action: ActionInsertNewline},
 		{Modifiers: 0, Key: key.NameEnter, Modes: nil, Action: ActionInsertNewline},
 		{Modifiers: 0, Key: key.NameDeleteBackward, Modes: nil, Action: ActionDeleteBackward},
+		{Modifiers: 0, Key: key.NameTab, Modes: nil, Action: ActionInsertTab},
+		{Modifiers: key.ModShift, Key: key.NameTab, Modes: nil, Action: ActionCommandCompletePrev},
+		{Modifiers: 0, Key: key.NameDeleteForward, Modes: nil, Action: ActionDeleteForward},
+		{Modifiers: 0, Key: key.NameLeftArrow, Modes: nil, Action: ActionMoveLeft},
+		{Modifiers: 0, Key: key.NameRightArrow, Modes: nil, Action: ActionMoveRight},
+		{Modifiers: 0, Key: key.NameUpArrow, Modes: nil, Action: ActionMoveUp},
+		{Modifiers: 0, Key: key.NameDownArrow, Modes: nil, Action: ActionMoveDown},
+		{Modifiers: key.ModShift, Key: key.NameLeftArrow, Modes: nil, Action: ActionCommandWordLeft},
+		{Modifiers: key.ModShift, Key: key.NameRightArrow, Modes: nil, Action: ActionCommandWordRight},
+		{Modifiers: key.ModCtrl, Key: key.NameLeftArrow, Modes: nil, Action: ActionCommandWordLeft},
+		{Modifiers: key.ModCtrl, Key: key.NameRightArrow, Modes: nil, Action: ActionCommandWordRight},
+		{Modifiers: 0, Key: key.NameHome, Modes: nil, Action: ActionJumpLineStart},
+		{Modifiers: 0, Key: key.NameEnd, Modes: nil, Action: ActionJumpLineEnd},
+		{Modifiers: key.ModCtrl, Key: "b", Modes: nil, Action: ActionJumpLineStart},
+		{Modifiers: key.ModCtrl, Key: "e", Modes: nil, Action: ActionJumpLineEnd},
+		{Modifiers: key.ModCtrl, Key: "w", Modes: nil, Action: ActionCommandDeleteWord},
+		{Modifiers: key.ModCtrl, Key: "u", Modes: nil, Action: ActionCommandDeleteToStart},
 	},
 	modeExplorer: {
 		{Modifiers: 0, Key: key.NameEscape, Modes: nil, Action: ActionExitMode},
@@ -228,6 +268,7 @@ var modeKeybindings = map[mode][]KeyBinding{
 		{Modifiers: 0, Key: "d", Modes: nil, Action: ActionDeleteFile},
 		{Modifiers: 0, Key: "n", Modes: nil, Action: ActionCreateFile},
 		{Modifiers: 0, Key: "u", Modes: nil, Action: ActionNavigateUp},
+		{Modifiers: key.ModShift, Key: "i", Modes: nil, Action: ActionToggleHidden},
 		{Modifiers: 0, Key: "q", Modes: nil, Action: ActionExitMode},
 		{Modifiers: key.ModShift, Key: key.NameTab, Modes: nil, Action: ActionPaneCycleNext},
 	},
@@ -244,10 +285,16 @@ var modeKeybindings = map[mode][]KeyBinding{
 		{Modifiers: 0, Key: key.NameUpArrow, Modes: nil, Action: ActionMoveUp},
 		{Modifiers: 0, Key: key.NameDownArrow, Modes: nil, Action: ActionMoveDown},
 		{Modifiers: 0, Key: key.NameDeleteBackward, Modes: nil, Action: ActionDeleteBackward},
+		{Modifiers: key.ModCtrl, Key: "i", Modes: nil, Action: ActionToggleHidden},
+		{Modifiers: key.ModCtrl, Key: "o", Modes: nil, Action: ActionToggleFuzzyPreview},
+		{Modifiers: 0, Key: key.NameTab, Modes: nil, Action: ActionFuzzyFinderToggleMark},
+		{Modifiers: key.ModCtrl, Key: "v", Modes: nil, Action: ActionFuzzyFinderOpenVSplit},
+		{Modifiers: key.ModCtrl, Key: "x", Modes: nil, Action: ActionFuzzyFinderOpenHSplit},
+		{Modifiers: key.ModCtrl, Key: "t", Modes: nil, Action: ActionFuzzyFinderOpenTab},
+		{Modifiers: key.ModCtrl, Key: "q", Modes: nil, Action: ActionFuzzyFinderQuickfix},
 	},
 	modeTerminal: {
 		{Modifiers: 0, Key: key.NameEscape, Modes: nil, Action: ActionTerminalExit},
-		{Modifiers: key.ModShift, Key: key.NameTab, Modes: nil, Action: ActionTerminalExit},
 	},
 }
 
@@ -437,13 +484,23 @@ func (s *appState) executeAction(action Action, ev key.Event) {
 		}
 
 	case ActionMoveLeft:
-		s.moveCursor("left")
+		if s.mode == modeCommand {
+			s.moveCommandCursor(false, false)
+		} else {
+			s.moveCursor("left")
+		}
 
 	case ActionMoveRight:
-		s.moveCursor("right")
+		if s.mode == modeCommand {
+			s.moveCommandCursor(true, false)
+		} else {
+			s.moveCursor("right")
+		}
 
 	case ActionMoveUp:
-		if s.mode == modeExplorer && s.fileTree != nil {
+		if s.mode == modeCommand {
+			s.recallCommandHistory(false)
+		} else if s.mode == modeExplorer && s.fileTree != nil {
 			if s.fileTree.MoveUp() {
 				s.ensureExplorerItemVisible()
 				s.status = "Explorer: moved up"
@@ -455,7 +512,9 @@ func (s *appState) executeAction(action Action, ev key.Event) {
 		}
 
 	case ActionMoveDown:
-		if s.mode == modeExplorer && s.fileTree != nil {
+		if s.mode == modeCommand {
+			s.recallCommandHistory(true)
+		} else if s.mode == modeExplorer && s.fileTree != nil {
 			if s.fileTree.MoveDown() {
 				s.ensureExplorerItemVisible()
 				s.status = "Explorer: moved down"
@@ -467,14 +526,18 @@ func (s *appState) executeAction(action Action, ev key.Event) {
 		}
 
 	case ActionJumpLineStart:
-		if s.activeBuffer().JumpLineStart() {
+		if s.mode == modeCommand {
+			s.cmdCursor = 0
+		} else if s.activeBuffer().JumpLineStart() {
 			s.setCursorStatus("Line start")
 		} else {
 			s.status = "Already at line start"
 		}
 
 	case ActionJumpLineEnd:
-		if s.activeBuffer().JumpLineEnd() {
+		if s.mode == modeCommand {
+			s.cmdCursor = len(s.cmdText)
+		} else if s.activeBuffer().JumpLineEnd() {
 			s.setCursorStatus("Line end")
 		} else {
 			s.status = "Already at line end"
@@ -519,11 +582,14 @@ func (s *appState) executeAction(action Action, ev key.Event) {
 		if s.mode == modeInsert {
 			s.insertText("\t")
 			s.skipNextEdit = true // Prevent EditEvent from inserting again
+		} else if s.mode == modeCommand {
+			s.completeCommandLine(false)
 		}
 
 	case ActionDeleteBackward:
 		if s.mode == modeInsert {
-			if s.activeBuffer().DeleteBackward() {
+			buf := s.activeBuffer()
+			if (s.autoPairs && buf.DeletePairBackward()) || buf.DeleteBackward() {
 				s.setCursorStatus("Backspace")
 			} else {
 				s.status = "Start of buffer"
@@ -543,6 +609,8 @@ func (s *appState) executeAction(action Action, ev key.Event) {
 			} else {
 				s.status = "End of buffer"
 			}
+		} else if s.mode == modeCommand {
+			s.deleteCommandCharForward()
 		}
 
 	case ActionUndo:
@@ -621,6 +689,9 @@ func (s *appState) executeAction(action Action, ev key.Event) {
 			s.enterCreateMode()
 		}
 
+	case ActionToggleHidden:
+		s.toggleHiddenFiles()
+
 	case ActionEnterSearch:
 		s.enterSearchMode()
 
@@ -643,6 +714,42 @@ func (s *appState) executeAction(action Action, ev key.Event) {
 	case ActionFuzzyFinderConfirm:
 		s.fuzzyFinderConfirm()
 
+	case ActionToggleFuzzyPreview:
+		s.toggleFuzzyPreview()
+
+	case ActionFuzzyFinderToggleMark:
+		s.fuzzyFinderToggleMark()
+
+	case ActionFuzzyFinderOpenVSplit:
+		s.fuzzyFinderOpen(fuzzyOpenVSplit)
+
+	case ActionFuzzyFinderOpenHSplit:
+		s.fuzzyFinderOpen(fuzzyOpenHSplit)
+
+	case ActionFuzzyFinderOpenTab:
+		s.fuzzyFinderOpen(fuzzyOpenTab)
+
+	case ActionFuzzyFinderQuickfix:
+		s.fuzzyFinderSendToQuickfix()
+
+	case ActionAlternateBuffer:
+		s.editAlternateBuffer()
+
+	case ActionCommandCompletePrev:
+		s.completeCommandLine(true)
+
+	case ActionCommandWordLeft:
+		s.moveCommandCursor(false, true)
+
+	case ActionCommandWordRight:
+		s.moveCommandCursor(true, true)
+
+	case ActionCommandDeleteWord:
+		s.deleteCommandWord()
+
+	case ActionCommandDeleteToStart:
+		s.deleteCommandToStart()
+
 	case ActionScrollToCenter:
 		linesPerPage := 20
 		s.scrollToCenter(linesPerPage)
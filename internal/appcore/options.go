@@ -0,0 +1,211 @@
+package appcore
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/vem/internal/syntax"
+	"github.com/javanhut/vem/internal/terminal"
+)
+
+// optionNames are the options :set knows, for completing their names.
+var optionNames = []string{
+	"autoindent", "autopairs", "errorformat", "expandtab", "makeprg", "scrollback",
+	"shiftwidth", "smartindent", "softtabstop", "tabstop", "termpalette",
+}
+
+// handleSetCommand runs :set, or :setlocal with local. Each argument is
+// either "name=value" to change an option or "name" / "name?" to show it;
+// on/off options are turned on with "name" and off with "noname". The
+// resulting values are shown. A backslash before a space keeps it in the
+// value. Only the indent options can be set locally, see setIndentOption.
+func (s *appState) handleSetCommand(args string, local bool) {
+	if args == "" {
+		shown := []string{fmt.Sprintf("scrollback=%d termpalette=%s makeprg=%s", s.terminalScrollback, s.terminalPalette, s.makeprg), formatFlag("autopairs", s.autoPairs)}
+		if local {
+			shown = nil
+		}
+		if buf := s.activeBuffer(); buf != nil {
+			for _, name := range indentOptionOrder {
+				shown = append(shown, formatIndentOption(buf.IndentOptions(), name))
+			}
+		}
+		s.status = strings.Join(shown, " ")
+		return
+	}
+
+	var shown []string
+	for _, arg := range splitSetArgs(args) {
+		if option, ok, err := s.setIndentOption(arg, local); err != nil {
+			s.status = err.Error()
+			return
+		} else if ok {
+			shown = append(shown, option)
+			continue
+		}
+		if local {
+			s.status = fmt.Sprintf("E518: Not a buffer-local option: %s", arg)
+			return
+		}
+
+		name, value, assign := strings.Cut(arg, "=")
+		name = strings.TrimSuffix(name, "?")
+
+		switch name {
+		case "autopairs", "ap", "noautopairs", "noap":
+			if assign {
+				s.status = fmt.Sprintf("E474: Invalid argument: %s", arg)
+				return
+			}
+			if !strings.HasSuffix(arg, "?") {
+				s.autoPairs = !strings.HasPrefix(name, "no")
+			}
+			shown = append(shown, formatFlag("autopairs", s.autoPairs))
+		case "scrollback", "scb":
+			if assign {
+				lines, err := strconv.Atoi(value)
+				if err != nil || lines < 0 {
+					s.status = fmt.Sprintf("E521: Number required after =: %s", arg)
+					return
+				}
+				s.setTerminalScrollback(lines)
+			}
+			shown = append(shown, fmt.Sprintf("scrollback=%d", s.terminalScrollback))
+		case "termpalette", "tpal":
+			if assign {
+				if _, ok := terminal.Palettes[value]; !ok && value != themePalette {
+					s.status = fmt.Sprintf("E474: Invalid argument: %s (use %s)", arg, strings.Join(terminalPaletteNames(), ", "))
+					return
+				}
+				s.setTerminalPalette(value)
+			}
+			shown = append(shown, "termpalette="+s.terminalPalette)
+		case "makeprg", "mp":
+			if assign {
+				s.makeprg = value
+			}
+			shown = append(shown, "makeprg="+s.makeprg)
+		case "errorformat", "efm":
+			if assign {
+				if _, err := compileErrorformat(value); err != nil {
+					s.status = fmt.Sprintf("E474: Invalid argument: %s (%v)", arg, err)
+					return
+				}
+				s.errorformat = value
+			}
+			shown = append(shown, "errorformat="+s.errorformat)
+		default:
+			s.status = fmt.Sprintf("E518: Unknown option: %s", name)
+			return
+		}
+	}
+
+	s.status = strings.Join(shown, " ")
+}
+
+// formatFlag returns an on/off option as :set shows it, "name" or
+// "noname".
+func formatFlag(name string, on bool) string {
+	if on {
+		return name
+	}
+	return "no" + name
+}
+
+// splitSetArgs splits :set arguments at whitespace. "\ " is a literal space
+// and "\\" a literal backslash; other escapes, such as errorformat's "\,",
+// are kept as written.
+func splitSetArgs(args string) []string {
+	var fields []string
+	var current strings.Builder
+	inField := false
+	for i := 0; i < len(args); i++ {
+		c := args[i]
+		switch {
+		case c == '\\' && i+1 < len(args) && (args[i+1] == ' ' || args[i+1] == '\\'):
+			i++
+			current.WriteByte(args[i])
+			inField = true
+		case c == ' ' || c == '\t':
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteByte(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// setTerminalScrollback changes how many scrollback lines terminals keep,
+// including the terminals that are already open.
+func (s *appState) setTerminalScrollback(lines int) {
+	s.terminalScrollback = lines
+	for _, term := range s.terminals {
+		if screen := term.GetScreen(); screen != nil {
+			screen.SetHistoryLimit(lines)
+		}
+	}
+}
+
+// themePalette is the termpalette value that derives the terminal colors
+// from the editor's syntax theme.
+const themePalette = "theme"
+
+// terminalPaletteNames returns the values termpalette accepts.
+func terminalPaletteNames() []string {
+	names := []string{themePalette}
+	for name := range terminal.Palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setTerminalPalette changes the colors of terminals, including the ones
+// that are already open.
+func (s *appState) setTerminalPalette(name string) {
+	s.terminalPalette = name
+	palette := s.terminalPaletteColors()
+	for _, term := range s.terminals {
+		if term != nil {
+			term.SetPalette(palette)
+		}
+	}
+}
+
+// terminalPaletteColors returns the colors of the termpalette setting.
+func (s *appState) terminalPaletteColors() terminal.Palette {
+	if s.terminalPalette != themePalette {
+		return terminal.Palettes[s.terminalPalette]
+	}
+
+	theme := syntax.DefaultTheme
+	for _, highlighter := range s.syntaxHighlighters {
+		if highlighter.IsEnabled() {
+			theme = highlighter.GetThemeName()
+			break
+		}
+	}
+	palette := terminal.DefaultPalette
+	palette.ANSI, palette.FG, palette.BG = syntax.TerminalColors(theme, terminal.DefaultPalette.ANSI)
+	palette.Cursor = palette.FG
+	return palette
+}
+
+// terminalScrollbackConfig returns the scrollback setting in the form
+// terminal.Config expects, where 0 means the default.
+func (s *appState) terminalScrollbackConfig() int {
+	if s.terminalScrollback == 0 {
+		return -1
+	}
+	return s.terminalScrollback
+}
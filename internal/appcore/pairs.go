@@ -0,0 +1,94 @@
+package appcore
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+
+	"github.com/javanhut/vem/internal/editor"
+)
+
+// matchPairColor is the background of the bracket under the cursor and the
+// one it pairs with.
+var matchPairColor = color.NRGBA{R: 0x5f, G: 0xd0, B: 0x7a, A: 0x66}
+
+// inStringOrComment returns a function reporting whether a position of the
+// active buffer is in a string or a comment, going by the syntax
+// highlighter, for bracket matching to skip. The buffer is only lexed once
+// a bracket needs checking.
+func (s *appState) inStringOrComment() func(editor.Cursor) bool {
+	buf := s.activeBuffer()
+	highlighter := s.getOrCreateHighlighter()
+	var inside func(line, col int) bool
+	return func(pos editor.Cursor) bool {
+		if inside == nil {
+			inside = highlighter.StringOrComment(buf.LinesRange(0, buf.LineCount()-1))
+		}
+		return inside(pos.Line, pos.Col)
+	}
+}
+
+// jumpToMatchingBracket runs %: without a count it jumps to the bracket
+// pairing with the one at or after the cursor, with a count to that
+// percentage of the buffer.
+func (s *appState) jumpToMatchingBracket() {
+	buf := s.activeBuffer()
+	if count := s.consumeCount(0); count > 0 {
+		if count > 100 {
+			s.status = "E1: Count must be 100 or less for %"
+			return
+		}
+		s.gotoLine((count*buf.LineCount() + 99) / 100)
+		return
+	}
+	if !buf.JumpToMatchingBracket(s.inStringOrComment()) {
+		s.status = "No matching bracket"
+		return
+	}
+	s.setCursorStatus("Matching bracket")
+}
+
+// matchingPair returns the bracket under the cursor, or in INSERT mode the
+// one just before it, and the bracket it pairs with, looking no more than
+// maxLines away; nil if there is none.
+func (s *appState) matchingPair(maxLines int) []editor.Cursor {
+	buf := s.activeBuffer()
+	if buf == nil || buf.IsTerminal() {
+		return nil
+	}
+	skip := s.inStringOrComment()
+	pos := buf.Cursor()
+	match, ok := buf.MatchingBracket(pos, skip, maxLines)
+	if !ok && s.mode == modeInsert && pos.Col > 0 {
+		pos.Col--
+		match, ok = buf.MatchingBracket(pos, skip, maxLines)
+	}
+	if !ok {
+		return nil
+	}
+	return []editor.Cursor{pos, match}
+}
+
+// drawPairHighlight draws the background of the brackets of pair that are
+// on line lineIdx.
+func (s *appState) drawPairHighlight(gtx layout.Context, lineIdx int, lineHeight int, pair []editor.Cursor) {
+	gutterWidth := s.measureTextWidth(gtx, fmt.Sprintf("%4d  ", lineIdx+1))
+	runes := []rune(s.activeBuffer().Line(lineIdx))
+	for _, pos := range pair {
+		if pos.Line != lineIdx || pos.Col >= len(runes) {
+			continue
+		}
+		x := gutterWidth + s.measureTextWidth(gtx, string(runes[:pos.Col]))
+		width := s.measureTextWidth(gtx, string(runes[pos.Col]))
+		rect := clip.Rect{
+			Min: image.Pt(x, 0),
+			Max: image.Pt(x+width, lineHeight),
+		}.Push(gtx.Ops)
+		paint.Fill(gtx.Ops, matchPairColor)
+		rect.Pop()
+	}
+}
@@ -2,9 +2,13 @@ package appcore
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 
 	"gioui.org/io/key"
+
+	"github.com/javanhut/vem/internal/panes"
 )
 
 // handleSplitVertical creates a vertical split (vertical divider - left|right).
@@ -21,14 +25,14 @@ func (s *appState) handleSplitVertical() {
 	fmt.Printf("[PANE_SPLIT] Current buffer count: %d\n", s.bufferMgr.BufferCount())
 
 	// Create a new empty buffer for the new pane
-	newBufferIndex := s.bufferMgr.CreateEmptyBuffer()
+	newBufferID := s.bufferMgr.CreateEmptyBuffer()
 
 	fmt.Printf("[PANE_SPLIT] Created new buffer, total buffers: %d\n", s.bufferMgr.BufferCount())
-	fmt.Printf("[PANE_SPLIT] New buffer index: %d\n", newBufferIndex)
+	fmt.Printf("[PANE_SPLIT] New buffer ID: %d\n", newBufferID)
 
 	// Split the active pane horizontally (creates vertical divider)
-	fmt.Printf("[PANE_SPLIT] Calling SplitHorizontal with buffer index %d\n", newBufferIndex)
-	if err := s.paneManager.SplitHorizontal(newBufferIndex); err != nil {
+	fmt.Printf("[PANE_SPLIT] Calling SplitHorizontal with buffer ID %d\n", newBufferID)
+	if err := s.paneManager.SplitHorizontal(newBufferID); err != nil {
 		s.status = fmt.Sprintf("Split failed: %v", err)
 		fmt.Printf("[PANE_SPLIT] ERROR: Split failed: %v\n", err)
 	} else {
@@ -39,7 +43,7 @@ func (s *appState) handleSplitVertical() {
 		// Debug: Print all panes
 		allPanes := s.paneManager.AllPanes()
 		for i, p := range allPanes {
-			fmt.Printf("[PANE_SPLIT]   Pane %d: ID=%s BufferIndex=%d Active=%v\n", i, p.ID, p.BufferIndex, p.Active)
+			fmt.Printf("[PANE_SPLIT]   Pane %d: ID=%s BufferID=%d Active=%v\n", i, p.ID, p.BufferID, p.Active)
 		}
 	}
 }
@@ -58,14 +62,14 @@ func (s *appState) handleSplitHorizontal() {
 	fmt.Printf("[PANE_SPLIT] Current buffer count: %d\n", s.bufferMgr.BufferCount())
 
 	// Create a new empty buffer for the new pane
-	newBufferIndex := s.bufferMgr.CreateEmptyBuffer()
+	newBufferID := s.bufferMgr.CreateEmptyBuffer()
 
 	fmt.Printf("[PANE_SPLIT] Created new buffer, total buffers: %d\n", s.bufferMgr.BufferCount())
-	fmt.Printf("[PANE_SPLIT] New buffer index: %d\n", newBufferIndex)
+	fmt.Printf("[PANE_SPLIT] New buffer ID: %d\n", newBufferID)
 
 	// Split the active pane vertically (creates horizontal divider)
-	fmt.Printf("[PANE_SPLIT] Calling SplitVertical with buffer index %d\n", newBufferIndex)
-	if err := s.paneManager.SplitVertical(newBufferIndex); err != nil {
+	fmt.Printf("[PANE_SPLIT] Calling SplitVertical with buffer ID %d\n", newBufferID)
+	if err := s.paneManager.SplitVertical(newBufferID); err != nil {
 		s.status = fmt.Sprintf("Split failed: %v", err)
 		fmt.Printf("[PANE_SPLIT] ERROR: Split failed: %v\n", err)
 	} else {
@@ -76,7 +80,7 @@ func (s *appState) handleSplitHorizontal() {
 		// Debug: Print all panes
 		allPanes := s.paneManager.AllPanes()
 		for i, p := range allPanes {
-			fmt.Printf("[PANE_SPLIT]   Pane %d: ID=%s BufferIndex=%d Active=%v\n", i, p.ID, p.BufferIndex, p.Active)
+			fmt.Printf("[PANE_SPLIT]   Pane %d: ID=%s BufferID=%d Active=%v\n", i, p.ID, p.BufferID, p.Active)
 		}
 	}
 }
@@ -184,15 +188,15 @@ func (s *appState) handlePaneClose() {
 	}
 
 	// Get the buffer for this pane
-	buf := s.bufferMgr.GetBuffer(activePane.BufferIndex)
+	buf := s.bufferMgr.GetBuffer(activePane.BufferID)
 	if buf == nil {
 		// No buffer, just close the pane if multiple exist
 		if s.paneManager.PaneCount() > 1 {
 			s.paneManager.ClosePane()
 			s.status = fmt.Sprintf("Pane closed - %d panes remaining", s.paneManager.PaneCount())
 		} else {
-			// Last pane with no buffer - switch to buffer 0 (sample buffer)
-			activePane.SetBufferIndex(0)
+			// Last pane with no buffer - show the active one
+			activePane.SetBufferID(s.bufferMgr.ActiveID())
 			s.status = "No buffer to close"
 		}
 		return
@@ -204,10 +208,10 @@ func (s *appState) handlePaneClose() {
 		return
 	}
 
-	bufferIndex := activePane.BufferIndex
+	bufferID := activePane.BufferID
 
 	// Close terminal if this buffer has one
-	s.closeTerminal(bufferIndex)
+	s.closeTerminal(bufferID)
 
 	// Multiple panes - close this pane and buffer
 	if s.paneManager.PaneCount() > 1 {
@@ -215,24 +219,23 @@ func (s *appState) handlePaneClose() {
 			s.status = fmt.Sprintf("Error closing pane: %v", err)
 			return
 		}
-		s.bufferMgr.CloseBuffer(bufferIndex, false)
+		// The check above covers unsaved changes
+		_ = s.closeBuffer(bufferID, false)
 		s.status = fmt.Sprintf("Pane closed - %d panes remaining", s.paneManager.PaneCount())
 		return
 	}
 
-	// Last pane - close buffer but keep editor open
-	s.bufferMgr.CloseBuffer(bufferIndex, false)
-
-	// Ensure we have at least one buffer (switch to buffer 0 - sample buffer)
-	if s.bufferMgr.BufferCount() == 0 || s.bufferMgr.GetBuffer(0) == nil {
-		// This shouldn't happen, but handle gracefully
-		activePane.SetBufferIndex(0)
-		s.status = "Buffer closed"
-	} else {
-		// Switch to buffer 0 (sample buffer)
-		activePane.SetBufferIndex(0)
-		s.status = "Buffer closed"
+	// The last pane of a tab page takes the tab with it
+	if s.tabs.Count() > 1 {
+		_ = s.closeBuffer(bufferID, false)
+		s.handleTabCloseCommand()
+		return
 	}
+
+	// Last pane - close buffer but keep editor open; the pane shows the
+	// buffer that became active
+	_ = s.closeBuffer(bufferID, false)
+	s.status = "Buffer closed"
 }
 
 // handlePaneEqualize makes all panes equal size.
@@ -245,6 +248,164 @@ func (s *appState) handlePaneEqualize() {
 	s.status = "All panes equalized (50/50)"
 }
 
+// windowCommandSymbols maps the keys Gio reports for Shift+key to the
+// symbols the Ctrl+W commands use.
+var windowCommandSymbols = map[string]string{
+	"=":  "+",
+	",":  "<",
+	".":  ">",
+	"-":  "_",
+	"\\": "|",
+}
+
+// handleWindowCommand handles the key after the Ctrl+W prefix, Vim's
+// commands for resizing and rearranging panes. A count typed before Ctrl+W
+// gives the number of lines or columns, or the size for _ and |.
+func (s *appState) handleWindowCommand(ev key.Event) {
+	count := s.consumeCount(0)
+	name := string(ev.Name)
+	if symbol, ok := windowCommandSymbols[name]; ok && (s.shiftPressed || ev.Modifiers.Contain(key.ModShift)) {
+		name = symbol
+	} else if r, ok := s.printableKey(ev); ok {
+		// Letters are case sensitive: r rotates down, R up
+		name = string(r)
+	}
+
+	switch name {
+	case "+":
+		s.resizePaneBy(panes.SplitVertical, max(count, 1))
+	case "-":
+		s.resizePaneBy(panes.SplitVertical, -max(count, 1))
+	case ">":
+		s.resizePaneBy(panes.SplitHorizontal, max(count, 1))
+	case "<":
+		s.resizePaneBy(panes.SplitHorizontal, -max(count, 1))
+	case "_":
+		s.resizePaneTo(panes.SplitVertical, count)
+	case "|":
+		s.resizePaneTo(panes.SplitHorizontal, count)
+	case "=":
+		s.executeAction(ActionPaneEqualize, ev)
+	case "x":
+		s.rearrangePanes(s.paneManager.Exchange, "Exchanged panes")
+	case "r":
+		s.rearrangePanes(func() error { return s.paneManager.Rotate(false) }, "Rotated panes")
+	case "R":
+		s.rearrangePanes(func() error { return s.paneManager.Rotate(true) }, "Rotated panes")
+	case "H":
+		s.movePaneToEdge(panes.DirLeft, "far left")
+	case "J":
+		s.movePaneToEdge(panes.DirDown, "bottom")
+	case "K":
+		s.movePaneToEdge(panes.DirUp, "top")
+	case "L":
+		s.movePaneToEdge(panes.DirRight, "far right")
+	case "T":
+		s.breakOutPane()
+	default:
+		s.status = "Unknown window command (+ - < > _ | = x r R H J K L T)"
+	}
+}
+
+// rearrangePanes runs a pane rearrangement and reports how it went.
+func (s *appState) rearrangePanes(rearrange func() error, done string) {
+	if s.paneManager == nil {
+		return
+	}
+	if err := rearrange(); err != nil {
+		s.status = fmt.Sprintf("Cannot rearrange panes: %v", err)
+		return
+	}
+	s.syncActivePaneBuffer()
+	s.status = done
+}
+
+// movePaneToEdge moves the active pane to the far edge in direction dir,
+// spanning the whole layout, Ctrl+W H/J/K/L.
+func (s *appState) movePaneToEdge(dir panes.Direction, edge string) {
+	s.rearrangePanes(func() error { return s.paneManager.MoveToEdge(dir) }, "Moved pane to the "+edge)
+}
+
+// breakOutPane moves the active pane to a new tab page, Ctrl+W T.
+func (s *appState) breakOutPane() {
+	if _, err := s.tabs.BreakOut(); err != nil {
+		s.status = fmt.Sprintf("Cannot move pane to a new tab: %v", err)
+		return
+	}
+	s.syncActiveTab()
+	s.status = fmt.Sprintf("Moved pane to tab page %d/%d", s.tabs.ActiveIndex()+1, s.tabs.Count())
+}
+
+// syncActivePaneBuffer makes the buffer of the active pane the active one
+// after the active pane changed.
+func (s *appState) syncActivePaneBuffer() {
+	if pane := s.paneManager.ActivePane(); pane != nil {
+		s.bufferMgr.SwitchToBuffer(pane.BufferID)
+	}
+}
+
+// handleResizeCommand implements :resize [N] and :vertical resize [N]. N
+// sets the height (the width for :vertical), +N and -N change it, and no N
+// maximizes the pane.
+func (s *appState) handleResizeCommand(args string, dir panes.SplitDirection) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		s.resizePaneTo(dir, 0)
+		return
+	}
+	n, err := strconv.Atoi(args)
+	if err != nil {
+		s.status = fmt.Sprintf("Invalid size: %s", args)
+		return
+	}
+	if strings.HasPrefix(args, "+") || strings.HasPrefix(args, "-") {
+		s.resizePaneBy(dir, n)
+		return
+	}
+	s.resizePaneTo(dir, max(n, 1))
+}
+
+// resizePaneBy grows the active pane by delta lines, or columns for
+// left | right splits, and shrinks it for a negative delta.
+func (s *appState) resizePaneBy(dir panes.SplitDirection, delta int) {
+	if s.paneManager == nil {
+		return
+	}
+	width, height := s.paneAreaCells()
+	s.resizePaneTo(dir, max(s.paneManager.ActivePaneSize(dir, width, height)+delta, 1))
+}
+
+// resizePaneTo makes the active pane size lines high, or columns wide for
+// left | right splits. A size of 0 maximizes it.
+func (s *appState) resizePaneTo(dir panes.SplitDirection, size int) {
+	if s.paneManager == nil {
+		return
+	}
+	width, height := s.paneAreaCells()
+	if size == 0 {
+		size = max(width, height)
+	}
+	if err := s.paneManager.ResizeActivePane(dir, size, width, height); err != nil {
+		s.status = fmt.Sprintf("Resize failed: %v", err)
+		return
+	}
+	if dir == panes.SplitHorizontal {
+		s.status = fmt.Sprintf("Pane width: %d columns", s.paneManager.ActivePaneSize(dir, width, height))
+	} else {
+		s.status = fmt.Sprintf("Pane height: %d lines", s.paneManager.ActivePaneSize(dir, width, height))
+	}
+}
+
+// paneAreaCells returns the size of the area the panes share in editor
+// columns and lines, as laid out in the last frame.
+func (s *appState) paneAreaCells() (int, int) {
+	if s.paneCell.X <= 0 || s.paneCell.Y <= 0 {
+		// Not laid out yet; any size will do until the first frame
+		return 80, 24
+	}
+	return s.paneArea.X / s.paneCell.X, s.paneArea.Y / s.paneCell.Y
+}
+
 // handlePaneZoomToggle toggles zoom for the active pane.
 func (s *appState) handlePaneZoomToggle() {
 	if s.paneManager == nil {
@@ -283,3 +444,29 @@ func (s *appState) handlePaneCommand(ev key.Event) {
 		s.status = "Unknown pane command (v=vsplit h=hsplit ==equalize o=zoom)"
 	}
 }
+
+// handleWindoCommand implements :windo, which runs the commands, separated
+// by |, in each pane of the tab page in turn and ends in the last one.
+func (s *appState) handleWindoCommand(args string) {
+	cmds := splitCommandBar(args)
+	if len(cmds) == 0 {
+		s.status = "E471: Argument required"
+		return
+	}
+
+	count := 0
+	for _, pane := range s.paneManager.AllPanes() {
+		if !slices.Contains(s.paneManager.AllPanes(), pane) {
+			// An earlier command closed it
+			continue
+		}
+		s.paneManager.SetActivePane(pane)
+		s.syncActivePaneBuffer()
+		s.syncPaneView()
+		for _, cmd := range cmds {
+			s.runCommand(cmd)
+		}
+		count++
+	}
+	s.status = fmt.Sprintf("windo: %d pane(s) - %s", count, s.status)
+}
@@ -4,6 +4,8 @@ import (
 	"image"
 	"image/color"
 
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/clip"
@@ -23,6 +25,12 @@ func (s *appState) drawPanes(gtx layout.Context) layout.Dimensions {
 		return s.drawBuffer(gtx)
 	}
 
+	s.syncPaneView()
+
+	// Pane sizes are measured in the editor's columns and lines
+	s.paneArea = gtx.Constraints.Max
+	s.paneCell = image.Pt(max(s.measureTextWidth(gtx, "M"), 1), max(gtx.Dp(unit.Dp(20)), 1))
+
 	// If zoomed, just draw the zoomed pane
 	if s.paneManager.IsZoomed() {
 		zoomedPane := s.paneManager.ZoomedPane()
@@ -52,33 +60,35 @@ func (s *appState) renderPaneNode(gtx layout.Context, node *panes.PaneNode) layo
 	}
 
 	// Internal node: render split with separator
+	size := gtx.Constraints.Max
+	extent, cell := size.Y, s.paneCell.Y
+	if node.Split == panes.SplitHorizontal {
+		extent, cell = size.X, s.paneCell.X
+	}
+	first, second := node.SplitSizes(extent, 1, cell)
+
 	if node.Split == panes.SplitHorizontal {
 		// Left | Right split (vertical divider)
-		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
-			layout.Flexed(node.Ratio, func(gtx layout.Context) layout.Dimensions {
-				return s.renderPaneNode(gtx, node.Left)
-			}),
-			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				return s.drawPaneSeparator(gtx, true)
-			}),
-			layout.Flexed(1-node.Ratio, func(gtx layout.Context) layout.Dimensions {
-				return s.renderPaneNode(gtx, node.Right)
-			}),
-		)
+		s.renderPaneChild(gtx, node.Left, image.Point{}, image.Pt(first, size.Y))
+		s.renderPaneChild(gtx, node.Right, image.Pt(first+1, 0), image.Pt(second, size.Y))
 	} else {
 		// Top / Bottom split (horizontal divider)
-		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
-			layout.Flexed(node.Ratio, func(gtx layout.Context) layout.Dimensions {
-				return s.renderPaneNode(gtx, node.Left)
-			}),
-			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				return s.drawPaneSeparator(gtx, false)
-			}),
-			layout.Flexed(1-node.Ratio, func(gtx layout.Context) layout.Dimensions {
-				return s.renderPaneNode(gtx, node.Right)
-			}),
-		)
+		s.renderPaneChild(gtx, node.Left, image.Point{}, image.Pt(size.X, first))
+		s.renderPaneChild(gtx, node.Right, image.Pt(0, first+1), image.Pt(size.X, second))
 	}
+
+	// The separator comes last so dragging it wins over the panes' own
+	// mouse handling near the edge
+	s.drawPaneSeparator(gtx, node, first, extent, cell)
+	return layout.Dimensions{Size: size}
+}
+
+// renderPaneChild renders one side of a split at offset, at the given size.
+func (s *appState) renderPaneChild(gtx layout.Context, node *panes.PaneNode, offset, size image.Point) {
+	defer op.Offset(offset).Push(gtx.Ops).Pop()
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+	gtx.Constraints = layout.Exact(size)
+	s.renderPaneNode(gtx, node)
 }
 
 // drawSinglePane renders a single pane with its buffer content.
@@ -88,7 +98,7 @@ func (s *appState) drawSinglePane(gtx layout.Context, pane *panes.Pane) layout.D
 	}
 
 	// Get buffer for this pane
-	buf := s.bufferMgr.GetBuffer(pane.BufferIndex)
+	buf := s.bufferMgr.GetBuffer(pane.BufferID)
 	if buf == nil {
 		return layout.Dimensions{}
 	}
@@ -113,7 +123,8 @@ func (s *appState) drawSinglePane(gtx layout.Context, pane *panes.Pane) layout.D
 	// but without actually changing the global active pane state (which would
 	// interfere with input handling).
 	//
-	// We temporarily swap the viewport state so drawBuffer renders the right view.
+	// We temporarily swap the viewport, cursor, selection and search state so
+	// drawBuffer renders the pane's own view.
 	wasActive := pane.Active
 	if !wasActive {
 		// Save current viewport state
@@ -128,12 +139,16 @@ func (s *appState) drawSinglePane(gtx layout.Context, pane *panes.Pane) layout.D
 		s.paneManager.SetActivePaneQuiet(pane)
 
 		// Draw buffer content
-		dims := s.drawBuffer(gtx)
+		var dims layout.Dimensions
+		s.withPaneView(pane, buf, func() {
+			dims = s.drawBuffer(gtx)
+		})
 
 		// Restore original active pane (quietly, without triggering side effects)
 		s.paneManager.SetActivePaneQuiet(oldActivePane)
 
-		// Restore viewport state
+		// The pane follows its own cursor when edits elsewhere move it
+		pane.SetViewportTop(s.viewportTopLine)
 		s.viewportTopLine = oldViewportTop
 
 		return dims
@@ -155,28 +170,53 @@ func (s *appState) drawSinglePane(gtx layout.Context, pane *panes.Pane) layout.D
 	return dims
 }
 
-// drawPaneSeparator draws a 1px separator line between panes.
-func (s *appState) drawPaneSeparator(gtx layout.Context, vertical bool) layout.Dimensions {
-	var width, height int
-	if vertical {
-		width = 1
-		height = gtx.Constraints.Max.Y
-	} else {
-		width = gtx.Constraints.Max.X
-		height = 1
+// drawPaneSeparator draws the 1px separator line at pos between the
+// children of a split, extent wide along the split axis. Dragging it with
+// the mouse resizes the split.
+func (s *appState) drawPaneSeparator(gtx layout.Context, node *panes.PaneNode, pos, extent, cell int) {
+	vertical := node.Split == panes.SplitHorizontal
+	for {
+		ev, ok := gtx.Event(pointer.Filter{
+			Target: node,
+			Kinds:  pointer.Press | pointer.Drag | pointer.Release,
+		})
+		if !ok {
+			break
+		}
+		e, ok := ev.(pointer.Event)
+		if !ok || e.Kind != pointer.Drag {
+			continue
+		}
+		if vertical {
+			node.SetFirstSize(int(e.Position.X), extent, 1, cell)
+		} else {
+			node.SetFirstSize(int(e.Position.Y), extent, 1, cell)
+		}
+		s.window.Invalidate()
 	}
 
-	rect := clip.Rect{Max: image.Pt(width, height)}.Push(gtx.Ops)
+	line := image.Rect(0, pos, gtx.Constraints.Max.X, pos+1)
+	cursor := pointer.CursorRowResize
+	if vertical {
+		line = image.Rect(pos, 0, pos+1, gtx.Constraints.Max.Y)
+		cursor = pointer.CursorColResize
+	}
+	rect := clip.Rect(line).Push(gtx.Ops)
 	paint.Fill(gtx.Ops, paneSeparator)
 	rect.Pop()
 
-	return layout.Dimensions{Size: image.Pt(width, height)}
+	// A few pixels either side of the line can be grabbed
+	grab := line.Inset(-gtx.Dp(unit.Dp(3))).Intersect(image.Rectangle{Max: gtx.Constraints.Max})
+	area := clip.Rect(grab).Push(gtx.Ops)
+	cursor.Add(gtx.Ops)
+	event.Op(gtx.Ops, node)
+	area.Pop()
 }
 
 // drawTerminalPane renders a terminal pane
 func (s *appState) drawTerminalPane(gtx layout.Context, pane *panes.Pane, buf *editor.Buffer) layout.Dimensions {
 	// Get terminal instance
-	term, exists := s.terminals[pane.BufferIndex]
+	term, exists := s.terminals[pane.BufferID]
 	if !exists || term == nil {
 		// Terminal not found - show error message
 		label := material.Body1(s.theme, "Terminal not initialized")
@@ -198,11 +238,11 @@ func (s *appState) drawTerminalPane(gtx layout.Context, pane *panes.Pane, buf *e
 	}
 
 	// Draw terminal content
-	return s.drawTerminalContent(gtx, screen, pane.BufferIndex)
+	return s.drawTerminalContent(gtx, screen, pane.BufferID)
 }
 
 // drawTerminalContent renders the terminal screen buffer with viewport scrolling
-func (s *appState) drawTerminalContent(gtx layout.Context, screen *terminal.ScreenBuffer, bufferIndex int) layout.Dimensions {
+func (s *appState) drawTerminalContent(gtx layout.Context, screen *terminal.ScreenBuffer, bufferID int) layout.Dimensions {
 	cols, rows := screen.Dimensions()
 	cursorX, cursorY, cursorStyle := screen.GetCursor()
 
@@ -233,19 +273,36 @@ func (s *appState) drawTerminalContent(gtx layout.Context, screen *terminal.Scre
 	}
 
 	// Ensure cursor is visible (auto-scroll)
-	s.ensureTerminalCursorVisible(bufferIndex, linesPerPage, screen)
+	s.ensureTerminalCursorVisible(bufferID, linesPerPage, screen)
 
 	// Get viewport top line
-	viewportTop, exists := s.terminalViewports[bufferIndex]
+	viewportTop, exists := s.terminalViewports[bufferID]
 	if !exists {
 		viewportTop = 0
-		s.terminalViewports[bufferIndex] = 0
+		s.terminalViewports[bufferID] = 0
 	}
 
-	// Calculate viewport end
-	viewportEnd := viewportTop + linesPerPage
-	if viewportEnd > rows {
-		viewportEnd = rows
+	// Lines are addressed by absolute index so scrollback and screen rows
+	// can be shown together. liveTop is where the view follows the output.
+	view := s.terminalHistoryView(bufferID)
+	first, screenTop := screen.HistoryRange()
+	view.liveTop = screenTop + viewportTop
+	view.pageLines = linesPerPage
+	s.handleTerminalPointer(gtx, bufferID, view, screen, charWidth, charHeight)
+	if view.scrolled && view.top < first {
+		// The lines shown were dropped from the scrollback
+		view.top = first
+	}
+	top := view.terminalHistoryTop()
+	cursorLine := screenTop + cursorY
+
+	// Other palettes than the default, which matches the pane background,
+	// color the margins too
+	defaultFG, defaultBG, termCursorColor := screen.Colors()
+	if defaultBG != terminal.DefaultBG {
+		background := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+		paint.Fill(gtx.Ops, defaultBG)
+		background.Pop()
 	}
 
 	inset := layout.Inset{
@@ -256,57 +313,57 @@ func (s *appState) drawTerminalContent(gtx layout.Context, screen *terminal.Scre
 	}
 
 	return inset.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-		// Draw only visible lines in viewport
-		for y := viewportTop; y < viewportEnd; y++ {
-			line := screen.GetLine(y)
-			for x := 0; x < len(line.Cells) && x < cols; x++ {
-				cell := line.Cells[x]
-
-				// Calculate cell position (adjusted for viewport)
-				cellX := x * charWidth
-				cellY := (y - viewportTop) * charHeight // Subtract viewportTop to adjust Y position
-
-				// Draw cell background
-				bgRect := clip.Rect{
-					Min: image.Pt(cellX, cellY),
-					Max: image.Pt(cellX+charWidth, cellY+charHeight),
-				}.Push(gtx.Ops)
-				paint.Fill(gtx.Ops, cell.BG)
-				bgRect.Pop()
-
-				// Draw cursor if at this position
-				if x == cursorX && y == cursorY && cursorStyle == terminal.CursorBlock {
-					cursorRect := clip.Rect{
-						Min: image.Pt(cellX, cellY),
-						Max: image.Pt(cellX+charWidth, cellY+charHeight),
-					}.Push(gtx.Ops)
-					paint.Fill(gtx.Ops, cursorColor)
-					cursorRect.Pop()
-				}
-
-				// Draw character
-				char := cell.Rune
-				if char == 0 {
-					char = ' '
-				}
-
-				label := material.Body1(s.theme, string(char))
-				label.Font.Typeface = "JetBrainsMono"
+		// Receive mouse wheel and click events over the terminal content
+		area := clip.Rect{Max: image.Pt(cols*charWidth, linesPerPage*charHeight)}.Push(gtx.Ops)
+		event.Op(gtx.Ops, view)
+		area.Pop()
+
+		// Draw only visible lines in viewport. Screen rows without a cursor,
+		// selection or search match are drawn from the last frame unless the
+		// terminal marked them dirty.
+		rowStyle := terminalRowStyle{
+			cols: cols, charWidth: charWidth, charHeight: charHeight,
+			fg: defaultFG, bg: defaultBG, cursor: termCursorColor,
+		}
+		view.rows.prepare(rowStyle, rows)
+		for row := 0; row < linesPerPage; row++ {
+			abs := top + row
+			line := screen.ViewLine(abs)
+			y := abs - screenTop
+			cursorCol := -1
+			if view.copyMode && abs == view.cursor.Line {
+				cursorCol = view.cursor.Col
+			} else if !view.copyMode && abs == cursorLine && cursorStyle == terminal.CursorBlock {
+				cursorCol = cursorX
+			}
 
-				// Use cell foreground color (or cursor color if cursor is here)
-				if x == cursorX && y == cursorY && cursorStyle == terminal.CursorBlock {
-					// Invert color for cursor
-					label.Color = color.NRGBA{R: 0x00, G: 0x00, B: 0x00, A: 0xff}
-				} else {
-					label.Color = cell.FG
+			offset := op.Offset(image.Pt(0, row*charHeight)).Push(gtx.Ops)
+			if y < 0 || y >= rows || cursorCol >= 0 || view.rowHighlighted(abs, cols) {
+				s.drawTerminalRow(gtx, view, line, abs, cursorCol, rowStyle)
+			} else {
+				entry := &view.rows.rows[y]
+				if line.Dirty || !entry.ok {
+					entry.ops.Reset()
+					recGtx := gtx
+					recGtx.Ops = &entry.ops
+					macro := op.Record(recGtx.Ops)
+					s.drawTerminalRow(recGtx, view, line, abs, -1, rowStyle)
+					entry.call = macro.Stop()
+					entry.ok = true
 				}
-
-				// Position and draw the character
-				offset := op.Offset(image.Pt(cellX, cellY)).Push(gtx.Ops)
-				label.Layout(gtx)
-				offset.Pop()
+				entry.call.Add(gtx.Ops)
+				entry.drawn = true
 			}
+			offset.Pop()
 		}
+		// Rows not recorded this frame may change unseen once marked clean
+		view.rows.dropUndrawn()
+
+		// Exit status of finished commands, next to their prompts
+		s.drawPromptMarks(gtx, screen, top, linesPerPage, charHeight)
+
+		// Everything changed so far is on screen now
+		screen.MarkClean()
 
 		// Return dimensions based on visible area
 		return layout.Dimensions{
@@ -314,3 +371,135 @@ func (s *appState) drawTerminalContent(gtx layout.Context, screen *terminal.Scre
 		}
 	})
 }
+
+// terminalRowStyle is what the drawing of a terminal row depends on besides
+// its cells.
+type terminalRowStyle struct {
+	cols, charWidth, charHeight int
+	fg, bg, cursor              color.NRGBA
+}
+
+// terminalRows keeps the drawing of each screen row of a terminal, so rows
+// the terminal didn't mark dirty aren't laid out again every frame.
+type terminalRows struct {
+	style terminalRowStyle
+	rows  []terminalRow
+}
+
+// terminalRow is a recorded screen row; ok is false when it has to be
+// recorded again.
+type terminalRow struct {
+	ops   op.Ops
+	call  op.CallOp
+	ok    bool
+	drawn bool // Drawn from the recording this frame
+}
+
+// prepare drops the recorded rows if the style or the screen height
+// changed.
+func (r *terminalRows) prepare(style terminalRowStyle, rows int) {
+	if r.style != style || len(r.rows) != rows {
+		r.style = style
+		r.rows = make([]terminalRow, rows)
+	}
+}
+
+// dropUndrawn forgets the rows that weren't drawn from their recording this
+// frame: their dirty flags are about to be cleared without them being
+// recorded again.
+func (r *terminalRows) dropUndrawn() {
+	for i := range r.rows {
+		if !r.rows[i].drawn {
+			r.rows[i].ok = false
+		}
+		r.rows[i].drawn = false
+	}
+}
+
+// rowHighlighted reports whether any cell of line abs is selected or part of
+// a search match.
+func (v *terminalHistoryView) rowHighlighted(abs, cols int) bool {
+	for x := 0; x < cols; x++ {
+		if matched, _ := v.terminalMatchAt(abs, x); matched || v.selectionContains(abs, x) {
+			return true
+		}
+	}
+	return false
+}
+
+// drawTerminalRow draws line abs of a terminal at the top of gtx, with the
+// cursor at column cursorCol, or none if it is negative.
+func (s *appState) drawTerminalRow(gtx layout.Context, view *terminalHistoryView, line terminal.Line, abs, cursorCol int, style terminalRowStyle) {
+	charWidth, charHeight := style.charWidth, style.charHeight
+	for x := 0; x < style.cols; x++ {
+		// Scrollback lines are stored without their trailing blanks
+		cell := terminal.Cell{Rune: ' ', FG: style.fg, BG: style.bg}
+		if x < len(line.Cells) {
+			cell = line.Cells[x]
+		}
+		if cell.Spacer {
+			// Drawn with the first half of the wide character
+			continue
+		}
+
+		// A wide character takes two cells
+		cellX := x * charWidth
+		cellW := charWidth
+		if cell.Wide {
+			cellW *= 2
+		}
+		cellRect := clip.Rect{
+			Min: image.Pt(cellX, 0),
+			Max: image.Pt(cellX+cellW, charHeight),
+		}
+
+		// Draw cell background, then selection and search highlights
+		bg := cell.BG
+		if matched, current := view.terminalMatchAt(abs, x); current {
+			bg = currentMatchColor
+		} else if matched {
+			bg = searchMatchColor
+		}
+		if view.selectionContains(abs, x) {
+			bg = selectionColor
+		}
+		bgRect := cellRect.Push(gtx.Ops)
+		paint.Fill(gtx.Ops, bg)
+		bgRect.Pop()
+
+		isCursor := x == cursorCol
+		if isCursor {
+			cursorRect := cellRect.Push(gtx.Ops)
+			paint.Fill(gtx.Ops, style.cursor)
+			cursorRect.Pop()
+		}
+
+		// Underline hyperlinks
+		if cell.Link != "" {
+			underline := clip.Rect{
+				Min: image.Pt(cellX, charHeight-max(charHeight/16, 1)),
+				Max: image.Pt(cellX+cellW, charHeight),
+			}.Push(gtx.Ops)
+			paint.Fill(gtx.Ops, cell.FG)
+			underline.Pop()
+		}
+
+		// Draw the character with its combining marks
+		if (cell.Rune == 0 || cell.Rune == ' ') && cell.Marks == "" {
+			continue
+		}
+
+		label := material.Body1(s.theme, cell.Grapheme())
+		label.Font.Typeface = "JetBrainsMono"
+		if isCursor {
+			// Invert color for cursor
+			label.Color = style.bg
+		} else {
+			label.Color = cell.FG
+		}
+
+		offset := op.Offset(image.Pt(cellX, 0)).Push(gtx.Ops)
+		label.Layout(gtx)
+		offset.Pop()
+	}
+}
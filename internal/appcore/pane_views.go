@@ -0,0 +1,177 @@
+package appcore
+
+import (
+	"github.com/javanhut/vem/internal/editor"
+	"github.com/javanhut/vem/internal/panes"
+)
+
+// Pane views: every pane keeps its own cursor, visual selection and search
+// over the buffer it shows. The active pane works on the live state, the
+// buffer's cursor and the visual and search fields of appState. The others
+// keep theirs in a paneView, with marks so edits made through another pane
+// move them along with the text.
+
+// paneView is the state of a pane while it isn't the active one.
+type paneView struct {
+	buffer      *editor.Buffer
+	cursor      *editor.Mark
+	visualMode  visualModeType
+	visualStart *editor.Mark
+	search      searchState
+}
+
+// searchState is the search a pane last ran and the matches it found.
+type searchState struct {
+	pattern string
+	matches []SearchMatch
+	current int
+	active  bool
+}
+
+// release stops the view's marks from tracking edits.
+func (v *paneView) release() {
+	v.cursor.Release()
+	v.visualStart.Release()
+}
+
+// saveSearch returns the live search state.
+func (s *appState) saveSearch() searchState {
+	return searchState{
+		pattern: s.searchPattern,
+		matches: s.searchMatches,
+		current: s.currentMatchIdx,
+		active:  s.searchActive,
+	}
+}
+
+// restoreSearch makes search the live search state.
+func (s *appState) restoreSearch(search searchState) {
+	s.searchPattern = search.pattern
+	s.searchMatches = search.matches
+	s.currentMatchIdx = search.current
+	s.searchActive = search.active
+}
+
+// syncPaneView swaps the live state over when another pane became active:
+// the pane that was active keeps its cursor, selection and search in its
+// view, and the active pane gets back the ones it had. A pane that is new,
+// or shows another buffer than it did, starts without a selection and
+// with the search that was live.
+func (s *appState) syncPaneView() {
+	if s.paneManager == nil || s.bufferMgr == nil {
+		return
+	}
+	pane := s.paneManager.ActivePane()
+	if pane == s.livePane {
+		return
+	}
+	if s.paneViews == nil {
+		s.paneViews = make(map[*panes.Pane]*paneView)
+	}
+
+	if old := s.livePane; old != nil {
+		s.saveLivePane(old)
+	}
+	s.livePane = pane
+	s.pruneViews()
+	if pane == nil {
+		return
+	}
+
+	buf := s.bufferMgr.GetBuffer(pane.BufferID)
+	view := s.paneViews[pane]
+	delete(s.paneViews, pane)
+	s.visualMode = visualModeNone
+	s.visualStartLine, s.visualStartCol = 0, 0
+	if view != nil {
+		view.release()
+		if view.buffer == buf {
+			buf.SetCursor(view.cursor.Cursor)
+			if view.visualMode != visualModeNone {
+				s.visualMode = view.visualMode
+				s.visualStartLine, s.visualStartCol = view.visualStart.Line, view.visualStart.Col
+			}
+		}
+		s.restoreSearch(view.search)
+	}
+	if s.searchActive && s.searchPattern != "" && buf != nil && !buf.IsTerminal() {
+		// The buffer may have changed since the matches were found
+		s.searchMatches = s.findAllMatches(s.searchPattern)
+		if s.currentMatchIdx >= len(s.searchMatches) {
+			s.currentMatchIdx = len(s.searchMatches) - 1
+		}
+	}
+
+	switch {
+	case s.mode == modeNormal && s.visualMode != visualModeNone:
+		s.mode = modeVisual
+	case s.mode == modeVisual && s.visualMode == visualModeNone:
+		s.mode = modeNormal
+	}
+}
+
+// saveLivePane keeps the live state in pane's view as it stops being the
+// active pane.
+func (s *appState) saveLivePane(pane *panes.Pane) {
+	buf := s.bufferMgr.GetBuffer(pane.BufferID)
+	if buf == nil || buf.IsTerminal() {
+		return
+	}
+	view := &paneView{
+		buffer:     buf,
+		cursor:     buf.NewMark(buf.Cursor()),
+		visualMode: s.visualMode,
+		search:     s.saveSearch(),
+	}
+	if s.visualMode != visualModeNone {
+		view.visualStart = buf.NewMark(editor.Cursor{Line: s.visualStartLine, Col: s.visualStartCol})
+	}
+	s.paneViews[pane] = view
+}
+
+// pruneViews drops the views of panes that were closed.
+func (s *appState) pruneViews() {
+	if s.tabs == nil {
+		return
+	}
+	open := make(map[*panes.Pane]bool)
+	for _, pm := range s.tabs.All() {
+		for _, pane := range pm.AllPanes() {
+			open[pane] = true
+		}
+	}
+	for pane, view := range s.paneViews {
+		if !open[pane] {
+			view.release()
+			delete(s.paneViews, pane)
+		}
+	}
+}
+
+// withPaneView runs draw with pane's view made the live state, so an
+// inactive pane renders its own cursor, selection and search, and puts the
+// live state back afterwards.
+func (s *appState) withPaneView(pane *panes.Pane, buf *editor.Buffer, draw func()) {
+	view := s.paneViews[pane]
+	if view == nil || view.buffer != buf {
+		draw()
+		return
+	}
+
+	cursor := buf.Cursor()
+	visualMode, startLine, startCol := s.visualMode, s.visualStartLine, s.visualStartCol
+	search := s.saveSearch()
+
+	buf.SetCursor(view.cursor.Cursor)
+	s.visualMode = view.visualMode
+	if view.visualMode != visualModeNone {
+		s.visualStartLine, s.visualStartCol = view.visualStart.Line, view.visualStart.Col
+	}
+	s.restoreSearch(view.search)
+
+	draw()
+
+	buf.SetCursor(cursor)
+	s.visualMode, s.visualStartLine, s.visualStartCol = visualMode, startLine, startCol
+	s.restoreSearch(search)
+}
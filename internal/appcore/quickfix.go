@@ -0,0 +1,142 @@
+package appcore
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// QuickfixEntry is one location in the quickfix list.
+type QuickfixEntry struct {
+	Path string // Absolute file path
+	Line int    // 1-based line, 0 when unknown
+	Col  int    // 1-based column, 0 when unknown
+	Text string // Message shown next to the location
+}
+
+// setQuickfixList replaces the quickfix list.
+func (s *appState) setQuickfixList(entries []QuickfixEntry) {
+	s.quickfixList = entries
+	s.quickfixIdx = 0
+}
+
+// quickfixJump opens the entry at idx and moves the cursor to its location.
+func (s *appState) quickfixJump(idx int) {
+	if len(s.quickfixList) == 0 {
+		s.status = "E42: No Errors"
+		return
+	}
+	if idx < 0 || idx >= len(s.quickfixList) {
+		s.status = "E553: No more items"
+		return
+	}
+
+	entry := s.quickfixList[idx]
+	if _, err := s.bufferMgr.OpenFile(entry.Path); err != nil {
+		s.status = fmt.Sprintf("Error opening %s: %v", entry.Path, err)
+		return
+	}
+	s.showBufferInActivePane(s.bufferMgr.ActiveID())
+	s.quickfixIdx = idx
+
+	s.gotoLineCol(entry.Line, entry.Col)
+
+	s.status = fmt.Sprintf("(%d of %d) %s", idx+1, len(s.quickfixList), entry.Text)
+}
+
+// gotoLineCol moves the cursor to a 1-based line and column; 0 leaves the
+// line or column as it is.
+func (s *appState) gotoLineCol(line, col int) {
+	if line > 0 {
+		s.gotoLine(line)
+	}
+	if buf := s.activeBuffer(); buf != nil && col > 1 {
+		buf.JumpLineStart()
+		for i := 1; i < col; i++ {
+			buf.MoveRight()
+		}
+	}
+}
+
+// formatQuickfixEntry renders an entry the way :clist and :copen show it.
+func (s *appState) formatQuickfixEntry(entry QuickfixEntry) string {
+	path := entry.Path
+	if s.fileTree != nil {
+		if rel, err := filepath.Rel(s.fileTree.CurrentPath(), path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+
+	location := path
+	if entry.Line > 0 {
+		location += ":" + strconv.Itoa(entry.Line)
+		if entry.Col > 0 {
+			location += ":" + strconv.Itoa(entry.Col)
+		}
+	}
+	if entry.Text == "" || entry.Text == path {
+		return location
+	}
+	return location + ": " + entry.Text
+}
+
+// handleQuickfixCommand runs the :c* quickfix commands.
+func (s *appState) handleQuickfixCommand(name, args string) {
+	switch name {
+	case "cn", "cnext":
+		s.quickfixJump(s.quickfixIdx + 1)
+	case "cp", "cprev", "cprevious":
+		s.quickfixJump(s.quickfixIdx - 1)
+	case "cfirst", "cr", "crewind":
+		s.quickfixJump(0)
+	case "clast", "cla":
+		s.quickfixJump(len(s.quickfixList) - 1)
+	case "cc":
+		idx := s.quickfixIdx
+		if args != "" {
+			n, err := strconv.Atoi(args)
+			if err != nil {
+				s.status = fmt.Sprintf("Invalid quickfix entry: %s", args)
+				return
+			}
+			idx = n - 1
+		}
+		s.quickfixJump(idx)
+	case "cl", "clist":
+		if len(s.quickfixList) == 0 {
+			s.status = "E42: No Errors"
+			return
+		}
+		items := make([]string, len(s.quickfixList))
+		for i, entry := range s.quickfixList {
+			items[i] = fmt.Sprintf("%d %s", i+1, s.formatQuickfixEntry(entry))
+		}
+		s.status = "Quickfix: " + strings.Join(items, " | ")
+	case "copen", "cope":
+		s.openQuickfixBuffer()
+	}
+}
+
+// openQuickfixBuffer shows the quickfix list in a read-only buffer.
+func (s *appState) openQuickfixBuffer() {
+	if len(s.quickfixList) == 0 {
+		s.status = "E42: No Errors"
+		return
+	}
+
+	lines := make([]string, len(s.quickfixList))
+	for i, entry := range s.quickfixList {
+		lines[i] = s.formatQuickfixEntry(entry)
+	}
+	bufID := s.bufferMgr.CreateBufferWithContent(strings.Join(lines, "\n"))
+	if buf := s.bufferMgr.GetBuffer(bufID); buf != nil {
+		buf.SetFilePath("[Quickfix]")
+		buf.SetReadOnly(true)
+		buf.SetListed(false)
+		buf.MoveToLine(s.quickfixIdx)
+	}
+	s.showBufferInActivePane(bufID)
+
+	s.status = fmt.Sprintf("Quickfix: %d entries (:cc N to jump, :q to close)", len(s.quickfixList))
+}
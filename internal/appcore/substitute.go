@@ -0,0 +1,191 @@
+package appcore
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// substituteCommand matches :[range]s/pattern/replacement/[flags], with
+// any punctuation in place of the slashes.
+var substituteCommand = regexp.MustCompile(`^([%.$0-9,]*)s(?:ubstitute)?([^\w\s"|])(.*)$`)
+
+// handleSubstituteCommand runs cmd if it is a :substitute command and
+// reports whether it was. The pattern is a Go regular expression; an empty
+// one reuses the last search. In the replacement & and \0 stand for the
+// match and \1 to \9 for its groups. The flags are g for every match on a
+// line, i and I to ignore case or not, and e to say nothing when there is
+// no match.
+func (s *appState) handleSubstituteCommand(cmd string) bool {
+	m := substituteCommand.FindStringSubmatch(cmd)
+	if m == nil {
+		return false
+	}
+	parts := splitDelimited(m[3], m[2][0])
+	pattern, repl, flags := parts[0], "", ""
+	if len(parts) > 1 {
+		repl = parts[1]
+	}
+	if len(parts) > 2 {
+		flags = parts[2]
+	}
+
+	buf := s.activeBuffer()
+	switch {
+	case buf == nil:
+		s.status = "No active buffer"
+		return true
+	case buf.IsTerminal():
+		s.status = "Cannot substitute in a terminal buffer"
+		return true
+	case buf.IsReadOnly():
+		s.status = "E21: Cannot make changes, buffer is read-only"
+		return true
+	}
+
+	start, end, err := s.commandRange(m[1])
+	if err != nil {
+		s.status = err.Error()
+		return true
+	}
+
+	global, quiet, ignoreCase := false, false, false
+	for _, f := range flags {
+		switch f {
+		case 'g':
+			global = true
+		case 'e':
+			quiet = true
+		case 'i':
+			ignoreCase = true
+		case 'I':
+			ignoreCase = false
+		default:
+			s.status = fmt.Sprintf("E488: Trailing characters: %s", flags)
+			return true
+		}
+	}
+
+	if pattern == "" {
+		// Searches match literally and ignore case
+		if s.searchPattern == "" {
+			s.status = "E35: No previous regular expression"
+			return true
+		}
+		pattern, ignoreCase = regexp.QuoteMeta(s.searchPattern), true
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		s.status = fmt.Sprintf("Invalid pattern: %v", err)
+		return true
+	}
+
+	count := buf.Substitute(re, vimReplacement(repl), start, end, global)
+	switch {
+	case count > 0:
+		s.status = fmt.Sprintf("%d substitution(s)", count)
+	case quiet:
+		s.status = "No match"
+	default:
+		s.status = fmt.Sprintf("E486: Pattern not found: %s", parts[0])
+	}
+	return true
+}
+
+// commandRange returns the lines (0-based, inclusive) an Ex command range
+// covers: % for the whole buffer, or one or two addresses separated by a
+// comma, each a line number, . for the cursor line or $ for the last line.
+// No range is the cursor line.
+func (s *appState) commandRange(spec string) (int, int, error) {
+	buf := s.activeBuffer()
+	cur, last := buf.Cursor().Line, buf.LineCount()-1
+	if spec == "%" {
+		return 0, last, nil
+	}
+
+	address := func(a string) (int, error) {
+		switch a {
+		case "", ".":
+			return cur, nil
+		case "$":
+			return last, nil
+		}
+		n, err := strconv.Atoi(a)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("E14: Invalid address: %s", a)
+		}
+		return max(0, min(n-1, last)), nil
+	}
+
+	from, to, _ := strings.Cut(spec, ",")
+	start, err := address(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	end := start
+	if strings.Contains(spec, ",") {
+		if end, err = address(to); err != nil {
+			return 0, 0, err
+		}
+	}
+	return min(start, end), max(start, end), nil
+}
+
+// splitDelimited splits the text after the first delimiter of a
+// :substitute command at the unescaped delimiters, into at most the
+// pattern, the replacement and the flags. An escaped delimiter stands for
+// itself; other backslashes are kept.
+func splitDelimited(text string, delim byte) []string {
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && i+1 < len(text) && text[i+1] == delim:
+			part.WriteByte(delim)
+			i++
+		case text[i] == '\\' && i+1 < len(text):
+			part.WriteString(text[i : i+2])
+			i++
+		case text[i] == delim && len(parts) < 2:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(text[i])
+		}
+	}
+	return append(parts, part.String())
+}
+
+// vimReplacement turns a Vim replacement string into the template
+// regexp.Regexp.Expand takes.
+func vimReplacement(repl string) string {
+	var sb strings.Builder
+	for i := 0; i < len(repl); i++ {
+		c := repl[i]
+		switch {
+		case c == '\\' && i+1 < len(repl):
+			i++
+			switch n := repl[i]; {
+			case n >= '0' && n <= '9':
+				sb.WriteString("${" + string(n) + "}")
+			case n == 't':
+				sb.WriteByte('\t')
+			case n == '$':
+				sb.WriteString("$$")
+			default:
+				sb.WriteByte(n)
+			}
+		case c == '&':
+			sb.WriteString("${0}")
+		case c == '$':
+			sb.WriteString("$$")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,36 @@
+package appcore
+
+import (
+	"testing"
+
+	"github.com/javanhut/vem/internal/editor"
+	"github.com/javanhut/vem/internal/panes"
+)
+
+func TestSubstituteMovesOtherPaneCursor(t *testing.T) {
+	buf := editor.NewBuffer("old old target\nkeep")
+	bufferMgr := editor.NewBufferManagerWithBuffer(buf)
+	tabs := panes.NewTabPages(buf.ID())
+	s := &appState{bufferMgr: bufferMgr, tabs: tabs, paneManager: tabs.Active(), mode: modeNormal}
+
+	// The first pane sits on "target", then a second pane on the same
+	// buffer runs :s on that line
+	first := s.paneManager.ActivePane()
+	s.syncPaneView()
+	buf.SetCursor(editor.Cursor{Line: 0, Col: 8})
+	if err := s.paneManager.SplitVertical(buf.ID()); err != nil {
+		t.Fatal(err)
+	}
+	s.syncPaneView()
+	buf.SetCursor(editor.Cursor{Line: 1, Col: 0})
+	s.runCommand("%s/old/n/g")
+	if got, want := buf.Line(0), "n n target"; got != want {
+		t.Fatalf("line got %q want %q (status %q)", got, want, s.status)
+	}
+
+	s.paneManager.SetActivePane(first)
+	s.syncPaneView()
+	if got, want := buf.Cursor(), (editor.Cursor{Line: 0, Col: 4}); got != want {
+		t.Fatalf("first pane's cursor got %+v want %+v", got, want)
+	}
+}
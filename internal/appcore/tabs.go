@@ -0,0 +1,238 @@
+package appcore
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gioui.org/font"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"github.com/javanhut/vem/internal/panes"
+)
+
+// Tab pages: each tab has its own pane layout, and s.paneManager is the
+// layout of the current one. The tab line above the panes shows up once
+// there is more than one tab.
+
+var (
+	tabLineBg       = statusBg
+	activeTabBg     = activePaneBg
+	activeTabText   = headerColor
+	inactiveTabText = color.NRGBA{R: 0x88, G: 0x88, B: 0x88, A: 0xff}
+)
+
+// handleTabNewCommand implements :tabnew [file], which opens a tab page
+// after the current one with an empty buffer, or the file.
+func (s *appState) handleTabNewCommand(path string) {
+	bufID := s.bufferMgr.ActiveID()
+	if path == "" {
+		bufID = s.bufferMgr.CreateEmptyBuffer()
+	}
+	s.tabs.New(bufID)
+	s.syncActiveTab()
+	if path != "" {
+		// Opening the file replaces the buffer the new pane shows
+		s.handleEditCommand(path)
+		return
+	}
+	s.status = fmt.Sprintf("Tab page %d/%d", s.tabs.ActiveIndex()+1, s.tabs.Count())
+}
+
+// handleTabCloseCommand implements :tabclose. The buffers shown in the tab
+// stay open.
+func (s *appState) handleTabCloseCommand() {
+	if err := s.tabs.Close(); err != nil {
+		s.status = fmt.Sprintf("Tab close failed: %v", err)
+		return
+	}
+	s.syncActiveTab()
+	s.status = fmt.Sprintf("Tab page closed - %d remaining", s.tabs.Count())
+}
+
+// handleTabMoveCommand implements :tabmove [N]: N moves the current tab
+// after tab N (0 makes it the first), +N and -N move it by N, and no N
+// makes it the last.
+func (s *appState) handleTabMoveCommand(args string) {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		s.tabs.Move(s.tabs.Count() - 1)
+	} else {
+		n, err := strconv.Atoi(args)
+		if err != nil {
+			s.status = fmt.Sprintf("Invalid tab position: %s", args)
+			return
+		}
+		if strings.HasPrefix(args, "+") || strings.HasPrefix(args, "-") {
+			s.tabs.Move(s.tabs.ActiveIndex() + n)
+		} else {
+			s.tabs.MoveAfter(n)
+		}
+	}
+	s.status = fmt.Sprintf("Tab page %d/%d", s.tabs.ActiveIndex()+1, s.tabs.Count())
+}
+
+// handleTabNextCommand implements :tabnext [N], which goes to tab N or the
+// next one.
+func (s *appState) handleTabNextCommand(args string) {
+	if args == "" {
+		s.gotoTab(true)
+		return
+	}
+	n, err := strconv.Atoi(args)
+	if err != nil {
+		s.status = fmt.Sprintf("Invalid tab number: %s", args)
+		return
+	}
+	s.selectTab(n - 1)
+}
+
+// gotoTab implements gt and gT: without a count they go to the next or
+// previous tab, wrapping around. A count makes gt go to that tab and gT
+// go back that many tabs.
+func (s *appState) gotoTab(forward bool) {
+	count := s.consumeCount(0)
+	if s.tabs.Count() <= 1 {
+		s.status = "Only one tab page"
+		return
+	}
+	switch {
+	case forward && count > 0:
+		if err := s.tabs.Select(count - 1); err != nil {
+			s.status = fmt.Sprintf("Tab: %v", err)
+			return
+		}
+	case forward:
+		s.tabs.Cycle(1)
+	default:
+		s.tabs.Cycle(-max(count, 1))
+	}
+	s.syncActiveTab()
+	s.status = fmt.Sprintf("Tab page %d/%d", s.tabs.ActiveIndex()+1, s.tabs.Count())
+}
+
+// selectTab makes tab page i (0-based) current.
+func (s *appState) selectTab(i int) {
+	if err := s.tabs.Select(i); err != nil {
+		s.status = fmt.Sprintf("Tab: %v", err)
+		return
+	}
+	s.syncActiveTab()
+	s.status = fmt.Sprintf("Tab page %d/%d", s.tabs.ActiveIndex()+1, s.tabs.Count())
+}
+
+// syncActiveTab points the editor at the current tab's layout after the
+// tab changed, and makes the buffer of its active pane the active one.
+func (s *appState) syncActiveTab() {
+	s.paneManager = s.tabs.Active()
+	s.syncActivePaneBuffer()
+	if s.mode == modeTerminal {
+		// The terminal belonged to the other tab
+		s.mode = modeNormal
+	}
+	s.syncPaneView()
+}
+
+// tabLabel returns the text shown for a tab in the tab line: its number,
+// the pane count when split, the active pane's file and a + when a buffer
+// in the tab has unsaved changes.
+func (s *appState) tabLabel(i int, pm *panes.PaneManager) string {
+	label := strconv.Itoa(i + 1)
+	if n := pm.PaneCount(); n > 1 {
+		label += fmt.Sprintf(" [%d]", n)
+	}
+
+	name := "[No Name]"
+	if pane := pm.ActivePane(); pane != nil {
+		if buf := s.bufferMgr.GetBuffer(pane.BufferID); buf != nil {
+			switch {
+			case buf.FilePath() != "":
+				name = filepath.Base(buf.FilePath())
+			case buf.IsTerminal():
+				name = "[Terminal]"
+			}
+		}
+	}
+	label += " " + name
+
+	for _, pane := range pm.AllPanes() {
+		if buf := s.bufferMgr.GetBuffer(pane.BufferID); buf != nil && buf.Modified() && !buf.IsTerminal() {
+			label += " +"
+			break
+		}
+	}
+	return label
+}
+
+// drawTabLine draws the tab line above the panes when there is more than
+// one tab page. Clicking a tab makes it current.
+func (s *appState) drawTabLine(gtx layout.Context) layout.Dimensions {
+	if s.tabs == nil || s.tabs.Count() <= 1 {
+		return layout.Dimensions{}
+	}
+
+	tabs := s.tabs.All()
+	for i, pm := range tabs {
+		for {
+			ev, ok := gtx.Event(pointer.Filter{Target: pm, Kinds: pointer.Press})
+			if !ok {
+				break
+			}
+			if e, ok := ev.(pointer.Event); ok && e.Buttons == pointer.ButtonPrimary {
+				s.selectTab(i)
+			}
+		}
+	}
+
+	children := make([]layout.FlexChild, len(tabs))
+	for i, pm := range tabs {
+		active := i == s.tabs.ActiveIndex()
+		children[i] = layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			label := material.Body2(s.theme, s.tabLabel(i, pm))
+			label.Font.Typeface = "JetBrainsMono"
+			label.Color = inactiveTabText
+			if active {
+				label.Color = activeTabText
+				label.Font.Weight = font.Bold
+			}
+
+			macro := op.Record(gtx.Ops)
+			inset := layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(12), Right: unit.Dp(12)}
+			dims := inset.Layout(gtx, label.Layout)
+			call := macro.Stop()
+
+			area := clip.Rect{Max: dims.Size}.Push(gtx.Ops)
+			if active {
+				paint.Fill(gtx.Ops, activeTabBg)
+			}
+			event.Op(gtx.Ops, pm)
+			pointer.CursorPointer.Add(gtx.Ops)
+			area.Pop()
+			call.Add(gtx.Ops)
+			return dims
+		})
+	}
+
+	macro := op.Record(gtx.Ops)
+	dims := layout.Flex{Axis: layout.Horizontal}.Layout(gtx, children...)
+	call := macro.Stop()
+
+	rect := clip.Rect{Max: image.Pt(gtx.Constraints.Max.X, dims.Size.Y)}.Push(gtx.Ops)
+	paint.Fill(gtx.Ops, tabLineBg)
+	rect.Pop()
+
+	call.Add(gtx.Ops)
+	return layout.Dimensions{
+		Size: image.Pt(gtx.Constraints.Max.X, dims.Size.Y),
+	}
+}
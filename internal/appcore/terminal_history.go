@@ -0,0 +1,621 @@
+package appcore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+
+	"github.com/javanhut/vem/internal/terminal"
+)
+
+// terminalPos is a position in a terminal's history. Line is an absolute
+// line index as returned by ScreenBuffer.HistoryRange, Col a cell index.
+type terminalPos struct {
+	Line int
+	Col  int
+}
+
+// terminalMatch is a search match in a terminal's history.
+type terminalMatch struct {
+	Line int
+	Col  int
+	Len  int
+}
+
+// terminalHistoryView holds the scrollback position, copy mode and search
+// state of one terminal buffer.
+type terminalHistoryView struct {
+	top       int     // Absolute line shown at the top while scrolled back
+	scrolled  bool    // Showing history instead of following the output
+	liveTop   int     // Top line when following the output, updated every frame
+	pageLines int     // Lines visible on the last frame
+	wheel     float32 // Wheel scroll not yet turned into whole lines
+
+	copyMode bool
+	cursor   terminalPos
+	visual   visualModeType
+	anchor   terminalPos
+	pendingG bool
+
+	pendingBracket key.Name // "[" or "]" waiting for a second one, see terminal_shell.go
+
+	pattern  string
+	matches  []terminalMatch
+	matchIdx int
+
+	// Mouse state reported to the application, see terminal_input.go
+	mouseButtons pointer.Buttons
+	mouseCell    terminalPos // Screen cell of the last reported motion
+
+	rows terminalRows // Screen rows as last drawn, see pane_rendering.go
+}
+
+// Keys handled in NORMAL mode while a terminal buffer is active. Motions
+// scroll the history, or move the cursor in copy mode.
+var terminalHistoryKeybindings = []KeyBinding{
+	{Modifiers: 0, Key: key.NameEscape, Action: ActionExitMode},
+	{Modifiers: 0, Key: key.NameUpArrow, Action: ActionMoveUp},
+	{Modifiers: 0, Key: key.NameDownArrow, Action: ActionMoveDown},
+	{Modifiers: 0, Key: key.NameLeftArrow, Action: ActionMoveLeft},
+	{Modifiers: 0, Key: key.NameRightArrow, Action: ActionMoveRight},
+	{Modifiers: 0, Key: "k", Action: ActionMoveUp},
+	{Modifiers: 0, Key: "j", Action: ActionMoveDown},
+	{Modifiers: 0, Key: "h", Action: ActionMoveLeft},
+	{Modifiers: 0, Key: "l", Action: ActionMoveRight},
+	{Modifiers: 0, Key: "w", Action: ActionWordForward},
+	{Modifiers: 0, Key: "b", Action: ActionWordBackward},
+	{Modifiers: 0, Key: "0", Action: ActionJumpLineStart},
+	{Modifiers: 0, Key: "$", Action: ActionJumpLineEnd},
+	{Modifiers: key.ModShift, Key: "4", Action: ActionJumpLineEnd},
+	{Modifiers: 0, Key: "g", Action: ActionStartGotoSequence},
+	{Modifiers: key.ModShift, Key: "g", Action: ActionGotoLine},
+	{Modifiers: key.ModCtrl, Key: "y", Action: ActionScrollLineUp},
+	{Modifiers: key.ModCtrl, Key: "e", Action: ActionScrollLineDown},
+	{Modifiers: key.ModCtrl, Key: "u", Action: ActionScrollHalfPageUp},
+	{Modifiers: key.ModCtrl, Key: "d", Action: ActionScrollHalfPageDown},
+	{Modifiers: 0, Key: key.NamePageUp, Action: ActionScrollPageUp},
+	{Modifiers: 0, Key: key.NamePageDown, Action: ActionScrollPageDown},
+	{Modifiers: 0, Key: "v", Action: ActionEnterVisualChar},
+	{Modifiers: key.ModShift, Key: "v", Action: ActionEnterVisualLine},
+	{Modifiers: 0, Key: "y", Action: ActionCopySelection},
+	{Modifiers: 0, Key: key.NameReturn, Action: ActionCopySelection},
+	{Modifiers: 0, Key: key.NameEnter, Action: ActionCopySelection},
+	{Modifiers: 0, Key: "n", Action: ActionNextMatch},
+	{Modifiers: key.ModShift, Key: "n", Action: ActionPrevMatch},
+	{Modifiers: 0, Key: "i", Action: ActionEnterInsert},
+	{Modifiers: 0, Key: "a", Action: ActionEnterInsert},
+}
+
+// activeTerminalHistory returns the active terminal buffer's index, screen
+// and history view, or ok=false when the active buffer isn't a terminal.
+func (s *appState) activeTerminalHistory() (bufID int, screen *terminal.ScreenBuffer, view *terminalHistoryView, ok bool) {
+	buf := s.activeBuffer()
+	if buf == nil || !buf.IsTerminal() {
+		return 0, nil, nil, false
+	}
+	bufID = s.paneManager.ActivePane().BufferID
+	term, exists := s.terminals[bufID]
+	if !exists || term == nil || term.GetScreen() == nil {
+		return 0, nil, nil, false
+	}
+	return bufID, term.GetScreen(), s.terminalHistoryView(bufID), true
+}
+
+// terminalHistoryView returns the history view of a terminal buffer,
+// creating it on first use.
+func (s *appState) terminalHistoryView(bufID int) *terminalHistoryView {
+	view, exists := s.terminalHistory[bufID]
+	if !exists {
+		view = &terminalHistoryView{}
+		s.terminalHistory[bufID] = view
+	}
+	return view
+}
+
+// followTerminalOutput leaves the history of a terminal and shows its live
+// output again. Called whenever input is sent to the terminal.
+func (s *appState) followTerminalOutput(bufID int) {
+	if view, exists := s.terminalHistory[bufID]; exists {
+		view.scrolled = false
+		view.copyMode = false
+		view.visual = visualModeNone
+	}
+}
+
+// handleTerminalHistoryKey handles NORMAL mode keys in terminal buffers.
+// It returns false for keys that should get their usual NORMAL meaning.
+func (s *appState) handleTerminalHistoryKey(ev key.Event) bool {
+	bufID, screen, view, ok := s.activeTerminalHistory()
+	if !ok {
+		return false
+	}
+
+	action := ActionNone
+	for _, binding := range terminalHistoryKeybindings {
+		if s.modifiersMatch(ev, binding.Modifiers) && s.keysMatch(ev.Name, binding.Key) {
+			action = binding.Action
+			break
+		}
+	}
+
+	// "gg" jumps to the oldest line, "gf" opens the file or URL shown
+	if view.pendingG {
+		view.pendingG = false
+		if action == ActionStartGotoSequence {
+			first, _ := screen.HistoryRange()
+			s.terminalHistoryMoveTo(screen, view, first, 0)
+			return true
+		}
+		if s.modifiersMatch(ev, 0) && s.keysMatch(ev.Name, "f") {
+			s.terminalGotoLink(bufID, screen, view)
+			return true
+		}
+	}
+
+	// "[[" and "]]" jump between shell prompts
+	if view.pendingBracket != "" {
+		pending := view.pendingBracket
+		view.pendingBracket = ""
+		if s.modifiersMatch(ev, 0) && s.keysMatch(ev.Name, pending) {
+			dir := 1
+			if pending == "[" {
+				dir = -1
+			}
+			s.terminalJumpPrompt(screen, view, dir)
+			return true
+		}
+	}
+	if action == ActionNone && s.modifiersMatch(ev, 0) && (s.keysMatch(ev.Name, "[") || s.keysMatch(ev.Name, "]")) {
+		view.pendingBracket = ev.Name
+		return true
+	}
+
+	switch action {
+	case ActionNone:
+		return false
+	case ActionExitMode:
+		if !view.copyMode && !view.scrolled {
+			return false
+		}
+		view.copyMode = false
+		view.visual = visualModeNone
+		view.scrolled = false
+		s.status = "Back to live terminal output"
+	case ActionEnterInsert:
+		s.followTerminalOutput(bufID)
+		s.handleOpenTerminal()
+	case ActionStartGotoSequence:
+		view.pendingG = true
+	case ActionMoveUp, ActionScrollLineUp:
+		s.terminalHistoryMoveLines(screen, view, -1)
+	case ActionMoveDown, ActionScrollLineDown:
+		s.terminalHistoryMoveLines(screen, view, 1)
+	case ActionScrollHalfPageUp:
+		s.terminalHistoryMoveLines(screen, view, -max(view.pageLines/2, 1))
+	case ActionScrollHalfPageDown:
+		s.terminalHistoryMoveLines(screen, view, max(view.pageLines/2, 1))
+	case ActionScrollPageUp:
+		s.terminalHistoryMoveLines(screen, view, -max(view.pageLines-1, 1))
+	case ActionScrollPageDown:
+		s.terminalHistoryMoveLines(screen, view, max(view.pageLines-1, 1))
+	case ActionGotoLine:
+		if view.copyMode {
+			_, screenTop := screen.HistoryRange()
+			_, rows := screen.Dimensions()
+			s.terminalHistoryMoveTo(screen, view, screenTop+rows-1, 0)
+		} else {
+			view.scrolled = false
+			s.status = "Back to live terminal output"
+		}
+	case ActionMoveLeft, ActionMoveRight, ActionWordForward, ActionWordBackward, ActionJumpLineStart, ActionJumpLineEnd:
+		if view.copyMode {
+			s.terminalHistoryMoveInLine(screen, view, action)
+		}
+	case ActionEnterVisualChar, ActionEnterVisualLine:
+		s.terminalHistoryToggleVisual(screen, view, action)
+	case ActionCopySelection:
+		if view.copyMode {
+			s.terminalHistoryYank(screen, view)
+		} else if s.keysMatch(ev.Name, "y") {
+			s.terminalYankOutput(screen, view)
+		}
+	case ActionNextMatch:
+		s.terminalHistoryJumpMatch(screen, view, 1)
+	case ActionPrevMatch:
+		s.terminalHistoryJumpMatch(screen, view, -1)
+	}
+	return true
+}
+
+// terminalHistoryTop returns the first line currently shown.
+func (v *terminalHistoryView) terminalHistoryTop() int {
+	if v.scrolled {
+		return v.top
+	}
+	return v.liveTop
+}
+
+// scrollTo shows the history from line top, following the output again
+// once the live screen is reached.
+func (v *terminalHistoryView) scrollTo(top int, screen *terminal.ScreenBuffer) {
+	first, _ := screen.HistoryRange()
+	top = max(top, first)
+	if top >= v.liveTop {
+		v.scrolled = false
+		return
+	}
+	v.top = top
+	v.scrolled = true
+}
+
+// revealCursor scrolls so the copy mode cursor is visible.
+func (v *terminalHistoryView) revealCursor(screen *terminal.ScreenBuffer) {
+	top := v.terminalHistoryTop()
+	if v.cursor.Line < top {
+		v.scrollTo(v.cursor.Line, screen)
+	} else if v.cursor.Line >= top+v.pageLines {
+		v.scrollTo(v.cursor.Line-v.pageLines+1, screen)
+	}
+}
+
+// terminalHistoryMoveLines scrolls the view, or moves the copy mode cursor,
+// by delta lines.
+func (s *appState) terminalHistoryMoveLines(screen *terminal.ScreenBuffer, view *terminalHistoryView, delta int) {
+	if view.copyMode {
+		s.terminalHistoryMoveTo(screen, view, view.cursor.Line+delta, view.cursor.Col)
+		return
+	}
+	view.scrollTo(view.terminalHistoryTop()+delta, screen)
+	s.updateTerminalHistoryStatus(screen, view)
+}
+
+// terminalHistoryMoveTo moves the copy mode cursor, entering copy mode when
+// the view was only scrolled.
+func (s *appState) terminalHistoryMoveTo(screen *terminal.ScreenBuffer, view *terminalHistoryView, line, col int) {
+	first, screenTop := screen.HistoryRange()
+	cols, rows := screen.Dimensions()
+	if !view.copyMode {
+		// Without a cursor, gg/G only scroll
+		view.scrollTo(line, screen)
+		s.updateTerminalHistoryStatus(screen, view)
+		return
+	}
+	view.cursor.Line = min(max(line, first), screenTop+rows-1)
+	view.cursor.Col = min(max(col, 0), max(cols-1, 0))
+	if cells := screen.ViewLine(view.cursor.Line).Cells; view.cursor.Col < len(cells) && cells[view.cursor.Col].Spacer {
+		// Stay on the first half of a wide character
+		view.cursor.Col--
+	}
+	view.revealCursor(screen)
+	s.updateTerminalHistoryStatus(screen, view)
+}
+
+// terminalHistoryMoveInLine applies a horizontal motion to the copy mode
+// cursor. Motions go by character, which may take two cells or carry
+// combining marks.
+func (s *appState) terminalHistoryMoveInLine(screen *terminal.ScreenBuffer, view *terminalHistoryView, action Action) {
+	line := screen.ViewLine(view.cursor.Line)
+	runes, cells := line.CellText()
+	col := view.cursor.Col
+	// i is the character under the cursor, len(runes) past the text
+	i := sort.SearchInts(cells[:len(runes)], col)
+
+	switch action {
+	case ActionMoveLeft:
+		col--
+	case ActionMoveRight:
+		col++
+		if col < len(line.Cells) && line.Cells[col].Spacer {
+			col++
+		}
+	case ActionJumpLineStart:
+		col = 0
+	case ActionJumpLineEnd:
+		col = cells[max(len(runes)-1, 0)]
+	case ActionWordForward:
+		col = cells[nextWordStart(runes, i)]
+	case ActionWordBackward:
+		col = cells[prevWordStart(runes, i)]
+	}
+	s.terminalHistoryMoveTo(screen, view, view.cursor.Line, col)
+}
+
+// nextWordStart returns the start of the next word after col, or the end
+// of the line.
+func nextWordStart(runes []rune, col int) int {
+	i := col
+	for i < len(runes) && !unicode.IsSpace(runes[i]) {
+		i++
+	}
+	for i < len(runes) && unicode.IsSpace(runes[i]) {
+		i++
+	}
+	return min(i, max(len(runes)-1, 0))
+}
+
+// prevWordStart returns the start of the word before col.
+func prevWordStart(runes []rune, col int) int {
+	i := min(col, len(runes)) - 1
+	for i > 0 && unicode.IsSpace(runes[i]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(runes[i-1]) {
+		i--
+	}
+	return max(i, 0)
+}
+
+// terminalHistoryToggleVisual starts copy mode with a selection, switches
+// between character and line selection, or clears the selection.
+func (s *appState) terminalHistoryToggleVisual(screen *terminal.ScreenBuffer, view *terminalHistoryView, action Action) {
+	kind := visualModeChar
+	if action == ActionEnterVisualLine {
+		kind = visualModeLine
+	}
+
+	if !view.copyMode {
+		view.copyMode = true
+		if view.scrolled {
+			view.cursor = terminalPos{Line: view.top}
+		} else {
+			_, screenTop := screen.HistoryRange()
+			x, y, _ := screen.GetCursor()
+			view.cursor = terminalPos{Line: screenTop + y, Col: x}
+		}
+		view.anchor = view.cursor
+		view.visual = kind
+	} else if view.visual == kind {
+		view.visual = visualModeNone
+	} else {
+		if view.visual == visualModeNone {
+			view.anchor = view.cursor
+		}
+		view.visual = kind
+	}
+	view.revealCursor(screen)
+	s.updateTerminalHistoryStatus(screen, view)
+}
+
+// terminalSelection returns the ordered copy mode selection.
+func (v *terminalHistoryView) terminalSelection() (start, end terminalPos, ok bool) {
+	if !v.copyMode {
+		return terminalPos{}, terminalPos{}, false
+	}
+	if v.visual == visualModeNone {
+		// Without a selection the cursor line is used
+		return terminalPos{Line: v.cursor.Line}, terminalPos{Line: v.cursor.Line}, true
+	}
+	start, end = v.anchor, v.cursor
+	if end.Line < start.Line || (end.Line == start.Line && end.Col < start.Col) {
+		start, end = end, start
+	}
+	return start, end, true
+}
+
+// selectionContains reports whether a cell is inside the copy mode selection.
+func (v *terminalHistoryView) selectionContains(line, col int) bool {
+	if !v.copyMode || v.visual == visualModeNone {
+		return false
+	}
+	start, end, _ := v.terminalSelection()
+	if line < start.Line || line > end.Line {
+		return false
+	}
+	if v.visual == visualModeLine {
+		return true
+	}
+	if line == start.Line && col < start.Col {
+		return false
+	}
+	if line == end.Line && col > end.Col {
+		return false
+	}
+	return true
+}
+
+// terminalHistoryYank copies the selection (or the cursor line) into the
+// clipboard registers and leaves copy mode.
+func (s *appState) terminalHistoryYank(screen *terminal.ScreenBuffer, view *terminalHistoryView) {
+	start, end, ok := view.terminalSelection()
+	if !ok {
+		return
+	}
+
+	var lines []string
+	for abs := start.Line; abs <= end.Line; abs++ {
+		runes, cells := screen.ViewLine(abs).CellText()
+		if view.visual == visualModeChar {
+			// Select the characters drawn in the selected cells
+			from, to := 0, len(runes)
+			if abs == start.Line {
+				from = sort.SearchInts(cells[:len(runes)], start.Col)
+			}
+			if abs == end.Line {
+				to = sort.SearchInts(cells[:len(runes)], end.Col+1)
+			}
+			runes = runes[from:max(to, from)]
+		}
+		lines = append(lines, string(runes))
+	}
+
+	if view.visual == visualModeChar {
+		text := strings.Join(lines, "\n")
+		s.writeToSystemClipboard(text)
+		s.clipLines = []string{text}
+		s.clipboardIsLine = false
+		s.status = fmt.Sprintf("Copied %d character(s) from terminal", len([]rune(text)))
+	} else {
+		s.writeToSystemClipboard(strings.Join(lines, "\n") + "\n")
+		s.clipLines = lines
+		s.clipboardIsLine = true
+		s.status = fmt.Sprintf("Copied %d line(s) from terminal", len(lines))
+	}
+
+	view.copyMode = false
+	view.visual = visualModeNone
+}
+
+// searchTerminalHistory finds pattern in the active terminal's scrollback
+// and screen and shows the match closest above the current view.
+func (s *appState) searchTerminalHistory(pattern string) {
+	_, screen, view, ok := s.activeTerminalHistory()
+	if !ok {
+		return
+	}
+
+	view.pattern = pattern
+	view.matches = nil
+	lowerPattern := []rune(strings.ToLower(pattern))
+
+	first, screenTop := screen.HistoryRange()
+	_, rows := screen.Dimensions()
+	for abs := first; abs < screenTop+rows; abs++ {
+		// Matches are found in the characters and shown in their cells
+		runes, cells := screen.ViewLine(abs).CellText()
+		for i, r := range runes {
+			runes[i] = unicode.ToLower(r)
+		}
+		for i := 0; i+len(lowerPattern) <= len(runes); i++ {
+			if string(runes[i:i+len(lowerPattern)]) == string(lowerPattern) {
+				width := max(cells[i+len(lowerPattern)]-cells[i], 1)
+				view.matches = append(view.matches, terminalMatch{Line: abs, Col: cells[i], Len: width})
+			}
+		}
+	}
+
+	if len(view.matches) == 0 {
+		s.status = fmt.Sprintf("Pattern not found: %s", pattern)
+		return
+	}
+
+	// Search backwards from the current position, like a shell's history
+	from := view.terminalHistoryTop() + view.pageLines
+	if view.copyMode {
+		from = view.cursor.Line
+	}
+	view.matchIdx = len(view.matches) - 1
+	for i := len(view.matches) - 1; i >= 0; i-- {
+		if view.matches[i].Line < from {
+			view.matchIdx = i
+			break
+		}
+	}
+	s.showTerminalMatch(screen, view)
+}
+
+// terminalHistoryJumpMatch moves to the next (dir > 0, towards newer output)
+// or previous search match.
+func (s *appState) terminalHistoryJumpMatch(screen *terminal.ScreenBuffer, view *terminalHistoryView, dir int) {
+	if len(view.matches) == 0 {
+		s.status = "No active search"
+		return
+	}
+	// n continues in the direction of the search, i.e. towards older output
+	view.matchIdx = (view.matchIdx - dir + len(view.matches)) % len(view.matches)
+	s.showTerminalMatch(screen, view)
+}
+
+// showTerminalMatch scrolls to the current match and puts the copy mode
+// cursor on it.
+func (s *appState) showTerminalMatch(screen *terminal.ScreenBuffer, view *terminalHistoryView) {
+	match := view.matches[view.matchIdx]
+	if view.copyMode {
+		view.cursor = terminalPos{Line: match.Line, Col: match.Col}
+		view.revealCursor(screen)
+	} else {
+		view.scrollTo(match.Line-view.pageLines/2, screen)
+	}
+	s.status = fmt.Sprintf("?%s [%d/%d]", view.pattern, view.matchIdx+1, len(view.matches))
+}
+
+// terminalMatchAt returns whether a cell is part of a search match, and
+// whether that match is the current one. The matches are in order, so the
+// line's ones are found by binary search.
+func (v *terminalHistoryView) terminalMatchAt(line, col int) (matched, current bool) {
+	i := sort.Search(len(v.matches), func(i int) bool { return v.matches[i].Line >= line })
+	for ; i < len(v.matches) && v.matches[i].Line == line; i++ {
+		if m := v.matches[i]; col >= m.Col && col < m.Col+m.Len {
+			return true, i == v.matchIdx
+		}
+	}
+	return false, false
+}
+
+// updateTerminalHistoryStatus describes the scroll position in the status bar.
+func (s *appState) updateTerminalHistoryStatus(screen *terminal.ScreenBuffer, view *terminalHistoryView) {
+	mode := "History"
+	if view.copyMode {
+		switch view.visual {
+		case visualModeChar:
+			mode = "COPY (visual)"
+		case visualModeLine:
+			mode = "COPY (visual line)"
+		default:
+			mode = "COPY"
+		}
+	}
+	if !view.scrolled {
+		s.status = fmt.Sprintf("%s: live output (y to yank, Esc to leave)", mode)
+		return
+	}
+	first, _ := screen.HistoryRange()
+	back := view.liveTop - view.top
+	s.status = fmt.Sprintf("%s: %d line(s) back of %d", mode, back, view.liveTop-first)
+}
+
+// handleTerminalPointer scrolls a terminal's history with the mouse wheel
+// and opens links that are clicked. Applications that asked for mouse
+// events get them instead while the terminal takes input.
+func (s *appState) handleTerminalPointer(gtx layout.Context, bufID int, view *terminalHistoryView, screen *terminal.ScreenBuffer, charWidth, lineHeight int) {
+	for {
+		ev, ok := gtx.Event(pointer.Filter{
+			Target:  view,
+			Kinds:   pointer.Scroll | pointer.Press | pointer.Release | pointer.Drag | pointer.Move,
+			ScrollY: pointer.ScrollRange{Min: -1 << 20, Max: 1 << 20},
+		})
+		if !ok {
+			break
+		}
+		e, ok := ev.(pointer.Event)
+		if !ok {
+			continue
+		}
+		if term, ok := s.terminalReportsMouse(bufID, view); ok {
+			_, screenTop := screen.HistoryRange()
+			row := view.terminalHistoryTop() + int(e.Position.Y)/max(lineHeight, 1) - screenTop
+			col := int(e.Position.X) / max(charWidth, 1)
+			if cols, rows := screen.Dimensions(); row >= 0 && row < rows && col >= 0 && col < cols {
+				s.forwardTerminalMouse(term, view, e, col, row, lineHeight)
+			}
+			continue
+		}
+		if e.Kind == pointer.Press {
+			if e.Buttons != pointer.ButtonPrimary {
+				continue
+			}
+			abs := view.terminalHistoryTop() + int(e.Position.Y)/max(lineHeight, 1)
+			col := int(e.Position.X) / max(charWidth, 1)
+			if link, ok := terminal.LinkAt(screen.ViewLine(abs), col); ok {
+				s.linkClick = &terminalLinkClick{bufID: bufID, link: link}
+				s.window.Invalidate()
+			}
+			continue
+		}
+		if e.Kind != pointer.Scroll {
+			continue
+		}
+
+		view.wheel += e.Scroll.Y
+		lines := int(view.wheel / float32(max(lineHeight, 1)))
+		if lines == 0 {
+			continue
+		}
+		view.wheel -= float32(lines * lineHeight)
+		view.scrollTo(view.terminalHistoryTop()+lines, screen)
+	}
+}
@@ -0,0 +1,115 @@
+package appcore
+
+import (
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+
+	"github.com/javanhut/vem/internal/terminal"
+)
+
+// terminalPasteKey reports whether ev is Ctrl+Shift+V, which pastes the
+// system clipboard into a terminal.
+func terminalPasteKey(ev key.Event) bool {
+	return ev.Modifiers.Contain(key.ModCtrl) && ev.Modifiers.Contain(key.ModShift) &&
+		(ev.Name == "V" || ev.Name == "v")
+}
+
+// pasteIntoTerminal sends the system clipboard to a terminal.
+func (s *appState) pasteIntoTerminal(bufID int, term *terminal.Terminal) {
+	text, ok := s.readFromSystemClipboard()
+	if !ok {
+		s.status = "Clipboard is empty"
+		return
+	}
+	s.followTerminalOutput(bufID)
+	if err := term.Paste(text); err != nil {
+		// Silently handle terminal write errors
+	}
+}
+
+// applyTerminalFocus tells every terminal whether it has the keyboard focus:
+// the window is focused and the terminal takes TERMINAL INPUT keys.
+func (s *appState) applyTerminalFocus() {
+	activeID := -1
+	if s.paneManager != nil && s.mode == modeTerminal {
+		if pane := s.paneManager.ActivePane(); pane != nil {
+			activeID = pane.BufferID
+		}
+	}
+	for bufID, term := range s.terminals {
+		if term != nil {
+			term.SetFocus(s.windowFocused && bufID == activeID)
+		}
+	}
+}
+
+// terminalReportsMouse returns the terminal mouse events on a terminal
+// buffer go to: the active terminal in TERMINAL INPUT mode showing its live
+// output, when its application asked for mouse events.
+func (s *appState) terminalReportsMouse(bufID int, view *terminalHistoryView) (*terminal.Terminal, bool) {
+	if s.mode != modeTerminal || view.scrolled || s.paneManager == nil {
+		return nil, false
+	}
+	if pane := s.paneManager.ActivePane(); pane == nil || pane.BufferID != bufID {
+		return nil, false
+	}
+	term, exists := s.terminals[bufID]
+	if !exists || term == nil || !term.ReportsMouse() {
+		return nil, false
+	}
+	return term, true
+}
+
+// forwardTerminalMouse passes a pointer event on to the application in the
+// terminal. row and col are the screen cell under the pointer.
+func (s *appState) forwardTerminalMouse(term *terminal.Terminal, view *terminalHistoryView, e pointer.Event, col, row, lineHeight int) {
+	switch e.Kind {
+	case pointer.Press, pointer.Release:
+		action := terminal.MousePress
+		changed := e.Buttons &^ view.mouseButtons
+		if e.Kind == pointer.Release {
+			action = terminal.MouseRelease
+			changed = view.mouseButtons &^ e.Buttons
+		}
+		view.mouseButtons = e.Buttons
+		for _, b := range []struct {
+			gio  pointer.Buttons
+			term terminal.MouseButton
+		}{
+			{pointer.ButtonPrimary, terminal.MouseLeft},
+			{pointer.ButtonTertiary, terminal.MouseMiddle},
+			{pointer.ButtonSecondary, terminal.MouseRight},
+		} {
+			if changed.Contain(b.gio) {
+				term.SendMouse(action, b.term, col, row, e.Modifiers)
+			}
+		}
+	case pointer.Drag, pointer.Move:
+		button := terminal.MouseNone
+		switch {
+		case e.Buttons.Contain(pointer.ButtonPrimary):
+			button = terminal.MouseLeft
+		case e.Buttons.Contain(pointer.ButtonTertiary):
+			button = terminal.MouseMiddle
+		case e.Buttons.Contain(pointer.ButtonSecondary):
+			button = terminal.MouseRight
+		}
+		if col == view.mouseCell.Col && row == view.mouseCell.Line {
+			// Motion is reported per cell
+			return
+		}
+		view.mouseCell = terminalPos{Line: row, Col: col}
+		term.SendMouse(terminal.MouseMotion, button, col, row, e.Modifiers)
+	case pointer.Scroll:
+		view.wheel += e.Scroll.Y
+		lines := int(view.wheel / float32(max(lineHeight, 1)))
+		view.wheel -= float32(lines * lineHeight)
+		button := terminal.MouseWheelDown
+		if lines < 0 {
+			button, lines = terminal.MouseWheelUp, -lines
+		}
+		for i := 0; i < lines; i++ {
+			term.SendMouse(terminal.MousePress, button, col, row, e.Modifiers)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package appcore
+
+import (
+	"fmt"
+
+	"github.com/javanhut/vem/internal/terminal"
+)
+
+// defaultMakeprg is the build command :make runs unless makeprg is set.
+const defaultMakeprg = "make"
+
+// terminalJob is a command running in a terminal buffer. Unlike a shell,
+// its buffer stays open after it exits so the output can be read.
+type terminalJob struct {
+	command  string
+	dir      string // Working directory, for resolving file names in errors
+	make     bool   // Parse the output into the quickfix list when done
+	reported bool   // The exit status has been shown
+}
+
+// runTerminalCommand runs command in a new terminal buffer.
+func (s *appState) runTerminalCommand(command string, isMake bool) {
+	cfg := terminal.Config{
+		Shell:         terminal.DefaultShell(),
+		Args:          terminal.CommandArgs(command),
+		CaptureOutput: isMake,
+		OnExit: func() {
+			// The exit is handled on the UI goroutine at the next frame
+			if s.window != nil {
+				s.window.Invalidate()
+			}
+		},
+	}
+	dir := s.getWorkingDirectory()
+	bufID := s.openTerminal(cfg)
+	buf := s.bufferMgr.GetBuffer(bufID)
+	if buf == nil {
+		return
+	}
+
+	buf.SetFilePath(fmt.Sprintf("[Term: %s]", command))
+	s.terminalJobs[bufID] = &terminalJob{command: command, dir: dir, make: isMake}
+	if isMake {
+		// Nothing to type into; stay in NORMAL mode to watch the build
+		s.mode = modeNormal
+		s.status = fmt.Sprintf(":!%s (quickfix list is filled when it finishes)", command)
+	} else {
+		s.status = fmt.Sprintf("TERMINAL INPUT - %s", command)
+	}
+}
+
+// handleMakeCommand runs makeprg with args and fills the quickfix list
+// from its output.
+func (s *appState) handleMakeCommand(args string) {
+	patterns, err := compileErrorformat(s.errorformat)
+	if err != nil {
+		s.status = fmt.Sprintf("Invalid errorformat: %v", err)
+		return
+	}
+	s.errorPatterns = patterns
+
+	command := s.makeprg
+	if args != "" {
+		command += " " + args
+	}
+	s.runTerminalCommand(command, true)
+}
+
+// applyTerminalJobs reports commands that exited since the last frame and
+// fills the quickfix list for :make.
+func (s *appState) applyTerminalJobs() {
+	for bufID, job := range s.terminalJobs {
+		if job.reported {
+			continue
+		}
+		term, exists := s.terminals[bufID]
+		if !exists || term == nil {
+			continue
+		}
+		code, exited := term.ExitCode()
+		if !exited {
+			continue
+		}
+		job.reported = true
+
+		if buf := s.bufferMgr.GetBuffer(bufID); buf != nil {
+			buf.SetFilePath(fmt.Sprintf("[Term: %s] [exit %d]", job.command, code))
+		}
+		// There's nothing left to type into
+		if s.mode == modeTerminal && s.paneManager != nil && s.paneManager.ActivePane().BufferID == bufID {
+			s.mode = modeNormal
+		}
+
+		if !job.make {
+			s.status = fmt.Sprintf("%s: exited with status %d", job.command, code)
+			continue
+		}
+
+		entries := parseErrorformat(term.CapturedOutput(), s.errorPatterns, job.dir)
+		s.setQuickfixList(entries)
+		if len(entries) == 0 {
+			s.status = fmt.Sprintf("%s: exited with status %d, no errors", job.command, code)
+		} else {
+			s.status = fmt.Sprintf("%s: exited with status %d, %d error(s) (:cc to jump, :copen to list)", job.command, code, len(entries))
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package appcore
+
+import (
+	"fmt"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/javanhut/vem/internal/panes"
+	"github.com/javanhut/vem/internal/terminal"
+)
+
+// terminalLinkClick is a link clicked in a terminal. It is opened at the
+// start of the next frame rather than while the panes are being drawn.
+type terminalLinkClick struct {
+	bufID int
+	link  terminal.Link
+}
+
+// terminalGotoLink opens the reference under the copy mode cursor, or
+// outside copy mode the last one shown, for gf.
+func (s *appState) terminalGotoLink(bufID int, screen *terminal.ScreenBuffer, view *terminalHistoryView) {
+	link, ok := terminalLinkForGoto(screen, view)
+	if !ok {
+		s.status = "No file or link found"
+		return
+	}
+	s.openTerminalLink(bufID, link)
+}
+
+// terminalLinkForGoto picks the reference gf opens. In copy mode it is the
+// one under the cursor or after it on the cursor line; otherwise the last
+// one in view, usually the latest error.
+func terminalLinkForGoto(screen *terminal.ScreenBuffer, view *terminalHistoryView) (terminal.Link, bool) {
+	if view.copyMode {
+		for _, link := range terminal.FindLinks(screen.ViewLine(view.cursor.Line)) {
+			if link.End > view.cursor.Col {
+				return link, true
+			}
+		}
+		return terminal.Link{}, false
+	}
+
+	top := view.terminalHistoryTop()
+	for abs := top + view.pageLines - 1; abs >= top; abs-- {
+		if links := terminal.FindLinks(screen.ViewLine(abs)); len(links) > 0 {
+			return links[len(links)-1], true
+		}
+	}
+	return terminal.Link{}, false
+}
+
+// applyTerminalLinkClick opens the link clicked on the last frame.
+func (s *appState) applyTerminalLinkClick() {
+	if s.linkClick == nil {
+		return
+	}
+	click := *s.linkClick
+	s.linkClick = nil
+	s.openTerminalLink(click.bufID, click.link)
+}
+
+// openTerminalLink opens a reference found in a terminal buffer. URLs go to
+// the system browser; files open in another pane at the referenced line.
+// A hyperlink whose text isn't its target only opens when asked twice, the
+// first time showing where it goes.
+func (s *appState) openTerminalLink(bufID int, link terminal.Link) {
+	if link.URL != "" {
+		if link.Text != "" && link.Text != link.URL && s.confirmLinkURL != link.URL {
+			s.confirmLinkURL = link.URL
+			s.status = fmt.Sprintf("Link goes to %s (open it again to go there)", link.URL)
+			return
+		}
+		s.confirmLinkURL = ""
+		if err := openURL(link.URL); err != nil {
+			s.status = fmt.Sprintf("Error opening %s: %v", link.URL, err)
+		} else {
+			s.status = fmt.Sprintf("Opened %s", link.URL)
+		}
+		return
+	}
+
+	path := link.Path
+	if !filepath.IsAbs(path) {
+		if term, exists := s.terminals[bufID]; exists && term != nil {
+			path = filepath.Join(term.WorkingDir(), path)
+		}
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		s.status = fmt.Sprintf("E447: Can't find file \"%s\"", link.Path)
+		return
+	}
+
+	if s.paneManager == nil {
+		s.status = "Pane manager not initialized"
+		return
+	}
+	if s.paneManager.IsZoomed() {
+		// Show the pane the file opens in
+		s.paneManager.ToggleZoom()
+	}
+	target := s.linkTargetPane(bufID)
+
+	if _, err := s.bufferMgr.OpenFile(path); err != nil {
+		s.status = fmt.Sprintf("Error opening %s: %v", link.Path, err)
+		return
+	}
+	fileID := s.bufferMgr.ActiveID()
+	if target != nil {
+		s.paneManager.SetActivePane(target)
+		target.SetBufferID(fileID)
+	} else {
+		// Split the terminal's pane, which may not be the active one
+		if pane := s.paneManager.FindPaneByBufferID(bufID); pane != nil {
+			s.paneManager.SetActivePane(pane)
+		}
+		if err := s.paneManager.SplitHorizontal(fileID); err != nil {
+			s.status = fmt.Sprintf("Split failed: %v", err)
+			return
+		}
+	}
+	s.mode = modeNormal
+	s.syncPaneView()
+
+	s.gotoLineCol(link.Line, link.Col)
+	if link.Line > 0 {
+		s.status = fmt.Sprintf("\"%s\" line %d", link.Path, link.Line)
+	} else {
+		s.status = fmt.Sprintf("\"%s\"", link.Path)
+	}
+}
+
+// linkTargetPane returns the pane links from terminal bufID open in: the
+// active pane if it shows a file, else another pane that isn't showing a
+// terminal, or nil when a new split is needed.
+func (s *appState) linkTargetPane(bufID int) *panes.Pane {
+	candidates := append([]*panes.Pane{s.paneManager.ActivePane()}, s.paneManager.AllPanes()...)
+	for _, pane := range candidates {
+		if pane == nil || pane.BufferID == bufID {
+			continue
+		}
+		if buf := s.bufferMgr.GetBuffer(pane.BufferID); buf != nil && buf.IsTerminal() {
+			continue
+		}
+		return pane
+	}
+	return nil
+}
+
+// openURLSchemes are the schemes openURL hands to the system. Others, such
+// as smb: or ms-msdt:, or a bare path on Windows, could run programs.
+var openURLSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// openURL opens a URL with the system's default handler, if its scheme is
+// one of openURLSchemes.
+func openURL(url string) error {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return err
+	}
+	if !openURLSchemes[strings.ToLower(u.Scheme)] {
+		return fmt.Errorf("only http, https and mailto links are opened")
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the opener; its exit status says nothing useful
+	go cmd.Wait()
+	return nil
+}
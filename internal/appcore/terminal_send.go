@@ -0,0 +1,141 @@
+package appcore
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/javanhut/vem/internal/terminal"
+)
+
+// Sending code from editor buffers to a terminal, for REPLs: :TermSend
+// sends the current line or the visual selection, gs the paragraph (or the
+// selection in VISUAL mode). The code is wrapped for the REPL the terminal
+// runs, see terminal/repl.go.
+
+// handleTermSendCommand implements :TermSend [N], which sends the visual
+// selection or the current line to terminal buffer N. Without N it goes to
+// the terminal used last.
+func (s *appState) handleTermSendCommand(args string) {
+	buf := s.activeBuffer()
+	if buf == nil || buf.IsTerminal() {
+		s.status = "TermSend: not in an editor buffer"
+		return
+	}
+
+	var code string
+	if s.visualMode != visualModeNone {
+		// The command line was opened from VISUAL mode
+		code = s.visualSelectionText()
+		s.exitVisualMode()
+	} else {
+		code = buf.Line(buf.Cursor().Line)
+	}
+	s.sendToTerminal(code, args)
+}
+
+// sendParagraphToTerminal sends the paragraph around the cursor, the lines
+// between the nearest empty lines, to the terminal used last.
+func (s *appState) sendParagraphToTerminal() {
+	buf := s.activeBuffer()
+	if buf == nil || buf.IsTerminal() {
+		return
+	}
+	start, end := buf.Cursor().Line, buf.Cursor().Line
+	for start > 0 && strings.TrimSpace(buf.Line(start-1)) != "" {
+		start--
+	}
+	for end < buf.LineCount()-1 && strings.TrimSpace(buf.Line(end+1)) != "" {
+		end++
+	}
+	s.sendToTerminal(strings.Join(buf.LinesRange(start, end), "\n"), "")
+}
+
+// visualSelectionText returns the text selected in VISUAL mode.
+func (s *appState) visualSelectionText() string {
+	if s.visualMode == visualModeChar {
+		startLine, startCol, endLine, endCol, _ := s.visualSelectionRangeChar()
+		return s.activeBuffer().GetCharRange(startLine, startCol, endLine, endCol)
+	}
+	start, end, _ := s.visualSelectionRange()
+	return strings.Join(s.activeBuffer().LinesRange(start, end), "\n")
+}
+
+// sendToTerminal sends code to the terminal buffer numbered target, or the
+// one used last, wrapped for the REPL running there.
+func (s *appState) sendToTerminal(code, target string) {
+	if strings.TrimSpace(code) == "" {
+		s.status = "TermSend: nothing to send"
+		return
+	}
+	bufID, term, err := s.termSendTerminal(target)
+	if err != nil {
+		s.status = fmt.Sprintf("TermSend: %v", err)
+		return
+	}
+	s.termSendTarget = bufID
+
+	// The prompt tells best what runs in the terminal, the file type is
+	// the fallback for REPLs whose prompt isn't recognized
+	repl, ok := term.PromptREPL()
+	if !ok {
+		repl = replForFile(s.activeBuffer().FilePath())
+	}
+
+	s.followTerminalOutput(bufID)
+	if err := term.Send(code, repl); err != nil {
+		s.status = fmt.Sprintf("TermSend: %v", err)
+		return
+	}
+	lines := strings.Count(strings.TrimRight(code, "\n"), "\n") + 1
+	s.status = fmt.Sprintf("Sent %d line(s) to terminal %d (%s)", lines, bufID, repl)
+}
+
+// termSendTerminal picks the terminal to send to: buffer number target
+// when given, else the one used last, a terminal shown in a pane or the
+// only one open.
+func (s *appState) termSendTerminal(target string) (int, *terminal.Terminal, error) {
+	if target != "" {
+		n, err := strconv.Atoi(target)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid buffer number %q", target)
+		}
+		if term, ok := s.terminals[n]; ok && term != nil {
+			return n, term, nil
+		}
+		return 0, nil, fmt.Errorf("buffer %d is not a terminal", n)
+	}
+
+	if term, ok := s.terminals[s.termSendTarget]; ok && term != nil {
+		return s.termSendTarget, term, nil
+	}
+	if s.paneManager != nil {
+		for _, pane := range s.paneManager.AllPanes() {
+			if term, ok := s.terminals[pane.BufferID]; ok && term != nil {
+				return pane.BufferID, term, nil
+			}
+		}
+	}
+	switch len(s.terminals) {
+	case 0:
+		return 0, nil, fmt.Errorf("no terminal open")
+	case 1:
+		for bufID, term := range s.terminals {
+			return bufID, term, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("%d terminals open, pick one with :TermSend <buffer number>", len(s.terminals))
+}
+
+// replForFile returns the REPL code from a file is meant for. Node is
+// never guessed from the file: its .editor wrapping ends with Ctrl+D,
+// which would exit a shell, so it is only used when PromptREPL sees Node.
+func replForFile(path string) terminal.REPL {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".py":
+		return terminal.REPLPython
+	default:
+		return terminal.REPLShell
+	}
+}
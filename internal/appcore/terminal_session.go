@@ -0,0 +1,124 @@
+package appcore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/javanhut/vem/internal/terminal"
+)
+
+// defaultSessionName is used by :tattach without a name.
+const defaultSessionName = "main"
+
+// handleSessionCommand runs the commands for persistent terminal sessions.
+// Sessions are owned by the session daemon, so their shells keep running
+// when the buffer is closed or Vem exits.
+func (s *appState) handleSessionCommand(name, args string) {
+	switch name {
+	case "tattach", "tatt":
+		s.attachSession(args)
+	case "tdetach", "tdet":
+		s.detachSession()
+	case "tsessions", "tls":
+		s.listSessions()
+	case "tkill":
+		s.killSession(args)
+	}
+}
+
+// attachSession shows the named session in the active pane, attaching to it
+// (and starting it) when no buffer shows it yet.
+func (s *appState) attachSession(name string) {
+	if name == "" {
+		name = defaultSessionName
+	}
+
+	if bufID, ok := s.sessionBuffer(name); ok {
+		s.showBufferInActivePane(bufID)
+		s.handleOpenTerminal()
+		return
+	}
+
+	bufID := s.openTerminal(terminal.Config{Session: name})
+	if buf := s.bufferMgr.GetBuffer(bufID); buf != nil {
+		buf.SetFilePath(fmt.Sprintf("[Session %s]", name))
+		s.status = fmt.Sprintf("TERMINAL INPUT - session %s (:tdetach to detach)", name)
+	}
+}
+
+// sessionBuffer returns the buffer attached to the named session.
+func (s *appState) sessionBuffer(name string) (int, bool) {
+	for bufID, term := range s.terminals {
+		if term != nil && term.Session() == name {
+			return bufID, true
+		}
+	}
+	return 0, false
+}
+
+// detachSession closes the active terminal buffer, leaving its session
+// running in the session daemon.
+func (s *appState) detachSession() {
+	term := s.activeTerminal()
+	if term == nil || term.Session() == "" {
+		s.status = "Not attached to a session (:tattach <name> to start one)"
+		return
+	}
+
+	name := term.Session()
+	if s.mode == modeTerminal {
+		s.mode = modeNormal
+	}
+	s.handleQuitCommand(true)
+	s.status = fmt.Sprintf("Detached from session %s (:tattach %s to reattach)", name, name)
+}
+
+// listSessions shows the running sessions in the status bar.
+func (s *appState) listSessions() {
+	sessions, err := terminal.ListSessions()
+	if err != nil {
+		s.status = fmt.Sprintf("Error listing sessions: %v", err)
+		return
+	}
+	if len(sessions) == 0 {
+		s.status = "No terminal sessions"
+		return
+	}
+
+	items := make([]string, len(sessions))
+	for i, session := range sessions {
+		items[i] = session.Name
+		if session.Attached {
+			items[i] += " (attached)"
+		}
+	}
+	s.status = "Sessions: " + strings.Join(items, ", ")
+}
+
+// killSession ends the shell of a session. Without a name the session of
+// the active terminal is ended.
+func (s *appState) killSession(name string) {
+	if name == "" {
+		if term := s.activeTerminal(); term != nil {
+			name = term.Session()
+		}
+	}
+	if name == "" {
+		s.status = "Usage: :tkill <session>"
+		return
+	}
+
+	if err := terminal.KillSession(name); err != nil {
+		s.status = fmt.Sprintf("Error ending session: %v", err)
+		return
+	}
+	s.status = fmt.Sprintf("Ended session %s", name)
+}
+
+// activeTerminal returns the terminal shown in the active pane, if any.
+func (s *appState) activeTerminal() *terminal.Terminal {
+	if s.paneManager == nil || s.paneManager.ActivePane() == nil {
+		return nil
+	}
+	return s.terminals[s.paneManager.ActivePane().BufferID]
+}
@@ -0,0 +1,171 @@
+package appcore
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+
+	"github.com/javanhut/vem/internal/terminal"
+)
+
+// Shell integration: shells that report their directory (OSC 7) and mark
+// their prompts (OSC 133) let the explorer follow cd, [[ and ]] jump
+// between prompts and y yank a command's output. See terminal/shell.go.
+
+// Gutter marks next to the prompts of finished commands
+var (
+	exitSuccessColor = color.NRGBA{R: 0x5f, G: 0xd0, B: 0x7a, A: 0xff}
+	exitFailureColor = color.NRGBA{R: 0xf0, G: 0x5a, B: 0x5a, A: 0xff}
+)
+
+// applyTerminalCwd points the explorer at the directory the active
+// terminal's shell has cd'd to. Called every frame before layout.
+func (s *appState) applyTerminalCwd() {
+	bufID, _, _, ok := s.activeTerminalHistory()
+	if !ok || s.fileTree == nil {
+		return
+	}
+	dir := s.terminals[bufID].WorkingDir()
+	last, seen := s.terminalDirs[bufID]
+	s.terminalDirs[bufID] = dir
+	if !seen || dir == last || dir == "" || dir == s.fileTree.CurrentPath() {
+		// Only follow changes, so switching to a terminal doesn't move
+		// the explorer away from where the user put it
+		return
+	}
+
+	if err := s.fileTree.ChangeRoot(dir); err != nil {
+		s.status = fmt.Sprintf("Error following terminal directory: %v", err)
+		return
+	}
+	if err := s.fileTree.LoadInitial(); err != nil {
+		s.status = fmt.Sprintf("Error loading directory: %v", err)
+		return
+	}
+	s.status = fmt.Sprintf("Explorer follows terminal: %s", s.fileTree.CurrentPath())
+}
+
+// terminalJumpPrompt moves to the previous (dir < 0) or next shell prompt.
+// In copy mode the cursor goes to the prompt, otherwise the view scrolls
+// it to the top.
+func (s *appState) terminalJumpPrompt(screen *terminal.ScreenBuffer, view *terminalHistoryView, dir int) {
+	prompts := screen.Prompts()
+	if len(prompts) == 0 {
+		s.status = "No prompts (the shell doesn't mark them with OSC 133)"
+		return
+	}
+
+	from := view.terminalHistoryTop()
+	if view.copyMode {
+		from = view.cursor.Line
+	} else if !view.scrolled {
+		// The latest prompt is the one being typed at, start before it
+		from = prompts[len(prompts)-1].Line
+	}
+
+	idx := -1
+	if dir < 0 {
+		for i := len(prompts) - 1; i >= 0; i-- {
+			if prompts[i].Line < from {
+				idx = i
+				break
+			}
+		}
+	} else {
+		for i, p := range prompts {
+			if p.Line > from {
+				idx = i
+				break
+			}
+		}
+	}
+	if idx < 0 {
+		if dir < 0 {
+			s.status = "No earlier prompt"
+		} else {
+			s.status = "No later prompt"
+		}
+		return
+	}
+
+	prompt := prompts[idx]
+	if view.copyMode {
+		view.cursor = terminalPos{Line: prompt.Line}
+		view.revealCursor(screen)
+	} else {
+		view.scrollTo(prompt.Line, screen)
+	}
+	s.status = fmt.Sprintf("Prompt %d/%d%s", idx+1, len(prompts), describePromptExit(prompt))
+}
+
+// describePromptExit describes how the command run from a prompt ended.
+func describePromptExit(p terminal.Prompt) string {
+	switch {
+	case p.Finished():
+		return fmt.Sprintf(": exited with status %d", p.ExitCode)
+	case p.Output >= 0:
+		return ": running"
+	default:
+		return ""
+	}
+}
+
+// terminalYankOutput copies the output of the last finished command shown
+// in the view into the clipboard registers, line-wise.
+func (s *appState) terminalYankOutput(screen *terminal.ScreenBuffer, view *terminalHistoryView) {
+	bottom := view.terminalHistoryTop() + view.pageLines
+	prompts := screen.Prompts()
+	idx := -1
+	for i := len(prompts) - 1; i >= 0; i-- {
+		if prompts[i].Finished() && prompts[i].Output < bottom {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		s.status = "No finished command output to yank (v starts copy mode)"
+		return
+	}
+
+	prompt := prompts[idx]
+	first, _ := screen.HistoryRange()
+	var lines []string
+	for abs := max(prompt.Output, first); abs < prompt.End; abs++ {
+		lines = append(lines, screen.ViewLine(abs).Text())
+	}
+	if len(lines) == 0 {
+		s.status = "The command printed nothing"
+		return
+	}
+
+	s.writeToSystemClipboard(strings.Join(lines, "\n") + "\n")
+	s.clipLines = lines
+	s.clipboardIsLine = true
+	s.status = fmt.Sprintf("Copied %d line(s) of command output%s", len(lines), describePromptExit(prompt))
+}
+
+// drawPromptMarks marks the prompts of finished commands in the left inset
+// of a terminal, green when the command succeeded and red when it failed.
+func (s *appState) drawPromptMarks(gtx layout.Context, screen *terminal.ScreenBuffer, top, lines, lineHeight int) {
+	for _, p := range screen.Prompts() {
+		if !p.Finished() || p.Line < top || p.Line >= top+lines {
+			continue
+		}
+		markColor := exitSuccessColor
+		if p.ExitCode != 0 {
+			markColor = exitFailureColor
+		}
+		y := (p.Line - top) * lineHeight
+		mark := clip.Rect{
+			Min: image.Pt(-gtx.Dp(10), y),
+			Max: image.Pt(-gtx.Dp(6), y+lineHeight),
+		}.Push(gtx.Ops)
+		paint.Fill(gtx.Ops, markColor)
+		mark.Pop()
+	}
+}
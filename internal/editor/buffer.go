@@ -4,6 +4,8 @@ import (
 	"os"
 	"strings"
 	"unicode/utf8"
+
+	"github.com/javanhut/vem/internal/textwidth"
 )
 
 // BufferType represents the type of buffer content
@@ -23,15 +25,22 @@ type UndoEntry struct {
 
 // Buffer represents an in-memory text buffer with a Vim-style cursor.
 type Buffer struct {
-	lines      []string
-	cursor     Cursor
-	filePath   string
-	modified   bool
-	undoStack  []UndoEntry
-	maxUndos   int
-	bufferType BufferType
-	terminal   interface{} // *terminal.Terminal (avoid import cycle)
-	readOnly   bool        // Prevent edits if true (for help, etc.)
+	lines        []string
+	cursor       Cursor
+	filePath     string
+	modified     bool
+	undoStack    []UndoEntry
+	maxUndos     int
+	bufferType   BufferType
+	terminal     interface{} // *terminal.Terminal (avoid import cycle)
+	readOnly     bool        // Prevent edits if true (for help, etc.)
+	marks        []*Mark     // Positions kept up to date through edits
+	id           int         // Set by the BufferManager, see ID
+	unlisted     bool        // Left out of :ls, :bnext and :bufdo
+	scratch      bool        // Never has unsaved changes, see CreateScratchBuffer
+	indent       IndentOptions
+	format       FileFormat        // Line endings and charset of the file, see LoadFromFile
+	editorConfig map[string]string // Properties from .editorconfig files, see OpenFileBuffer
 }
 
 // Cursor stores the current line/column position (1 rune == 1 column).
@@ -51,9 +60,17 @@ func NewBuffer(text string) *Buffer {
 		cursor:    Cursor{},
 		undoStack: make([]UndoEntry, 0),
 		maxUndos:  100,
+		indent:    DefaultIndentOptions(),
 	}
 }
 
+// ID returns the buffer's number, which the BufferManager hands out from 1
+// up as buffers are added and never reuses. It is 0 for buffers that
+// aren't managed.
+func (b *Buffer) ID() int {
+	return b.id
+}
+
 // LineCount returns the number of lines in the buffer.
 func (b *Buffer) LineCount() int {
 	return len(b.lines)
@@ -149,6 +166,7 @@ func (b *Buffer) DeleteLines(start, end int) {
 	if len(b.lines) == 0 {
 		b.lines = []string{""}
 	}
+	b.moveMarks(Cursor{Line: start}, Cursor{Line: end + 1}, Cursor{Line: start})
 	if start >= len(b.lines) {
 		start = len(b.lines) - 1
 	}
@@ -181,6 +199,7 @@ func (b *Buffer) InsertLines(at int, lines []string) {
 	newLines = append(newLines, linesCopy...)
 	newLines = append(newLines, b.lines[at:]...)
 	b.lines = newLines
+	b.moveMarks(Cursor{Line: at}, Cursor{Line: at}, Cursor{Line: at + len(linesCopy)})
 	b.cursor.Line = at + len(linesCopy) - 1
 	b.clampColumn()
 	b.markModified()
@@ -211,6 +230,7 @@ func (b *Buffer) InsertText(text string) {
 	suffix := append([]string{}, b.lines[b.cursor.Line+1:]...)
 
 	b.lines = append(append(prefix, segments...), suffix...)
+	b.moveMarks(b.cursor, b.cursor, endOfText(b.cursor, text))
 
 	b.cursor.Line += lastIdx
 	if lastIdx == 0 {
@@ -239,6 +259,7 @@ func (b *Buffer) DeleteBackward() bool {
 		prevLen := runeCount(b.lines[prev])
 		b.lines[prev] = b.lines[prev] + b.lines[b.cursor.Line]
 		b.lines = removeLine(b.lines, b.cursor.Line)
+		b.moveMarks(Cursor{Line: prev, Col: prevLen}, b.cursor, Cursor{Line: prev, Col: prevLen})
 		b.cursor.Line = prev
 		b.cursor.Col = prevLen
 		b.markModified()
@@ -249,15 +270,19 @@ func (b *Buffer) DeleteBackward() bool {
 	if b.cursor.Col > len(line) {
 		b.cursor.Col = len(line)
 	}
-	line = append(line[:b.cursor.Col-1], line[b.cursor.Col:]...)
+	// With softtabstop spaces go back to the previous stop at once
+	n := max(1, b.softTabDeletion())
+	line = append(line[:b.cursor.Col-n], line[b.cursor.Col:]...)
 	b.lines[b.cursor.Line] = string(line)
-	b.cursor.Col--
+	b.moveMarks(Cursor{Line: b.cursor.Line, Col: b.cursor.Col - n}, b.cursor, Cursor{Line: b.cursor.Line, Col: b.cursor.Col - n})
+	b.cursor.Col -= n
 	b.markModified()
 	return true
 }
 
-// DeleteForward deletes the rune at the cursor (delete semantics).
-// When at the end of a line, it merges with the following line.
+// DeleteForward deletes the character at the cursor with its combining
+// marks (delete semantics). When at the end of a line, it merges with the
+// following line.
 func (b *Buffer) DeleteForward() bool {
 	// Check if buffer is read-only
 	if b.readOnly {
@@ -268,24 +293,35 @@ func (b *Buffer) DeleteForward() bool {
 
 	lineRunes := []rune(b.lines[b.cursor.Line])
 	if b.cursor.Col < len(lineRunes) {
-		lineRunes = append(lineRunes[:b.cursor.Col], lineRunes[b.cursor.Col+1:]...)
+		end := b.cursor.Col + 1
+		for end < len(lineRunes) && textwidth.IsCombining(lineRunes[end]) {
+			end++
+		}
+		lineRunes = append(lineRunes[:b.cursor.Col], lineRunes[end:]...)
 		b.lines[b.cursor.Line] = string(lineRunes)
+		b.moveMarks(b.cursor, Cursor{Line: b.cursor.Line, Col: end}, b.cursor)
 		b.markModified()
 		return true
 	}
 	if b.cursor.Line >= len(b.lines)-1 {
 		return false
 	}
+	lineEnd := Cursor{Line: b.cursor.Line, Col: len(lineRunes)}
 	b.lines[b.cursor.Line] = b.lines[b.cursor.Line] + b.lines[b.cursor.Line+1]
 	b.lines = removeLine(b.lines, b.cursor.Line+1)
+	b.moveMarks(lineEnd, Cursor{Line: lineEnd.Line + 1}, lineEnd)
 	b.markModified()
 	return true
 }
 
 // MoveLeft moves the cursor left, spilling to the previous line when needed.
+// Combining marks are skipped along with the character they belong to.
 func (b *Buffer) MoveLeft() bool {
 	if b.cursor.Col > 0 {
 		b.cursor.Col--
+		for b.cursor.Col > 0 && b.combiningAt(b.cursor.Line, b.cursor.Col) {
+			b.cursor.Col--
+		}
 		return true
 	}
 	if b.cursor.Line == 0 {
@@ -297,10 +333,14 @@ func (b *Buffer) MoveLeft() bool {
 }
 
 // MoveRight moves the cursor right, spilling to the next line when needed.
+// Combining marks are skipped along with the character they belong to.
 func (b *Buffer) MoveRight() bool {
 	lineLen := b.lineLength(b.cursor.Line)
 	if b.cursor.Col < lineLen {
 		b.cursor.Col++
+		for b.cursor.Col < lineLen && b.combiningAt(b.cursor.Line, b.cursor.Col) {
+			b.cursor.Col++
+		}
 		return true
 	}
 	if b.cursor.Line >= len(b.lines)-1 {
@@ -545,6 +585,21 @@ func (b *Buffer) clampColumn() {
 	}
 }
 
+// combiningAt reports whether the rune at col of line is a combining mark,
+// which the cursor doesn't stop on.
+func (b *Buffer) combiningAt(line, col int) bool {
+	if line < 0 || line >= len(b.lines) {
+		return false
+	}
+	text := b.lines[line]
+	idx := byteIndexForRune(text, col)
+	if idx >= len(text) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(text[idx:])
+	return textwidth.IsCombining(r)
+}
+
 func (b *Buffer) lineLength(line int) int {
 	if line < 0 || line >= len(b.lines) {
 		return 0
@@ -614,7 +669,23 @@ func (b *Buffer) SetModified(modified bool) {
 
 // MarkModified marks the buffer as modified (used internally after edits).
 func (b *Buffer) markModified() {
-	b.modified = true
+	b.modified = !b.scratch
+}
+
+// Listed reports whether the buffer shows up in :ls and is visited by
+// :bnext, :bprevious and :bufdo.
+func (b *Buffer) Listed() bool {
+	return !b.unlisted
+}
+
+// SetListed adds the buffer to the buffer list or takes it off.
+func (b *Buffer) SetListed(listed bool) {
+	b.unlisted = !listed
+}
+
+// IsScratch reports whether the buffer is a scratch buffer.
+func (b *Buffer) IsScratch() bool {
+	return b.scratch
 }
 
 // SetReadOnly marks buffer as read-only (prevents edits).
@@ -634,7 +705,7 @@ func (b *Buffer) LoadFromFile(path string) error {
 		return err
 	}
 
-	text := string(content)
+	text := b.format.decode(content)
 	lines := strings.Split(text, "\n")
 
 	// Remove trailing empty line if file ends with newline
@@ -648,22 +719,32 @@ func (b *Buffer) LoadFromFile(path string) error {
 
 	b.lines = lines
 	b.cursor = Cursor{Line: 0, Col: 0}
+	b.clampMarks()
 	b.filePath = path
 	b.modified = false
 
 	return nil
 }
 
-// SaveToFile saves the buffer content to a file.
+// SaveToFile saves the buffer content to a file, in the buffer's
+// FileFormat.
 func (b *Buffer) SaveToFile(path string) error {
+	if b.format.TrimTrailingWhitespace {
+		b.trimTrailingWhitespace()
+	}
 	content := b.GetContent()
 
-	// Ensure file ends with newline
-	if !strings.HasSuffix(content, "\n") {
+	// The last line ends with a newline, the one LoadFromFile drops, unless
+	// the format says not to; blank lines before it are kept either way
+	if !b.format.NoFinalNewline && !strings.HasSuffix(content, "\n") {
 		content += "\n"
 	}
 
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+	data, err := b.format.encode(content)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
 		return err
 	}
 
@@ -690,6 +771,7 @@ func NewBufferFromFile(path string) (*Buffer, error) {
 	buf := &Buffer{
 		lines:  []string{""},
 		cursor: Cursor{},
+		indent: DefaultIndentOptions(),
 	}
 
 	if err := buf.LoadFromFile(path); err != nil {
@@ -774,6 +856,7 @@ func (b *Buffer) DeleteCharRange(startLine, startCol, endLine, endCol int) {
 			endCol = len(runes)
 		}
 		b.lines[startLine] = string(runes[:startCol]) + string(runes[endCol:])
+		b.moveMarks(Cursor{Line: startLine, Col: startCol}, Cursor{Line: startLine, Col: endCol}, Cursor{Line: startLine, Col: startCol})
 		b.cursor.Line = startLine
 		b.cursor.Col = startCol
 		b.markModified()
@@ -803,6 +886,7 @@ func (b *Buffer) DeleteCharRange(startLine, startCol, endLine, endCol int) {
 	if len(b.lines) == 0 {
 		b.lines = []string{""}
 	}
+	b.moveMarks(Cursor{Line: startLine, Col: startCol}, Cursor{Line: endLine, Col: endCol}, Cursor{Line: startLine, Col: startCol})
 
 	b.cursor.Line = startLine
 	b.cursor.Col = startCol
@@ -842,6 +926,7 @@ func (b *Buffer) Undo() bool {
 	// Restore state
 	b.lines = lastEntry.lines
 	b.cursor = lastEntry.cursor
+	b.clampMarks()
 	b.markModified()
 
 	return true
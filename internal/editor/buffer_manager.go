@@ -4,46 +4,40 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // BufferManager manages multiple buffers and tracks the active buffer.
+// Buffers are known by their ID, which stays the same while other buffers
+// are opened and closed, like Vim's buffer numbers.
 type BufferManager struct {
-	buffers     []*Buffer
-	activeIndex int
-	pathToIndex map[string]int
+	buffers     []*Buffer // In the order they were added
+	activeID    int
+	alternateID int // Buffer Ctrl+^ and :e # go back to, 0 for none
+	nextID      int
+	pathToID    map[string]int
+	indent      IndentOptions // Given to buffers as they are added
 }
 
 // NewBufferManager creates a new buffer manager with a default empty buffer.
 func NewBufferManager() *BufferManager {
-	defaultBuffer := NewBuffer("")
-	return &BufferManager{
-		buffers:     []*Buffer{defaultBuffer},
-		activeIndex: 0,
-		pathToIndex: make(map[string]int),
-	}
+	return NewBufferManagerWithBuffer(NewBuffer(""))
 }
 
 // NewBufferManagerWithBuffer creates a buffer manager with an initial buffer.
 func NewBufferManagerWithBuffer(buf *Buffer) *BufferManager {
 	bm := &BufferManager{
-		buffers:     []*Buffer{buf},
-		activeIndex: 0,
-		pathToIndex: make(map[string]int),
+		nextID:   1,
+		pathToID: make(map[string]int),
+		indent:   DefaultIndentOptions(),
 	}
-
-	if buf.FilePath() != "" {
-		bm.pathToIndex[buf.FilePath()] = 0
-	}
-
+	bm.addBuffer(buf)
 	return bm
 }
 
 // ActiveBuffer returns the currently active buffer.
 func (bm *BufferManager) ActiveBuffer() *Buffer {
-	if bm.activeIndex >= 0 && bm.activeIndex < len(bm.buffers) {
-		return bm.buffers[bm.activeIndex]
-	}
-	return nil
+	return bm.GetBuffer(bm.activeID)
 }
 
 // BufferCount returns the total number of buffers.
@@ -51,19 +45,57 @@ func (bm *BufferManager) BufferCount() int {
 	return len(bm.buffers)
 }
 
-// ActiveIndex returns the index of the active buffer.
-func (bm *BufferManager) ActiveIndex() int {
-	return bm.activeIndex
+// ActiveID returns the ID of the active buffer.
+func (bm *BufferManager) ActiveID() int {
+	return bm.activeID
 }
 
-// GetBuffer returns the buffer at the specified index.
-func (bm *BufferManager) GetBuffer(index int) *Buffer {
-	if index >= 0 && index < len(bm.buffers) {
-		return bm.buffers[index]
+// Alternate returns the alternate buffer, the one shown before the
+// current one, or nil if there is none.
+func (bm *BufferManager) Alternate() *Buffer {
+	return bm.GetBuffer(bm.alternateID)
+}
+
+// SetAlternate makes the buffer with the given ID the alternate buffer.
+func (bm *BufferManager) SetAlternate(id int) {
+	bm.alternateID = id
+}
+
+// IndentOptions returns the indent options buffers get when they are added.
+func (bm *BufferManager) IndentOptions() IndentOptions {
+	return bm.indent
+}
+
+// SetIndentOptions sets the indent options buffers added from now on get,
+// the global values of :set.
+func (bm *BufferManager) SetIndentOptions(o IndentOptions) {
+	bm.indent = o
+}
+
+// GetBuffer returns the buffer with the given ID, or nil if there is none.
+func (bm *BufferManager) GetBuffer(id int) *Buffer {
+	if i := bm.position(id); i >= 0 {
+		return bm.buffers[i]
 	}
 	return nil
 }
 
+// Buffers returns all buffers in the order they were added.
+func (bm *BufferManager) Buffers() []*Buffer {
+	return append([]*Buffer(nil), bm.buffers...)
+}
+
+// position returns where the buffer with the given ID is in bm.buffers,
+// or -1.
+func (bm *BufferManager) position(id int) int {
+	for i, buf := range bm.buffers {
+		if buf.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
 // GetBufferByPath returns the buffer for the given file path, or nil if not found.
 func (bm *BufferManager) GetBufferByPath(path string) *Buffer {
 	absPath, err := filepath.Abs(path)
@@ -71,14 +103,51 @@ func (bm *BufferManager) GetBufferByPath(path string) *Buffer {
 		return nil
 	}
 
-	if index, exists := bm.pathToIndex[absPath]; exists {
-		return bm.buffers[index]
+	if id, exists := bm.pathToID[absPath]; exists {
+		return bm.GetBuffer(id)
 	}
 	return nil
 }
 
+// FindBuffer returns the buffer name refers to, for :buffer name. An exact
+// file path or file name wins; otherwise name must be part of the path of
+// exactly one buffer.
+func (bm *BufferManager) FindBuffer(name string) (*Buffer, error) {
+	if buf := bm.GetBufferByPath(name); buf != nil {
+		return buf, nil
+	}
+
+	var exact, partial []*Buffer
+	for _, buf := range bm.buffers {
+		path := buf.FilePath()
+		if path == "" {
+			continue
+		}
+		switch {
+		case filepath.Base(path) == name:
+			exact = append(exact, buf)
+		case strings.Contains(path, name):
+			partial = append(partial, buf)
+		}
+	}
+	matches := exact
+	if len(matches) == 0 {
+		matches = partial
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no matching buffer for %s", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("more than one match for %s", name)
+	}
+}
+
 // OpenFile opens a file into a new or existing buffer and makes it active.
-// If the file is already open, it switches to that buffer instead.
+// If the file is already open, it switches to that buffer instead. See
+// OpenFileBuffer for new buffers.
 func (bm *BufferManager) OpenFile(path string) (*Buffer, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
@@ -87,53 +156,69 @@ func (bm *BufferManager) OpenFile(path string) (*Buffer, error) {
 
 	// Check if already open
 	if existing := bm.GetBufferByPath(absPath); existing != nil {
-		// Switch to existing buffer
-		for i, buf := range bm.buffers {
-			if buf == existing {
-				bm.activeIndex = i
-				return buf, nil
-			}
-		}
+		bm.activeID = existing.id
+		return existing, nil
 	}
 
+	buf, err := OpenFileBuffer(absPath)
+	if err != nil {
+		return nil, err
+	}
+	return bm.addBuffer(buf), nil
+}
+
+// OpenFileBuffer loads the file at path, an absolute path, into a new
+// buffer that isn't managed yet, or makes an empty buffer for it if the
+// file doesn't exist. The buffer's indent options and file format come
+// from the .editorconfig files above the file, and win over the global
+// indent options when the buffer is added to a BufferManager.
+func OpenFileBuffer(path string) (*Buffer, error) {
+	buf := NewBuffer("")
+	buf.editorConfig = editorConfig(path)
+	buf.applyEditorConfig(buf.editorConfig)
+
 	// Check if file exists
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		// Create new empty buffer for new file
-		buf := NewBuffer("")
-		buf.SetFilePath(absPath)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// Empty buffer for new file
+		buf.SetFilePath(path)
 		buf.SetModified(false)
-		return bm.addBuffer(buf), nil
+		return buf, nil
 	}
 
-	// Load existing file
-	buf, err := NewBufferFromFile(absPath)
-	if err != nil {
+	// Load existing file, decoding it in the charset .editorconfig gives
+	if err := buf.LoadFromFile(path); err != nil {
 		return nil, err
 	}
-
-	return bm.addBuffer(buf), nil
+	return buf, nil
 }
 
 // addBuffer adds a buffer to the manager and makes it active.
 func (bm *BufferManager) addBuffer(buf *Buffer) *Buffer {
+	bm.activeID = bm.register(buf)
+	return buf
+}
+
+// register gives buf the next ID and adds it after the other buffers.
+func (bm *BufferManager) register(buf *Buffer) int {
+	buf.id = bm.nextID
+	bm.nextID++
+	buf.indent = bm.indent
+	buf.applyEditorConfig(buf.editorConfig)
 	bm.buffers = append(bm.buffers, buf)
-	bm.activeIndex = len(bm.buffers) - 1
 
 	if buf.FilePath() != "" {
-		bm.pathToIndex[buf.FilePath()] = bm.activeIndex
+		bm.pathToID[buf.FilePath()] = buf.id
 	}
 
-	return buf
+	return buf.id
 }
 
-// CreateEmptyBuffer creates a new empty buffer and returns its index.
+// CreateEmptyBuffer creates a new empty buffer and returns its ID.
 func (bm *BufferManager) CreateEmptyBuffer() int {
-	buf := NewBuffer("")
-	bm.buffers = append(bm.buffers, buf)
-	return len(bm.buffers) - 1
+	return bm.register(NewBuffer(""))
 }
 
-// CreateTerminalBuffer creates a new buffer for a terminal and returns its index.
+// CreateTerminalBuffer creates a new buffer for a terminal and returns its ID.
 func (bm *BufferManager) CreateTerminalBuffer() int {
 	buf := &Buffer{
 		lines:      []string{""},
@@ -142,15 +227,21 @@ func (bm *BufferManager) CreateTerminalBuffer() int {
 		undoStack:  make([]UndoEntry, 0),
 		maxUndos:   100,
 	}
-	bm.buffers = append(bm.buffers, buf)
-	return len(bm.buffers) - 1
+	return bm.register(buf)
 }
 
-// CreateBufferWithContent creates a new buffer with the given content and returns its index.
+// CreateScratchBuffer creates an unlisted buffer that is never modified,
+// for notes and command output that needn't be saved, and returns its ID.
+func (bm *BufferManager) CreateScratchBuffer() int {
+	buf := NewBuffer("")
+	buf.scratch = true
+	buf.unlisted = true
+	return bm.register(buf)
+}
+
+// CreateBufferWithContent creates a new buffer with the given content and returns its ID.
 func (bm *BufferManager) CreateBufferWithContent(content string) int {
-	buf := NewBuffer(content)
-	bm.buffers = append(bm.buffers, buf)
-	return len(bm.buffers) - 1
+	return bm.register(NewBuffer(content))
 }
 
 // SaveActiveBuffer saves the currently active buffer.
@@ -181,7 +272,7 @@ func (bm *BufferManager) SaveAs(path string) error {
 
 	// Remove old path mapping
 	if buf.FilePath() != "" {
-		delete(bm.pathToIndex, buf.FilePath())
+		delete(bm.pathToID, buf.FilePath())
 	}
 
 	// Save to new path
@@ -190,16 +281,17 @@ func (bm *BufferManager) SaveAs(path string) error {
 	}
 
 	// Update path mapping
-	bm.pathToIndex[absPath] = bm.activeIndex
+	bm.pathToID[absPath] = buf.id
 
 	return nil
 }
 
-// CloseBuffer closes the buffer at the specified index.
+// CloseBuffer closes the buffer with the given ID.
 // If it's modified, returns an error unless force is true.
-func (bm *BufferManager) CloseBuffer(index int, force bool) error {
-	if index < 0 || index >= len(bm.buffers) {
-		return fmt.Errorf("invalid buffer index")
+func (bm *BufferManager) CloseBuffer(id int, force bool) error {
+	index := bm.position(id)
+	if index < 0 {
+		return fmt.Errorf("no buffer %d", id)
 	}
 
 	buf := bm.buffers[index]
@@ -214,29 +306,25 @@ func (bm *BufferManager) CloseBuffer(index int, force bool) error {
 
 	// Remove from path mapping
 	if buf.FilePath() != "" {
-		delete(bm.pathToIndex, buf.FilePath())
+		delete(bm.pathToID, buf.FilePath())
 	}
 
 	// Remove buffer
 	bm.buffers = append(bm.buffers[:index], bm.buffers[index+1:]...)
-
-	// Update path mappings for shifted buffers
-	for i := index; i < len(bm.buffers); i++ {
-		if bm.buffers[i].FilePath() != "" {
-			bm.pathToIndex[bm.buffers[i].FilePath()] = i
-		}
+	if bm.alternateID == id {
+		bm.alternateID = 0
 	}
 
-	// Adjust active index
-	if len(bm.buffers) == 0 {
+	// The buffer that took its place becomes active, or the last one
+	switch {
+	case len(bm.buffers) == 0:
 		// Create default empty buffer
-		defaultBuf := NewBuffer("")
-		bm.buffers = []*Buffer{defaultBuf}
-		bm.activeIndex = 0
-	} else if bm.activeIndex >= len(bm.buffers) {
-		bm.activeIndex = len(bm.buffers) - 1
-	} else if bm.activeIndex > index {
-		bm.activeIndex--
+		bm.addBuffer(NewBuffer(""))
+	case bm.activeID != id:
+	case index < len(bm.buffers):
+		bm.activeID = bm.buffers[index].id
+	default:
+		bm.activeID = bm.buffers[len(bm.buffers)-1].id
 	}
 
 	return nil
@@ -244,53 +332,69 @@ func (bm *BufferManager) CloseBuffer(index int, force bool) error {
 
 // CloseActiveBuffer closes the currently active buffer.
 func (bm *BufferManager) CloseActiveBuffer(force bool) error {
-	return bm.CloseBuffer(bm.activeIndex, force)
+	return bm.CloseBuffer(bm.activeID, force)
 }
 
-// NextBuffer switches to the next buffer (wraps around).
+// NextBuffer switches to the next listed buffer (wraps around).
 func (bm *BufferManager) NextBuffer() bool {
-	if len(bm.buffers) <= 1 {
-		return false
-	}
-
-	bm.activeIndex = (bm.activeIndex + 1) % len(bm.buffers)
-	return true
+	return bm.cycle(1)
 }
 
-// PrevBuffer switches to the previous buffer (wraps around).
+// PrevBuffer switches to the previous listed buffer (wraps around).
 func (bm *BufferManager) PrevBuffer() bool {
-	if len(bm.buffers) <= 1 {
-		return false
-	}
+	return bm.cycle(-1)
+}
 
-	bm.activeIndex--
-	if bm.activeIndex < 0 {
-		bm.activeIndex = len(bm.buffers) - 1
+func (bm *BufferManager) cycle(step int) bool {
+	n := len(bm.buffers)
+	i := bm.position(bm.activeID)
+	for range n - 1 {
+		i = ((i+step)%n + n) % n
+		if bm.buffers[i].Listed() {
+			bm.activeID = bm.buffers[i].id
+			return true
+		}
 	}
-	return true
+	return false
 }
 
-// SwitchToBuffer switches to the buffer at the specified index.
-func (bm *BufferManager) SwitchToBuffer(index int) bool {
-	if index >= 0 && index < len(bm.buffers) {
-		bm.activeIndex = index
-		return true
+// SwitchToBuffer switches to the buffer with the given ID.
+func (bm *BufferManager) SwitchToBuffer(id int) bool {
+	if bm.position(id) < 0 {
+		return false
 	}
-	return false
+	bm.activeID = id
+	return true
 }
 
-// ListBuffers returns a slice of buffer info for display.
-func (bm *BufferManager) ListBuffers() []string {
-	result := make([]string, len(bm.buffers))
-	for i, buf := range bm.buffers {
-		prefix := " "
-		if i == bm.activeIndex {
-			prefix = "*"
+// ListBuffers returns a line for each listed buffer, or every buffer with
+// all, for :ls. The flags follow Vim's: u for unlisted, % for the active
+// buffer and # for the alternate one, a for shown in a pane and h for
+// hidden, and + for unsaved changes.
+func (bm *BufferManager) ListBuffers(all bool, shown map[int]bool) []string {
+	var result []string
+	for _, buf := range bm.buffers {
+		if !all && !buf.Listed() {
+			continue
 		}
 
-		modFlag := " "
+		flags := []byte("     ")
+		if !buf.Listed() {
+			flags[0] = 'u'
+		}
+		switch buf.id {
+		case bm.activeID:
+			flags[1] = '%'
+		case bm.alternateID:
+			flags[1] = '#'
+		}
+		if shown[buf.id] {
+			flags[2] = 'a'
+		} else {
+			flags[2] = 'h'
+		}
 		if buf.Modified() {
-			modFlag = "+"
+			flags[4] = '+'
 		}
 
 		name := buf.FilePath()
@@ -302,7 +406,7 @@ func (bm *BufferManager) ListBuffers() []string {
 			}
 		}
 
-		result[i] = fmt.Sprintf("%s %d %s %s", prefix, i+1, modFlag, name)
+		result = append(result, fmt.Sprintf("%d %s %s", buf.id, flags, name))
 	}
 	return result
 }
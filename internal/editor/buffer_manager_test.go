@@ -0,0 +1,256 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBufferIDsSurviveClose(t *testing.T) {
+	bm := NewBufferManager()
+	second := bm.CreateEmptyBuffer()
+	third := bm.CreateBufferWithContent("third")
+	if bm.Buffers()[0].ID() != 1 || second != 2 || third != 3 {
+		t.Fatalf("IDs got %d, %d, %d", bm.Buffers()[0].ID(), second, third)
+	}
+
+	bm.SwitchToBuffer(second)
+	if err := bm.CloseBuffer(second, false); err != nil {
+		t.Fatalf("CloseBuffer: %v", err)
+	}
+	if got := bm.GetBuffer(third); got == nil || got.Line(0) != "third" {
+		t.Fatalf("buffer %d after closing %d got %v", third, second, got)
+	}
+	if bm.GetBuffer(second) != nil {
+		t.Fatalf("closed buffer %d still found", second)
+	}
+	if bm.ActiveID() != third {
+		t.Fatalf("active got %d, want the buffer after the closed one", bm.ActiveID())
+	}
+
+	// IDs are not reused
+	if id := bm.CreateEmptyBuffer(); id != 4 {
+		t.Fatalf("new buffer got ID %d", id)
+	}
+}
+
+func TestFindBuffer(t *testing.T) {
+	dir := t.TempDir()
+	bm := NewBufferManager()
+	for _, name := range []string{"main.go", "main_test.go", "util.go"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bm.OpenFile(path); err != nil {
+			t.Fatalf("OpenFile: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"main.go", "main.go", true}, // Exact file name wins over main_test.go
+		{"util", "util.go", true},
+		{"main", "", false}, // Two matches
+		{"nothing", "", false},
+	}
+	for _, tt := range tests {
+		buf, err := bm.FindBuffer(tt.name)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("FindBuffer(%q) got %s, want an error", tt.name, buf.FilePath())
+			}
+			continue
+		}
+		if err != nil || filepath.Base(buf.FilePath()) != tt.want {
+			t.Errorf("FindBuffer(%q) got %v, %v want %s", tt.name, buf, err, tt.want)
+		}
+	}
+}
+
+func TestUnlistedBuffers(t *testing.T) {
+	bm := NewBufferManager()
+	scratch := bm.CreateScratchBuffer()
+	third := bm.CreateEmptyBuffer()
+
+	// Cycling skips the scratch buffer both ways
+	if !bm.NextBuffer() || bm.ActiveID() != third {
+		t.Fatalf("next buffer got %d want %d", bm.ActiveID(), third)
+	}
+	if !bm.PrevBuffer() || bm.ActiveID() != 1 {
+		t.Fatalf("previous buffer got %d want 1", bm.ActiveID())
+	}
+
+	if got := len(bm.ListBuffers(false, nil)); got != 2 {
+		t.Fatalf(":ls listed %d buffers, want 2", got)
+	}
+	all := bm.ListBuffers(true, map[int]bool{1: true})
+	if len(all) != 3 || all[0] != "1  %a   [No Name]" || all[1] != "2 u h   [No Name]" {
+		t.Fatalf(":ls! got %q", all)
+	}
+
+	buf := bm.GetBuffer(scratch)
+	buf.InsertText("notes")
+	if buf.Modified() {
+		t.Fatalf("scratch buffer got modified")
+	}
+}
+
+func TestEditorConfigGlob(t *testing.T) {
+	tests := []struct {
+		glob, path string
+		want       bool
+	}{
+		{"*", "main.go", true},
+		{"*", "sub/main.go", true},
+		{"*.go", "internal/editor/buffer.go", true},
+		{"*.go", "go.mod", false},
+		{"*.{js,py}", "lib/app.py", true},
+		{"*.{js,py}", "lib/app.rb", false},
+		{"lib/**.js", "lib/a/b/c.js", true},
+		{"/lib/*.js", "lib/a/c.js", false},
+		{"Makefile", "sub/Makefile", true},
+		{"file[0-9].txt", "file7.txt", true},
+		{"file[!0-9].txt", "file7.txt", false},
+		{"file{1..10}.txt", "file10.txt", true},
+		{"file{1..10}.txt", "file11.txt", false},
+		{"{single}.txt", "{single}.txt", true},
+	}
+	for _, tt := range tests {
+		re, err := editorConfigGlob(tt.glob)
+		if err != nil {
+			t.Fatalf("editorConfigGlob(%q): %v", tt.glob, err)
+		}
+		if got := re.MatchString(tt.path); got != tt.want {
+			t.Errorf("%q matching %q got %v, want %v", tt.glob, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestOpenFileAppliesEditorConfig(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, editorConfigName), `root = true
+
+[*]
+indent_style = space
+indent_size = 2
+end_of_line = crlf
+trim_trailing_whitespace = true
+
+[*.md]
+trim_trailing_whitespace = false
+`)
+	write(filepath.Join(sub, editorConfigName), `[*.go]
+indent_style = Tab
+tab_width = 8
+insert_final_newline = false
+`)
+	path := filepath.Join(sub, "main.go")
+	write(path, "package main\r\n\r\nfunc main() {}  \r\n")
+
+	bm := NewBufferManager()
+	buf, err := bm.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	o := buf.IndentOptions()
+	if o.ExpandTab || o.ShiftWidth != 2 || o.TabStop != 8 {
+		t.Fatalf("indent options got %+v", o)
+	}
+	if got := buf.Line(2); got != "func main() {}  " {
+		t.Fatalf("line 2 got %q, want the CR stripped", got)
+	}
+
+	if err := buf.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "package main\r\n\r\nfunc main() {}"; string(data) != want {
+		t.Fatalf("saved %q, want %q", data, want)
+	}
+
+	// Buffers for new files get the settings too, and others keep the defaults
+	notes, err := bm.OpenFile(filepath.Join(dir, "notes.md"))
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if f := notes.FileFormat(); f.TrimTrailingWhitespace || f.EndOfLine != "crlf" || !notes.IndentOptions().ExpandTab {
+		t.Fatalf("notes.md got %+v, %+v", f, notes.IndentOptions())
+	}
+	if f := bm.Buffers()[0].FileFormat(); f != (FileFormat{}) {
+		t.Fatalf("first buffer got format %+v", f)
+	}
+}
+
+func TestFileFormatCharsets(t *testing.T) {
+	for _, charset := range []string{"utf-8", "utf-8-bom", "latin1", "utf-16be", "utf-16le"} {
+		f := FileFormat{Charset: charset}
+		data, err := f.encode("café\n")
+		if err != nil {
+			t.Fatalf("%s: encode: %v", charset, err)
+		}
+		if got := f.decode(data); got != "café\n" {
+			t.Errorf("%s: round trip got %q", charset, got)
+		}
+	}
+	if _, err := (FileFormat{Charset: "latin1"}).encode("€"); err == nil {
+		t.Fatal("encoding € as latin1 succeeded")
+	}
+}
+
+func TestEditorConfigLineEndings(t *testing.T) {
+	tests := []struct {
+		name, config, file string
+		lines              []string
+		saved              string
+	}{
+		{"crlf file under lf", "end_of_line = lf", "a\r\nb\r\n", []string{"a", "b"}, "a\nb\n"},
+		{"crlf file under cr", "end_of_line = cr", "a\r\nb\r\n", []string{"a", "b"}, "a\rb\r"},
+		{"cr file under cr", "end_of_line = cr", "a\rb\r", []string{"a", "b"}, "a\rb\r"},
+		{"crlf file without a setting", "", "a\r\nb\r\n", []string{"a", "b"}, "a\r\nb\r\n"},
+		{"blank lines without final newline", "insert_final_newline = false", "a\n\n\nb\n\n", []string{"a", "", "", "b", ""}, "a\n\n\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := "root = true\n[*]\n" + tt.config + "\n"
+			if err := os.WriteFile(filepath.Join(dir, editorConfigName), []byte(config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, "file.txt")
+			if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			buf, err := NewBufferManager().OpenFile(path)
+			if err != nil {
+				t.Fatalf("OpenFile: %v", err)
+			}
+			if got := buf.LinesRange(0, buf.LineCount()-1); strings.Join(got, "|") != strings.Join(tt.lines, "|") {
+				t.Fatalf("lines got %q, want %q", got, tt.lines)
+			}
+			if err := buf.Save(); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if data, _ := os.ReadFile(path); string(data) != tt.saved {
+				t.Fatalf("saved %q, want %q", data, tt.saved)
+			}
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package editor
 
-import "testing"
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/javanhut/vem/internal/syntax"
+)
 
 func TestInsertTextWithinLine(t *testing.T) {
 	buf := NewBuffer("abc")
@@ -96,6 +102,31 @@ func TestDeleteForward(t *testing.T) {
 	}
 }
 
+func TestMoveOverCombiningMarks(t *testing.T) {
+	// "e" with an acute accent and a circumflex, then a wide character
+	buf := NewBuffer("xe\u0301\u0302日y")
+	buf.cursor.Col = 1
+
+	for _, want := range []int{4, 5} {
+		buf.MoveRight()
+		if buf.cursor.Col != want {
+			t.Fatalf("move right got col %d want %d", buf.cursor.Col, want)
+		}
+	}
+	for _, want := range []int{4, 1, 0} {
+		buf.MoveLeft()
+		if buf.cursor.Col != want {
+			t.Fatalf("move left got col %d want %d", buf.cursor.Col, want)
+		}
+	}
+
+	buf.cursor.Col = 1
+	buf.DeleteForward()
+	if got, want := buf.Line(0), "x日y"; got != want {
+		t.Fatalf("line got %q want %q", got, want)
+	}
+}
+
 func TestDeleteLinesRange(t *testing.T) {
 	buf := NewBuffer("l1\nl2\nl3\nl4")
 	buf.DeleteLines(1, 2)
@@ -180,3 +211,312 @@ func TestInsertLines(t *testing.T) {
 		t.Fatalf("cursor line expected 2 got %d", buf.cursor.Line)
 	}
 }
+
+func TestMarksFollowEdits(t *testing.T) {
+	buf := NewBuffer("one\ntwo\nthree")
+	after := buf.NewMark(Cursor{Line: 2, Col: 3})
+	middle := buf.NewMark(Cursor{Line: 1, Col: 1})
+	before := buf.NewMark(Cursor{Line: 0, Col: 1})
+
+	// Splitting line 0 after "on" moves the later marks down a line
+	buf.cursor = Cursor{Line: 0, Col: 2}
+	buf.InsertText("X\n")
+	if got, want := after.Cursor, (Cursor{Line: 3, Col: 3}); got != want {
+		t.Fatalf("mark after insert got %+v want %+v", got, want)
+	}
+	if got, want := before.Cursor, (Cursor{Line: 0, Col: 1}); got != want {
+		t.Fatalf("mark before insert got %+v want %+v", got, want)
+	}
+
+	// Deleting from "tw|o" through "th|ree" leaves the mark before it and
+	// brings the one after onto the joined line
+	buf.DeleteCharRange(2, 2, 3, 2)
+	if got, want := buf.Line(2), "twree"; got != want {
+		t.Fatalf("line 2 got %q want %q", got, want)
+	}
+	if got, want := middle.Cursor, (Cursor{Line: 2, Col: 1}); got != want {
+		t.Fatalf("mark before range got %+v want %+v", got, want)
+	}
+	if got, want := after.Cursor, (Cursor{Line: 2, Col: 3}); got != want {
+		t.Fatalf("mark after range got %+v want %+v", got, want)
+	}
+
+	buf.DeleteLines(0, 1)
+	if got, want := after.Cursor, (Cursor{Line: 0, Col: 3}); got != want {
+		t.Fatalf("mark after deleted lines got %+v want %+v", got, want)
+	}
+	if got, want := before.Cursor, (Cursor{Line: 0, Col: 0}); got != want {
+		t.Fatalf("mark in deleted lines got %+v want %+v", got, want)
+	}
+
+	after.Release()
+	buf.InsertLines(0, []string{"new"})
+	if got, want := after.Cursor, (Cursor{Line: 0, Col: 3}); got != want {
+		t.Fatalf("released mark moved to %+v", got)
+	}
+	if got, want := middle.Cursor, (Cursor{Line: 1, Col: 1}); got != want {
+		t.Fatalf("mark after inserted lines got %+v want %+v", got, want)
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	buf := NewBuffer("old old\nkeep\nold one")
+	re := regexp.MustCompile(`(o)ld`)
+
+	if n := buf.Substitute(re, "n${1}w", 0, 2, false); n != 2 {
+		t.Fatalf("replacements got %d want 2", n)
+	}
+	if got, want := buf.GetContent(), "now old\nkeep\nnow one"; got != want {
+		t.Fatalf("content got %q want %q", got, want)
+	}
+	if buf.cursor != (Cursor{Line: 2}) {
+		t.Fatalf("cursor got %+v want the start of the last changed line", buf.cursor)
+	}
+
+	if n := buf.Substitute(re, "new", 0, 0, true); n != 1 {
+		t.Fatalf("global replacements got %d want 1", n)
+	}
+	buf.Undo()
+	if got, want := buf.Line(0), "now old"; got != want {
+		t.Fatalf("line after undo got %q want %q", got, want)
+	}
+	if n := buf.Substitute(regexp.MustCompile("missing"), "x", 0, 2, true); n != 0 || !buf.Modified() {
+		t.Fatalf("no match got %d replacements", n)
+	}
+}
+
+func TestSubstituteMovesOtherPaneCursors(t *testing.T) {
+	// Panes showing the buffer keep their cursors in marks while another
+	// pane edits it
+	buf := NewBuffer("old old x\nkeep\néold y")
+	after := buf.NewMark(Cursor{Line: 0, Col: 8})
+	inside := buf.NewMark(Cursor{Line: 0, Col: 5})
+	other := buf.NewMark(Cursor{Line: 1, Col: 2})
+	wide := buf.NewMark(Cursor{Line: 2, Col: 5})
+
+	if n := buf.Substitute(regexp.MustCompile("old"), "n", 0, 2, true); n != 3 {
+		t.Fatalf("replacements got %d want 3", n)
+	}
+	tests := []struct {
+		name string
+		mark *Mark
+		want Cursor
+	}{
+		{"after both matches", after, Cursor{Line: 0, Col: 4}},
+		{"in the second match", inside, Cursor{Line: 0, Col: 2}},
+		{"on an unchanged line", other, Cursor{Line: 1, Col: 2}},
+		{"after a multibyte rune", wide, Cursor{Line: 2, Col: 3}},
+	}
+	for _, tt := range tests {
+		if tt.mark.Cursor != tt.want {
+			t.Errorf("%s: mark got %+v want %+v", tt.name, tt.mark.Cursor, tt.want)
+		}
+	}
+
+	// Growing the text moves the cursor along past the old end of the line
+	buf = NewBuffer("a b")
+	end := buf.NewMark(Cursor{Line: 0, Col: 3})
+	buf.Substitute(regexp.MustCompile(" "), "   ", 0, 0, false)
+	if got, want := end.Cursor, (Cursor{Line: 0, Col: 5}); got != want {
+		t.Fatalf("mark after a longer replacement got %+v want %+v", got, want)
+	}
+}
+
+func TestTrimTrailingWhitespaceMovesMarks(t *testing.T) {
+	buf := NewBuffer("ab  \ncd\t\nef")
+	inBlanks := buf.NewMark(Cursor{Line: 0, Col: 3})
+	kept := buf.NewMark(Cursor{Line: 1, Col: 1})
+	last := buf.NewMark(Cursor{Line: 2, Col: 2})
+
+	buf.trimTrailingWhitespace()
+	if got, want := buf.GetContent(), "ab\ncd\nef"; got != want {
+		t.Fatalf("content got %q want %q", got, want)
+	}
+	for _, tt := range []struct {
+		mark *Mark
+		want Cursor
+	}{
+		{inBlanks, Cursor{Line: 0, Col: 2}},
+		{kept, Cursor{Line: 1, Col: 1}},
+		{last, Cursor{Line: 2, Col: 2}},
+	} {
+		if tt.mark.Cursor != tt.want {
+			t.Errorf("mark got %+v want %+v", tt.mark.Cursor, tt.want)
+		}
+	}
+}
+
+func TestInsertNewlineIndents(t *testing.T) {
+	buf := NewBuffer("\tif x {}")
+	buf.cursor.Col = 7
+
+	buf.InsertNewline()
+
+	if got, want := buf.GetContent(), "\tif x {\n\t\t\n\t}"; got != want {
+		t.Fatalf("content got %q want %q", got, want)
+	}
+	if buf.cursor != (Cursor{Line: 1, Col: 2}) {
+		t.Fatalf("cursor got %+v want the end of the new indent", buf.cursor)
+	}
+
+	buf.TypeText("x")
+	buf.TypeText("\n")
+	buf.TypeText("}")
+	if got, want := buf.Line(2), "\t}"; got != want {
+		t.Fatalf("closer line got %q want %q", got, want)
+	}
+
+	py := NewBuffer("    if x:")
+	py.SetFilePath("a.py")
+	py.SetIndentOptions(IndentOptions{AutoIndent: true, SmartIndent: true, ExpandTab: true, ShiftWidth: 4})
+	py.cursor.Col = 9
+	py.TypeText("\n")
+	py.TypeText("return")
+	py.TypeText("\n")
+	if got, want := py.GetContent(), "    if x:\n        return\n    "; got != want {
+		t.Fatalf("python content got %q want %q", got, want)
+	}
+}
+
+func TestBracketBalance(t *testing.T) {
+	tests := []struct {
+		code string
+		want int
+	}{
+		{"f(a, [b]) {", 1},
+		{"}) + g(", 1},
+		{`s := "({[" + x(`, 1},
+		{`s := "\"(" + '\'' + ` + "`)`" + `)`, -1},
+		{strings.Repeat(`"(" `, 50000) + "(", 1},
+	}
+	for _, tt := range tests {
+		if got := bracketBalance(tt.code); got != tt.want {
+			t.Errorf("bracketBalance(%.40q) = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestSoftTabStop(t *testing.T) {
+	buf := NewBuffer("ab")
+	buf.SetIndentOptions(IndentOptions{ExpandTab: true, ShiftWidth: 4, TabStop: 8, SoftTabStop: -1})
+	buf.cursor.Col = 2
+
+	buf.InsertTab()
+	buf.InsertTab()
+	if got, want := buf.Line(0), "ab      "; got != want {
+		t.Fatalf("line after tabs got %q want %q", got, want)
+	}
+	buf.DeleteBackward()
+	if got, want := buf.Line(0), "ab  "; got != want {
+		t.Fatalf("line after backspace got %q want %q", got, want)
+	}
+
+	buf.SetIndentOptions(IndentOptions{ShiftWidth: 4, TabStop: 8, SoftTabStop: 4})
+	buf.InsertTab()
+	if got, want := buf.Line(0), "ab\t"; got != want {
+		t.Fatalf("spaces not turned into a tab: got %q want %q", got, want)
+	}
+}
+
+func TestShiftAndReindentLines(t *testing.T) {
+	buf := NewBuffer("func f() {\nx()\n\n  if y {\nz()\n      }\n}")
+	buf.SetIndentOptions(IndentOptions{TabStop: 4})
+
+	buf.ReindentLines(0, 6)
+	if got, want := buf.GetContent(), "func f() {\n\tx()\n\n\tif y {\n\t\tz()\n\t}\n}"; got != want {
+		t.Fatalf("reindented got %q want %q", got, want)
+	}
+
+	buf.SetIndentOptions(IndentOptions{ExpandTab: true, ShiftWidth: 2, TabStop: 4})
+	buf.ShiftLines(1, 2, 1)
+	if got, want := buf.LinesRange(1, 2), []string{"      x()", ""}; got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("shifted got %q want %q", got, want)
+	}
+	buf.ShiftLines(1, 1, -4)
+	if got, want := buf.Line(1), "x()"; got != want {
+		t.Fatalf("shifted left got %q want %q", got, want)
+	}
+	buf.Undo()
+	if got, want := buf.Line(1), "      x()"; got != want {
+		t.Fatalf("undo got %q want %q", got, want)
+	}
+}
+
+func TestMatchingBracket(t *testing.T) {
+	buf := NewBuffer("f(a, \"(\", [b]) {\n\tg()\n}")
+	// The ( in the string at column 6 doesn't count
+	inString := func(pos Cursor) bool { return pos.Line == 0 && pos.Col >= 5 && pos.Col <= 7 }
+
+	tests := []struct {
+		pos, want Cursor
+	}{
+		{Cursor{0, 1}, Cursor{0, 13}},
+		{Cursor{0, 13}, Cursor{0, 1}},
+		{Cursor{0, 10}, Cursor{0, 12}},
+		{Cursor{0, 15}, Cursor{2, 0}},
+		{Cursor{2, 0}, Cursor{0, 15}},
+	}
+	for _, tt := range tests {
+		if got, ok := buf.MatchingBracket(tt.pos, inString, 0); !ok || got != tt.want {
+			t.Errorf("MatchingBracket(%v) got %v, %v, want %v", tt.pos, got, ok, tt.want)
+		}
+	}
+	if _, ok := buf.MatchingBracket(Cursor{0, 15}, inString, 1); ok {
+		t.Error("found a match past maxLines")
+	}
+
+	// % uses the first bracket at or after the cursor
+	buf.SetCursor(Cursor{Line: 1, Col: 0})
+	if !buf.JumpToMatchingBracket(inString) || buf.Cursor() != (Cursor{1, 3}) {
+		t.Fatalf("%% got cursor %v", buf.Cursor())
+	}
+}
+
+func TestMatchingBracketSkipsBlockComments(t *testing.T) {
+	lines := []string{
+		"func f() {",
+		"\t/* } (",
+		"\t   ) { */",
+		"\ts := `{",
+		"}`",
+		"}",
+	}
+	buf := NewBuffer(strings.Join(lines, "\n"))
+	inside := syntax.NewHighlighter("f.go").StringOrComment(lines)
+	skip := func(pos Cursor) bool { return inside(pos.Line, pos.Col) }
+
+	if got, ok := buf.MatchingBracket(Cursor{0, 9}, skip, 0); !ok || got != (Cursor{5, 0}) {
+		t.Fatalf("MatchingBracket got %v, %v, want {5 0}", got, ok)
+	}
+	if got, ok := buf.MatchingBracket(Cursor{5, 0}, skip, 0); !ok || got != (Cursor{0, 9}) {
+		t.Fatalf("MatchingBracket backward got %v, %v, want {0 9}", got, ok)
+	}
+	// Within the comment its brackets still pair with each other
+	if got, ok := buf.MatchingBracket(Cursor{1, 6}, nil, 0); !ok || got != (Cursor{2, 4}) {
+		t.Fatalf("MatchingBracket in the comment got %v, %v, want {2 4}", got, ok)
+	}
+}
+
+func TestTypePaired(t *testing.T) {
+	buf := NewBuffer("")
+	for _, r := range "f(\"a\")" {
+		buf.TypePaired(string(r))
+	}
+	if got := buf.Line(0); got != "f(\"a\")" || buf.Cursor().Col != 6 {
+		t.Fatalf("typed got %q, cursor %v", got, buf.Cursor())
+	}
+
+	buf = NewBuffer("")
+	for _, r := range "don't [" {
+		buf.TypePaired(string(r))
+	}
+	if got := buf.Line(0); got != "don't []" {
+		t.Fatalf("typed got %q", got)
+	}
+	if !buf.DeletePairBackward() || buf.Line(0) != "don't " {
+		t.Fatalf("deleting the pair got %q", buf.Line(0))
+	}
+	if buf.DeletePairBackward() {
+		t.Fatal("deleted a pair that isn't there")
+	}
+}
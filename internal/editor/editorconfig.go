@@ -0,0 +1,484 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// FileFormat is how a buffer is read from and written to its file, set
+// from the end_of_line, charset, trim_trailing_whitespace and
+// insert_final_newline keys of .editorconfig files.
+type FileFormat struct {
+	EndOfLine              string // "lf", "crlf" or "cr"; empty leaves line endings as they are, see decode
+	Charset                string // "utf-8", "utf-8-bom", "latin1", "utf-16be" or "utf-16le"; empty for utf-8
+	TrimTrailingWhitespace bool   // Remove spaces and tabs at the end of lines on save
+	NoFinalNewline         bool   // Write the file without a newline after the last line
+}
+
+// FileFormat returns how the buffer is read and written.
+func (b *Buffer) FileFormat() FileFormat {
+	return b.format
+}
+
+// SetFileFormat sets how the buffer is written from its next save on.
+func (b *Buffer) SetFileFormat(f FileFormat) {
+	b.format = f
+}
+
+// editorConfigName is the file EditorConfig settings are read from.
+const editorConfigName = ".editorconfig"
+
+// editorConfigSection is a [glob] section of an .editorconfig file.
+type editorConfigSection struct {
+	glob       string
+	properties [][2]string // Key and value, in the order they appear
+}
+
+// editorConfigFile is a parsed .editorconfig file.
+type editorConfigFile struct {
+	dir      string // Directory the file is in, which its globs are relative to
+	root     bool   // root = true: files further up are not read
+	sections []editorConfigSection
+}
+
+// editorConfig returns the EditorConfig properties for the file at path,
+// an absolute path, read from the .editorconfig files in its directory and
+// the ones above it up to the first with root = true. Keys are lower case,
+// as are the values of the keys Vem knows; "unset" removes a property.
+// Files that can't be read are skipped.
+func editorConfig(path string) map[string]string {
+	var files []editorConfigFile
+	for dir := filepath.Dir(path); ; {
+		data, err := os.ReadFile(filepath.Join(dir, editorConfigName))
+		if err == nil {
+			file := parseEditorConfig(string(data))
+			file.dir = dir
+			files = append(files, file)
+			if file.root {
+				break
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	// Nearer files and later sections override the ones before them
+	props := make(map[string]string)
+	for i := len(files) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(files[i].dir, path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, section := range files[i].sections {
+			re, err := editorConfigGlob(section.glob)
+			if err != nil || !re.MatchString(rel) {
+				continue
+			}
+			for _, kv := range section.properties {
+				props[kv[0]] = kv[1]
+			}
+		}
+	}
+	for key, value := range props {
+		if value == "unset" {
+			delete(props, key)
+		}
+	}
+
+	// Fill in indent_size and tab_width from each other, as the spec says
+	if props["indent_style"] == "tab" && props["indent_size"] == "" {
+		props["indent_size"] = "tab"
+	}
+	if size := props["indent_size"]; size != "" && size != "tab" && props["tab_width"] == "" {
+		props["tab_width"] = size
+	}
+	if props["indent_size"] == "tab" && props["tab_width"] != "" {
+		props["indent_size"] = props["tab_width"]
+	}
+	return props
+}
+
+// editorConfigKeys are the keys whose values are compared without case.
+var editorConfigKeys = map[string]bool{
+	"indent_style": true, "indent_size": true, "tab_width": true, "end_of_line": true,
+	"charset": true, "trim_trailing_whitespace": true, "insert_final_newline": true,
+}
+
+// parseEditorConfig parses the contents of an .editorconfig file. Lines
+// that are neither a section, a key = value pair nor a comment are
+// ignored.
+func parseEditorConfig(data string) editorConfigFile {
+	var file editorConfigFile
+	var section *editorConfigSection
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			end := strings.LastIndex(line, "]")
+			if end < 1 {
+				continue
+			}
+			file.sections = append(file.sections, editorConfigSection{glob: line[1:end]})
+			section = &file.sections[len(file.sections)-1]
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			key = strings.ToLower(strings.TrimSpace(key))
+			value = strings.TrimSpace(value)
+			if editorConfigKeys[key] || strings.EqualFold(value, "unset") {
+				value = strings.ToLower(value)
+			}
+			if section == nil {
+				if key == "root" {
+					file.root = strings.EqualFold(value, "true")
+				}
+				continue
+			}
+			section.properties = append(section.properties, [2]string{key, value})
+		}
+	}
+	return file
+}
+
+// editorConfigGlob compiles a section glob to a regexp matched against
+// paths relative to the .editorconfig file, with forward slashes. A glob
+// without a slash matches file names in any directory.
+func editorConfigGlob(glob string) (*regexp.Regexp, error) {
+	prefix := "^(?:.*/)?"
+	if strings.Contains(glob, "/") {
+		glob = strings.TrimPrefix(glob, "/")
+		prefix = "^"
+	}
+	return regexp.Compile(prefix + globRegexp([]rune(glob)) + "$")
+}
+
+// numericRange matches the {num1..num2} glob.
+var numericRange = regexp.MustCompile(`^([+-]?\d+)\.\.([+-]?\d+)$`)
+
+// maxRangeNumbers is how many numbers a {num1..num2} glob lists before it
+// matches any number instead.
+const maxRangeNumbers = 10000
+
+// globRegexp returns the regexp for an EditorConfig glob: * matches
+// within a path segment, ** across them, ? a single character, [seq] and
+// [!seq] a character in or not in seq, {a,b} either of a and b, and
+// {num1..num2} a number between them. A backslash quotes the next
+// character.
+func globRegexp(glob []rune) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+			} else {
+				sb.WriteString(`\\`)
+			}
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				sb.WriteString(".*")
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := closingBracket(glob, i)
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			sb.WriteString(bracketRegexp(glob[i+1 : end]))
+			i = end
+		case '{':
+			end := closingBrace(glob, i)
+			if end < 0 {
+				sb.WriteString(`\{`)
+				continue
+			}
+			sb.WriteString(braceRegexp(glob[i+1 : end]))
+			i = end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// closingBracket returns the index of the ] closing the [ at open, or -1
+// if there isn't one before the end of the path segment.
+func closingBracket(glob []rune, open int) int {
+	for i := open + 1; i < len(glob); i++ {
+		switch glob[i] {
+		case '/':
+			return -1
+		case '\\':
+			i++
+		case ']':
+			if i > open+1 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// bracketRegexp returns the regexp character class for the contents of a
+// [seq] glob.
+func bracketRegexp(seq []rune) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	if seq[0] == '!' {
+		sb.WriteByte('^')
+		seq = seq[1:]
+	}
+	for i := 0; i < len(seq); i++ {
+		switch seq[i] {
+		case '\\':
+			if i+1 < len(seq) {
+				i++
+			}
+			if unicode.IsLetter(seq[i]) || unicode.IsDigit(seq[i]) {
+				sb.WriteRune(seq[i])
+			} else {
+				sb.WriteString(`\` + string(seq[i]))
+			}
+		case '[', ']', '^':
+			sb.WriteString(`\` + string(seq[i]))
+		default:
+			sb.WriteRune(seq[i])
+		}
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+// closingBrace returns the index of the } closing the { at open, or -1.
+func closingBrace(glob []rune, open int) int {
+	depth := 0
+	for i := open; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// braceRegexp returns the regexp for the contents of a {a,b} or
+// {num1..num2} glob. Braces around a single choice are literal.
+func braceRegexp(inner []rune) string {
+	if m := numericRange.FindStringSubmatch(string(inner)); m != nil {
+		lo, _ := strconv.Atoi(m[1]) // The pattern only matches numbers
+		hi, _ := strconv.Atoi(m[2])
+		lo, hi = min(lo, hi), max(lo, hi)
+		if hi-lo >= maxRangeNumbers {
+			return `[+-]?\d+`
+		}
+		nums := make([]string, 0, hi-lo+1)
+		for n := lo; n <= hi; n++ {
+			nums = append(nums, regexp.QuoteMeta(strconv.Itoa(n)))
+		}
+		return "(?:" + strings.Join(nums, "|") + ")"
+	}
+
+	var choices []string
+	depth, start := 0, 0
+	for i := 0; i < len(inner); i++ {
+		switch inner[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				choices = append(choices, globRegexp(inner[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if choices == nil {
+		return `\{` + globRegexp(inner) + `\}`
+	}
+	choices = append(choices, globRegexp(inner[start:]))
+	return "(?:" + strings.Join(choices, "|") + ")"
+}
+
+// applyEditorConfig sets the buffer's indent options and file format from
+// EditorConfig properties, leaving the ones not given as they are.
+func (b *Buffer) applyEditorConfig(props map[string]string) {
+	o := b.indent
+	switch props["indent_style"] {
+	case "tab":
+		o.ExpandTab = false
+	case "space":
+		o.ExpandTab = true
+	}
+	if size := props["indent_size"]; size == "tab" {
+		o.ShiftWidth = 0
+	} else if n, err := strconv.Atoi(size); err == nil && n > 0 {
+		o.ShiftWidth = n
+	}
+	if n, err := strconv.Atoi(props["tab_width"]); err == nil && n > 0 {
+		o.TabStop = n
+	}
+	b.indent = o
+
+	switch eol := props["end_of_line"]; eol {
+	case "lf", "crlf", "cr":
+		b.format.EndOfLine = eol
+	}
+	switch charset := props["charset"]; charset {
+	case "utf-8", "utf-8-bom", "latin1", "utf-16be", "utf-16le":
+		b.format.Charset = charset
+	}
+	switch props["trim_trailing_whitespace"] {
+	case "true":
+		b.format.TrimTrailingWhitespace = true
+	case "false":
+		b.format.TrimTrailingWhitespace = false
+	}
+	switch props["insert_final_newline"] {
+	case "true":
+		b.format.NoFinalNewline = false
+	case "false":
+		b.format.NoFinalNewline = true
+	}
+}
+
+// utf8BOM is the byte order mark of the utf-8-bom charset.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// decode returns the text of a file's contents in the format's charset,
+// with line endings turned into "\n". CRLF always ends a line, so a file
+// read with CRLF is saved with the line endings the format asks for, and a
+// lone CR does too with "cr". Without an EndOfLine a file whose lines all
+// end in CRLF sets it to "crlf", to be saved as it was read.
+func (f *FileFormat) decode(data []byte) string {
+	var text string
+	switch f.Charset {
+	case "utf-8-bom":
+		text = string(bytes.TrimPrefix(data, utf8BOM))
+	case "latin1":
+		runes := make([]rune, len(data))
+		for i, c := range data {
+			runes[i] = rune(c)
+		}
+		text = string(runes)
+	case "utf-16be", "utf-16le":
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if f.Charset == "utf-16be" {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			} else {
+				units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+			}
+		}
+		if len(units) > 0 && units[0] == 0xFEFF {
+			units = units[1:]
+		}
+		text = string(utf16.Decode(units))
+	default:
+		text = string(data)
+	}
+
+	crlf := strings.Count(text, "\r\n")
+	if f.EndOfLine == "" {
+		if crlf == 0 || crlf != strings.Count(text, "\n") {
+			// Mixed line endings are left as they are
+			return text
+		}
+		f.EndOfLine = "crlf"
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if f.EndOfLine == "cr" {
+		text = strings.ReplaceAll(text, "\r", "\n")
+	}
+	return text
+}
+
+// encode returns the file contents for text, whose lines end in "\n", in
+// the format's line endings and charset.
+func (f FileFormat) encode(text string) ([]byte, error) {
+	switch f.EndOfLine {
+	case "crlf":
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	case "cr":
+		text = strings.ReplaceAll(text, "\n", "\r")
+	}
+
+	switch f.Charset {
+	case "utf-8-bom":
+		return append(append([]byte{}, utf8BOM...), text...), nil
+	case "latin1":
+		data := make([]byte, 0, len(text))
+		for _, r := range text {
+			if r > 0xFF {
+				return nil, fmt.Errorf("cannot write %q as latin1", r)
+			}
+			data = append(data, byte(r))
+		}
+		return data, nil
+	case "utf-16be", "utf-16le":
+		units := utf16.Encode([]rune(text))
+		data := make([]byte, 2*len(units))
+		for i, u := range units {
+			if f.Charset == "utf-16be" {
+				data[2*i], data[2*i+1] = byte(u>>8), byte(u)
+			} else {
+				data[2*i], data[2*i+1] = byte(u), byte(u>>8)
+			}
+		}
+		return data, nil
+	default:
+		return []byte(text), nil
+	}
+}
+
+// trimTrailingWhitespace removes the spaces and tabs at the ends of lines,
+// as one undo step, for saving with TrimTrailingWhitespace.
+func (b *Buffer) trimTrailingWhitespace() {
+	trimmed := make([]string, len(b.lines))
+	changed := false
+	for i, line := range b.lines {
+		trimmed[i] = strings.TrimRight(line, " \t")
+		changed = changed || trimmed[i] != line
+	}
+	if !changed {
+		return
+	}
+	b.saveState("trim trailing whitespace")
+	old := b.lines
+	b.lines = trimmed
+	for i := range old {
+		if trimmed[i] != old[i] {
+			end := Cursor{Line: i, Col: runeCount(trimmed[i])}
+			b.moveMarks(end, Cursor{Line: i, Col: runeCount(old[i])}, end)
+		}
+	}
+	b.clampColumn()
+}
@@ -0,0 +1,426 @@
+package editor
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/javanhut/vem/internal/textwidth"
+)
+
+// IndentOptions are how a buffer indents, Vim's 'autoindent',
+// 'smartindent', 'expandtab', 'shiftwidth', 'tabstop' and 'softtabstop'.
+type IndentOptions struct {
+	AutoIndent  bool // New lines start with the indent of the line above
+	SmartIndent bool // and one level more after an opening bracket
+	ExpandTab   bool // Indent with spaces instead of tabs
+	ShiftWidth  int  // Columns per indent level, 0 for TabStop
+	TabStop     int  // Columns a tab takes up
+	SoftTabStop int  // Columns Tab and Backspace move in insert mode, 0 for off and negative for ShiftWidth
+}
+
+// DefaultIndentOptions returns the options buffers start with: autoindent
+// and smartindent on, and tabs four columns wide.
+func DefaultIndentOptions() IndentOptions {
+	return IndentOptions{AutoIndent: true, SmartIndent: true, TabStop: 4}
+}
+
+// Tab returns the width of a tab, 8 if TabStop isn't set.
+func (o IndentOptions) Tab() int {
+	if o.TabStop > 0 {
+		return o.TabStop
+	}
+	return 8
+}
+
+// Shift returns the width of an indent level.
+func (o IndentOptions) Shift() int {
+	if o.ShiftWidth > 0 {
+		return o.ShiftWidth
+	}
+	return o.Tab()
+}
+
+// softTab returns how far Tab and Backspace move in insert mode, or 0 when
+// they insert and delete a single character.
+func (o IndentOptions) softTab() int {
+	if o.SoftTabStop < 0 {
+		return o.Shift()
+	}
+	return o.SoftTabStop
+}
+
+// width returns the display width of s starting at column 0.
+func (o IndentOptions) width(s string) int {
+	w := 0
+	for _, r := range s {
+		if r == '\t' {
+			w += o.Tab() - w%o.Tab()
+		} else {
+			w += textwidth.Rune(r)
+		}
+	}
+	return w
+}
+
+// whitespace returns the whitespace that fills the columns from up to to:
+// spaces with ExpandTab, otherwise as many tabs as fit and then spaces.
+func (o IndentOptions) whitespace(from, to int) string {
+	if to <= from {
+		return ""
+	}
+	if o.ExpandTab {
+		return strings.Repeat(" ", to-from)
+	}
+	var sb strings.Builder
+	for next := from + o.Tab() - from%o.Tab(); next <= to; next += o.Tab() {
+		sb.WriteByte('\t')
+		from = next
+	}
+	sb.WriteString(strings.Repeat(" ", to-from))
+	return sb.String()
+}
+
+// IndentOptions returns the buffer's indent options.
+func (b *Buffer) IndentOptions() IndentOptions {
+	return b.indent
+}
+
+// SetIndentOptions sets the buffer's indent options.
+func (b *Buffer) SetIndentOptions(o IndentOptions) {
+	b.indent = o
+}
+
+// leadingWhitespace returns the spaces and tabs line starts with.
+func leadingWhitespace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// colonBlockExts are the file types where a line ending in a colon opens
+// a block.
+var colonBlockExts = map[string]bool{
+	".py": true, ".pyi": true, ".pyw": true, ".yaml": true, ".yml": true, ".nim": true, ".gd": true,
+}
+
+// pythonDedents start the last line of a Python block.
+var pythonDedents = []string{"return", "pass", "break", "continue", "raise"}
+
+// colonBlocks reports whether a trailing colon opens a block in the buffer.
+func (b *Buffer) colonBlocks() bool {
+	return colonBlockExts[strings.ToLower(filepath.Ext(b.filePath))]
+}
+
+// stripComment returns code without a trailing line comment, # for the
+// colon languages and // otherwise, and without trailing whitespace.
+func (b *Buffer) stripComment(code string) string {
+	marker := "//"
+	if b.colonBlocks() {
+		marker = "#"
+	}
+	if i := strings.Index(code, marker); i >= 0 && !inString(code, i) {
+		code = code[:i]
+	}
+	return strings.TrimRight(code, " \t")
+}
+
+// inString reports whether byte i of code is inside a string literal that
+// starts on the same line.
+func inString(code string, i int) bool {
+	var quote byte
+	for j := 0; j < i; j++ {
+		switch c := code[j]; {
+		case quote != 0 && c == '\\':
+			j++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\'' || c == '`'):
+			quote = c
+		}
+	}
+	return quote != 0
+}
+
+// bracketBalance returns the opening brackets in code less the closing ones,
+// leaving out brackets in string literals and a leading run of closing
+// brackets, which the line's own indent already accounts for. String
+// literals are followed the way inString does, in the same pass.
+func bracketBalance(code string) int {
+	code = strings.TrimLeft(code, ")]} \t")
+	balance := 0
+	var quote byte
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			balance++
+		case c == ')' || c == ']' || c == '}':
+			balance--
+		}
+	}
+	return balance
+}
+
+// indentChange returns how many levels the line after the code of line
+// goes in or out with smartindent: in after an unclosed opening bracket or
+// a colon in the colon languages, out after closing one opened on an
+// earlier line or a Python return.
+func (b *Buffer) indentChange(line string) int {
+	code := b.stripComment(strings.TrimLeft(line, " \t"))
+	switch balance := bracketBalance(code); {
+	case balance > 0:
+		return 1
+	case balance < 0:
+		return -1
+	}
+	if b.colonBlocks() {
+		if strings.HasSuffix(code, ":") {
+			return 1
+		}
+		word, _, _ := strings.Cut(code, " ")
+		for _, d := range pythonDedents {
+			if word == d {
+				return -1
+			}
+		}
+	}
+	return 0
+}
+
+// startsWithCloser reports whether code starts with a closing bracket.
+func startsWithCloser(code string) bool {
+	return code != "" && strings.ContainsRune(")]}", rune(code[0]))
+}
+
+// setIndent gives line the indent whitespace, moving the marks and the
+// cursor on it with the rest of the line. A cursor in the old indent goes to
+// the end of the new one.
+func (b *Buffer) setIndent(line int, indent string) {
+	old := leadingWhitespace(b.lines[line])
+	if old == indent {
+		return
+	}
+	oldLen, newLen := runeCount(old), runeCount(indent)
+	b.lines[line] = indent + b.lines[line][len(old):]
+	b.moveMarks(Cursor{Line: line}, Cursor{Line: line, Col: oldLen}, Cursor{Line: line, Col: newLen})
+	if b.cursor.Line == line {
+		b.cursor.Col = max(newLen, b.cursor.Col-oldLen+newLen)
+	}
+}
+
+// TypeText inserts text typed in insert mode. A newline goes through
+// InsertNewline and a tab through InsertTab; with smartindent a closing
+// bracket typed first on a line takes the indent of the line holding the
+// bracket it closes.
+func (b *Buffer) TypeText(text string) {
+	switch text {
+	case "\n":
+		b.InsertNewline()
+		return
+	case "\t":
+		b.InsertTab()
+		return
+	}
+	b.InsertText(text)
+	if b.indent.SmartIndent && len(text) == 1 && strings.Contains(")]}", text) {
+		b.alignCloser()
+	}
+}
+
+// alignCloser indents the line of the closing bracket just typed like the
+// line of its opening bracket, if nothing but whitespace comes before it.
+func (b *Buffer) alignCloser() {
+	line := []rune(b.lines[b.cursor.Line])
+	col := b.cursor.Col - 1
+	if col < 0 || strings.TrimSpace(string(line[:col])) != "" {
+		return
+	}
+	closer := line[col]
+	opener := map[rune]rune{')': '(', ']': '[', '}': '{'}[closer]
+
+	depth := 0
+	for l := b.cursor.Line; l >= 0; l-- {
+		runes := []rune(b.lines[l])
+		end := len(runes)
+		if l == b.cursor.Line {
+			end = col
+		}
+		for c := end - 1; c >= 0; c-- {
+			switch runes[c] {
+			case closer:
+				depth++
+			case opener:
+				if depth == 0 {
+					b.setIndent(b.cursor.Line, leadingWhitespace(b.lines[l]))
+					return
+				}
+				depth--
+			}
+		}
+	}
+}
+
+// InsertNewline splits the line at the cursor as Enter does in insert
+// mode. With autoindent the new line starts with the indent of the current
+// one, without the whitespace that was after the cursor; with smartindent
+// it goes in or out a level by the rules of ReindentLines, and a closing
+// bracket right after the cursor moves to a line of its own. A line left
+// holding only indent is emptied.
+func (b *Buffer) InsertNewline() {
+	o := b.indent
+	if !o.AutoIndent {
+		b.InsertText("\n")
+		return
+	}
+	if b.readOnly {
+		return
+	}
+	b.saveState("insert newline")
+
+	left, right := splitAtRune(b.lines[b.cursor.Line], b.cursor.Col)
+	right = strings.TrimLeft(right, " \t")
+	indent := leadingWhitespace(left)
+	if strings.TrimSpace(left) == "" {
+		left = ""
+	}
+
+	newLines := []string{left}
+	if o.SmartIndent {
+		change := b.indentChange(left)
+		inner := o.whitespace(0, max(0, o.width(indent)+change*o.Shift()))
+		if change > 0 && startsWithCloser(right) {
+			// The closer stays at the old indent, below the new line
+			newLines = append(newLines, inner)
+		} else {
+			indent = inner
+		}
+	}
+	newLines = append(newLines, indent+right)
+
+	at := b.cursor
+	b.lines = append(b.lines[:at.Line], append(newLines, b.lines[at.Line+1:]...)...)
+	b.cursor = Cursor{Line: at.Line + 1, Col: runeCount(leadingWhitespace(newLines[1]))}
+	b.moveMarks(at, at, Cursor{Line: at.Line + len(newLines) - 1, Col: runeCount(indent)})
+	b.clampMarks()
+	b.markModified()
+}
+
+// InsertTab inserts what Tab does in insert mode: a tab, or spaces up to
+// the next soft tab stop with softtabstop or expandtab. Without expandtab
+// the whitespace before the cursor is then turned into tabs where it can be.
+func (b *Buffer) InsertTab() {
+	o := b.indent
+	stop := o.softTab()
+	if stop == 0 {
+		if !o.ExpandTab {
+			b.InsertText("\t")
+			return
+		}
+		stop = o.Tab()
+	}
+	if b.readOnly {
+		return
+	}
+
+	left, right := splitAtRune(b.lines[b.cursor.Line], b.cursor.Col)
+	w := o.width(left)
+	to := w + stop - w%stop
+	if o.ExpandTab {
+		b.InsertText(strings.Repeat(" ", to-w))
+		return
+	}
+
+	// Redo the whitespace before the cursor, so spaces become tabs
+	b.saveState("insert tab")
+	code := strings.TrimRight(left, " \t")
+	ws := o.whitespace(o.width(code), to)
+	b.lines[b.cursor.Line] = code + ws + right
+	oldEnd := b.cursor
+	b.cursor.Col = runeCount(code + ws)
+	b.moveMarks(Cursor{Line: oldEnd.Line, Col: runeCount(code)}, oldEnd, b.cursor)
+	b.markModified()
+}
+
+// softTabDeletion returns how many spaces Backspace deletes with
+// softtabstop: those back to the previous soft tab stop, as long as they
+// are spaces. It is 0 when Backspace should delete one character.
+func (b *Buffer) softTabDeletion() int {
+	stop := b.indent.softTab()
+	if stop <= 1 {
+		return 0
+	}
+	left, _ := splitAtRune(b.lines[b.cursor.Line], b.cursor.Col)
+	w := b.indent.width(left)
+	target := (w - 1) / stop * stop
+	n := 0
+	for ; w > target && strings.HasSuffix(left, " "); n++ {
+		left = left[:len(left)-1]
+		w--
+	}
+	return n
+}
+
+// ShiftLines adds count indent levels to lines start through end, or takes
+// them away for a negative count, as one undo step. Lines that are empty
+// are left alone. The cursor goes to the first non-blank of line start.
+func (b *Buffer) ShiftLines(start, end, count int) {
+	if b.readOnly || count == 0 {
+		return
+	}
+	start, end = max(start, 0), min(end, len(b.lines)-1)
+	b.saveState("shift lines")
+
+	o := b.indent
+	for i := start; i <= end; i++ {
+		if b.lines[i] == "" {
+			continue
+		}
+		width := max(0, o.width(leadingWhitespace(b.lines[i]))+count*o.Shift())
+		b.setIndent(i, o.whitespace(0, width))
+	}
+	b.cursor = Cursor{Line: start, Col: runeCount(leadingWhitespace(b.lines[start]))}
+	b.markModified()
+}
+
+// ReindentLines works out the indent of lines start through end, as Vim's
+// = does, from the line above each: the same indent, one level more after
+// an unclosed opening bracket (or a colon in Python and YAML), one less
+// after a closing bracket opened on an earlier line or a Python return,
+// and one less for a line that starts with a closing bracket. Blank lines
+// are emptied. It is one undo step; the cursor goes to the first non-blank
+// of line start.
+func (b *Buffer) ReindentLines(start, end int) {
+	if b.readOnly {
+		return
+	}
+	start, end = max(start, 0), min(end, len(b.lines)-1)
+	b.saveState("reindent lines")
+
+	o := b.indent
+	prev := start - 1
+	for prev >= 0 && strings.TrimSpace(b.lines[prev]) == "" {
+		prev--
+	}
+	for i := start; i <= end; i++ {
+		code := strings.TrimLeft(b.lines[i], " \t")
+		if code == "" {
+			b.setIndent(i, "")
+			continue
+		}
+		width := 0
+		if prev >= 0 {
+			width = o.width(leadingWhitespace(b.lines[prev])) + b.indentChange(b.lines[prev])*o.Shift()
+		}
+		if startsWithCloser(code) {
+			width -= o.Shift()
+		}
+		b.setIndent(i, o.whitespace(0, max(0, width)))
+		prev = i
+	}
+	b.cursor = Cursor{Line: start, Col: runeCount(leadingWhitespace(b.lines[start]))}
+	b.markModified()
+}
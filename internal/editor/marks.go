@@ -0,0 +1,100 @@
+package editor
+
+// Mark is a position in a buffer that follows edits: text inserted or
+// deleted before it moves it along, and deleting the text around it moves
+// it to where the deletion started. Marks keep the cursors of panes that
+// show the buffer while another pane edits it.
+type Mark struct {
+	Cursor
+	buf *Buffer
+}
+
+// NewMark starts tracking pos through edits. Release the mark when it is
+// no longer needed.
+func (b *Buffer) NewMark(pos Cursor) *Mark {
+	m := &Mark{Cursor: pos, buf: b}
+	b.marks = append(b.marks, m)
+	b.clampMark(m)
+	return m
+}
+
+// Release stops tracking the mark, which keeps its last position.
+func (m *Mark) Release() {
+	if m == nil || m.buf == nil {
+		return
+	}
+	for i, other := range m.buf.marks {
+		if other == m {
+			m.buf.marks = append(m.buf.marks[:i], m.buf.marks[i+1:]...)
+			break
+		}
+	}
+	m.buf = nil
+}
+
+// SetCursor moves the cursor to pos, clamped to the buffer.
+func (b *Buffer) SetCursor(pos Cursor) {
+	b.MoveToLine(pos.Line)
+	b.cursor.Col = max(pos.Col, 0)
+	b.clampColumn()
+}
+
+// before reports whether position a comes before b.
+func before(a, b Cursor) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
+}
+
+// moveMarks adjusts the marks for the text from start up to oldEnd being
+// replaced by text ending at newEnd. Marks before start stay, marks in the
+// replaced text go to start and marks after it move with the text.
+func (b *Buffer) moveMarks(start, oldEnd, newEnd Cursor) {
+	b.shiftMarks(start, oldEnd, newEnd)
+	b.clampMarks()
+}
+
+// shiftMarks is moveMarks without keeping the marks inside the buffer, for
+// several replacements on one line whose columns only add up once all are
+// done.
+func (b *Buffer) shiftMarks(start, oldEnd, newEnd Cursor) {
+	for _, m := range b.marks {
+		switch {
+		case before(m.Cursor, start):
+		case before(m.Cursor, oldEnd):
+			m.Cursor = start
+		case m.Line == oldEnd.Line:
+			m.Col = newEnd.Col + m.Col - oldEnd.Col
+			m.Line = newEnd.Line
+		default:
+			m.Line += newEnd.Line - oldEnd.Line
+		}
+	}
+}
+
+// clampMarks keeps the marks inside the buffer after its lines were
+// replaced wholesale, by undo or reloading.
+func (b *Buffer) clampMarks() {
+	for _, m := range b.marks {
+		b.clampMark(m)
+	}
+}
+
+func (b *Buffer) clampMark(m *Mark) {
+	m.Line = max(0, min(m.Line, len(b.lines)-1))
+	m.Col = max(0, min(m.Col, b.lineLength(m.Line)))
+}
+
+// endOfText returns the position after text inserted at pos.
+func endOfText(pos Cursor, text string) Cursor {
+	lines := 0
+	last := text
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines++
+			last = text[i+1:]
+		}
+	}
+	if lines == 0 {
+		return Cursor{Line: pos.Line, Col: pos.Col + runeCount(text)}
+	}
+	return Cursor{Line: pos.Line + lines, Col: runeCount(last)}
+}
@@ -0,0 +1,149 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bracketPairs maps each bracket % jumps between to its partner.
+var bracketPairs = map[rune]rune{'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{'}
+
+// MatchingBracket returns the position of the bracket that pairs with the
+// one at pos, looking at most maxLines lines away when maxLines is
+// positive. Brackets skip reports true for, those in strings and comments,
+// don't count, unless the one at pos is skipped itself.
+func (b *Buffer) MatchingBracket(pos Cursor, skip func(Cursor) bool, maxLines int) (Cursor, bool) {
+	if pos.Line < 0 || pos.Line >= len(b.lines) {
+		return Cursor{}, false
+	}
+	runes := []rune(b.lines[pos.Line])
+	if pos.Col < 0 || pos.Col >= len(runes) {
+		return Cursor{}, false
+	}
+	bracket := runes[pos.Col]
+	partner, ok := bracketPairs[bracket]
+	if !ok {
+		return Cursor{}, false
+	}
+	if skip != nil && skip(pos) {
+		skip = nil
+	}
+
+	step := 1
+	if strings.ContainsRune(")]}", bracket) {
+		step = -1
+	}
+	depth := 0
+	for line := pos.Line; line >= 0 && line < len(b.lines); line += step {
+		if maxLines > 0 && max(line-pos.Line, pos.Line-line) > maxLines {
+			break
+		}
+		runes := []rune(b.lines[line])
+		col := 0
+		switch {
+		case line == pos.Line:
+			col = pos.Col + step
+		case step < 0:
+			col = len(runes) - 1
+		}
+		for ; col >= 0 && col < len(runes); col += step {
+			r := runes[col]
+			if r != bracket && r != partner || skip != nil && skip(Cursor{Line: line, Col: col}) {
+				continue
+			}
+			if r == bracket {
+				depth++
+			} else if depth == 0 {
+				return Cursor{Line: line, Col: col}, true
+			} else {
+				depth--
+			}
+		}
+	}
+	return Cursor{}, false
+}
+
+// JumpToMatchingBracket is Vim's %: it moves the cursor to the bracket
+// pairing with the first bracket at or after the cursor on its line, and
+// reports whether there was one. See MatchingBracket for skip.
+func (b *Buffer) JumpToMatchingBracket(skip func(Cursor) bool) bool {
+	if skip != nil && skip(b.cursor) {
+		skip = nil
+	}
+	runes := []rune(b.lines[b.cursor.Line])
+	for col := b.cursor.Col; col < len(runes); col++ {
+		pos := Cursor{Line: b.cursor.Line, Col: col}
+		if _, ok := bracketPairs[runes[col]]; !ok || skip != nil && skip(pos) {
+			continue
+		}
+		match, ok := b.MatchingBracket(pos, skip, 0)
+		if !ok {
+			return false
+		}
+		b.cursor = match
+		return true
+	}
+	return false
+}
+
+// autoPairs maps the brackets and quotes TypePaired closes to their
+// closers.
+var autoPairs = map[rune]rune{'(': ')', '[': ']', '{': '}', '"': '"', '\'': '\'', '`': '`'}
+
+// isCloser reports whether r closes one of the autoPairs.
+func isCloser(r rune) bool {
+	return strings.ContainsRune(")]}\"'`", r)
+}
+
+// TypePaired types text like TypeText, except that an opening bracket or
+// quote comes with its closer, the cursor between them, and typing the
+// closer just after the cursor steps over it. Pairs are only closed
+// before whitespace, a closer or the end of the line, and quotes not
+// right after a letter or digit, so apostrophes stay single.
+func (b *Buffer) TypePaired(text string) {
+	r, size := utf8.DecodeRuneInString(text)
+	if b.readOnly || size == 0 || size != len(text) {
+		b.TypeText(text)
+		return
+	}
+	left, right := splitAtRune(b.lines[b.cursor.Line], b.cursor.Col)
+	next, _ := utf8.DecodeRuneInString(right)
+	prev, _ := utf8.DecodeLastRuneInString(left)
+	closer, opens := autoPairs[r]
+
+	switch {
+	case right != "" && next == r && isCloser(r):
+		b.cursor.Col++
+	case opens && (right == "" || unicode.IsSpace(next) || isCloser(next)) &&
+		!(closer == r && left != "" && isWordChar(prev)):
+		b.InsertText(text + string(closer))
+		b.cursor.Col--
+	default:
+		b.TypeText(text)
+	}
+}
+
+// DeletePairBackward deletes the empty pair of brackets or quotes around
+// the cursor, as Backspace does after TypePaired opened it, and reports
+// whether there was one.
+func (b *Buffer) DeletePairBackward() bool {
+	if b.readOnly {
+		return false
+	}
+	left, right := splitAtRune(b.lines[b.cursor.Line], b.cursor.Col)
+	prev, _ := utf8.DecodeLastRuneInString(left)
+	next, _ := utf8.DecodeRuneInString(right)
+	if closer, ok := autoPairs[prev]; !ok || left == "" || right == "" || closer != next {
+		return false
+	}
+	b.saveState("delete pair")
+
+	line, col := b.cursor.Line, b.cursor.Col-1
+	runes := []rune(b.lines[line])
+	b.lines[line] = string(append(runes[:col], runes[col+2:]...))
+	b.moveMarks(Cursor{Line: line, Col: col}, Cursor{Line: line, Col: col + 2}, Cursor{Line: line, Col: col})
+	b.cursor.Col = col
+	b.markModified()
+	return true
+}
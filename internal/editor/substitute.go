@@ -0,0 +1,74 @@
+package editor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Substitute replaces the matches of re on lines start through end
+// (0-based, inclusive) with repl, expanded like regexp.Regexp.Expand so
+// $1 or ${name} put in what a group matched. Without global only the first
+// match on each line is replaced. It returns the number of replacements
+// and puts the cursor at the start of the last line changed, as a single
+// undo step.
+func (b *Buffer) Substitute(re *regexp.Regexp, repl string, start, end int, global bool) int {
+	if b.readOnly {
+		return 0
+	}
+	start = max(start, 0)
+	end = min(end, len(b.lines)-1)
+
+	// spans are the replacements in the columns of the line as replaced
+	// so far, for moving the marks
+	type span struct{ start, oldEnd, newEnd Cursor }
+	var spans []span
+	count, last := 0, -1
+	changed := make(map[int]string)
+	for i := start; i <= end; i++ {
+		line := b.lines[i]
+		n := 1
+		if global {
+			n = -1
+		}
+		matches := re.FindAllStringSubmatchIndex(line, n)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var sb strings.Builder
+		prev, col := 0, 0
+		for _, m := range matches {
+			sb.WriteString(line[prev:m[0]])
+			col += runeCount(line[prev:m[0]])
+			replacement := string(re.ExpandString(nil, repl, line, m))
+			sb.WriteString(replacement)
+			spans = append(spans, span{
+				start:  Cursor{Line: i, Col: col},
+				oldEnd: Cursor{Line: i, Col: col + runeCount(line[m[0]:m[1]])},
+				newEnd: Cursor{Line: i, Col: col + runeCount(replacement)},
+			})
+			col += runeCount(replacement)
+			prev = m[1]
+		}
+		sb.WriteString(line[prev:])
+
+		changed[i] = sb.String()
+		count += len(matches)
+		last = i
+	}
+	if count == 0 {
+		return 0
+	}
+
+	b.saveState("substitute")
+	for i, line := range changed {
+		b.lines[i] = line
+	}
+	for _, sp := range spans {
+		b.shiftMarks(sp.start, sp.oldEnd, sp.newEnd)
+	}
+	b.clampMarks()
+	b.cursor = Cursor{Line: last}
+	b.markModified()
+	return count
+}
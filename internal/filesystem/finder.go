@@ -1,41 +1,43 @@
 package filesystem
 
 import (
-	"os"
+	"io/fs"
 	"path/filepath"
-	"strings"
 )
 
 // FindAllFiles recursively finds all files starting from the given root directory.
 // It returns a list of file paths relative to the root.
-// Hidden files and directories (starting with .) are excluded.
-func FindAllFiles(root string) ([]string, error) {
+// Hidden and ignored files are excluded according to matcher; if matcher is
+// nil, a new one is created for root.
+func FindAllFiles(root string, matcher *IgnoreMatcher) ([]string, error) {
+	if matcher == nil {
+		matcher = NewIgnoreMatcher(root)
+	}
+
 	var files []string
 
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			// Skip directories we can't access
+			if entry != nil && entry.IsDir() && path != root {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		// Skip hidden files and directories (starting with .)
-		if info.Name() != "." && strings.HasPrefix(info.Name(), ".") {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
+		if path == root {
 			return nil
 		}
 
-		// Skip common build/cache directories
-		if info.IsDir() {
-			switch info.Name() {
-			case "node_modules", "vendor", ".git", ".gocache", "dist", "build", "target":
+		if matcher.Ignored(path, entry.IsDir()) {
+			if entry.IsDir() {
 				return filepath.SkipDir
 			}
+			return nil
 		}
 
 		// Only include files, not directories
-		if !info.IsDir() {
+		if !entry.IsDir() {
 			// Make path relative to root
 			relPath, err := filepath.Rel(root, path)
 			if err != nil {
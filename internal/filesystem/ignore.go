@@ -0,0 +1,551 @@
+package filesystem
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ignoreFileNames are the per-directory ignore files, in increasing order of
+// precedence (.ignore is the ripgrep/fd convention and wins over .gitignore).
+var ignoreFileNames = []string{".gitignore", ".ignore"}
+
+// alwaysHidden lists version control directories that are never shown, even
+// when ignored files are enabled.
+var alwaysHidden = map[string]bool{
+	".git": true,
+	".hg":  true,
+	".svn": true,
+}
+
+// defaultIgnorePatterns returns dependency and build directories and editor
+// and OS clutter, ignored even without an ignore file. They have the lowest
+// precedence, so an ignore file can re-include them with a "!" pattern.
+func defaultIgnorePatterns() []string {
+	return []string{
+		"node_modules/",
+		"vendor/",
+		"dist/",
+		"build/",
+		"target/",
+		".gocache/",
+		".DS_Store",
+		"*.swp",
+		"*.swo",
+		"*~",
+	}
+}
+
+// ignoreRule is a single compiled line from an ignore file.
+type ignoreRule struct {
+	re       *regexp.Regexp
+	base     string // Slash-separated directory of the ignore file, relative to the matcher base
+	negate   bool
+	dirOnly  bool
+	anchored bool // Matches the whole path below base, not just the file name
+}
+
+// IgnoreMatcher decides which files are hidden from the explorer and the
+// fuzzy finder. It follows nested .gitignore and .ignore files, the
+// repository's .git/info/exclude and the global git excludes file, with git's
+// glob and negation semantics. Dotfiles are shown unless a rule ignores them.
+//
+// Rules are loaded lazily per directory and cached; call Invalidate when
+// ignore files may have changed.
+type IgnoreMatcher struct {
+	root       string
+	base       string // Repository root if root is inside a git repository, otherwise root
+	rootRel    string // root relative to base, slash-separated ("" if they are equal)
+	showHidden bool
+
+	mu         sync.RWMutex
+	baseRules  []ignoreRule
+	dirRules   map[string][]ignoreRule
+	dirIgnored map[string]bool
+}
+
+// NewIgnoreMatcher creates a matcher for files below root.
+func NewIgnoreMatcher(root string) *IgnoreMatcher {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		absRoot = root
+	}
+
+	m := &IgnoreMatcher{
+		root: absRoot,
+		base: findRepositoryRoot(absRoot),
+	}
+	if rel, err := filepath.Rel(m.base, absRoot); err == nil && rel != "." {
+		m.rootRel = filepath.ToSlash(rel)
+	}
+	m.Invalidate()
+	return m
+}
+
+// Root returns the directory the matcher was created for.
+func (m *IgnoreMatcher) Root() string {
+	return m.root
+}
+
+// ShowHidden reports whether ignored files are shown.
+func (m *IgnoreMatcher) ShowHidden() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.showHidden
+}
+
+// SetShowHidden toggles whether ignored files are shown.
+func (m *IgnoreMatcher) SetShowHidden(show bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.showHidden = show
+	m.dirIgnored = make(map[string]bool)
+}
+
+// Invalidate drops all cached rules so ignore files are re-read.
+func (m *IgnoreMatcher) Invalidate() {
+	rules := compileIgnoreLines(defaultIgnorePatterns(), "")
+	if path := globalExcludesFile(); path != "" {
+		rules = append(rules, readIgnoreFile(path, "")...)
+	}
+	rules = append(rules, readIgnoreFile(filepath.Join(m.base, ".git", "info", "exclude"), "")...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.baseRules = rules
+	m.dirRules = make(map[string][]ignoreRule)
+	m.dirIgnored = make(map[string]bool)
+}
+
+// InvalidateDir drops the cached rules for a single directory, e.g. after
+// its .gitignore changed.
+func (m *IgnoreMatcher) InvalidateDir(dir string) {
+	rel, ok := m.relative(dir)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, loaded := m.dirRules[rel]; loaded {
+		delete(m.dirRules, rel)
+		m.dirIgnored = make(map[string]bool)
+	}
+}
+
+// Ignored reports whether path should be hidden.
+func (m *IgnoreMatcher) Ignored(path string, isDir bool) bool {
+	name := filepath.Base(path)
+	if alwaysHidden[name] {
+		return true
+	}
+	if m.ShowHidden() {
+		return false
+	}
+
+	rel, ok := m.relative(path)
+	if !ok || rel == "" {
+		return false
+	}
+
+	// Git cannot re-include a file whose parent directory is excluded.
+	if dir := parentOf(rel); dir != "" && m.dirIsIgnored(dir) {
+		return true
+	}
+	return m.matches(rel, isDir)
+}
+
+// relative returns path relative to the matcher base, slash-separated.
+func (m *IgnoreMatcher) relative(path string) (string, bool) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(m.base, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if rel == "." {
+		return "", true
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// dirIsIgnored reports whether the directory rel (or any of its parents
+// below the root) is ignored. Results are cached.
+func (m *IgnoreMatcher) dirIsIgnored(rel string) bool {
+	// Directories between the repository root and the tree root are never
+	// treated as ignored, or opening e.g. ~/.config/nvim would hide it all.
+	if m.rootRel != "" && !strings.HasPrefix(rel, m.rootRel+"/") {
+		return false
+	}
+
+	m.mu.RLock()
+	ignored, ok := m.dirIgnored[rel]
+	m.mu.RUnlock()
+	if ok {
+		return ignored
+	}
+
+	name := rel[strings.LastIndex(rel, "/")+1:]
+	if parent := parentOf(rel); parent != "" && m.dirIsIgnored(parent) {
+		ignored = true
+	} else if alwaysHidden[name] {
+		ignored = true
+	} else {
+		ignored = m.matches(rel, true)
+	}
+
+	m.mu.Lock()
+	m.dirIgnored[rel] = ignored
+	m.mu.Unlock()
+	return ignored
+}
+
+// matches applies every rule that is in scope for rel; the last match wins.
+func (m *IgnoreMatcher) matches(rel string, isDir bool) bool {
+	ignored := false
+	apply := func(rules []ignoreRule) {
+		for _, rule := range rules {
+			if rule.match(rel, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+
+	m.mu.RLock()
+	baseRules := m.baseRules
+	m.mu.RUnlock()
+	apply(baseRules)
+
+	// Walk from the base down to the file's directory so deeper ignore
+	// files override shallower ones.
+	apply(m.rulesFor(""))
+	for i := 0; i < len(rel); i++ {
+		if rel[i] == '/' {
+			apply(m.rulesFor(rel[:i]))
+		}
+	}
+	return ignored
+}
+
+// rulesFor returns the compiled rules from the ignore files in dir.
+func (m *IgnoreMatcher) rulesFor(dir string) []ignoreRule {
+	m.mu.RLock()
+	rules, ok := m.dirRules[dir]
+	m.mu.RUnlock()
+	if ok {
+		return rules
+	}
+
+	absDir := filepath.Join(m.base, filepath.FromSlash(dir))
+	for _, name := range ignoreFileNames {
+		rules = append(rules, readIgnoreFile(filepath.Join(absDir, name), dir)...)
+	}
+
+	m.mu.Lock()
+	m.dirRules[dir] = rules
+	m.mu.Unlock()
+	return rules
+}
+
+// match reports whether the rule applies to rel (relative to the matcher
+// base).
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	sub := rel
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		sub = rel[len(r.base)+1:]
+	}
+
+	if !r.anchored {
+		sub = sub[strings.LastIndex(sub, "/")+1:]
+	}
+	return r.re.MatchString(sub)
+}
+
+// readIgnoreFile compiles the rules in an ignore file. Missing files yield
+// no rules.
+func readIgnoreFile(path, base string) []ignoreRule {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return compileIgnoreLines(lines, base)
+}
+
+// compileIgnoreLines compiles gitignore-style lines, skipping blanks,
+// comments and invalid patterns.
+func compileIgnoreLines(lines []string, base string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range lines {
+		if rule, ok := compileIgnorePattern(line, base); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// compileIgnorePattern parses one gitignore line.
+func compileIgnorePattern(line, base string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+
+	// Trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A slash at the start or in the middle anchors the pattern to the
+	// directory of the ignore file
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp converts a gitignore glob to a regular expression body.
+// "*" and "?" never match "/", "**" matches across directories when it is
+// a whole path component.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	n := len(glob)
+
+	for i := 0; i < n; i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < n && glob[i+1] == '*' {
+				atStart := i == 0 || glob[i-1] == '/'
+				atEnd := i+2 == n
+				if atStart && atEnd {
+					sb.WriteString(".*")
+					i++
+					continue
+				}
+				if atStart && glob[i+2] == '/' {
+					// "**/" matches zero or more directories
+					sb.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
+				for i+1 < n && glob[i+1] == '*' {
+					i++
+				}
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := classEnd(glob, i)
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			sb.WriteString(classToRegexp(glob[i+1 : end]))
+			i = end
+		case '\\':
+			if i+1 < n {
+				i++
+				sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	return sb.String()
+}
+
+// classEnd returns the index of the "]" closing the bracket expression that
+// starts at glob[start], or -1 if it is unterminated.
+func classEnd(glob string, start int) int {
+	i := start + 1
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		i++
+	}
+	// A "]" right after the opening bracket is literal
+	if i < len(glob) && glob[i] == ']' {
+		i++
+	}
+	for ; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			i++
+		case '[':
+			if i+1 < len(glob) && glob[i+1] == ':' {
+				if end := strings.Index(glob[i+2:], ":]"); end >= 0 {
+					i += end + 3
+				}
+			}
+		case ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// classToRegexp converts the inside of a glob bracket expression.
+func classToRegexp(class string) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+
+	i := 0
+	if i < len(class) && (class[i] == '!' || class[i] == '^') {
+		sb.WriteByte('^')
+		i++
+	}
+	for ; i < len(class); i++ {
+		c := class[i]
+		switch {
+		case c == '\\' && i+1 < len(class):
+			i++
+			sb.WriteString(regexp.QuoteMeta(class[i : i+1]))
+		case c == '[' && i+1 < len(class) && class[i+1] == ':':
+			end := strings.Index(class[i:], ":]")
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			sb.WriteString(class[i : i+end+2])
+			i += end + 1
+		case c == '[' || c == ']' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+// parentOf returns the parent of a slash-separated relative path, or "" for
+// top-level entries.
+func parentOf(rel string) string {
+	if i := strings.LastIndex(rel, "/"); i >= 0 {
+		return rel[:i]
+	}
+	return ""
+}
+
+// findRepositoryRoot returns the nearest ancestor of dir containing a .git
+// entry, or dir itself if there is none.
+func findRepositoryRoot(dir string) string {
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// globalExcludesFile returns git's core.excludesFile, falling back to the
+// XDG default location.
+func globalExcludesFile() string {
+	home, _ := os.UserHomeDir()
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" && home != "" {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	var configs []string
+	if configHome != "" {
+		configs = append(configs, filepath.Join(configHome, "git", "config"))
+	}
+	if home != "" {
+		configs = append(configs, filepath.Join(home, ".gitconfig"))
+	}
+
+	// ~/.gitconfig takes precedence over the XDG config
+	path := ""
+	for _, config := range configs {
+		if value := readExcludesFileSetting(config); value != "" {
+			path = value
+		}
+	}
+
+	if path == "" && configHome != "" {
+		return filepath.Join(configHome, "git", "ignore")
+	}
+	if strings.HasPrefix(path, "~/") && home != "" {
+		path = filepath.Join(home, path[2:])
+	}
+	return path
+}
+
+// readExcludesFileSetting extracts core.excludesFile from a git config file.
+func readExcludesFileSetting(configPath string) string {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	inCore := false
+	value := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section := strings.Trim(line, "[] \t")
+			inCore = strings.EqualFold(section, "core")
+			continue
+		}
+		if !inCore {
+			continue
+		}
+		key, val, found := strings.Cut(line, "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(key), "excludesfile") {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(val), `"`)
+	}
+	return value
+}
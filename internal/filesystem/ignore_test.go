@@ -0,0 +1,204 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnorePatternGlobs(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "dir/sub/a.log", false, true},
+		{"*.log", "a.logx", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "src/build", true, true},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"doc/*.txt", "doc/a.txt", false, true},
+		{"doc/*.txt", "doc/x/a.txt", false, false},
+		{"doc/*.txt", "src/doc/a.txt", false, false},
+		{"**/foo", "foo", false, true},
+		{"**/foo", "a/b/foo", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**", "a/x/y", false, true},
+		{"a/**", "a", true, false},
+		{"file?.go", "file1.go", false, true},
+		{"file?.go", "file10.go", false, false},
+		{"[abc].txt", "b.txt", false, true},
+		{"[!abc].txt", "b.txt", false, false},
+		{"[!abc].txt", "d.txt", false, true},
+		{"[a-c]x", "bx", false, true},
+		{`\#notes`, "#notes", false, true},
+		{`\!bang`, "!bang", false, true},
+		{"trailing   ", "trailing", false, true},
+	}
+
+	for _, tc := range cases {
+		rule, ok := compileIgnorePattern(tc.pattern, "")
+		if !ok {
+			t.Fatalf("pattern %q failed to compile", tc.pattern)
+		}
+		if got := rule.match(tc.path, tc.isDir); got != tc.want {
+			t.Fatalf("pattern %q on %q (dir=%v): got %v want %v", tc.pattern, tc.path, tc.isDir, got, tc.want)
+		}
+	}
+}
+
+func TestIgnorePatternSkipsCommentsAndBlanks(t *testing.T) {
+	for _, line := range []string{"", "   ", "# comment", "!", "/"} {
+		if _, ok := compileIgnorePattern(line, ""); ok {
+			t.Fatalf("expected %q to be skipped", line)
+		}
+	}
+}
+
+func TestIgnoreMatcherNestedAndNegation(t *testing.T) {
+	isolateGitConfig(t)
+	root := t.TempDir()
+	writeFile(t, root, ".git/HEAD", "")
+	writeFile(t, root, ".gitignore", "*.log\nbuild/\n!keep.log\n.env\n")
+	writeFile(t, root, "sub/.gitignore", "!debug.log\n/local.txt\n")
+	writeFile(t, root, "sub/.ignore", "generated/\n")
+
+	m := NewIgnoreMatcher(root)
+	cases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"main.go", false, false},
+		{"app.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build/out.bin", false, true},
+		{"sub/app.log", false, true},
+		{"sub/debug.log", false, false},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{"sub/generated", true, true},
+		{".env", false, true},
+		{".editorconfig", false, false},
+		{".github", true, false},
+		{".git", true, true},
+		{"x.swp", false, true},
+	}
+	for _, tc := range cases {
+		path := filepath.Join(root, filepath.FromSlash(tc.path))
+		if got := m.Ignored(path, tc.isDir); got != tc.want {
+			t.Fatalf("Ignored(%q) got %v want %v", tc.path, got, tc.want)
+		}
+	}
+
+	m.SetShowHidden(true)
+	if m.Ignored(filepath.Join(root, "app.log"), false) {
+		t.Fatalf("ignored file should be shown when hidden files are enabled")
+	}
+	if !m.Ignored(filepath.Join(root, ".git"), true) {
+		t.Fatalf(".git should stay hidden")
+	}
+}
+
+func TestIgnoreMatcherParentIgnoreFromSubdirectoryRoot(t *testing.T) {
+	isolateGitConfig(t)
+	repo := t.TempDir()
+	writeFile(t, repo, ".git/HEAD", "")
+	writeFile(t, repo, ".gitignore", "*.tmp\n")
+	writeFile(t, repo, ".config/app/main.go", "")
+
+	m := NewIgnoreMatcher(filepath.Join(repo, ".config", "app"))
+	if m.Ignored(filepath.Join(repo, ".config", "app", "main.go"), false) {
+		t.Fatalf("files below a hidden tree root should not be hidden")
+	}
+	if !m.Ignored(filepath.Join(repo, ".config", "app", "x.tmp"), false) {
+		t.Fatalf("repository .gitignore should apply below the tree root")
+	}
+}
+
+func TestIgnoreMatcherDefaults(t *testing.T) {
+	isolateGitConfig(t)
+	root := t.TempDir()
+	writeFile(t, root, "sub/.gitignore", "!dist/\n")
+
+	m := NewIgnoreMatcher(root)
+	cases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"node_modules", true, true},
+		{"vendor", true, true},
+		{"dist", true, true},
+		{"build", true, true},
+		{"target", true, true},
+		{".gocache", true, true},
+		{"pkg/node_modules", true, true},
+		{"build", false, false},
+		{"sub/dist", true, false},
+		{"src", true, false},
+	}
+	for _, tc := range cases {
+		path := filepath.Join(root, filepath.FromSlash(tc.path))
+		if got := m.Ignored(path, tc.isDir); got != tc.want {
+			t.Fatalf("Ignored(%q) got %v want %v", tc.path, got, tc.want)
+		}
+	}
+
+	m.SetShowHidden(true)
+	if m.Ignored(filepath.Join(root, "node_modules"), true) {
+		t.Fatalf("node_modules should be shown when ignored files are enabled")
+	}
+}
+
+func TestFindAllFilesUsesIgnoreMatcher(t *testing.T) {
+	isolateGitConfig(t)
+	root := t.TempDir()
+	writeFile(t, root, ".gitignore", "vendor/\n*.o\n.cache/\n")
+	writeFile(t, root, "main.go", "")
+	writeFile(t, root, "main.o", "")
+	writeFile(t, root, "vendor/lib.go", "")
+	writeFile(t, root, "dist/app.js", "")
+	writeFile(t, root, "node_modules/pkg/index.js", "")
+	writeFile(t, root, ".hidden/x", "")
+	writeFile(t, root, ".cache/y", "")
+
+	files, err := FindAllFiles(root, nil)
+	if err != nil {
+		t.Fatalf("FindAllFiles: %v", err)
+	}
+	want := []string{".gitignore", filepath.Join(".hidden", "x"), "main.go"}
+	if len(files) != len(want) {
+		t.Fatalf("files got %v want %v", files, want)
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Fatalf("files got %v want %v", files, want)
+		}
+	}
+}
+
+// isolateGitConfig keeps the user's global git excludes out of the tests.
+func isolateGitConfig(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+}
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
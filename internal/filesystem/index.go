@@ -0,0 +1,404 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// indexNotifyInterval rate-limits update notifications while a scan is
+// streaming results.
+const indexNotifyInterval = 50 * time.Millisecond
+
+// indexMaxWatches caps the directories an index watches, leaving the rest
+// of the system's inotify watches to other programs. Directories past it
+// are rescanned by Refresh instead.
+var indexMaxWatches = 4096
+
+// FileIndex is a persistent, concurrently built list of the files below a
+// root directory, used by the fuzzy finder. The initial scan runs in the
+// background on several goroutines and results become visible as they are
+// found. Afterwards the index follows filesystem events for up to
+// indexMaxWatches directories; the others are rescanned on Refresh.
+//
+// Files are stored relative to the root. Between removals the list only
+// grows, so readers can process just the tail they have not seen yet; any
+// removal bumps the version.
+type FileIndex struct {
+	root     string
+	matcher  *IgnoreMatcher
+	onUpdate func()
+
+	mu         sync.RWMutex
+	files      []string
+	fileSet    map[string]bool
+	dirs       map[string]bool // Indexed directories, relative to root ("." for the root)
+	version    uint64
+	scanning   bool
+	lastNotify time.Time
+	watches    int             // Directories being watched
+	unwatched  map[string]bool // Indexed directories without a watch
+	watchErr   error           // Why no more watches are added, if the system ran out
+
+	watcher *Watcher
+	changes chan struct{}
+	rescan  chan struct{}
+	done    chan struct{}
+}
+
+// NewFileIndex creates an index for root and starts scanning it in the
+// background. onUpdate is called from background goroutines whenever new
+// results are available; it should only schedule a redraw.
+func NewFileIndex(root string, matcher *IgnoreMatcher, onUpdate func()) *FileIndex {
+	if matcher == nil {
+		matcher = NewIgnoreMatcher(root)
+	}
+
+	idx := &FileIndex{
+		root:      root,
+		matcher:   matcher,
+		onUpdate:  onUpdate,
+		fileSet:   make(map[string]bool),
+		dirs:      make(map[string]bool),
+		unwatched: make(map[string]bool),
+		scanning:  true,
+		changes:   make(chan struct{}, 1),
+		rescan:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	watcher, err := NewWatcher(func() {
+		select {
+		case idx.changes <- struct{}{}:
+		default:
+		}
+	})
+	if err == nil {
+		// Without a watcher the index is still built, and only picks up
+		// later changes on Refresh.
+		idx.watcher = watcher
+	}
+
+	go idx.run()
+	return idx
+}
+
+// Root returns the directory being indexed.
+func (idx *FileIndex) Root() string {
+	return idx.root
+}
+
+// Snapshot returns the indexed files, the index version and whether the
+// initial scan is still running. The returned slice must not be modified.
+// While the version is unchanged, later snapshots extend earlier ones.
+func (idx *FileIndex) Snapshot() ([]string, uint64, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.files[:len(idx.files):len(idx.files)], idx.version, idx.scanning
+}
+
+// Refresh rescans the directories the index can't watch, in the
+// background. It is cheap when every directory is watched.
+func (idx *FileIndex) Refresh() {
+	idx.mu.RLock()
+	stale := len(idx.unwatched) > 0
+	idx.mu.RUnlock()
+	if !stale {
+		return
+	}
+	select {
+	case idx.rescan <- struct{}{}:
+	default:
+	}
+}
+
+// WatchErr returns the error that stopped the index adding watches, such
+// as running out of inotify watches, or nil.
+func (idx *FileIndex) WatchErr() error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.watchErr
+}
+
+// Close stops scanning and watching.
+func (idx *FileIndex) Close() {
+	close(idx.done)
+	if idx.watcher != nil {
+		idx.watcher.Close()
+	}
+}
+
+// run performs the initial scan and then applies watch events.
+func (idx *FileIndex) run() {
+	idx.walk([]string{"."})
+
+	idx.mu.Lock()
+	idx.scanning = false
+	idx.mu.Unlock()
+	idx.notify(true)
+
+	for {
+		select {
+		case <-idx.done:
+			return
+		case <-idx.changes:
+			if idx.watcher != nil {
+				idx.applyChanges(idx.watcher.TakeChanges())
+			}
+		case <-idx.rescan:
+			idx.mu.RLock()
+			dirs := make([]string, 0, len(idx.unwatched))
+			for dir := range idx.unwatched {
+				dirs = append(dirs, filepath.Join(idx.root, dir))
+			}
+			idx.mu.RUnlock()
+			idx.applyChanges(dirs)
+		}
+	}
+}
+
+// walk indexes the given directories (relative to root) and everything
+// below them using one worker per CPU.
+func (idx *FileIndex) walk(start []string) {
+	var (
+		mu      sync.Mutex
+		cond    = sync.NewCond(&mu)
+		queue   = append([]string(nil), start...)
+		pending = len(start) // Directories queued or being read
+	)
+
+	worker := func() {
+		for {
+			mu.Lock()
+			for len(queue) == 0 && pending > 0 {
+				cond.Wait()
+			}
+			if len(queue) == 0 {
+				mu.Unlock()
+				return
+			}
+			dir := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			mu.Unlock()
+
+			subdirs := idx.scanDirectory(dir)
+
+			mu.Lock()
+			queue = append(queue, subdirs...)
+			pending += len(subdirs) - 1
+			cond.Broadcast()
+			mu.Unlock()
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker()
+		}()
+	}
+	wg.Wait()
+}
+
+// scanDirectory reads one directory, adds its files to the index and
+// returns its subdirectories.
+func (idx *FileIndex) scanDirectory(dir string) []string {
+	select {
+	case <-idx.done:
+		return nil
+	default:
+	}
+
+	absDir := filepath.Join(idx.root, dir)
+	entries, err := os.ReadDir(absDir)
+	if err != nil {
+		// Skip directories we can't access
+		return nil
+	}
+	idx.watch(dir)
+
+	var files, subdirs []string
+	for _, entry := range entries {
+		rel := filepath.Join(dir, entry.Name())
+		if idx.matcher.Ignored(filepath.Join(idx.root, rel), entry.IsDir()) {
+			continue
+		}
+		if entry.IsDir() {
+			subdirs = append(subdirs, rel)
+		} else {
+			files = append(files, rel)
+		}
+	}
+
+	idx.mu.Lock()
+	idx.dirs[dir] = true
+	for _, file := range files {
+		if !idx.fileSet[file] {
+			idx.fileSet[file] = true
+			idx.files = append(idx.files, file)
+		}
+	}
+	idx.mu.Unlock()
+
+	if len(files) > 0 {
+		idx.notify(false)
+	}
+	return subdirs
+}
+
+// watch starts watching an indexed directory (relative to root), or marks
+// it for Refresh when it can't be watched.
+func (idx *FileIndex) watch(dir string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.watcher == nil || idx.watchErr != nil || idx.watches >= indexMaxWatches {
+		idx.unwatched[dir] = true
+		return
+	}
+	if err := idx.watcher.Add(filepath.Join(idx.root, dir)); err != nil {
+		if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE) {
+			// Out of inotify watches or kqueue descriptors, so every
+			// further Add would fail as well
+			idx.watchErr = err
+		}
+		idx.unwatched[dir] = true
+		return
+	}
+	idx.watches++
+}
+
+// applyChanges re-reads directories reported by the watcher, indexing new
+// files and subdirectories and dropping ones that disappeared.
+func (idx *FileIndex) applyChanges(absDirs []string) {
+	if len(absDirs) == 0 {
+		return
+	}
+
+	present := make(map[string]map[string]bool) // Changed dir -> current entry names
+	var removedDirs, newDirs []string
+
+	for _, absDir := range absDirs {
+		dir, err := filepath.Rel(idx.root, absDir)
+		if err != nil || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+			continue
+		}
+		idx.matcher.InvalidateDir(absDir)
+
+		entries, err := os.ReadDir(absDir)
+		if err != nil {
+			removedDirs = append(removedDirs, dir)
+			continue
+		}
+
+		names := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			rel := filepath.Join(dir, entry.Name())
+			if idx.matcher.Ignored(filepath.Join(idx.root, rel), entry.IsDir()) {
+				continue
+			}
+			names[entry.Name()] = true
+			if entry.IsDir() {
+				idx.mu.RLock()
+				known := idx.dirs[rel]
+				idx.mu.RUnlock()
+				if !known {
+					newDirs = append(newDirs, rel)
+				}
+			}
+		}
+		present[dir] = names
+	}
+
+	// Drop files and directories that no longer exist
+	idx.mu.Lock()
+	for dir := range idx.dirs {
+		parent := filepath.Dir(dir)
+		if names, ok := present[parent]; ok && dir != "." && !names[filepath.Base(dir)] {
+			removedDirs = append(removedDirs, dir)
+		}
+	}
+	isRemoved := func(path string) bool {
+		for _, dir := range removedDirs {
+			if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+	for known := range idx.dirs {
+		if isRemoved(known) {
+			delete(idx.dirs, known)
+			if idx.unwatched[known] {
+				delete(idx.unwatched, known)
+			} else if idx.watcher != nil {
+				idx.watcher.Remove(filepath.Join(idx.root, known))
+				idx.watches--
+			}
+		}
+	}
+
+	kept := make([]string, 0, len(idx.files))
+	for _, file := range idx.files {
+		names, changed := present[filepath.Dir(file)]
+		if (changed && !names[filepath.Base(file)]) || isRemoved(file) {
+			delete(idx.fileSet, file)
+			continue
+		}
+		kept = append(kept, file)
+	}
+	if len(kept) != len(idx.files) {
+		// Readers may still hold the old slice, so never compact in place
+		idx.files = kept
+		idx.version++
+	}
+	idx.mu.Unlock()
+
+	// Add new files in the changed directories and walk new subdirectories
+	for dir, names := range present {
+		idx.mu.Lock()
+		for name := range names {
+			rel := filepath.Join(dir, name)
+			if idx.dirs[rel] || idx.fileSet[rel] {
+				continue
+			}
+			if info, err := os.Stat(filepath.Join(idx.root, rel)); err != nil || info.IsDir() {
+				continue
+			}
+			idx.fileSet[rel] = true
+			idx.files = append(idx.files, rel)
+		}
+		idx.mu.Unlock()
+	}
+	if len(newDirs) > 0 {
+		idx.walk(newDirs)
+	}
+
+	idx.notify(true)
+}
+
+// notify reports new results, at most once per indexNotifyInterval unless
+// force is set.
+func (idx *FileIndex) notify(force bool) {
+	if idx.onUpdate == nil {
+		return
+	}
+
+	idx.mu.Lock()
+	now := time.Now()
+	if !force && now.Sub(idx.lastNotify) < indexNotifyInterval {
+		idx.mu.Unlock()
+		return
+	}
+	idx.lastNotify = now
+	idx.mu.Unlock()
+
+	idx.onUpdate()
+}
@@ -0,0 +1,92 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFileIndexScansAndFollowsChanges(t *testing.T) {
+	isolateGitConfig(t)
+	root := t.TempDir()
+	writeFile(t, root, ".gitignore", "out/\n")
+	writeFile(t, root, "a.go", "")
+	writeFile(t, root, "pkg/b.go", "")
+	writeFile(t, root, "pkg/deep/c.go", "")
+	writeFile(t, root, "out/skip.go", "")
+
+	idx := NewFileIndex(root, nil, nil)
+	defer idx.Close()
+
+	waitForFiles(t, idx, []string{".gitignore", "a.go", filepath.Join("pkg", "b.go"), filepath.Join("pkg", "deep", "c.go")})
+
+	writeFile(t, root, "pkg/new/d.go", "")
+	if err := os.Remove(filepath.Join(root, "a.go")); err != nil {
+		t.Fatal(err)
+	}
+	waitForFiles(t, idx, []string{".gitignore", filepath.Join("pkg", "b.go"), filepath.Join("pkg", "deep", "c.go"), filepath.Join("pkg", "new", "d.go")})
+
+	if err := os.RemoveAll(filepath.Join(root, "pkg", "deep")); err != nil {
+		t.Fatal(err)
+	}
+	waitForFiles(t, idx, []string{".gitignore", filepath.Join("pkg", "b.go"), filepath.Join("pkg", "new", "d.go")})
+}
+
+func TestFileIndexRescansUnwatchedDirectories(t *testing.T) {
+	isolateGitConfig(t)
+	defer func(max int) { indexMaxWatches = max }(indexMaxWatches)
+	indexMaxWatches = 1
+
+	root := t.TempDir()
+	writeFile(t, root, "a.go", "")
+	writeFile(t, root, "pkg/b.go", "")
+
+	idx := NewFileIndex(root, nil, nil)
+	defer idx.Close()
+	waitForFiles(t, idx, []string{"a.go", filepath.Join("pkg", "b.go")})
+
+	// Only the root is watched, so pkg is only seen again on Refresh
+	writeFile(t, root, "pkg/c.go", "")
+	writeFile(t, root, "pkg/sub/d.go", "")
+	if err := os.Remove(filepath.Join(root, "pkg", "b.go")); err != nil {
+		t.Fatal(err)
+	}
+	idx.Refresh()
+	waitForFiles(t, idx, []string{"a.go", filepath.Join("pkg", "c.go"), filepath.Join("pkg", "sub", "d.go")})
+	if err := idx.WatchErr(); err != nil {
+		t.Fatalf("WatchErr = %v, want nil when only the cap is reached", err)
+	}
+}
+
+// waitForFiles polls the index until it holds exactly want.
+func waitForFiles(t *testing.T, idx *FileIndex, want []string) {
+	t.Helper()
+	sort.Strings(want)
+
+	var got []string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		files, _, scanning := idx.Snapshot()
+		got = append([]string(nil), files...)
+		sort.Strings(got)
+		if !scanning && equalStrings(got, want) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("index files got %v want %v", got, want)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // LoadDirectory loads the immediate children of a directory node.
@@ -34,13 +35,13 @@ func (ft *FileTree) LoadDirectory(node *TreeNode) error {
 
 	for _, entry := range entries {
 		name := entry.Name()
-		
+		childPath := filepath.Join(node.Path, name)
+
 		// Skip ignored files
-		if ft.shouldIgnore(name) {
+		if ft.shouldIgnore(childPath, entry.IsDir()) {
 			continue
 		}
 
-		childPath := filepath.Join(node.Path, name)
 		child := &TreeNode{
 			Path:     childPath,
 			Name:     name,
@@ -57,6 +58,9 @@ func (ft *FileTree) LoadDirectory(node *TreeNode) error {
 
 // Refresh reloads the tree from the filesystem.
 func (ft *FileTree) Refresh() error {
+	// Pick up edited ignore files
+	ft.ignore.Invalidate()
+
 	// Save expanded state
 	expandedPaths := make(map[string]bool)
 	ft.collectExpandedPaths(ft.Root, expandedPaths)
@@ -111,6 +115,150 @@ func (ft *FileTree) reloadExpanded(node *TreeNode, expandedPaths map[string]bool
 	return nil
 }
 
+// ApplyWatchChanges reconciles directories reported by the attached watcher
+// with the filesystem. Existing nodes are kept, so expanded directories and
+// the current selection survive. Returns true if anything was updated.
+func (ft *FileTree) ApplyWatchChanges() bool {
+	if ft.watcher == nil {
+		return false
+	}
+
+	dirs := ft.watcher.TakeChanges()
+	if len(dirs) == 0 {
+		return false
+	}
+
+	selected := ft.SelectedNode()
+	changed := false
+	for _, dir := range dirs {
+		// A changed directory may hold an edited .gitignore
+		ft.ignore.InvalidateDir(dir)
+
+		node := ft.findLoadedNode(dir)
+		if node == nil {
+			continue
+		}
+		if err := ft.reconcileDirectory(node); err != nil {
+			continue
+		}
+		changed = true
+	}
+
+	if !changed {
+		return false
+	}
+
+	ft.rebuildFlatList()
+	ft.selectNode(selected)
+	return true
+}
+
+// selectNode moves the selection to node if it is still visible.
+// Otherwise the (clamped) index is kept.
+func (ft *FileTree) selectNode(node *TreeNode) {
+	if node == nil {
+		return
+	}
+	for i, n := range ft.GetFlatList() {
+		if n == node {
+			ft.selectedIndex = i
+			return
+		}
+	}
+}
+
+// findLoadedNode returns the loaded directory node for path, or nil if it
+// is not part of the tree.
+func (ft *FileTree) findLoadedNode(path string) *TreeNode {
+	if ft.Root == nil {
+		return nil
+	}
+
+	rel, err := filepath.Rel(ft.Root.Path, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	if rel == "." {
+		return ft.Root
+	}
+
+	node := ft.Root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		var next *TreeNode
+		for _, child := range node.Children {
+			if child.Name == part && child.IsDir {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// reconcileDirectory updates a directory's children to match the disk
+// without replacing nodes that still exist.
+func (ft *FileTree) reconcileDirectory(node *TreeNode) error {
+	entries, err := os.ReadDir(node.Path)
+	if err != nil {
+		// The directory itself went away; drop it from its parent.
+		if os.IsNotExist(err) && node.Parent != nil {
+			node.Parent.removeChild(node)
+			ft.needsRebuild = true
+			return nil
+		}
+		return err
+	}
+
+	existing := make(map[string]*TreeNode, len(node.Children))
+	var parentLink *TreeNode
+	for _, child := range node.Children {
+		if child.Name == ".." {
+			parentLink = child
+			continue
+		}
+		existing[child.Name] = child
+	}
+
+	children := make([]*TreeNode, 0, len(entries)+1)
+	if parentLink != nil {
+		children = append(children, parentLink)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if ft.shouldIgnore(filepath.Join(node.Path, name), entry.IsDir()) {
+			continue
+		}
+		if child, ok := existing[name]; ok && child.IsDir == entry.IsDir() {
+			children = append(children, child)
+			delete(existing, name)
+			continue
+		}
+		children = append(children, &TreeNode{
+			Path:     filepath.Join(node.Path, name),
+			Name:     name,
+			IsDir:    entry.IsDir(),
+			Expanded: false,
+		})
+	}
+
+	for _, gone := range existing {
+		gone.Parent = nil
+	}
+	for _, child := range children {
+		child.Parent = node
+		child.Depth = node.Depth + 1
+	}
+	node.Children = children
+	node.sortChildren()
+
+	ft.needsRebuild = true
+	return nil
+}
+
 // LoadInitial loads the initial tree structure (root + first level).
 func (ft *FileTree) LoadInitial() error {
 	if err := ft.LoadDirectory(ft.Root); err != nil {
@@ -126,11 +274,14 @@ func (ft *FileTree) ExpandAndLoad(node *TreeNode) error {
 		return nil
 	}
 
-	// Load children if not already loaded
+	// Load children if not already loaded. Collapsed directories are not
+	// watched, so previously loaded children are reconciled instead.
 	if len(node.Children) == 0 {
 		if err := ft.LoadDirectory(node); err != nil {
 			return err
 		}
+	} else if err := ft.reconcileDirectory(node); err != nil {
+		return err
 	}
 
 	node.Expanded = true
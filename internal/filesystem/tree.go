@@ -20,11 +20,12 @@ type TreeNode struct {
 
 // FileTree manages the file system tree structure and navigation.
 type FileTree struct {
-	Root           *TreeNode
-	flatList       []*TreeNode
-	selectedIndex  int
-	needsRebuild   bool
-	ignorePatterns []string
+	Root          *TreeNode
+	flatList      []*TreeNode
+	selectedIndex int
+	needsRebuild  bool
+	ignore        *IgnoreMatcher
+	watcher       *Watcher
 }
 
 // NewFileTree creates a new file tree rooted at the given path.
@@ -43,26 +44,29 @@ func NewFileTree(rootPath string) (*FileTree, error) {
 	}
 
 	tree := &FileTree{
-		Root:           root,
-		selectedIndex:  0,
-		needsRebuild:   true,
-		ignorePatterns: defaultIgnorePatterns(),
+		Root:          root,
+		selectedIndex: 0,
+		needsRebuild:  true,
+		ignore:        NewIgnoreMatcher(absPath),
 	}
 
 	return tree, nil
 }
 
-// defaultIgnorePatterns returns common patterns to ignore in file trees.
-func defaultIgnorePatterns() []string {
-	return []string{
-		".git",
-		".gocache",
-		"node_modules",
-		".DS_Store",
-		"*.swp",
-		"*.swo",
-		"*~",
-	}
+// IgnoreMatcher returns the matcher deciding which entries are hidden.
+func (ft *FileTree) IgnoreMatcher() *IgnoreMatcher {
+	return ft.ignore
+}
+
+// ShowHidden reports whether ignored files are shown.
+func (ft *FileTree) ShowHidden() bool {
+	return ft.ignore.ShowHidden()
+}
+
+// SetShowHidden shows or hides ignored files and reloads the tree.
+func (ft *FileTree) SetShowHidden(show bool) error {
+	ft.ignore.SetShowHidden(show)
+	return ft.Refresh()
 }
 
 // GetFlatList returns a flattened list of visible nodes for rendering.
@@ -78,6 +82,7 @@ func (ft *FileTree) rebuildFlatList() {
 	ft.flatList = make([]*TreeNode, 0, 100)
 	ft.flattenNode(ft.Root)
 	ft.needsRebuild = false
+	ft.syncWatches()
 
 	// Clamp selected index
 	if ft.selectedIndex >= len(ft.flatList) {
@@ -103,6 +108,28 @@ func (ft *FileTree) flattenNode(node *TreeNode) {
 	}
 }
 
+// SetWatcher attaches a filesystem watcher that follows the expanded
+// directories of the tree. Pass nil to detach.
+func (ft *FileTree) SetWatcher(w *Watcher) {
+	ft.watcher = w
+	ft.syncWatches()
+}
+
+// syncWatches points the watcher at the root and every expanded directory.
+func (ft *FileTree) syncWatches() {
+	if ft.watcher == nil || ft.Root == nil {
+		return
+	}
+
+	dirs := []string{ft.Root.Path}
+	for _, node := range ft.flatList {
+		if node != ft.Root && node.IsDir && node.Expanded && node.Name != ".." {
+			dirs = append(dirs, node.Path)
+		}
+	}
+	ft.watcher.Sync(dirs)
+}
+
 // SelectedNode returns the currently selected node.
 func (ft *FileTree) SelectedNode() *TreeNode {
 	list := ft.GetFlatList()
@@ -196,20 +223,9 @@ func (ft *FileTree) Collapse() bool {
 	return false
 }
 
-// shouldIgnore checks if a path matches any ignore pattern.
-func (ft *FileTree) shouldIgnore(name string) bool {
-	for _, pattern := range ft.ignorePatterns {
-		if strings.HasPrefix(pattern, "*.") {
-			// Simple suffix match for *.ext patterns
-			ext := pattern[1:]
-			if strings.HasSuffix(name, ext) {
-				return true
-			}
-		} else if name == pattern {
-			return true
-		}
-	}
-	return false
+// shouldIgnore checks if a path is hidden or matched by an ignore file.
+func (ft *FileTree) shouldIgnore(path string, isDir bool) bool {
+	return ft.ignore.Ignored(path, isDir)
 }
 
 // AddChild adds a child node to a directory, maintaining sorted order.
@@ -217,8 +233,11 @@ func (node *TreeNode) AddChild(child *TreeNode) {
 	child.Parent = node
 	child.Depth = node.Depth + 1
 	node.Children = append(node.Children, child)
+	node.sortChildren()
+}
 
-	// Sort: directories first, then alphabetically
+// sortChildren orders children with directories first, then alphabetically.
+func (node *TreeNode) sortChildren() {
 	sort.Slice(node.Children, func(i, j int) bool {
 		if node.Children[i].IsDir != node.Children[j].IsDir {
 			return node.Children[i].IsDir
@@ -227,6 +246,17 @@ func (node *TreeNode) AddChild(child *TreeNode) {
 	})
 }
 
+// removeChild detaches child from the node's children.
+func (node *TreeNode) removeChild(child *TreeNode) {
+	for i, c := range node.Children {
+		if c == child {
+			node.Children = append(node.Children[:i], node.Children[i+1:]...)
+			child.Parent = nil
+			return
+		}
+	}
+}
+
 // ClearChildren removes all children from a directory node.
 func (node *TreeNode) ClearChildren() {
 	for _, child := range node.Children {
@@ -268,7 +298,11 @@ func (ft *FileTree) ChangeRoot(newPath string) error {
 		Depth:    0,
 	}
 
+	ignore := NewIgnoreMatcher(absPath)
+	ignore.SetShowHidden(ft.ignore.ShowHidden())
+
 	ft.Root = root
+	ft.ignore = ignore
 	ft.selectedIndex = 0
 	ft.needsRebuild = true
 
@@ -0,0 +1,205 @@
+package filesystem
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// watchDebounce is how long the watcher waits for the filesystem to go
+	// quiet before publishing a batch of changed directories.
+	watchDebounce = 150 * time.Millisecond
+	// watchMaxDelay caps how long a continuous event storm (go build,
+	// npm install, ...) can hold back a batch.
+	watchMaxDelay = time.Second
+)
+
+// Watcher observes a set of directories and reports which of them changed.
+// Events are coalesced per directory and debounced, so a burst of thousands
+// of creates and deletes produces a single batch.
+type Watcher struct {
+	fsw      *fsnotify.Watcher
+	mu       sync.Mutex
+	watched  map[string]bool
+	ready    map[string]bool
+	onChange func()
+	done     chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewWatcher creates a watcher. onChange is called from a background
+// goroutine whenever a new batch of changed directories is ready; it should
+// only schedule work (e.g. invalidate the window) and not touch the tree.
+func NewWatcher(onChange func()) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		fsw:      fsw,
+		watched:  make(map[string]bool),
+		ready:    make(map[string]bool),
+		onChange: onChange,
+		done:     make(chan struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+// Sync makes the watched set match dirs exactly, adding and removing
+// watches as needed.
+func (w *Watcher) Sync(dirs []string) {
+	want := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		want[dir] = true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for dir := range w.watched {
+		if !want[dir] {
+			// The directory may already be gone, in which case fsnotify
+			// has dropped the watch itself.
+			_ = w.fsw.Remove(dir)
+			delete(w.watched, dir)
+		}
+	}
+	for dir := range want {
+		if w.watched[dir] {
+			continue
+		}
+		if err := w.fsw.Add(dir); err != nil {
+			// Unreadable or vanished directory; it will be retried on the
+			// next sync.
+			continue
+		}
+		w.watched[dir] = true
+	}
+}
+
+// Add starts watching a single directory.
+func (w *Watcher) Add(dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.watched[dir] {
+		return nil
+	}
+	if err := w.fsw.Add(dir); err != nil {
+		return err
+	}
+	w.watched[dir] = true
+	return nil
+}
+
+// Remove stops watching a single directory.
+func (w *Watcher) Remove(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.watched[dir] {
+		_ = w.fsw.Remove(dir)
+		delete(w.watched, dir)
+	}
+}
+
+// TakeChanges returns the directories that changed since the last call.
+func (w *Watcher) TakeChanges() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.ready) == 0 {
+		return nil
+	}
+	dirs := make([]string, 0, len(w.ready))
+	for dir := range w.ready {
+		dirs = append(dirs, dir)
+	}
+	w.ready = make(map[string]bool)
+	return dirs
+}
+
+// Close stops the watcher and releases its resources. Closing it again
+// returns the error of the first Close.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.closeErr = w.fsw.Close()
+	})
+	return w.closeErr
+}
+
+// loop collects raw fsnotify events into debounced batches.
+func (w *Watcher) loop() {
+	pending := make(map[string]bool)
+	var first time.Time
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+
+	publish := func() {
+		if len(pending) == 0 {
+			return
+		}
+		w.mu.Lock()
+		for dir := range pending {
+			w.ready[dir] = true
+		}
+		w.mu.Unlock()
+		pending = make(map[string]bool)
+		if w.onChange != nil {
+			w.onChange()
+		}
+	}
+
+	for {
+		select {
+		case <-w.done:
+			timer.Stop()
+			return
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			// Content writes and permission changes don't alter the tree.
+			if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Remove) && !ev.Has(fsnotify.Rename) {
+				continue
+			}
+			if len(pending) == 0 {
+				first = time.Now()
+			}
+			pending[filepath.Dir(ev.Name)] = true
+			if time.Since(first) >= watchMaxDelay {
+				timer.Stop()
+				publish()
+				continue
+			}
+			timer.Reset(watchDebounce)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			// The kernel dropped events, so any watched directory may be
+			// stale: rescan all of them.
+			if err == fsnotify.ErrEventOverflow {
+				if len(pending) == 0 {
+					first = time.Now()
+				}
+				w.mu.Lock()
+				for dir := range w.watched {
+					pending[dir] = true
+				}
+				w.mu.Unlock()
+				timer.Reset(watchDebounce)
+			}
+		case <-timer.C:
+			publish()
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWatcherDebouncesBursts(t *testing.T) {
+	dir := t.TempDir()
+	batches := make(chan struct{}, 16)
+	w, err := NewWatcher(func() { batches <- struct{}{} })
+	if err != nil {
+		t.Skipf("no watcher: %v", err)
+	}
+	defer w.Close()
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		writeFile(t, dir, "f"+strconv.Itoa(i), "")
+	}
+	select {
+	case <-batches:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch after a burst of creates")
+	}
+	if got := w.TakeChanges(); len(got) != 1 || got[0] != dir {
+		t.Fatalf("changes got %v want [%s]", got, dir)
+	}
+
+	// The burst was published once, after it went quiet
+	select {
+	case <-batches:
+		t.Fatal("burst published more than once")
+	case <-time.After(3 * watchDebounce):
+	}
+	if got := w.TakeChanges(); got != nil {
+		t.Fatalf("changes after taking them got %v want none", got)
+	}
+}
+
+func TestWatcherPublishesDuringStorm(t *testing.T) {
+	dir := t.TempDir()
+	batches := make(chan struct{}, 16)
+	w, err := NewWatcher(func() { batches <- struct{}{} })
+	if err != nil {
+		t.Skipf("no watcher: %v", err)
+	}
+	defer w.Close()
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Events closer together than watchDebounce never let the storm go
+	// quiet, so only watchMaxDelay gets a batch out
+	start := time.Now()
+	for i := 0; ; i++ {
+		select {
+		case <-batches:
+			if elapsed := time.Since(start); elapsed < watchMaxDelay {
+				t.Fatalf("batch after %v, before the storm hit watchMaxDelay", elapsed)
+			}
+			return
+		default:
+		}
+		if time.Since(start) > watchMaxDelay+3*time.Second {
+			t.Fatal("no batch while events kept coming")
+		}
+		writeFile(t, dir, "f"+strconv.Itoa(i), "")
+		time.Sleep(watchDebounce / 4)
+	}
+}
+
+func TestWatcherCloseTwice(t *testing.T) {
+	w, err := NewWatcher(nil)
+	if err != nil {
+		t.Skipf("no watcher: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("second Close got %v want the first Close's nil", err)
+	}
+}
+
+func TestApplyWatchChanges(t *testing.T) {
+	isolateGitConfig(t)
+	root := t.TempDir()
+	writeFile(t, root, "sub/a.txt", "")
+	writeFile(t, root, "sub/b.txt", "")
+	writeFile(t, root, "sub/c.txt", "")
+	writeFile(t, root, "top.txt", "")
+
+	tree := newTestTree(t, root)
+	sub := childNamed(tree.Root, "sub")
+	if err := tree.ExpandAndLoad(sub); err != nil {
+		t.Fatal(err)
+	}
+	kept := childNamed(sub, "c.txt")
+	tree.selectNode(kept)
+
+	w, err := NewWatcher(nil)
+	if err != nil {
+		t.Skipf("no watcher: %v", err)
+	}
+	defer w.Close()
+	tree.SetWatcher(w)
+
+	writeFile(t, root, "sub/new.txt", "")
+	if err := os.Remove(filepath.Join(root, "sub", "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(root, "sub", "b.txt"), filepath.Join(root, "sub", "renamed.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"c.txt", "new.txt", "renamed.txt"}
+	deadline := time.Now().Add(5 * time.Second)
+	for !equalStrings(childNames(sub), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("children got %v want %v", childNames(sub), want)
+		}
+		tree.ApplyWatchChanges()
+		time.Sleep(20 * time.Millisecond)
+	}
+	if childNamed(sub, "c.txt") != kept || tree.SelectedNode() != kept {
+		t.Fatal("unchanged node was replaced or lost the selection")
+	}
+	if tree.ApplyWatchChanges() {
+		t.Fatal("ApplyWatchChanges reported an update without changes")
+	}
+}
+
+func TestReconcileDirectory(t *testing.T) {
+	isolateGitConfig(t)
+	root := t.TempDir()
+	writeFile(t, root, "sub/deep/x.txt", "")
+	writeFile(t, root, "sub/a.txt", "")
+
+	tree := newTestTree(t, root)
+	sub := childNamed(tree.Root, "sub")
+	if err := tree.ExpandAndLoad(sub); err != nil {
+		t.Fatal(err)
+	}
+	deep := childNamed(sub, "deep")
+	if err := tree.ExpandAndLoad(deep); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, root, "sub/b.txt", "")
+	if err := os.Remove(filepath.Join(root, "sub", "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.reconcileDirectory(sub); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := childNames(sub), []string{"b.txt", "deep"}; !equalStrings(got, want) {
+		t.Fatalf("children got %v want %v", got, want)
+	}
+	if childNamed(sub, "deep") != deep || !deep.Expanded {
+		t.Fatal("expanded directory was replaced")
+	}
+	if b := childNamed(sub, "b.txt"); b.Parent != sub || b.Depth != sub.Depth+1 {
+		t.Fatalf("new child parent/depth got %p/%d", b.Parent, b.Depth)
+	}
+
+	// A directory that disappeared is dropped from its parent
+	if err := os.RemoveAll(filepath.Join(root, "sub", "deep")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.reconcileDirectory(deep); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := childNames(sub), []string{"b.txt"}; !equalStrings(got, want) {
+		t.Fatalf("children after removing deep got %v want %v", got, want)
+	}
+}
+
+func newTestTree(t *testing.T, root string) *FileTree {
+	t.Helper()
+	tree, err := NewFileTree(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.LoadInitial(); err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+// childNamed returns the child of node called name, or nil.
+func childNamed(node *TreeNode, name string) *TreeNode {
+	for _, child := range node.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// childNames returns the sorted names of node's children.
+func childNames(node *TreeNode) []string {
+	var names []string
+	for _, child := range node.Children {
+		names = append(names, child.Name)
+	}
+	sort.Strings(names)
+	return names
+}
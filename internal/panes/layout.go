@@ -20,7 +20,7 @@ type PaneNode struct {
 
 	// Internal node fields (if this is a split container)
 	Split SplitDirection
-	Ratio float32   // Split ratio (always 0.5 for 50/50 splits)
+	Ratio float32   // Share of the split taken by the left or top child
 	Left  *PaneNode // Left or top child
 	Right *PaneNode // Right or bottom child
 }
@@ -36,7 +36,7 @@ func NewPaneNode(pane *Pane) *PaneNode {
 func NewSplitNode(direction SplitDirection, left, right *PaneNode) *PaneNode {
 	return &PaneNode{
 		Split: direction,
-		Ratio: 0.5, // Always 50/50 splits
+		Ratio: 0.5, // Splits start out 50/50
 		Left:  left,
 		Right: right,
 	}
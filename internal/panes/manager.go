@@ -13,8 +13,8 @@ type PaneManager struct {
 }
 
 // NewPaneManager creates a new pane manager with a single initial pane.
-func NewPaneManager(initialBufferIndex int) *PaneManager {
-	pane := NewPane("pane-0", initialBufferIndex)
+func NewPaneManager(initialBufferID int) *PaneManager {
+	pane := NewPane("pane-0", initialBufferID)
 	pane.SetActive(true)
 
 	return &PaneManager{
@@ -66,7 +66,7 @@ func (pm *PaneManager) SetActivePane(pane *Pane) {
 	pane.SetActive(true)
 	pm.activePane = pane
 
-	fmt.Printf("[PANE_MANAGER] SetActivePane: ID=%s, BufferIndex=%d\n", pane.ID, pane.BufferIndex)
+	fmt.Printf("[PANE_MANAGER] SetActivePane: ID=%s, BufferID=%d\n", pane.ID, pane.BufferID)
 }
 
 // SetActivePaneQuiet is a low-level setter for the active pane that doesn't
@@ -77,13 +77,13 @@ func (pm *PaneManager) SetActivePaneQuiet(pane *Pane) {
 
 // SplitVertical splits the active pane vertically (creates horizontal divider).
 // Creates a new pane below the active pane.
-func (pm *PaneManager) SplitVertical(newBufferIndex int) error {
+func (pm *PaneManager) SplitVertical(newBufferID int) error {
 	if pm.activePane == nil {
 		return fmt.Errorf("no active pane to split")
 	}
 
 	// Create new pane
-	newPane := NewPane(fmt.Sprintf("pane-%d", pm.nextPaneID), newBufferIndex)
+	newPane := NewPane(fmt.Sprintf("pane-%d", pm.nextPaneID), newBufferID)
 	pm.nextPaneID++
 
 	// Find the node containing the active pane and replace it with a split
@@ -97,13 +97,13 @@ func (pm *PaneManager) SplitVertical(newBufferIndex int) error {
 
 // SplitHorizontal splits the active pane horizontally (creates vertical divider).
 // Creates a new pane to the right of the active pane.
-func (pm *PaneManager) SplitHorizontal(newBufferIndex int) error {
+func (pm *PaneManager) SplitHorizontal(newBufferID int) error {
 	if pm.activePane == nil {
 		return fmt.Errorf("no active pane to split")
 	}
 
 	// Create new pane
-	newPane := NewPane(fmt.Sprintf("pane-%d", pm.nextPaneID), newBufferIndex)
+	newPane := NewPane(fmt.Sprintf("pane-%d", pm.nextPaneID), newBufferID)
 	pm.nextPaneID++
 
 	// Find the node containing the active pane and replace it with a split
@@ -233,10 +233,10 @@ func (pm *PaneManager) ZoomedPane() *Pane {
 	return pm.zoomed
 }
 
-// FindPaneByBufferIndex finds a pane displaying the given buffer index.
-func (pm *PaneManager) FindPaneByBufferIndex(bufferIndex int) *Pane {
+// FindPaneByBufferID finds a pane displaying the buffer with the given ID.
+func (pm *PaneManager) FindPaneByBufferID(bufferID int) *Pane {
 	for _, pane := range pm.AllPanes() {
-		if pane.BufferIndex == bufferIndex {
+		if pane.BufferID == bufferID {
 			return pane
 		}
 	}
@@ -117,8 +117,9 @@ type PaneGeometry struct {
 	Height int
 }
 
-// CalculateGeometry calculates the on-screen geometry of all panes.
-// This is used for geometric pane navigation.
+// CalculateGeometry calculates the on-screen geometry of all panes in an
+// area of width columns and height lines. Panes keep MinPaneWidth and
+// MinPaneHeight where the area leaves room for them.
 func (pm *PaneManager) CalculateGeometry(width, height int) []PaneGeometry {
 	var geometries []PaneGeometry
 	pm.calculateNodeGeometry(pm.root, 0, 0, width, height, &geometries)
@@ -145,15 +146,13 @@ func (pm *PaneManager) calculateNodeGeometry(node *PaneNode, x, y, width, height
 	// Calculate split position
 	if node.Split == SplitHorizontal {
 		// Left | Right split
-		leftWidth := int(float32(width) * node.Ratio)
-		rightWidth := width - leftWidth - 1 // -1 for separator
+		leftWidth, rightWidth := node.SplitSizes(width, 1, 1) // 1 column for the separator
 
 		pm.calculateNodeGeometry(node.Left, x, y, leftWidth, height, geometries)
 		pm.calculateNodeGeometry(node.Right, x+leftWidth+1, y, rightWidth, height, geometries)
 	} else {
 		// Top / Bottom split
-		topHeight := int(float32(height) * node.Ratio)
-		bottomHeight := height - topHeight - 1 // -1 for separator
+		topHeight, bottomHeight := node.SplitSizes(height, 1, 1) // 1 line for the separator
 
 		pm.calculateNodeGeometry(node.Left, x, y, width, topHeight, geometries)
 		pm.calculateNodeGeometry(node.Right, x, y+topHeight+1, width, bottomHeight, geometries)
@@ -4,16 +4,16 @@ package panes
 // Each pane displays exactly one buffer and maintains independent scroll position.
 type Pane struct {
 	ID          string // Unique identifier for this pane
-	BufferIndex int    // Index into BufferManager.buffers
+	BufferID    int    // ID of the buffer shown, see editor.Buffer.ID
 	Active      bool   // Is this pane currently focused?
 	ViewportTop int    // First visible line (0-based) for independent scrolling
 }
 
-// NewPane creates a new pane with the given buffer index.
-func NewPane(id string, bufferIndex int) *Pane {
+// NewPane creates a new pane showing the buffer with the given ID.
+func NewPane(id string, bufferID int) *Pane {
 	return &Pane{
 		ID:          id,
-		BufferIndex: bufferIndex,
+		BufferID:    bufferID,
 		Active:      false,
 		ViewportTop: 0,
 	}
@@ -24,9 +24,9 @@ func (p *Pane) SetActive(active bool) {
 	p.Active = active
 }
 
-// SetBufferIndex changes which buffer this pane displays.
-func (p *Pane) SetBufferIndex(index int) {
-	p.BufferIndex = index
+// SetBufferID changes which buffer this pane displays.
+func (p *Pane) SetBufferID(id int) {
+	p.BufferID = id
 	// Reset viewport when switching buffers
 	p.ViewportTop = 0
 }
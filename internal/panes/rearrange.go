@@ -0,0 +1,184 @@
+package panes
+
+import (
+	"fmt"
+)
+
+// Rearranging panes, Vim's Ctrl+W x, r, R, H, J, K, L and T. A row (or
+// column) is the run of splits in one direction around a pane; its members
+// are the panes and differently split subtrees it lays out side by side.
+
+// row returns the slots of the row around pane in order, the child fields
+// that hold its members, and the index of the pane's own slot. It returns
+// no slots when the pane isn't split from anything.
+func (pm *PaneManager) row(pane *Pane) ([]**PaneNode, int) {
+	parent := pm.root.parentOf(pane)
+	if parent == nil {
+		return nil, -1
+	}
+
+	// Climb to the outermost split of the row
+	top := parent
+	for {
+		up := pm.root.parentOfNode(top)
+		if up == nil || up.Split != parent.Split {
+			break
+		}
+		top = up
+	}
+
+	var slots []**PaneNode
+	var collect func(node *PaneNode)
+	collect = func(node *PaneNode) {
+		for _, child := range []**PaneNode{&node.Left, &node.Right} {
+			if !(*child).IsLeaf() && (*child).Split == parent.Split {
+				collect(*child)
+			} else {
+				slots = append(slots, child)
+			}
+		}
+	}
+	collect(top)
+
+	for i, slot := range slots {
+		if (*slot).IsLeaf() && (*slot).Pane == pane {
+			return slots, i
+		}
+	}
+	return nil, -1
+}
+
+// Exchange swaps the active pane with the next one in its row or column,
+// or the previous one when it is the last. The cursor stays where it was,
+// in the pane that moved there.
+func (pm *PaneManager) Exchange() error {
+	if pm.activePane == nil {
+		return fmt.Errorf("no active pane")
+	}
+	slots, i := pm.row(pm.activePane)
+	if len(slots) < 2 {
+		return fmt.Errorf("no pane to exchange with")
+	}
+	j := i + 1
+	if j == len(slots) {
+		j = i - 1
+	}
+	if !(*slots[j]).IsLeaf() {
+		return fmt.Errorf("cannot exchange with a pane that is split the other way")
+	}
+
+	*slots[i], *slots[j] = *slots[j], *slots[i]
+	pm.SetActivePane((*slots[i]).Pane)
+	return nil
+}
+
+// Rotate moves every pane in the active pane's row or column one place
+// down (or right), the last one becoming the first. With upwards it goes
+// the other way. The active pane moves along and stays active.
+func (pm *PaneManager) Rotate(upwards bool) error {
+	if pm.activePane == nil {
+		return fmt.Errorf("no active pane")
+	}
+	slots, _ := pm.row(pm.activePane)
+	if len(slots) < 2 {
+		return fmt.Errorf("no panes to rotate")
+	}
+
+	members := make([]*PaneNode, len(slots))
+	for i, slot := range slots {
+		members[i] = *slot
+	}
+	for i := range slots {
+		if upwards {
+			*slots[i] = members[(i+1)%len(members)]
+		} else {
+			*slots[i] = members[(i-1+len(members))%len(members)]
+		}
+	}
+	return nil
+}
+
+// MoveToEdge moves the active pane to the far edge in direction dir, where
+// it takes the full height (for left and right) or width (for up and down)
+// of the layout.
+func (pm *PaneManager) MoveToEdge(dir Direction) error {
+	if pm.activePane == nil {
+		return fmt.Errorf("no active pane")
+	}
+	if pm.PaneCount() <= 1 {
+		return fmt.Errorf("no other pane to move around")
+	}
+
+	pane := pm.activePane
+	rest := pm.removeNodeContainingPane(pm.root, pane)
+	leaf := NewPaneNode(pane)
+	switch dir {
+	case DirLeft:
+		pm.root = NewSplitNode(SplitHorizontal, leaf, rest)
+	case DirRight:
+		pm.root = NewSplitNode(SplitHorizontal, rest, leaf)
+	case DirUp:
+		pm.root = NewSplitNode(SplitVertical, leaf, rest)
+	default:
+		pm.root = NewSplitNode(SplitVertical, rest, leaf)
+	}
+	return nil
+}
+
+// BreakOut takes the active pane out of the layout, which must have
+// another pane, and returns a new layout holding just that pane, for a new
+// tab page. The first pane left becomes active.
+func (pm *PaneManager) BreakOut() (*PaneManager, error) {
+	if pm.activePane == nil {
+		return nil, fmt.Errorf("no active pane")
+	}
+	if pm.PaneCount() <= 1 {
+		return nil, fmt.Errorf("only one pane in this tab page")
+	}
+
+	pane := pm.activePane
+	pm.root = pm.removeNodeContainingPane(pm.root, pane)
+	if pm.zoomed == pane {
+		pm.zoomed = nil
+	}
+	pm.SetActivePane(pm.AllPanes()[0])
+
+	broken := &PaneManager{
+		root:       NewPaneNode(pane),
+		nextPaneID: pm.nextPaneID,
+	}
+	broken.SetActivePane(pane)
+	return broken, nil
+}
+
+// parentOf returns the split node whose child is the leaf holding pane,
+// or nil when there is none.
+func (n *PaneNode) parentOf(pane *Pane) *PaneNode {
+	if n == nil || n.IsLeaf() {
+		return nil
+	}
+	for _, child := range []*PaneNode{n.Left, n.Right} {
+		if child.IsLeaf() && child.Pane == pane {
+			return n
+		}
+	}
+	if parent := n.Left.parentOf(pane); parent != nil {
+		return parent
+	}
+	return n.Right.parentOf(pane)
+}
+
+// parentOfNode returns the split node whose child is node, or nil when
+// node is the root or not in the tree.
+func (n *PaneNode) parentOfNode(node *PaneNode) *PaneNode {
+	if n == nil || n.IsLeaf() {
+		return nil
+	}
+	if n.Left == node || n.Right == node {
+		return n
+	}
+	if parent := n.Left.parentOfNode(node); parent != nil {
+		return parent
+	}
+	return n.Right.parentOfNode(node)
+}
@@ -0,0 +1,159 @@
+package panes
+
+import "testing"
+
+// layoutString describes a pane tree: panes by their buffer ID, left |
+// right splits as (a | b) and top / bottom ones as (a / b).
+func layoutString(n *PaneNode) string {
+	if n.IsLeaf() {
+		return string(rune('a' + n.Pane.BufferID))
+	}
+	sep := " / "
+	if n.Split == SplitHorizontal {
+		sep = " | "
+	}
+	return "(" + layoutString(n.Left) + sep + layoutString(n.Right) + ")"
+}
+
+// newTestLayout builds (a | (b | (c / d))), a row of a, b and a column of
+// c and d, with c active.
+func newTestLayout(t *testing.T) *PaneManager {
+	t.Helper()
+	pm := NewPaneManager(0)
+	for _, step := range []struct {
+		split func(int) error
+		buf   int
+	}{
+		{pm.SplitHorizontal, 1},
+		{pm.SplitHorizontal, 2},
+		{pm.SplitVertical, 3},
+	} {
+		if err := step.split(step.buf); err != nil {
+			t.Fatalf("split: %v", err)
+		}
+	}
+	pm.SetActivePane(pm.FindPaneByBufferID(2))
+	if got := layoutString(pm.Root()); got != "(a | (b | (c / d)))" {
+		t.Fatalf("test layout got %s", got)
+	}
+	return pm
+}
+
+func activeName(pm *PaneManager) string {
+	return string(rune('a' + pm.ActivePane().BufferID))
+}
+
+func TestExchange(t *testing.T) {
+	pm := newTestLayout(t)
+
+	// c and d form a column; c swaps with the pane after it
+	if err := pm.Exchange(); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if got := layoutString(pm.Root()); got != "(a | (b | (d / c)))" {
+		t.Fatalf("after exchange got %s", got)
+	}
+	if activeName(pm) != "d" {
+		t.Fatalf("active pane got %s, want the one moved into its place", activeName(pm))
+	}
+
+	// The last pane in the row swaps with the one before it, and panes
+	// don't swap with a subtree split the other way
+	pm.SetActivePane(pm.FindPaneByBufferID(1))
+	if err := pm.Exchange(); err == nil {
+		t.Fatalf("exchange with a split subtree got %s", layoutString(pm.Root()))
+	}
+	pm.SetActivePane(pm.FindPaneByBufferID(0))
+	if err := pm.Exchange(); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if got := layoutString(pm.Root()); got != "(b | (a | (d / c)))" {
+		t.Fatalf("after second exchange got %s", got)
+	}
+
+	single := NewPaneManager(0)
+	if err := single.Exchange(); err == nil {
+		t.Fatalf("exchange with a single pane succeeded")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	pm := newTestLayout(t)
+	pm.SetActivePane(pm.FindPaneByBufferID(0))
+
+	// The row is a, b and the (c / d) column
+	if err := pm.Rotate(false); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if got := layoutString(pm.Root()); got != "((c / d) | (a | b))" {
+		t.Fatalf("after rotating right got %s", got)
+	}
+	if err := pm.Rotate(true); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if got := layoutString(pm.Root()); got != "(a | (b | (c / d)))" {
+		t.Fatalf("after rotating back got %s", got)
+	}
+	if activeName(pm) != "a" {
+		t.Fatalf("active pane got %s", activeName(pm))
+	}
+
+	// The column only has c and d
+	pm.SetActivePane(pm.FindPaneByBufferID(3))
+	if err := pm.Rotate(true); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if got := layoutString(pm.Root()); got != "(a | (b | (d / c)))" {
+		t.Fatalf("after rotating the column got %s", got)
+	}
+}
+
+func TestMoveToEdge(t *testing.T) {
+	tests := []struct {
+		dir  Direction
+		want string
+	}{
+		{DirLeft, "(c | (a | (b | d)))"},
+		{DirRight, "((a | (b | d)) | c)"},
+		{DirUp, "(c / (a | (b | d)))"},
+		{DirDown, "((a | (b | d)) / c)"},
+	}
+	for _, tt := range tests {
+		pm := newTestLayout(t)
+		if err := pm.MoveToEdge(tt.dir); err != nil {
+			t.Fatalf("MoveToEdge(%d): %v", tt.dir, err)
+		}
+		if got := layoutString(pm.Root()); got != tt.want {
+			t.Errorf("MoveToEdge(%d) got %s want %s", tt.dir, got, tt.want)
+		}
+		if activeName(pm) != "c" || pm.PaneCount() != 4 {
+			t.Errorf("MoveToEdge(%d) left %s active of %d panes", tt.dir, activeName(pm), pm.PaneCount())
+		}
+	}
+}
+
+func TestBreakOut(t *testing.T) {
+	tabs := NewTabPages(0)
+	pm := tabs.Active()
+	if _, err := tabs.BreakOut(); err == nil {
+		t.Fatalf("breaking out the only pane succeeded")
+	}
+	if err := pm.SplitHorizontal(1); err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	pm.ToggleZoom()
+
+	broken, err := tabs.BreakOut()
+	if err != nil {
+		t.Fatalf("BreakOut: %v", err)
+	}
+	if tabs.Count() != 2 || tabs.ActiveIndex() != 1 || tabs.Active() != broken {
+		t.Fatalf("tabs got %d, active %d", tabs.Count(), tabs.ActiveIndex())
+	}
+	if got := layoutString(broken.Root()); got != "b" || activeName(broken) != "b" {
+		t.Fatalf("new tab got %s", got)
+	}
+	if got := layoutString(pm.Root()); got != "a" || activeName(pm) != "a" || pm.IsZoomed() {
+		t.Fatalf("old tab got %s, active %s, zoomed %v", got, activeName(pm), pm.IsZoomed())
+	}
+}
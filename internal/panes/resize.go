@@ -0,0 +1,191 @@
+package panes
+
+import "fmt"
+
+// Minimum pane size in cells. Resizing never makes a pane smaller, and
+// splits keep it when the window shrinks, as long as there is room.
+const (
+	MinPaneWidth  = 10
+	MinPaneHeight = 3
+)
+
+// SplitSizes divides extent, the size of a split node along its axis,
+// between its children. first is the size of the left or top child, second
+// that of the right or bottom one, and sep is taken by the separator. cell
+// is the size of one cell along the axis, in the unit of extent, so the
+// minimum pane sizes can be kept whatever the unit.
+func (n *PaneNode) SplitSizes(extent, sep, cell int) (first, second int) {
+	first = int(float32(extent) * n.Ratio)
+	minFirst := n.Left.minExtent(n.Split, sep, cell)
+	minSecond := n.Right.minExtent(n.Split, sep, cell)
+	if minFirst+sep+minSecond <= extent {
+		first = max(minFirst, min(first, extent-sep-minSecond))
+	}
+	first = max(0, min(first, extent-sep))
+	return first, extent - first - sep
+}
+
+// SetFirstSize sets the ratio so the left or top child gets size out of
+// extent, within the minimum pane sizes. sep and cell are as for
+// SplitSizes.
+func (n *PaneNode) SetFirstSize(size, extent, sep, cell int) {
+	if extent <= sep {
+		return
+	}
+	minFirst := n.Left.minExtent(n.Split, sep, cell)
+	minSecond := n.Right.minExtent(n.Split, sep, cell)
+	size = max(minFirst, min(size, extent-sep-minSecond))
+	size = max(0, min(size, extent-sep))
+
+	// Aim at the middle of the unit so SplitSizes rounds down to size
+	n.Ratio = min((float32(size)+0.5)/float32(extent), 1)
+}
+
+// minExtent returns the smallest size of the subtree along the axis of
+// splits in direction dir.
+func (n *PaneNode) minExtent(dir SplitDirection, sep, cell int) int {
+	if n == nil {
+		return 0
+	}
+	if n.IsLeaf() {
+		if dir == SplitHorizontal {
+			return MinPaneWidth * cell
+		}
+		return MinPaneHeight * cell
+	}
+	left := n.Left.minExtent(dir, sep, cell)
+	right := n.Right.minExtent(dir, sep, cell)
+	if n.Split == dir {
+		return left + sep + right
+	}
+	return max(left, right)
+}
+
+// enclosingSplit is a split around a pane, with its size along the split
+// axis and the side the pane is on.
+type enclosingSplit struct {
+	node   *PaneNode
+	extent int
+	first  bool // The pane is in the left or top child
+}
+
+// enclosingSplits returns the splits in direction dir around pane, from the
+// root inwards, for an area of width columns and height lines.
+func (n *PaneNode) enclosingSplits(pane *Pane, dir SplitDirection, width, height int) ([]enclosingSplit, bool) {
+	if n == nil {
+		return nil, false
+	}
+	if n.IsLeaf() {
+		return nil, n.Pane == pane
+	}
+
+	extent := height
+	if n.Split == SplitHorizontal {
+		extent = width
+	}
+	first, second := n.SplitSizes(extent, 1, 1)
+	leftW, leftH, rightW, rightH := width, first, width, second
+	if n.Split == SplitHorizontal {
+		leftW, leftH, rightW, rightH = first, height, second, height
+	}
+
+	inner, found := n.Left.enclosingSplits(pane, dir, leftW, leftH)
+	inFirst := found
+	if !found {
+		inner, found = n.Right.enclosingSplits(pane, dir, rightW, rightH)
+	}
+	if !found {
+		return nil, false
+	}
+	if n.Split != dir {
+		return inner, true
+	}
+	return append([]enclosingSplit{{node: n, extent: extent, first: inFirst}}, inner...), true
+}
+
+// paneSize returns the size of pane along the axis of splits in direction
+// dir, in an area of width columns and height lines.
+func (pm *PaneManager) paneSize(pane *Pane, dir SplitDirection, width, height int) int {
+	for _, geom := range pm.CalculateGeometry(width, height) {
+		if geom.Pane != pane {
+			continue
+		}
+		if dir == SplitHorizontal {
+			return geom.Width
+		}
+		return geom.Height
+	}
+	return 0
+}
+
+// ActivePaneSize returns the size of the active pane in an area of width
+// columns and height lines: its width for dir SplitHorizontal (left | right
+// splits), its height for SplitVertical (top / bottom splits).
+func (pm *PaneManager) ActivePaneSize(dir SplitDirection, width, height int) int {
+	return pm.paneSize(pm.activePane, dir, width, height)
+}
+
+// ResizeActivePane makes the active pane size columns wide for dir
+// SplitHorizontal, or size lines high for SplitVertical, in an area of
+// width columns and height lines. The nearest split around the pane gives
+// or takes the space first, the ones further out when it runs out of room,
+// so a size larger than the area maximizes the pane.
+func (pm *PaneManager) ResizeActivePane(dir SplitDirection, size, width, height int) error {
+	if pm.activePane == nil {
+		return fmt.Errorf("no active pane to resize")
+	}
+	splits, _ := pm.root.enclosingSplits(pm.activePane, dir, width, height)
+	if len(splits) == 0 {
+		if dir == SplitHorizontal {
+			return fmt.Errorf("no pane to the left or right")
+		}
+		return fmt.Errorf("no pane above or below")
+	}
+
+	for i := len(splits) - 1; i >= 0; i-- {
+		current := pm.paneSize(pm.activePane, dir, width, height)
+		if current == size {
+			break
+		}
+		// Resizing a split changes the extent of the splits inside it,
+		// which keep what is beside the pane as it is, so the change all
+		// goes to the pane
+		beside := make([]int, len(splits))
+		for j := i + 1; j < len(splits); j++ {
+			beside[j] = splits[j].besideSize()
+		}
+
+		split := splits[i]
+		first, second := split.node.SplitSizes(split.extent, 1, 1)
+		if split.first {
+			split.node.SetFirstSize(first+size-current, split.extent, 1, 1)
+		} else {
+			split.node.SetFirstSize(split.extent-1-(second+size-current), split.extent, 1, 1)
+		}
+
+		for j := i + 1; j < len(splits); j++ {
+			// Each split sets the extent of the next one in
+			splits, _ = pm.root.enclosingSplits(pm.activePane, dir, width, height)
+			splits[j].setBesideSize(beside[j])
+		}
+	}
+	return nil
+}
+
+// besideSize returns the size of the child of the split the pane isn't in.
+func (s enclosingSplit) besideSize() int {
+	first, second := s.node.SplitSizes(s.extent, 1, 1)
+	if s.first {
+		return second
+	}
+	return first
+}
+
+// setBesideSize resizes the child of the split the pane isn't in.
+func (s enclosingSplit) setBesideSize(size int) {
+	if s.first {
+		s.node.SetFirstSize(s.extent-1-size, s.extent, 1, 1)
+	} else {
+		s.node.SetFirstSize(size, s.extent, 1, 1)
+	}
+}
@@ -0,0 +1,121 @@
+package panes
+
+import "testing"
+
+// testLeaf and testSplit build pane trees for the size tests.
+func testLeaf(id int) *PaneNode {
+	return NewPaneNode(NewPane("", id))
+}
+
+func testSplit(dir SplitDirection, ratio float32, left, right *PaneNode) *PaneNode {
+	node := NewSplitNode(dir, left, right)
+	node.Ratio = ratio
+	return node
+}
+
+func TestSplitSizes(t *testing.T) {
+	column := testSplit(SplitVertical, 0.5, testLeaf(1), testLeaf(2))
+	row := testSplit(SplitHorizontal, 0.5, testLeaf(3), testLeaf(4))
+	tests := []struct {
+		name                string
+		node                *PaneNode
+		extent, sep, cell   int
+		wantFirst, wantSecd int
+	}{
+		{"even", testSplit(SplitHorizontal, 0.5, testLeaf(0), testLeaf(1)), 81, 1, 1, 40, 40},
+		{"first at its minimum", testSplit(SplitHorizontal, 0.1, testLeaf(0), testLeaf(1)), 81, 1, 1, 10, 70},
+		{"second at its minimum", testSplit(SplitHorizontal, 0.95, testLeaf(0), testLeaf(1)), 81, 1, 1, 70, 10},
+		{"lines", testSplit(SplitVertical, 0, testLeaf(0), testLeaf(1)), 20, 1, 1, 3, 16},
+		{"too small for the minimums", testSplit(SplitHorizontal, 0.5, testLeaf(0), testLeaf(1)), 15, 1, 1, 7, 7},
+		{"too small for the separator", testSplit(SplitHorizontal, 1, testLeaf(0), testLeaf(1)), 1, 1, 1, 0, 0},
+		{"nested", testSplit(SplitHorizontal, 0.9, column, row), 50, 1, 1, 28, 21},
+		{"pixels", testSplit(SplitHorizontal, 0.1, testLeaf(0), testLeaf(1)), 400, 2, 8, 80, 318},
+	}
+	for _, tt := range tests {
+		first, second := tt.node.SplitSizes(tt.extent, tt.sep, tt.cell)
+		if first != tt.wantFirst || second != tt.wantSecd {
+			t.Errorf("%s: SplitSizes got %d, %d want %d, %d", tt.name, first, second, tt.wantFirst, tt.wantSecd)
+		}
+	}
+}
+
+func TestMinExtent(t *testing.T) {
+	// (a | (b / c)) is two panes wide and, for its column, two high
+	tree := testSplit(SplitHorizontal, 0.5, testLeaf(0), testSplit(SplitVertical, 0.5, testLeaf(1), testLeaf(2)))
+	tests := []struct {
+		dir       SplitDirection
+		sep, cell int
+		want      int
+	}{
+		{SplitHorizontal, 1, 1, 2*MinPaneWidth + 1},
+		{SplitVertical, 1, 1, 2*MinPaneHeight + 1},
+		{SplitHorizontal, 2, 8, 2*MinPaneWidth*8 + 2},
+		{SplitVertical, 2, 20, 2*MinPaneHeight*20 + 2},
+	}
+	for _, tt := range tests {
+		if got := tree.minExtent(tt.dir, tt.sep, tt.cell); got != tt.want {
+			t.Errorf("minExtent(%d, %d, %d) = %d, want %d", tt.dir, tt.sep, tt.cell, got, tt.want)
+		}
+	}
+	if got := (*PaneNode)(nil).minExtent(SplitHorizontal, 1, 1); got != 0 {
+		t.Errorf("minExtent of nil = %d, want 0", got)
+	}
+}
+
+func TestSetFirstSizeRoundTrip(t *testing.T) {
+	for _, extent := range []int{21, 50, 81, 203} {
+		node := testSplit(SplitHorizontal, 0.5, testLeaf(0), testLeaf(1))
+		for size := MinPaneWidth; size <= extent-1-MinPaneWidth; size++ {
+			node.SetFirstSize(size, extent, 1, 1)
+			if first, _ := node.SplitSizes(extent, 1, 1); first != size {
+				t.Fatalf("extent %d: SetFirstSize(%d) gave SplitSizes first %d", extent, size, first)
+			}
+		}
+	}
+
+	// Sizes out of range are clamped to the minimums
+	node := testSplit(SplitVertical, 0.5, testLeaf(0), testLeaf(1))
+	for _, tt := range []struct{ size, want int }{{0, 3}, {100, 16}} {
+		node.SetFirstSize(tt.size, 20, 1, 1)
+		if first, _ := node.SplitSizes(20, 1, 1); first != tt.want {
+			t.Errorf("SetFirstSize(%d) gave first %d, want %d", tt.size, first, tt.want)
+		}
+	}
+}
+
+func TestResizeActivePane(t *testing.T) {
+	const width, height = 100, 30
+	tests := []struct {
+		name   string
+		dir    SplitDirection
+		size   int
+		want   int
+		others map[int]int // Sizes of other panes by buffer ID
+	}{
+		{"nearest split", SplitHorizontal, 30, 30, nil},
+		{"past the nearest split", SplitHorizontal, 90, width - 2*MinPaneWidth - 2, map[int]int{0: MinPaneWidth, 1: MinPaneWidth}},
+		{"lines", SplitVertical, 5, 5, map[int]int{3: height - 5 - 1}},
+		{"below the minimum", SplitVertical, 1, MinPaneHeight, nil},
+	}
+	for _, tt := range tests {
+		pm := newTestLayout(t)
+		if err := pm.ResizeActivePane(tt.dir, tt.size, width, height); err != nil {
+			t.Fatalf("%s: ResizeActivePane: %v", tt.name, err)
+		}
+		if got := pm.ActivePaneSize(tt.dir, width, height); got != tt.want {
+			t.Errorf("%s: size got %d want %d", tt.name, got, tt.want)
+		}
+		for id, want := range tt.others {
+			if got := pm.paneSize(pm.FindPaneByBufferID(id), tt.dir, width, height); got != want {
+				t.Errorf("%s: pane %d size got %d want %d", tt.name, id, got, want)
+			}
+		}
+	}
+
+	// a has no pane above or below it
+	pm := newTestLayout(t)
+	pm.SetActivePane(pm.FindPaneByBufferID(0))
+	if err := pm.ResizeActivePane(SplitVertical, 5, width, height); err == nil {
+		t.Fatalf("resizing a pane without vertical splits succeeded")
+	}
+}
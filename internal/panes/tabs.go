@@ -0,0 +1,115 @@
+package panes
+
+import (
+	"fmt"
+)
+
+// TabPages holds the tab pages, each with its own pane layout. Switching
+// tabs keeps every layout as it was, zoom included, since each tab has its
+// own PaneManager.
+type TabPages struct {
+	tabs   []*PaneManager
+	active int
+}
+
+// NewTabPages creates a single tab page with one pane showing the initial
+// buffer.
+func NewTabPages(initialBufferID int) *TabPages {
+	return &TabPages{
+		tabs: []*PaneManager{NewPaneManager(initialBufferID)},
+	}
+}
+
+// Active returns the pane layout of the current tab page.
+func (t *TabPages) Active() *PaneManager {
+	return t.tabs[t.active]
+}
+
+// ActiveIndex returns the index of the current tab page.
+func (t *TabPages) ActiveIndex() int {
+	return t.active
+}
+
+// Count returns the number of tab pages.
+func (t *TabPages) Count() int {
+	return len(t.tabs)
+}
+
+// All returns the pane layouts of all tab pages, in order.
+func (t *TabPages) All() []*PaneManager {
+	return append([]*PaneManager(nil), t.tabs...)
+}
+
+// New opens a tab page after the current one, with one pane showing the
+// given buffer, and makes it current.
+func (t *TabPages) New(bufferID int) *PaneManager {
+	pm := NewPaneManager(bufferID)
+	t.insert(pm)
+	return pm
+}
+
+// BreakOut moves the active pane of the current tab page, which must have
+// another pane, to a new tab page after it and makes that current.
+func (t *TabPages) BreakOut() (*PaneManager, error) {
+	pm, err := t.Active().BreakOut()
+	if err != nil {
+		return nil, err
+	}
+	t.insert(pm)
+	return pm, nil
+}
+
+// insert adds a tab page after the current one and makes it current.
+func (t *TabPages) insert(pm *PaneManager) {
+	t.active++
+	t.tabs = append(t.tabs[:t.active], append([]*PaneManager{pm}, t.tabs[t.active:]...)...)
+}
+
+// Close closes the current tab page. The one after it becomes current, or
+// the one before when it was the last.
+func (t *TabPages) Close() error {
+	if len(t.tabs) <= 1 {
+		return fmt.Errorf("cannot close the last tab page")
+	}
+	t.tabs = append(t.tabs[:t.active], t.tabs[t.active+1:]...)
+	if t.active >= len(t.tabs) {
+		t.active = len(t.tabs) - 1
+	}
+	return nil
+}
+
+// Select makes tab page i (0-based) current.
+func (t *TabPages) Select(i int) error {
+	if i < 0 || i >= len(t.tabs) {
+		return fmt.Errorf("no tab page %d", i+1)
+	}
+	t.active = i
+	return nil
+}
+
+// Cycle moves count tab pages forward, or backward for a negative count,
+// wrapping around at either end.
+func (t *TabPages) Cycle(count int) {
+	n := len(t.tabs)
+	t.active = ((t.active+count)%n + n) % n
+}
+
+// MoveAfter moves the current tab page after tab page n, counting from 1
+// like :tabmove, or to the front for 0.
+func (t *TabPages) MoveAfter(n int) {
+	if n > t.active {
+		// Tab page n moves down one when the current one leaves its place
+		n--
+	}
+	t.Move(n)
+}
+
+// Move moves the current tab page to index i, clamped to the tab pages
+// there are.
+func (t *TabPages) Move(i int) {
+	i = max(0, min(i, len(t.tabs)-1))
+	pm := t.tabs[t.active]
+	t.tabs = append(t.tabs[:t.active], t.tabs[t.active+1:]...)
+	t.tabs = append(t.tabs[:i], append([]*PaneManager{pm}, t.tabs[i:]...)...)
+	t.active = i
+}
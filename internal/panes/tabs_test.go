@@ -0,0 +1,135 @@
+package panes
+
+import "testing"
+
+// tabOrder describes tab pages by the buffer of their first pane, with the
+// current one in brackets.
+func tabOrder(tabs *TabPages) string {
+	var order string
+	for i, pm := range tabs.All() {
+		name := string(rune('a' + pm.Root().Pane.BufferID))
+		if i == tabs.ActiveIndex() {
+			name = "[" + name + "]"
+		}
+		order += name
+	}
+	return order
+}
+
+// newTestTabs opens tab pages a to d with b current.
+func newTestTabs() *TabPages {
+	tabs := NewTabPages(0)
+	for id := 1; id < 4; id++ {
+		tabs.New(id)
+	}
+	tabs.Select(1)
+	return tabs
+}
+
+func TestTabPagesInsert(t *testing.T) {
+	tabs := NewTabPages(0)
+	tabs.New(1)
+	tabs.Select(0)
+	tabs.New(2)
+	if got := tabOrder(tabs); got != "a[c]b" {
+		t.Fatalf("tabs got %s, want the new page after the current one", got)
+	}
+}
+
+func TestTabPagesMove(t *testing.T) {
+	tests := []struct {
+		to   int
+		want string
+	}{
+		{0, "[b]acd"},
+		{2, "ac[b]d"},
+		{3, "acd[b]"},
+		{9, "acd[b]"},
+		{-1, "[b]acd"},
+	}
+	for _, tt := range tests {
+		tabs := newTestTabs()
+		tabs.Move(tt.to)
+		if got := tabOrder(tabs); got != tt.want {
+			t.Errorf("Move(%d) got %s want %s", tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestTabPagesMoveAfter(t *testing.T) {
+	// :tabmove N puts the tab page after tab page N; the tab page itself
+	// is counted before it moves
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "[b]acd"},
+		{1, "a[b]cd"},
+		{2, "a[b]cd"},
+		{3, "ac[b]d"},
+		{4, "acd[b]"},
+		{9, "acd[b]"},
+	}
+	for _, tt := range tests {
+		tabs := newTestTabs()
+		tabs.MoveAfter(tt.n)
+		if got := tabOrder(tabs); got != tt.want {
+			t.Errorf("MoveAfter(%d) got %s want %s", tt.n, got, tt.want)
+		}
+	}
+
+	tabs := newTestTabs()
+	tabs.Select(3)
+	tabs.MoveAfter(1)
+	if got := tabOrder(tabs); got != "a[d]bc" {
+		t.Errorf("MoveAfter(1) from the last tab got %s want a[d]bc", got)
+	}
+}
+
+func TestTabPagesClose(t *testing.T) {
+	tabs := newTestTabs()
+	if err := tabs.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := tabOrder(tabs); got != "a[c]d" {
+		t.Fatalf("after closing b got %s, want the next page current", got)
+	}
+
+	tabs.Select(2)
+	if err := tabs.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := tabOrder(tabs); got != "a[c]" {
+		t.Fatalf("after closing the last page got %s, want the one before current", got)
+	}
+
+	tabs.Close()
+	if err := tabs.Close(); err == nil {
+		t.Fatalf("closing the only tab page succeeded")
+	}
+	if got := tabOrder(tabs); got != "[a]" {
+		t.Fatalf("after closing all but one got %s", got)
+	}
+}
+
+func TestTabPagesCycle(t *testing.T) {
+	tests := []struct {
+		count int
+		want  string
+	}{
+		{1, "ab[c]d"},
+		{2, "abc[d]"},
+		{3, "[a]bcd"},
+		{-1, "[a]bcd"},
+		{-2, "abc[d]"},
+		{-6, "abc[d]"},
+		{8, "a[b]cd"},
+	}
+	for _, tt := range tests {
+		tabs := newTestTabs()
+		tabs.Cycle(tt.count)
+		if got := tabOrder(tabs); got != tt.want {
+			t.Errorf("Cycle(%d) got %s want %s", tt.count, got, tt.want)
+		}
+	}
+}
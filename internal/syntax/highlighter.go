@@ -4,6 +4,7 @@ import (
 	"hash/fnv"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/alecthomas/chroma/v2"
 	"github.com/alecthomas/chroma/v2/lexers"
@@ -23,6 +24,9 @@ type HighlightedLine struct {
 	Hash   uint64
 }
 
+// DefaultTheme is the theme new highlighters use.
+const DefaultTheme = "monokai"
+
 // Highlighter provides syntax highlighting for a specific file/language.
 type Highlighter struct {
 	lexer     chroma.Lexer
@@ -30,6 +34,10 @@ type Highlighter struct {
 	cache     map[int]*HighlightedLine
 	formatter *chroma.Formatter
 	enabled   bool
+
+	// Strings and comments of the text StringOrComment was last given
+	spans     [][]span
+	spansHash uint64
 }
 
 // NewHighlighter creates a new highlighter for the given file path.
@@ -52,7 +60,7 @@ func NewHighlighter(filePath string) *Highlighter {
 	}
 
 	// Get the style (theme)
-	style := styles.Get("monokai")
+	style := styles.Get(DefaultTheme)
 	if style == nil {
 		style = styles.Fallback
 	}
@@ -126,6 +134,67 @@ func (h *Highlighter) HighlightLine(lineNum int, text string) []Token {
 	return tokens
 }
 
+// span is the rune columns [start, end) of part of a line.
+type span struct{ start, end int }
+
+// StringOrComment returns a function reporting whether rune column col of
+// line is in a string or a comment. The lines are lexed as one text, so
+// block comments and raw strings spanning lines are found; the result is
+// kept until the text changes.
+func (h *Highlighter) StringOrComment(lines []string) func(line, col int) bool {
+	if !h.enabled {
+		return func(int, int) bool { return false }
+	}
+	text := strings.Join(lines, "\n")
+	if hash := hashString(text); h.spans == nil || hash != h.spansHash {
+		h.spans = h.stringOrCommentSpans(text, len(lines))
+		h.spansHash = hash
+	}
+	spans := h.spans
+	return func(line, col int) bool {
+		if line < 0 || line >= len(spans) {
+			return false
+		}
+		for _, sp := range spans[line] {
+			if col >= sp.start && col < sp.end {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// stringOrCommentSpans lexes text and returns, for each of its lineCount
+// lines, the spans of strings and comments on it.
+func (h *Highlighter) stringOrCommentSpans(text string, lineCount int) [][]span {
+	spans := make([][]span, lineCount)
+	iterator, err := h.lexer.Tokenise(nil, text)
+	if err != nil {
+		return spans
+	}
+	line, col := 0, 0
+	for _, token := range iterator.Tokens() {
+		inside := Token{Type: token.Type}.IsStringOrComment()
+		for i, part := range strings.Split(token.Value, "\n") {
+			if i > 0 {
+				line, col = line+1, 0
+			}
+			n := utf8.RuneCountInString(part)
+			if inside && n > 0 && line < lineCount {
+				spans[line] = append(spans[line], span{col, col + n})
+			}
+			col += n
+		}
+	}
+	return spans
+}
+
+// IsStringOrComment reports whether the token is part of a string or a
+// comment, whose brackets don't pair with the ones in code.
+func (t Token) IsStringOrComment() bool {
+	return t.Type.InSubCategory(chroma.LiteralString) || t.Type.InCategory(chroma.Comment)
+}
+
 // InvalidateLine removes a line from the cache (called when line is edited).
 func (h *Highlighter) InvalidateLine(lineNum int) {
 	delete(h.cache, lineNum)
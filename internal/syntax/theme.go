@@ -2,8 +2,10 @@ package syntax
 
 import (
 	"image/color"
+	"math"
 
 	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
 )
 
 // GetTokenColor returns the color for a given token type from the style.
@@ -110,3 +112,99 @@ func IsDarkTheme(style *chroma.Style) bool {
 	// If brightness is less than 128 (middle of 0-255), it's dark
 	return brightness < 128
 }
+
+// TerminalColors derives a terminal's 16 ANSI colors and its default
+// foreground and background from a theme, so terminals match the editor.
+// The six hues are the theme's token colors closest to them; where the
+// theme has no color near a hue, the one from fallback is used.
+func TerminalColors(themeName string, fallback [16]color.NRGBA) (ansi [16]color.NRGBA, fg, bg color.NRGBA) {
+	style := styles.Get(themeName)
+	bg = GetBackgroundColor(style)
+	dark := IsDarkTheme(style)
+	if text := style.Get(chroma.Text); text.Colour.IsSet() {
+		fg = chromaColorToNRGBA(text.Colour)
+	} else if dark {
+		fg = color.NRGBA{R: 0xdf, G: 0xe7, B: 0xff, A: 0xff}
+	} else {
+		fg = color.NRGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}
+	}
+
+	var candidates []color.NRGBA
+	for _, tokenType := range style.Types() {
+		if entry := style.Get(tokenType); entry.Colour.IsSet() {
+			candidates = append(candidates, chromaColorToNRGBA(entry.Colour))
+		}
+	}
+
+	// Red, green, yellow, blue, magenta and cyan
+	hues := []float64{0, 120, 60, 240, 300, 180}
+	for i, hue := range hues {
+		ansi[i+1] = fallback[i+1]
+		best := 45.0 // Farthest hue accepted
+		for _, c := range candidates {
+			h, s, v := toHSV(c)
+			if s < 0.35 || v < 0.3 {
+				// Grays and near-black colors have no meaningful hue
+				continue
+			}
+			if d := hueDistance(h, hue); d < best {
+				best = d
+				ansi[i+1] = c
+			}
+		}
+		ansi[i+9] = mixColors(ansi[i+1], color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, 0.2)
+	}
+
+	// Black and white are the darker and the lighter of the default colors
+	darkest, lightest := bg, fg
+	if !dark {
+		darkest, lightest = fg, bg
+	}
+	ansi[0] = mixColors(darkest, lightest, 0.1)
+	ansi[7] = mixColors(lightest, darkest, 0.1)
+	ansi[8] = mixColors(darkest, lightest, 0.4)
+	if comment := style.Get(chroma.Comment); comment.Colour.IsSet() {
+		ansi[8] = chromaColorToNRGBA(comment.Colour)
+	}
+	ansi[15] = lightest
+	return ansi, fg, bg
+}
+
+// toHSV returns the hue (in degrees), saturation and value of c.
+func toHSV(c color.NRGBA) (h, s, v float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	maxC := max(r, g, b)
+	minC := min(r, g, b)
+	v = maxC
+	if maxC == 0 || maxC == minC {
+		return 0, 0, v
+	}
+	s = (maxC - minC) / maxC
+	switch maxC {
+	case r:
+		h = (g - b) / (maxC - minC)
+	case g:
+		h = 2 + (b-r)/(maxC-minC)
+	default:
+		h = 4 + (r-g)/(maxC-minC)
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, s, v
+}
+
+// hueDistance returns the angle between two hues.
+func hueDistance(a, b float64) float64 {
+	d := math.Abs(a - b)
+	return math.Min(d, 360-d)
+}
+
+// mixColors blends a with the fraction t of b.
+func mixColors(a, b color.NRGBA, t float64) color.NRGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x)*(1-t) + float64(y)*t))
+	}
+	return color.NRGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 0xff}
+}
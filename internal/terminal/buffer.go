@@ -16,6 +16,19 @@ type Cell struct {
 	Underline bool        // Underline attribute
 	Blink     bool        // Blink attribute
 	Reverse   bool        // Reverse video
+	Link      string      // OSC 8 hyperlink target, empty when not a link
+	Wide      bool        // Double-width character; the next cell is its second half
+	Spacer    bool        // Second half of a double-width character, drawn by the cell before
+	Marks     string      // Combining characters drawn over Rune
+}
+
+// Grapheme returns the text drawn in the cell: its character followed by
+// any combining marks.
+func (c Cell) Grapheme() string {
+	if c.Marks == "" {
+		return string(c.Rune)
+	}
+	return string(c.Rune) + c.Marks
 }
 
 // Line represents a row of cells
@@ -24,14 +37,56 @@ type Line struct {
 	Dirty bool // Whether line needs redraw
 }
 
+// Text returns the characters of the line without trailing blanks.
+func (l Line) Text() string {
+	runes, _ := l.CellText()
+	return string(runes)
+}
+
+// CellText returns the characters of the line without trailing blanks and
+// for each of them the cell it is drawn in, followed by the cell after the
+// text. Combining marks share the cell of their base character, and the
+// second half of a wide character adds no character.
+func (l Line) CellText() ([]rune, []int) {
+	runes := make([]rune, 0, len(l.Cells))
+	cells := make([]int, 0, len(l.Cells)+1)
+	end, endCell := 0, 0
+	for x, c := range l.Cells {
+		if c.Spacer {
+			continue
+		}
+		r := c.Rune
+		if r == 0 {
+			r = ' '
+		}
+		runes = append(runes, r)
+		cells = append(cells, x)
+		for _, m := range c.Marks {
+			runes = append(runes, m)
+			cells = append(cells, x)
+		}
+		if r != ' ' || c.Marks != "" {
+			end, endCell = len(runes), x+1
+			if c.Wide {
+				endCell++
+			}
+		}
+	}
+	return runes[:end], append(cells[:end], endCell)
+}
+
 // ScreenBuffer represents the terminal screen
 type ScreenBuffer struct {
 	lines       []Line
+	history     *scrollback // Lines scrolled off the top of the screen
+	prompts     []Prompt    // Shell prompts, oldest first, see shell.go
 	width       int
 	height      int
 	cursorX     int
 	cursorY     int
 	cursorStyle CursorStyle
+	blank       Cell         // Empty cell in the palette's default colors
+	cursorColor color.NRGBA  // The palette's cursor color
 	mu          sync.RWMutex // Protects buffer
 }
 
@@ -47,10 +102,12 @@ const (
 // NewScreenBuffer creates a new screen buffer
 func NewScreenBuffer(width, height int) *ScreenBuffer {
 	sb := &ScreenBuffer{
-		width:  width,
-		height: height,
-		lines:  make([]Line, height),
+		width:   width,
+		height:  height,
+		lines:   make([]Line, height),
+		history: newScrollback(DefaultScrollbackLines),
 	}
+	sb.setColors(&DefaultPalette)
 
 	// Initialize all cells
 	for i := range sb.lines {
@@ -58,11 +115,7 @@ func NewScreenBuffer(width, height int) *ScreenBuffer {
 		sb.lines[i].Dirty = true
 		// Initialize cells with default colors
 		for j := range sb.lines[i].Cells {
-			sb.lines[i].Cells[j] = Cell{
-				Rune: ' ',
-				FG:   DefaultFG,
-				BG:   DefaultBG,
-			}
+			sb.lines[i].Cells[j] = sb.blank
 		}
 	}
 
@@ -92,6 +145,92 @@ func (sb *ScreenBuffer) GetLine(index int) Line {
 	return Line{Cells: cells, Dirty: line.Dirty}
 }
 
+// HistoryRange returns the absolute index of the oldest scrollback line and
+// of the first screen row. Scrollback lines keep their index as more output
+// arrives, so it can be used to anchor a view or a selection.
+func (sb *ScreenBuffer) HistoryRange() (first, screenTop int) {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+	return sb.history.first(), sb.history.end()
+}
+
+// ViewLine returns a copy of the line with absolute index abs, which is either
+// a scrollback line or, from the first screen row on, a screen row.
+func (sb *ScreenBuffer) ViewLine(abs int) Line {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+
+	if abs >= sb.history.end() {
+		y := abs - sb.history.end()
+		if y >= len(sb.lines) {
+			return Line{}
+		}
+		cells := make([]Cell, len(sb.lines[y].Cells))
+		copy(cells, sb.lines[y].Cells)
+		return Line{Cells: cells, Dirty: sb.lines[y].Dirty}
+	}
+
+	// Scrollback lines are never modified, so they can be shared
+	line, _ := sb.history.line(abs)
+	return line
+}
+
+// rowUpdate holds the new cells of a screen row.
+type rowUpdate struct {
+	y     int
+	cells []Cell
+}
+
+// applyUpdate appends scrolled-off lines to the scrollback, replaces the
+// changed rows and moves the cursor under a single lock, so readers never
+// see the scrollback and the screen out of step.
+func (sb *ScreenBuffer) applyUpdate(history []Line, rows []rowUpdate, cursorX, cursorY int) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	for i := range history {
+		history[i] = trimLine(history[i], sb.blank.BG)
+	}
+
+	for _, line := range history {
+		sb.history.push(line)
+	}
+	for _, row := range rows {
+		if row.y < 0 || row.y >= sb.height {
+			continue
+		}
+		// The emulator may not have been resized yet
+		line := &sb.lines[row.y]
+		n := copy(line.Cells, row.cells)
+		for x := n; x < len(line.Cells); x++ {
+			line.Cells[x] = sb.blank
+		}
+		line.Dirty = true
+	}
+	sb.setCursorLocked(cursorX, cursorY)
+}
+
+// SetHistoryLimit sets how many scrollback lines are kept.
+func (sb *ScreenBuffer) SetHistoryLimit(lines int) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.history.setLimit(lines)
+}
+
+// historyEnabled reports whether scrolled-off lines are kept.
+func (sb *ScreenBuffer) historyEnabled() bool {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+	return sb.history.limit > 0
+}
+
+// ClearHistory drops the scrollback.
+func (sb *ScreenBuffer) ClearHistory() {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.history.clear()
+}
+
 // GetCursor returns cursor position
 func (sb *ScreenBuffer) GetCursor() (x, y int, style CursorStyle) {
 	sb.mu.RLock()
@@ -103,7 +242,11 @@ func (sb *ScreenBuffer) GetCursor() (x, y int, style CursorStyle) {
 func (sb *ScreenBuffer) SetCursor(x, y int) {
 	sb.mu.Lock()
 	defer sb.mu.Unlock()
+	sb.setCursorLocked(x, y)
+}
 
+// setCursorLocked clamps and sets the cursor; sb.mu must be held.
+func (sb *ScreenBuffer) setCursorLocked(x, y int) {
 	if x < 0 {
 		x = 0
 	}
@@ -144,11 +287,7 @@ func (sb *ScreenBuffer) ClearLine(y int) {
 	}
 
 	for x := range sb.lines[y].Cells {
-		sb.lines[y].Cells[x] = Cell{
-			Rune: ' ',
-			FG:   DefaultFG,
-			BG:   DefaultBG,
-		}
+		sb.lines[y].Cells[x] = sb.blank
 	}
 	sb.lines[y].Dirty = true
 }
@@ -160,11 +299,7 @@ func (sb *ScreenBuffer) Clear() {
 
 	for y := range sb.lines {
 		for x := range sb.lines[y].Cells {
-			sb.lines[y].Cells[x] = Cell{
-				Rune: ' ',
-				FG:   DefaultFG,
-				BG:   DefaultBG,
-			}
+			sb.lines[y].Cells[x] = sb.blank
 		}
 		sb.lines[y].Dirty = true
 	}
@@ -206,11 +341,7 @@ func (sb *ScreenBuffer) Resize(width, height int) {
 		// Fill remaining cells with defaults
 		for j := range newLines[i].Cells {
 			if i >= len(sb.lines) || j >= len(sb.lines[i].Cells) {
-				newLines[i].Cells[j] = Cell{
-					Rune: ' ',
-					FG:   DefaultFG,
-					BG:   DefaultBG,
-				}
+				newLines[i].Cells[j] = sb.blank
 			}
 		}
 	}
@@ -1,6 +1,11 @@
 package terminal
 
-import "image/color"
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
 
 // ANSI 16-color palette
 var ansiColors = [16]color.NRGBA{
@@ -57,8 +62,94 @@ var (
 	DefaultBG = color.NRGBA{R: 0x1a, G: 0x1f, B: 0x2e, A: 0xff} // Match Vem bg
 )
 
-// vt10xColorToNRGBA converts vt10x.Color to color.NRGBA
-func vt10xColorToNRGBA(vtColor uint32) color.NRGBA {
+// Palette holds the colors a terminal draws with: the 16 ANSI colors that
+// programs pick by number, the default foreground and background, and the
+// cursor color. Colors 16-255 are always the standard cube and gray ramp.
+type Palette struct {
+	ANSI   [16]color.NRGBA
+	FG     color.NRGBA
+	BG     color.NRGBA
+	Cursor color.NRGBA
+}
+
+// DefaultPalette is the palette terminals start with.
+var DefaultPalette = Palette{
+	ANSI:   ansiColors,
+	FG:     DefaultFG,
+	BG:     DefaultBG,
+	Cursor: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+}
+
+// Palettes are the preset palettes by name.
+var Palettes = map[string]Palette{
+	"vem": DefaultPalette,
+	"xterm": {
+		ANSI: [16]color.NRGBA{
+			rgb(0x000000), rgb(0xcd0000), rgb(0x00cd00), rgb(0xcdcd00),
+			rgb(0x0000ee), rgb(0xcd00cd), rgb(0x00cdcd), rgb(0xe5e5e5),
+			rgb(0x7f7f7f), rgb(0xff0000), rgb(0x00ff00), rgb(0xffff00),
+			rgb(0x5c5cff), rgb(0xff00ff), rgb(0x00ffff), rgb(0xffffff),
+		},
+		FG:     rgb(0xe5e5e5),
+		BG:     rgb(0x000000),
+		Cursor: rgb(0xe5e5e5),
+	},
+	"solarized": {
+		ANSI: [16]color.NRGBA{
+			rgb(0x073642), rgb(0xdc322f), rgb(0x859900), rgb(0xb58900),
+			rgb(0x268bd2), rgb(0xd33682), rgb(0x2aa198), rgb(0xeee8d5),
+			rgb(0x002b36), rgb(0xcb4b16), rgb(0x586e75), rgb(0x657b83),
+			rgb(0x839496), rgb(0x6c71c4), rgb(0x93a1a1), rgb(0xfdf6e3),
+		},
+		FG:     rgb(0x839496),
+		BG:     rgb(0x002b36),
+		Cursor: rgb(0x93a1a1),
+	},
+	"dracula": {
+		ANSI: [16]color.NRGBA{
+			rgb(0x21222c), rgb(0xff5555), rgb(0x50fa7b), rgb(0xf1fa8c),
+			rgb(0xbd93f9), rgb(0xff79c6), rgb(0x8be9fd), rgb(0xf8f8f2),
+			rgb(0x6272a4), rgb(0xff6e6e), rgb(0x69ff94), rgb(0xffffa5),
+			rgb(0xd6acff), rgb(0xff92df), rgb(0xa4ffff), rgb(0xffffff),
+		},
+		FG:     rgb(0xf8f8f2),
+		BG:     rgb(0x282a36),
+		Cursor: rgb(0xf8f8f2),
+	},
+}
+
+// rgb returns the opaque color 0xRRGGBB.
+func rgb(v uint32) color.NRGBA {
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
+
+// colorTable is a terminal's palette expanded to all 256 indexed colors,
+// which programs can change one by one with OSC 4.
+type colorTable struct {
+	indexed        [256]color.NRGBA
+	fg, bg, cursor color.NRGBA
+}
+
+// newColorTable expands a palette.
+func newColorTable(p *Palette) colorTable {
+	var c colorTable
+	for i := range c.indexed {
+		c.indexed[i] = GetANSIColor(i)
+	}
+	copy(c.indexed[:16], p.ANSI[:])
+	c.fg, c.bg, c.cursor = p.FG, p.BG, p.Cursor
+	return c
+}
+
+// palette returns the colors the screen buffer needs.
+func (c *colorTable) palette() Palette {
+	p := Palette{FG: c.fg, BG: c.bg, Cursor: c.cursor}
+	copy(p.ANSI[:], c.indexed[:16])
+	return p
+}
+
+// convert converts a vt10x.Color to color.NRGBA
+func (c *colorTable) convert(vtColor uint32) color.NRGBA {
 	// vt10x uses special values for default colors
 	const (
 		DefaultFGValue = 1 << 24       // DefaultFG in vt10x
@@ -67,15 +158,15 @@ func vt10xColorToNRGBA(vtColor uint32) color.NRGBA {
 
 	// Check for default colors
 	if vtColor == DefaultFGValue {
-		return DefaultFG
+		return c.fg
 	}
 	if vtColor == DefaultBGValue {
-		return DefaultBG
+		return c.bg
 	}
 
-	// Check if it's a standard ANSI color (0-255)
+	// Check if it's an indexed color (0-255)
 	if vtColor < 256 {
-		return GetANSIColor(int(vtColor))
+		return c.indexed[vtColor]
 	}
 
 	// Check if it's a true color (RGB)
@@ -88,5 +179,171 @@ func vt10xColorToNRGBA(vtColor uint32) color.NRGBA {
 	}
 
 	// Fallback to default
-	return DefaultFG
+	return c.fg
+}
+
+// SetPalette changes the terminal's colors, dropping the changes programs
+// made with OSC 4/10/11/12. The screen is redrawn in the new colors;
+// scrollback lines keep theirs.
+func (t *Terminal) SetPalette(p Palette) {
+	t.feedMu.Lock()
+	t.palette = p
+	t.colors = newColorTable(&p)
+	t.snapshot = nil // Convert every row again
+	t.feedMu.Unlock()
+
+	t.screen.setColors(&p)
+	t.updateScreenFromVT10x()
+}
+
+// Palette returns the colors the terminal currently draws with.
+func (t *Terminal) Palette() Palette {
+	t.feedMu.Lock()
+	defer t.feedMu.Unlock()
+	return t.colors.palette()
+}
+
+// setColors sets the colors of blank cells and the cursor.
+func (sb *ScreenBuffer) setColors(p *Palette) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.blank = Cell{Rune: ' ', FG: p.FG, BG: p.BG}
+	sb.cursorColor = p.Cursor
+}
+
+// Colors returns the default foreground and background of the screen and
+// the cursor color.
+func (sb *ScreenBuffer) Colors() (fg, bg, cursor color.NRGBA) {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+	return sb.blank.FG, sb.blank.BG, sb.cursorColor
+}
+
+// Programs query and change the palette with OSC 4 (indexed colors), 10
+// (foreground), 11 (background) and 12 (cursor), and reset it with OSC 104,
+// 110, 111 and 112. A query ("?" for the color) is answered with the color
+// in xterm's "rgb:rrrr/gggg/bbbb" form.
+var (
+	oscIndexedColor = []byte("\x1b]4;")
+	oscDynamicColor = [][]byte{[]byte("\x1b]10;"), []byte("\x1b]11;"), []byte("\x1b]12;")}
+	oscResetIndexed = []byte("\x1b]104")
+	oscResetDynamic = [][]byte{[]byte("\x1b]110"), []byte("\x1b]111"), []byte("\x1b]112")}
+)
+
+// colorOSC lists the palette sequences for interceptedOSC.
+func colorOSC() [][]byte {
+	prefixes := [][]byte{oscIndexedColor, oscResetIndexed}
+	prefixes = append(prefixes, oscDynamicColor...)
+	return append(prefixes, oscResetDynamic...)
+}
+
+// handleIndexedColor handles the "index;color" pairs of OSC 4. st ends the
+// replies to queries. Called with feedMu held.
+func (t *Terminal) handleIndexedColor(params, st string) {
+	fields := strings.Split(params, ";")
+	for i := 0; i+1 < len(fields); i += 2 {
+		index, err := strconv.Atoi(fields[i])
+		if err != nil || index < 0 || index > 255 {
+			continue
+		}
+		if fields[i+1] == "?" {
+			t.replyColor(fmt.Sprintf("4;%d", index), t.colors.indexed[index], st)
+		} else if c, ok := parseColorSpec(fields[i+1]); ok {
+			t.colors.indexed[index] = c
+			t.colorsChanged()
+		}
+	}
+}
+
+// handleDynamicColor handles OSC 10, 11 and 12. Further colors set the
+// following dynamic colors, as in xterm. Called with feedMu held.
+func (t *Terminal) handleDynamicColor(first int, params, st string) {
+	targets := []*color.NRGBA{&t.colors.fg, &t.colors.bg, &t.colors.cursor}
+	for i, spec := range strings.Split(params, ";") {
+		n := first + i
+		if n >= len(targets) {
+			break
+		}
+		if spec == "?" {
+			t.replyColor(strconv.Itoa(10+n), *targets[n], st)
+		} else if c, ok := parseColorSpec(spec); ok {
+			*targets[n] = c
+			t.colorsChanged()
+		}
+	}
+}
+
+// handleResetIndexed handles OSC 104, which resets the listed indexed
+// colors or, without a list, all of them. Called with feedMu held.
+func (t *Terminal) handleResetIndexed(params string) {
+	initial := newColorTable(&t.palette)
+	params = strings.TrimPrefix(params, ";")
+	if params == "" {
+		t.colors.indexed = initial.indexed
+	}
+	for _, field := range strings.Split(params, ";") {
+		if index, err := strconv.Atoi(field); err == nil && index >= 0 && index <= 255 {
+			t.colors.indexed[index] = initial.indexed[index]
+		}
+	}
+	t.colorsChanged()
+}
+
+// handleResetDynamic handles OSC 110, 111 and 112. Called with feedMu held.
+func (t *Terminal) handleResetDynamic(n int) {
+	switch n {
+	case 0:
+		t.colors.fg = t.palette.FG
+	case 1:
+		t.colors.bg = t.palette.BG
+	case 2:
+		t.colors.cursor = t.palette.Cursor
+	}
+	t.colorsChanged()
+}
+
+// colorsChanged redraws the screen after a program changed the palette.
+// Called with feedMu held.
+func (t *Terminal) colorsChanged() {
+	t.snapshot = nil
+	p := t.colors.palette()
+	t.screen.setColors(&p)
+}
+
+// replyColor answers a color query.
+func (t *Terminal) replyColor(code string, c color.NRGBA, st string) {
+	reply := fmt.Sprintf("\x1b]%s;rgb:%04x/%04x/%04x%s", code, uint16(c.R)*0x101, uint16(c.G)*0x101, uint16(c.B)*0x101, st)
+	// Write errors show up in the read loop
+	_ = t.Write([]byte(reply))
+}
+
+// parseColorSpec parses an X11 color specification as programs send it:
+// "rgb:r/g/b" with one to four hex digits per component, or "#rgb",
+// "#rrggbb" and "#rrrrggggbbbb".
+func parseColorSpec(spec string) (color.NRGBA, bool) {
+	var parts []string
+	if rest, ok := strings.CutPrefix(spec, "rgb:"); ok {
+		parts = strings.Split(rest, "/")
+	} else if rest, ok := strings.CutPrefix(spec, "#"); ok && len(rest)%3 == 0 && len(rest) > 0 && len(rest) <= 12 {
+		n := len(rest) / 3
+		parts = []string{rest[:n], rest[n : 2*n], rest[2*n:]}
+	}
+	if len(parts) != 3 {
+		return color.NRGBA{}, false
+	}
+
+	var components [3]uint8
+	for i, part := range parts {
+		if len(part) < 1 || len(part) > 4 {
+			return color.NRGBA{}, false
+		}
+		v, err := strconv.ParseUint(part, 16, 16)
+		if err != nil {
+			return color.NRGBA{}, false
+		}
+		// Scale to 8 bits: "f" is 0xff, "ffff" is 0xff
+		maxValue := uint64(1)<<(4*len(part)) - 1
+		components[i] = uint8(v * 0xff / maxValue)
+	}
+	return color.NRGBA{R: components[0], G: components[1], B: components[2], A: 0xff}, true
 }
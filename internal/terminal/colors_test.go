@@ -0,0 +1,71 @@
+package terminal
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColorSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want color.NRGBA
+		ok   bool
+	}{
+		{"rgb:ff/80/00", rgb(0xff8000), true},
+		{"rgb:ffff/0000/8080", rgb(0xff0080), true},
+		{"rgb:f/0/8", rgb(0xff0088), true},
+		{"#102030", rgb(0x102030), true},
+		{"#fff", rgb(0xffffff), true},
+		{"rgb:ff/80", color.NRGBA{}, false},
+		{"red", color.NRGBA{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseColorSpec(tt.spec)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseColorSpec(%q) got %v, %v want %v, %v", tt.spec, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFeedPaletteChanges(t *testing.T) {
+	term := newTestTerminal(t, 10, 2)
+	term.mu.Lock()
+	term.running = true // Let replies reach inputChan
+	term.mu.Unlock()
+
+	term.feed([]byte("\x1b]4;1;#102030\x1b\\\x1b]11;rgb:00/00/00\x07\x1b[31mX"))
+	term.updateScreenFromVT10x()
+	cell := term.GetScreen().GetLine(0).Cells[0]
+	if cell.FG != rgb(0x102030) || cell.BG != rgb(0x000000) {
+		t.Fatalf("cell colors got %v on %v", cell.FG, cell.BG)
+	}
+	if _, bg, _ := term.GetScreen().Colors(); bg != rgb(0x000000) {
+		t.Fatalf("screen background got %v", bg)
+	}
+
+	// Queries are answered with the terminator they came with
+	term.feed([]byte("\x1b]4;1;?\x1b\\\x1b]10;?\x07"))
+	for _, want := range []string{
+		"\x1b]4;1;rgb:1010/2020/3030\x1b\\",
+		"\x1b]10;rgb:d3d3/d7d7/cfcf\x07",
+	} {
+		if got := string(<-term.inputChan); got != want {
+			t.Fatalf("reply got %q want %q", got, want)
+		}
+	}
+
+	term.feed([]byte("\x1b]104\x07\x1b]111\x07"))
+	term.updateScreenFromVT10x()
+	cell = term.GetScreen().GetLine(0).Cells[0]
+	if cell.FG != DefaultPalette.ANSI[1] || cell.BG != DefaultBG {
+		t.Fatalf("cell colors after reset got %v on %v", cell.FG, cell.BG)
+	}
+
+	// A new palette replaces the changes programs made
+	term.feed([]byte("\x1b]4;1;#102030\x07"))
+	term.SetPalette(Palettes["dracula"])
+	cell = term.GetScreen().GetLine(0).Cells[0]
+	if cell.FG != Palettes["dracula"].ANSI[1] || cell.BG != Palettes["dracula"].BG {
+		t.Fatalf("cell colors after SetPalette got %v on %v", cell.FG, cell.BG)
+	}
+}
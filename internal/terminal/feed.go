@@ -0,0 +1,396 @@
+package terminal
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hinshun/vt10x"
+
+	"github.com/javanhut/vem/internal/textwidth"
+)
+
+// cursorWrapNext mirrors vt10x's unexported cursor state bit that is set when
+// the next printed character wraps to a new line.
+const cursorWrapNext = 1 << 1
+
+// feed parses PTY output with the emulator. vt10x discards lines that
+// scroll off the top of the screen, so output is fed in pieces that scroll
+// at most once, and the rows scrolled off are queued for the scrollback
+// whenever a piece scrolled the main screen. The queued lines reach the
+// screen buffer with the next screen update. OSC 8 hyperlinks and the shell
+// integration sequences are taken out of the output, see links.go and
+// shell.go, and characters that don't take one column are fed on their own,
+// see width.go.
+func (t *Terminal) feed(data []byte) {
+	t.feedMu.Lock()
+	defer t.feedMu.Unlock()
+
+	t.trackPrivateModes(data)
+	if len(t.partial) > 0 {
+		data = append(t.partial, data...)
+		t.partial = nil
+	}
+
+	for len(data) > 0 {
+		text := data
+		if i := oscStart(data); i >= 0 {
+			text = data[:i]
+		}
+		for len(text) > 0 {
+			n := t.feedPiece(text)
+			if n == 0 {
+				// Only an incomplete UTF-8 sequence is left; finish it with
+				// the next read.
+				t.partial = append([]byte(nil), data...)
+				return
+			}
+			text = text[n:]
+			data = data[n:]
+		}
+
+		if len(data) > 0 {
+			n := t.parseOSC(data)
+			if n == 0 {
+				// Wait for the rest of the sequence
+				t.partial = append([]byte(nil), data...)
+				return
+			}
+			data = data[n:]
+		}
+	}
+}
+
+// interceptedOSC are the OSC sequences handled here rather than by vt10x.
+var interceptedOSC = append([][]byte{oscHyperlink, oscCwd, oscPrompt}, colorOSC()...)
+
+// maxOSCSequence bounds an unterminated OSC sequence kept while waiting for
+// the rest of it.
+const maxOSCSequence = 8 << 10
+
+// oscStart returns where the next intercepted OSC sequence starts in data,
+// including one cut off at the end of data, or -1.
+func oscStart(data []byte) int {
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], []byte("\x1b]"))
+		if i < 0 {
+			if bytes.HasSuffix(data, []byte{0x1b}) {
+				return len(data) - 1
+			}
+			return -1
+		}
+		start := offset + i
+		rest := data[start:]
+		for _, prefix := range interceptedOSC {
+			if bytes.HasPrefix(rest, prefix) || bytes.HasPrefix(prefix, rest) {
+				return start
+			}
+		}
+		offset = start + 2
+	}
+}
+
+// parseOSC handles the intercepted OSC sequence data starts with and
+// returns its length, or 0 when the sequence isn't complete yet.
+func (t *Terminal) parseOSC(data []byte) int {
+	var prefix []byte
+	for _, p := range interceptedOSC {
+		if bytes.HasPrefix(data, p) {
+			prefix = p
+		}
+	}
+	if prefix == nil {
+		// The prefix was cut off at the end of data; wait for the rest
+		return 0
+	}
+
+	end, size := -1, 0
+	for i := len(prefix); i < len(data); i++ {
+		if data[i] == '\a' {
+			end, size = i, 1
+			break
+		}
+		if data[i] == 0x1b {
+			if i+1 == len(data) {
+				break
+			}
+			end, size = i, 2
+			break
+		}
+	}
+	if end < 0 {
+		if len(data) > maxOSCSequence {
+			// Not a sequence worth waiting for; drop it like an unknown one
+			return len(data)
+		}
+		return 0
+	}
+
+	params := string(data[len(prefix):end])
+	st := string(data[end : end+size])
+	switch {
+	case bytes.Equal(prefix, oscHyperlink):
+		t.handleHyperlink(params)
+	case bytes.Equal(prefix, oscCwd):
+		t.handleCwd(params)
+	case bytes.Equal(prefix, oscPrompt):
+		t.handlePromptMark(params)
+	case bytes.Equal(prefix, oscIndexedColor):
+		t.handleIndexedColor(params, st)
+	case bytes.Equal(prefix, oscResetIndexed):
+		t.handleResetIndexed(params)
+	}
+	for n := range oscDynamicColor {
+		if bytes.Equal(prefix, oscDynamicColor[n]) {
+			t.handleDynamicColor(n, params, st)
+		}
+		if bytes.Equal(prefix, oscResetDynamic[n]) {
+			t.handleResetDynamic(n)
+		}
+	}
+	t.escState = escGround
+	return end + size
+}
+
+// feedPiece feeds the next piece of data and returns how many bytes were
+// consumed. A piece holds at most one line feed or other scrolling
+// sequence, see scrollAt, and fewer printable characters than it takes to
+// wrap twice. On the last row of the scroll region a line feed is fed on
+// its own, and so is a scrolling escape sequence anywhere.
+func (t *Terminal) feedPiece(data []byte) int {
+	t.vt.Lock()
+	cols, rows := t.vt.Size()
+	cur := t.vt.Cursor()
+	wrapNext := cur.State&cursorWrapNext != 0
+
+	// Limit the piece so it cannot wrap twice: every printable character
+	// takes at least one byte.
+	limit := cols - cur.X
+	if wrapNext {
+		limit = cols
+	}
+	// Like xterm, only lines scrolled out of a region starting at the top
+	// row are kept; under a fixed header they aren't what scrolled off.
+	top, bottom := t.margins.limits(cols, rows)
+	mainScreen := top == 0 && bottom > 0 && t.vt.Mode()&vt10x.ModeAltScreen == 0
+	atBottom := mainScreen && cur.Y == bottom
+	piece := data
+	if start, end := t.scrollAt(piece); start > 0 && (atBottom || piece[start] == 0x1b) {
+		// Feed the line feed or sequence on its own so the rows can be
+		// compared
+		piece = piece[:start]
+	} else if start >= 0 {
+		piece = piece[:end]
+	}
+	if len(piece) > max(limit, 1) {
+		piece = piece[:max(limit, 1)]
+	}
+	// Don't split a multi-byte character
+	for len(piece) < len(data) && !utf8.RuneStart(data[len(piece)]) {
+		piece = data[:len(piece)+1]
+	}
+
+	// While hyperlinks or marks are tracked, printed characters are fed one
+	// at a time so each cell can be marked. Characters that don't take one
+	// column are always fed on their own.
+	var printed rune = -1
+	width, wraps := 1, false
+	fed := piece
+	perChar := t.trackingCells()
+	if !perChar {
+		if n := t.plainPrefix(piece); n > 0 {
+			piece, fed = piece[:n], piece[:n]
+		} else {
+			perChar = true
+		}
+	}
+	if perChar {
+		if n := t.controlPrefix(piece); n > 0 {
+			piece = piece[:n]
+		} else if char, size := utf8.DecodeRune(piece); char == utf8.RuneError && size == 1 && utf8.FullRune(piece) {
+			// Invalid UTF-8, which vt10x skips
+			piece = piece[:min(len(piece), 2)]
+		} else {
+			piece = piece[:size]
+			printed = char
+			width = textwidth.Rune(char)
+		}
+		fed = piece
+		switch width {
+		case 0:
+			t.addMark(printed, cols, rows)
+			t.vt.Unlock()
+			return len(piece)
+		case 2:
+			fed, wraps = t.wideSequence(printed, cols)
+		}
+	}
+
+	// The main screen can only scroll by a line if the cursor is on the last
+	// row of the scroll region and the piece ends a line, with a line feed,
+	// IND or NEL or by wrapping. SU scrolls it wherever the cursor is.
+	lines := 0
+	if atBottom && (wrapNext || wraps || t.escLineFeed || bytes.ContainsAny(piece, "\n\v\f")) {
+		lines = 1
+	}
+	if mainScreen && t.escScrollUp > 0 {
+		lines = min(t.escScrollUp, bottom+1)
+	}
+	t.escLineFeed, t.escScrollUp = false, 0
+
+	var scrolled [][]vt10x.Glyph
+	if lines > 0 && (t.screen.historyEnabled() || t.linkCells > 0 || t.markCells > 0) {
+		if len(t.bottomRow) != cols {
+			t.topRows = nil
+			t.bottomRow = make([]vt10x.Glyph, cols)
+		}
+		for len(t.topRows) < lines {
+			t.topRows = append(t.topRows, make([]vt10x.Glyph, cols))
+		}
+		scrolled = t.topRows[:lines]
+		for x := 0; x < cols; x++ {
+			for y, row := range scrolled {
+				row[x] = t.vt.Cell(x, y)
+			}
+			t.bottomRow[x] = t.vt.Cell(x, bottom)
+		}
+	}
+	t.vt.Unlock()
+
+	n, _ := t.vt.Write(fed)
+	if len(fed) != len(piece) {
+		// A wide character is fed whole
+		n = len(piece)
+	}
+
+	if scrolled != nil && n > 0 && t.scrolledUp(t.bottomRow, rows, bottom, lines) {
+		for _, row := range scrolled {
+			links := t.scrollLinks(bottom)
+			marks := t.scrollMarks(bottom)
+			if t.screen.historyEnabled() {
+				line := Line{Cells: make([]Cell, len(row))}
+				for x, glyph := range row {
+					line.Cells[x] = glyphToCell(glyph, &t.colors)
+				}
+				applyLinks(line.Cells, links)
+				applyMarks(line.Cells, marks)
+				applyWidths(line.Cells)
+				t.history = append(t.history, line)
+			}
+		}
+	}
+	if printed >= 0 && n > 0 {
+		t.vt.Lock()
+		x, y := t.printedCell(width)
+		t.markLink(printed, x, y, cols, rows)
+		t.clearMarks(x, y)
+		if width == 2 {
+			t.markLink(wideSpacer, x+1, y, cols, rows)
+			t.clearMarks(x+1, y)
+		}
+		t.vt.Unlock()
+	}
+	return n
+}
+
+// scrolledUp reports whether the scroll region, whose last row is bottom,
+// scrolled by lines, i.e. the row that was at its bottom (bottomRow) is now
+// lines further up. A region scrolled out entirely is taken on trust.
+func (t *Terminal) scrolledUp(bottomRow []vt10x.Glyph, rows, bottom, lines int) bool {
+	t.vt.Lock()
+	defer t.vt.Unlock()
+
+	cols, newRows := t.vt.Size()
+	if newRows != rows || cols != len(bottomRow) {
+		return false
+	}
+	if lines > bottom {
+		return true
+	}
+	for x, glyph := range bottomRow {
+		moved := t.vt.Cell(x, bottom-lines)
+		// Wrapping sets the wrap flag on the last cell before scrolling
+		if moved.Char != glyph.Char || moved.FG != glyph.FG || moved.BG != glyph.BG ||
+			moved.Mode&^attrWrap != glyph.Mode&^attrWrap {
+			return false
+		}
+	}
+	return true
+}
+
+// maxCSIParams bounds the CSI parameters the escape sequence scanner keeps.
+const maxCSIParams = 32
+
+// scrollMargins is the scroll region set with DECSTBM, which vt10x keeps to
+// itself. vt10x resets it when the screen is resized.
+type scrollMargins struct {
+	top, bottom int // First and last row of the region
+	cols, rows  int // Screen size the region was set for
+}
+
+// limits returns the first and last row scrolled on a screen of the given
+// size.
+func (m scrollMargins) limits(cols, rows int) (top, bottom int) {
+	if m.cols != cols || m.rows != rows {
+		return 0, rows - 1
+	}
+	return m.top, m.bottom
+}
+
+// scrollAt returns where the first line feed, IND, NEL or SU in data
+// starts and ends, following escape sequences from the scanner's state, or
+// -1 for both. A sequence begun before data starts at 0.
+func (t *Terminal) scrollAt(data []byte) (start, end int) {
+	state, seqStart := t.escState, 0
+	for i, b := range data {
+		switch {
+		case b == '\n' || b == '\v' || b == '\f':
+			return i, i + 1
+		case state == escEscape && (b == 'D' || b == 'E'), state == escCSI && b == 'S':
+			return seqStart, i + 1
+		}
+		if b == 0x1b && state == escGround {
+			seqStart = i
+		}
+		state, _ = escapeNext(state, b)
+	}
+	return -1, -1
+}
+
+// endCSI notes the CSI sequences that scroll the screen or set its scroll
+// region, reading their parameters the way vt10x does. Called with the
+// vt10x lock held.
+func (t *Terminal) endCSI(final byte) {
+	if final != 'S' && final != 'r' {
+		return
+	}
+	params, private := strings.CutPrefix(string(t.escParams), "?")
+	var args []int
+	for _, param := range strings.Split(params, ";") {
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			break
+		}
+		args = append(args, n)
+	}
+	arg := func(i, def int) int {
+		if i < len(args) {
+			return args[i]
+		}
+		return def
+	}
+
+	switch {
+	case final == 'S':
+		t.escScrollUp = arg(0, 1)
+	case !private:
+		cols, rows := t.vt.Size()
+		top := min(max(arg(0, 1)-1, 0), rows-1)
+		bottom := min(max(arg(1, rows)-1, 0), rows-1)
+		if top > bottom {
+			top, bottom = bottom, top
+		}
+		t.margins = scrollMargins{top: top, bottom: bottom, cols: cols, rows: rows}
+	}
+}
@@ -1,137 +1,286 @@
 package terminal
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
+
 	"gioui.org/io/key"
+	"github.com/hinshun/vt10x"
 )
 
-// KeyToTerminalSequence converts Gio key event to terminal input
+// Keys are encoded the way xterm does: modified special keys carry the
+// modifiers as a parameter (Ctrl+Right is "CSI 1;5C"), Alt prefixes ESC and
+// the cursor keys follow DECCKM application cursor mode.
+
+// cursorKeys are the keys sent as "CSI <final>", or "SS3 <final>" in
+// application cursor mode.
+var cursorKeys = map[key.Name]byte{
+	key.NameUpArrow:    'A',
+	key.NameDownArrow:  'B',
+	key.NameRightArrow: 'C',
+	key.NameLeftArrow:  'D',
+	key.NameHome:       'H',
+	key.NameEnd:        'F',
+}
+
+// ss3Keys are the keys sent as "SS3 <final>" when unmodified.
+var ss3Keys = map[key.Name]byte{
+	key.NameF1: 'P',
+	key.NameF2: 'Q',
+	key.NameF3: 'R',
+	key.NameF4: 'S',
+}
+
+// tildeKeys are the keys sent as "CSI <code> ~".
+var tildeKeys = map[key.Name]int{
+	key.NameDeleteForward: 3,
+	key.NamePageUp:        5,
+	key.NamePageDown:      6,
+	key.NameF5:            15,
+	key.NameF6:            17,
+	key.NameF7:            18,
+	key.NameF8:            19,
+	key.NameF9:            20,
+	key.NameF10:           21,
+	key.NameF11:           23,
+	key.NameF12:           24,
+}
+
+// KeyToTerminalSequence converts Gio key event to terminal input, with the
+// cursor keys in normal mode. Use Terminal.KeySequence to follow the modes
+// the application in the terminal has set.
 func KeyToTerminalSequence(ev key.Event) string {
+	return encodeKey(ev, false)
+}
+
+// KeySequence converts a Gio key event to the input the terminal's
+// application expects.
+func (t *Terminal) KeySequence(ev key.Event) string {
+	return encodeKey(ev, t.vtMode()&vt10x.ModeAppCursor != 0)
+}
+
+// xtermModifier returns the xterm modifier parameter: 1 plus 1 for Shift,
+// 2 for Alt, 4 for Ctrl and 8 for Super.
+func xtermModifier(mods key.Modifiers) int {
+	m := 1
+	if mods.Contain(key.ModShift) {
+		m += 1
+	}
+	if mods.Contain(key.ModAlt) {
+		m += 2
+	}
+	if mods.Contain(key.ModCtrl) {
+		m += 4
+	}
+	if mods.Contain(key.ModSuper) {
+		m += 8
+	}
+	return m
+}
+
+// encodeKey converts a key event to terminal input. Plain characters come
+// through EditEvent instead and give "".
+func encodeKey(ev key.Event, appCursor bool) string {
+	mod := xtermModifier(ev.Modifiers)
+
 	// Special keys (VT100 escape sequences)
+	if final, ok := cursorKeys[ev.Name]; ok {
+		switch {
+		case mod > 1:
+			return fmt.Sprintf("\x1b[1;%d%c", mod, final)
+		case appCursor:
+			return "\x1bO" + string(final)
+		default:
+			return "\x1b[" + string(final)
+		}
+	}
+	if final, ok := ss3Keys[ev.Name]; ok {
+		if mod > 1 {
+			return fmt.Sprintf("\x1b[1;%d%c", mod, final)
+		}
+		return "\x1bO" + string(final)
+	}
+	if code, ok := tildeKeys[ev.Name]; ok {
+		if mod > 1 {
+			return fmt.Sprintf("\x1b[%d;%d~", code, mod)
+		}
+		return fmt.Sprintf("\x1b[%d~", code)
+	}
+
+	// Alt sends ESC before the key
+	alt := ""
+	if ev.Modifiers.Contain(key.ModAlt) {
+		alt = "\x1b"
+	}
+	ctrl := ev.Modifiers.Contain(key.ModCtrl)
+
 	switch ev.Name {
 	case key.NameReturn, key.NameEnter:
-		return "\r"
+		return alt + "\r"
 	case key.NameTab:
-		return "\t"
+		if ev.Modifiers.Contain(key.ModShift) {
+			return "\x1b[Z" // Back tab
+		}
+		return alt + "\t"
 	case key.NameDeleteBackward:
-		return "\x7f" // DEL (127)
-	case key.NameDeleteForward:
-		return "\x1b[3~" // Delete
-	case key.NameUpArrow:
-		return "\x1b[A"
-	case key.NameDownArrow:
-		return "\x1b[B"
-	case key.NameRightArrow:
-		return "\x1b[C"
-	case key.NameLeftArrow:
-		return "\x1b[D"
-	case key.NameHome:
-		return "\x1b[H"
-	case key.NameEnd:
-		return "\x1b[F"
-	case key.NamePageUp:
-		return "\x1b[5~"
-	case key.NamePageDown:
-		return "\x1b[6~"
+		if ctrl {
+			return alt + "\x08" // BS
+		}
+		return alt + "\x7f" // DEL (127)
 	case key.NameEscape:
-		return "\x1b"
+		return alt + "\x1b"
+	case key.NameSpace:
+		if ctrl {
+			return alt + "\x00" // Ctrl+Space = NUL
+		}
+		if alt != "" {
+			return alt + " "
+		}
+		return ""
 	}
 
-	// Function keys
-	switch ev.Name {
-	case key.NameF1:
-		return "\x1bOP"
-	case key.NameF2:
-		return "\x1bOQ"
-	case key.NameF3:
-		return "\x1bOR"
-	case key.NameF4:
-		return "\x1bOS"
-	case key.NameF5:
-		return "\x1b[15~"
-	case key.NameF6:
-		return "\x1b[17~"
-	case key.NameF7:
-		return "\x1b[18~"
-	case key.NameF8:
-		return "\x1b[19~"
-	case key.NameF9:
-		return "\x1b[20~"
-	case key.NameF10:
-		return "\x1b[21~"
-	case key.NameF11:
-		return "\x1b[23~"
-	case key.NameF12:
-		return "\x1b[24~"
-	}
-
-	// Shift+Arrow combinations
-	if ev.Modifiers.Contain(key.ModShift) {
-		switch ev.Name {
-		case key.NameUpArrow:
-			return "\x1b[1;2A"
-		case key.NameDownArrow:
-			return "\x1b[1;2B"
-		case key.NameRightArrow:
-			return "\x1b[1;2C"
-		case key.NameLeftArrow:
-			return "\x1b[1;2D"
+	if ctrl {
+		if code, ok := controlCode(ev.Name); ok {
+			return alt + code
 		}
 	}
 
-	// Ctrl+Arrow combinations
-	if ev.Modifiers.Contain(key.ModCtrl) {
-		switch ev.Name {
-		case key.NameUpArrow:
-			return "\x1b[1;5A"
-		case key.NameDownArrow:
-			return "\x1b[1;5B"
-		case key.NameRightArrow:
-			return "\x1b[1;5C"
-		case key.NameLeftArrow:
-			return "\x1b[1;5D"
+	// Alt+X sends ESC followed by X
+	if alt != "" && len([]rune(string(ev.Name))) == 1 {
+		char := string(ev.Name)
+		if !ev.Modifiers.Contain(key.ModShift) {
+			// Letter key names are upper case
+			char = strings.ToLower(char)
 		}
+		return alt + char
+	}
 
-		// Ctrl+Key combinations
-		if len(ev.Name) == 1 {
-			r := rune(ev.Name[0])
-			// Ctrl+A = 0x01, Ctrl+B = 0x02, ..., Ctrl+Z = 0x1A
-			if r >= 'a' && r <= 'z' {
-				return string(rune(r - 'a' + 1))
-			}
-			if r >= 'A' && r <= 'Z' {
-				return string(rune(r - 'A' + 1))
+	// Regular character input comes through EditEvent, not KeyEvent
+	return ""
+}
+
+// controlCode returns the control character Ctrl plus the key sends.
+func controlCode(name key.Name) (string, bool) {
+	if len(name) == 1 {
+		r := rune(name[0])
+		// Ctrl+A = 0x01, Ctrl+B = 0x02, ..., Ctrl+Z = 0x1A
+		if r >= 'a' && r <= 'z' {
+			return string(r - 'a' + 1), true
+		}
+		if r >= 'A' && r <= 'Z' {
+			return string(r - 'A' + 1), true
+		}
+	}
+
+	// Special Ctrl combinations
+	switch name {
+	case "2", "@":
+		return "\x00", true // Ctrl+2 or Ctrl+@ = NUL
+	case "3", "[":
+		return "\x1b", true // Ctrl+3 or Ctrl+[ = ESC
+	case "4", "\\":
+		return "\x1c", true // Ctrl+4 or Ctrl+\ = FS
+	case "5", "]":
+		return "\x1d", true // Ctrl+5 or Ctrl+] = GS
+	case "6", "^":
+		return "\x1e", true // Ctrl+6 or Ctrl+^ = RS
+	case "7", "_", "/", "-":
+		return "\x1f", true // Ctrl+7, Ctrl+_ or Ctrl+/ = US
+	case "8":
+		return "\x7f", true // Ctrl+8 = DEL
+	}
+	return "", false
+}
+
+// vtMode returns the emulator's mode flags.
+func (t *Terminal) vtMode() vt10x.ModeFlag {
+	t.vt.Lock()
+	defer t.vt.Unlock()
+	return t.vt.Mode()
+}
+
+// Bracketed paste (mode 2004) is not tracked by vt10x, so the output is
+// scanned for the private mode sequences that switch it.
+var privateModePrefix = []byte("\x1b[?")
+
+// maxPrivateModeSequence bounds a private mode sequence kept while waiting
+// for its end.
+const maxPrivateModeSequence = 32
+
+// trackPrivateModes updates the modes vt10x doesn't track from output.
+// Called with feedMu held.
+func (t *Terminal) trackPrivateModes(data []byte) {
+	if len(t.modeTail) > 0 {
+		data = append(t.modeTail, data...)
+		t.modeTail = nil
+	}
+
+	for {
+		i := bytes.Index(data, privateModePrefix)
+		if i < 0 {
+			// Keep a prefix cut off at the end
+			for k := len(privateModePrefix) - 1; k > 0; k-- {
+				if bytes.HasSuffix(data, privateModePrefix[:k]) {
+					t.modeTail = append([]byte(nil), data[len(data)-k:]...)
+					break
+				}
 			}
+			return
 		}
+		data = data[i+len(privateModePrefix):]
 
-		// Special Ctrl combinations
-		switch ev.Name {
-		case " ":
-			return "\x00" // Ctrl+Space = NUL
-		case "2", "@":
-			return "\x00" // Ctrl+2 or Ctrl+@ = NUL
-		case "3", "[":
-			return "\x1b" // Ctrl+3 or Ctrl+[ = ESC
-		case "4", "\\":
-			return "\x1c" // Ctrl+4 or Ctrl+\ = FS
-		case "5", "]":
-			return "\x1d" // Ctrl+5 or Ctrl+] = GS
-		case "6", "^":
-			return "\x1e" // Ctrl+6 or Ctrl+^ = RS
-		case "7", "_":
-			return "\x1f" // Ctrl+7 or Ctrl+_ = US
-		case "8":
-			return "\x7f" // Ctrl+8 = DEL
+		end := bytes.IndexFunc(data, func(r rune) bool { return r < '0' || r > ';' })
+		if end < 0 {
+			if len(data) < maxPrivateModeSequence {
+				t.modeTail = append(append([]byte(nil), privateModePrefix...), data...)
+			}
+			return
+		}
+		if data[end] == 'h' || data[end] == 'l' {
+			for _, param := range strings.Split(string(data[:end]), ";") {
+				if param == "2004" {
+					t.bracketedPaste.Store(data[end] == 'h')
+				}
+			}
 		}
+		data = data[end:]
 	}
+}
 
-	// Alt+Key combinations
-	if ev.Modifiers.Contain(key.ModAlt) {
-		if len(ev.Name) == 1 {
-			// Alt+X sends ESC followed by X
-			return "\x1b" + string(ev.Name)
-		}
+// Paste sends pasted text to the terminal. Line breaks are sent as carriage
+// returns like typed Enter keys, and the text is bracketed when the
+// application asked for bracketed paste so it isn't run line by line.
+func (t *Terminal) Paste(text string) error {
+	text = strings.ReplaceAll(text, "\r\n", "\r")
+	text = strings.ReplaceAll(text, "\n", "\r")
+	if t.bracketedPaste.Load() {
+		text = bracketPaste(text)
 	}
+	return t.Write([]byte(text))
+}
 
-	// Regular character input comes through EditEvent, not KeyEvent
-	return ""
+// bracketPaste wraps text in the bracketed paste markers. Every ESC is
+// taken out of the text, as xterm does, so nothing in it, however nested,
+// can end the paste early and have the rest run as typed.
+func bracketPaste(text string) string {
+	return "\x1b[200~" + strings.ReplaceAll(text, "\x1b", "") + "\x1b[201~"
+}
+
+// SetFocus tells the terminal whether it has the keyboard focus. The
+// application is sent a focus report (CSI I or CSI O) on changes if it
+// asked for them.
+func (t *Terminal) SetFocus(focused bool) {
+	if t.focused.Swap(focused) == focused {
+		return
+	}
+	if t.vtMode()&vt10x.ModeFocus == 0 {
+		return
+	}
+	report := "\x1b[O"
+	if focused {
+		report = "\x1b[I"
+	}
+	// Write errors show up in the read loop
+	_ = t.Write([]byte(report))
 }
@@ -0,0 +1,102 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+
+	"gioui.org/io/key"
+	"github.com/hinshun/vt10x"
+)
+
+func TestEncodeKey(t *testing.T) {
+	tests := []struct {
+		name      key.Name
+		mods      key.Modifiers
+		appCursor bool
+		want      string
+	}{
+		{key.NameUpArrow, 0, false, "\x1b[A"},
+		{key.NameUpArrow, 0, true, "\x1bOA"},
+		{key.NameRightArrow, key.ModCtrl, true, "\x1b[1;5C"},
+		{key.NameLeftArrow, key.ModShift | key.ModAlt, false, "\x1b[1;4D"},
+		{key.NameHome, 0, true, "\x1bOH"},
+		{key.NameF1, 0, false, "\x1bOP"},
+		{key.NameF1, key.ModShift, false, "\x1b[1;2P"},
+		{key.NameF5, key.ModCtrl, false, "\x1b[15;5~"},
+		{key.NameDeleteForward, 0, false, "\x1b[3~"},
+		{key.NameTab, key.ModShift, false, "\x1b[Z"},
+		{key.NameDeleteBackward, key.ModAlt, false, "\x1b\x7f"},
+		{key.NameDeleteBackward, key.ModCtrl, false, "\x08"},
+		{key.NameSpace, key.ModCtrl, false, "\x00"},
+		{"B", key.ModAlt, false, "\x1bb"},
+		{"B", key.ModAlt | key.ModShift, false, "\x1bB"},
+		{"C", key.ModCtrl, false, "\x03"},
+		{"C", key.ModCtrl | key.ModAlt, false, "\x1b\x03"},
+		{"A", 0, false, ""},
+	}
+	for _, tt := range tests {
+		got := encodeKey(key.Event{Name: tt.name, Modifiers: tt.mods}, tt.appCursor)
+		if got != tt.want {
+			t.Errorf("%q mods=%v app=%v: got %q want %q", tt.name, tt.mods, tt.appCursor, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeMouse(t *testing.T) {
+	sgr := vt10x.ModeMouseButton | vt10x.ModeMouseSgr
+	tests := []struct {
+		mode   vt10x.ModeFlag
+		action MouseAction
+		button MouseButton
+		mods   key.Modifiers
+		want   string
+	}{
+		{sgr, MousePress, MouseLeft, 0, "\x1b[<0;5;3M"},
+		{sgr, MouseRelease, MouseLeft, 0, "\x1b[<0;5;3m"},
+		{sgr, MousePress, MouseRight, key.ModCtrl, "\x1b[<18;5;3M"},
+		{sgr, MousePress, MouseWheelDown, 0, "\x1b[<65;5;3M"},
+		{sgr, MouseMotion, MouseLeft, 0, ""},
+		{vt10x.ModeMouseMotion | vt10x.ModeMouseSgr, MouseMotion, MouseLeft, 0, "\x1b[<32;5;3M"},
+		{vt10x.ModeMouseMotion, MouseMotion, MouseNone, 0, ""},
+		{vt10x.ModeMouseMany, MouseMotion, MouseNone, 0, "\x1b[M" + string([]byte{32 + 35, 33 + 4, 33 + 2})},
+		{vt10x.ModeMouseButton, MousePress, MouseMiddle, 0, "\x1b[M" + string([]byte{32 + 1, 33 + 4, 33 + 2})},
+		{vt10x.ModeMouseButton, MouseRelease, MouseMiddle, 0, "\x1b[M" + string([]byte{32 + 3, 33 + 4, 33 + 2})},
+		{vt10x.ModeMouseX10, MouseRelease, MouseLeft, 0, ""},
+		{0, MousePress, MouseLeft, 0, ""},
+	}
+	for i, tt := range tests {
+		got := encodeMouse(tt.mode, tt.action, tt.button, 4, 2, tt.mods)
+		if got != tt.want {
+			t.Errorf("case %d: got %q want %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestBracketPasteCannotEndEarly(t *testing.T) {
+	tests := []string{
+		"a\x1b[201~b",
+		"a\x1b[20\x1b[201~1~b",
+		"\x1b\x1b[201~[201~",
+	}
+	for _, text := range tests {
+		got := bracketPaste(text)
+		inner := strings.TrimSuffix(strings.TrimPrefix(got, "\x1b[200~"), "\x1b[201~")
+		if strings.Contains(inner, "\x1b") {
+			t.Errorf("bracketPaste(%q) got %q, which ends the paste early", text, got)
+		}
+	}
+}
+
+func TestTrackBracketedPaste(t *testing.T) {
+	term := newTestTerminal(t, 10, 2)
+	term.feed([]byte("$ \x1b[?20"))
+	term.feed([]byte("04h"))
+	if !term.bracketedPaste.Load() {
+		t.Fatalf("bracketed paste not enabled by a split sequence")
+	}
+	term.feed([]byte("\x1b[?1;2004l"))
+	if term.bracketedPaste.Load() {
+		t.Fatalf("bracketed paste not disabled")
+	}
+	assertScreen(t, term, "$", "")
+}
@@ -0,0 +1,359 @@
+package terminal
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// OSC 8 hyperlinks ("ESC ] 8 ; params ; URI ST") are handled here instead of
+// by vt10x, which ignores them; feed takes them out of the output. While a
+// link is open every printed cell is
+// recorded in a grid that follows the vt10x screen, and the link reaches the
+// screen buffer as Cell.Link. While the grid holds links, plain text is fed
+// a character at a time too so it can replace them. A grid cell only counts
+// while the screen still holds the character it was recorded for, so links
+// erased or moved by anything other than printing or a scroll drop out.
+
+// oscHyperlink starts an OSC 8 sequence.
+var oscHyperlink = []byte("\x1b]8;")
+
+// linkCell is one cell of the link grid.
+type linkCell struct {
+	uri  string
+	char rune // Character printed while the link was open
+}
+
+// handleHyperlink handles the parameters of an OSC 8 sequence.
+func (t *Terminal) handleHyperlink(params string) {
+	// The parameters (e.g. "id=...") come before the URI; an empty URI ends
+	// the link
+	_, uri, _ := strings.Cut(params, ";")
+	t.link = uri
+}
+
+// States of the escape sequence scanner used while tracking links
+const (
+	escGround    = iota // Printing text
+	escEscape           // After ESC
+	escCSI              // In a CSI sequence
+	escString           // In an OSC, DCS, APC or PM string
+	escStringEnd        // After ESC in a string
+	escCharset          // After a charset or test introducer
+)
+
+// controlPrefix returns how many bytes at the start of data are control
+// codes or parts of escape sequences, i.e. print nothing.
+func (t *Terminal) controlPrefix(data []byte) int {
+	for i, b := range data {
+		if t.escapeStep(b) {
+			return i
+		}
+	}
+	return len(data)
+}
+
+// escapeStep moves the escape sequence scanner past b, following the
+// states vt10x goes through, and reports whether b is part of a printed
+// character, which leaves the state as it is. Sequences that scroll the
+// screen or set its scroll region are noted for feedPiece, see feed.go.
+// Called with the vt10x lock held.
+func (t *Terminal) escapeStep(b byte) bool {
+	state := t.escState
+	next, printed := escapeNext(state, b)
+	switch {
+	case state == escEscape && b == '[':
+		t.escParams = t.escParams[:0]
+	case state == escEscape && (b == 'D' || b == 'E'):
+		t.escLineFeed = true
+	case state == escEscape && b == 'c':
+		t.margins = scrollMargins{}
+	case state == escCSI && next == escGround:
+		t.endCSI(b)
+	case state == escCSI && b >= ' ' && len(t.escParams) < maxCSIParams:
+		t.escParams = append(t.escParams, b)
+	}
+	t.escState = next
+	return printed
+}
+
+// escapeNext returns the scanner state after b and whether b is part of a
+// printed character.
+func escapeNext(state int, b byte) (int, bool) {
+	switch state {
+	case escGround:
+		if b == 0x1b {
+			return escEscape, false
+		}
+		return state, b >= ' ' && b != 0x7f
+	case escEscape:
+		switch b {
+		case '[':
+			return escCSI, false
+		case ']', 'P', '_', '^', 'k':
+			return escString, false
+		case '(', ')', '*', '+', '#':
+			return escCharset, false
+		}
+		return escGround, false
+	case escCSI:
+		if b >= 0x40 && b <= 0x7e {
+			return escGround, false
+		}
+	case escString:
+		if b == '\a' {
+			return escGround, false
+		} else if b == 0x1b {
+			return escStringEnd, false
+		}
+	case escStringEnd, escCharset:
+		return escGround, false
+	}
+	return state, false
+}
+
+// trackingCells reports whether printed text has to be fed a character at a
+// time to keep the link and mark grids up to date.
+func (t *Terminal) trackingCells() bool {
+	return t.link != "" || t.linkCells > 0 || t.markCells > 0
+}
+
+// markLink records the open link, or no link, for the character just
+// printed in a cell. Called with the vt10x lock held.
+func (t *Terminal) markLink(char rune, x, y, cols, rows int) {
+	if x < 0 || y < 0 || x >= cols || y >= rows {
+		return
+	}
+
+	if len(t.linkGrid) != rows || len(t.linkGrid[0]) != cols {
+		if t.link == "" {
+			return
+		}
+		// Links recorded before a resize no longer line up
+		t.linkGrid = make([][]linkCell, rows)
+		for i := range t.linkGrid {
+			t.linkGrid[i] = make([]linkCell, cols)
+		}
+		t.linkCells = 0
+	}
+	cell := &t.linkGrid[y][x]
+	if cell.uri == "" && t.link == "" {
+		return
+	}
+	switch {
+	case cell.uri == "":
+		t.linkCells++
+	case t.link == "":
+		t.linkCells--
+	}
+	*cell = linkCell{uri: t.link, char: char}
+
+	// Make the next screen update copy the row even if the glyph is unchanged
+	if y < len(t.snapshot) && x < len(t.snapshot[y]) {
+		t.snapshot[y][x].Char = -1
+	}
+}
+
+// scrollLinks moves the link grid rows down to bottom up with the screen
+// and returns the links of the row that scrolled off.
+func (t *Terminal) scrollLinks(bottom int) []linkCell {
+	if len(t.linkGrid) == 0 {
+		return nil
+	}
+	bottom = min(bottom, len(t.linkGrid)-1)
+	top := t.linkGrid[0]
+	copy(t.linkGrid[:bottom], t.linkGrid[1:bottom+1])
+	t.linkGrid[bottom] = make([]linkCell, len(top))
+	for _, cell := range top {
+		if cell.uri != "" {
+			t.linkCells--
+		}
+	}
+	return top
+}
+
+// cellLink returns the link of a screen cell showing char.
+func (t *Terminal) cellLink(x, y int, char rune) string {
+	if y >= len(t.linkGrid) || x >= len(t.linkGrid[y]) {
+		return ""
+	}
+	cell := &t.linkGrid[y][x]
+	if cell.uri == "" {
+		return ""
+	}
+	if cell.char != char {
+		*cell = linkCell{}
+		t.linkCells--
+		return ""
+	}
+	return cell.uri
+}
+
+// applyLinks sets Cell.Link on a converted row from the grid row links.
+func applyLinks(cells []Cell, links []linkCell) {
+	for x := range cells {
+		if x < len(links) && links[x].uri != "" && links[x].char == cells[x].Rune {
+			cells[x].Link = links[x].uri
+		}
+	}
+}
+
+// Link is a reference found in a terminal line: an OSC 8 hyperlink, a URL
+// or a file location such as "main.go:12:5".
+type Link struct {
+	Start int // First cell of the reference
+	End   int // Cell after the reference
+
+	URL  string // Web address, empty for file locations
+	Text string // What a hyperlink shows, which may differ from URL; empty for links found in the text
+	Path string // File path as written, possibly relative
+	Line int    // 1-based line in Path, 0 when not given
+	Col  int    // 1-based column in Path, 0 when not given
+}
+
+// Patterns for references in plain text
+var (
+	urlPattern      = regexp.MustCompile(`\b(?:https?|ftp|file)://[^\s<>"'` + "`" + `]+`)
+	locationPattern = regexp.MustCompile(`(?:[A-Za-z]:)?[\w.~+@/\\-]*[\w~+@-]:(\d+)(?::(\d+))?`)
+	pythonPattern   = regexp.MustCompile(`File "([^"]+)", line (\d+)`)
+)
+
+// FindLinks returns the references in a line ordered by their first cell.
+// Hyperlinks come first; URLs and file locations are only looked for in the
+// text outside them.
+func FindLinks(line Line) []Link {
+	var links []Link
+
+	// Runs of cells carrying the same hyperlink
+	for x := 0; x < len(line.Cells); {
+		uri := line.Cells[x].Link
+		end := x + 1
+		for end < len(line.Cells) && line.Cells[end].Link == uri {
+			end++
+		}
+		if uri != "" {
+			link := hyperlinkTarget(uri, x, end)
+			link.Text = cellsText(line.Cells[x:end])
+			links = append(links, link)
+		}
+		x = end
+	}
+
+	// cellOf maps byte offsets of the text to cells
+	var text strings.Builder
+	cellOf := make([]int, 0, len(line.Cells)+1)
+	for x, c := range line.Cells {
+		if c.Spacer {
+			continue
+		}
+		s := c.Grapheme()
+		if c.Rune == 0 || c.Link != "" {
+			// Hyperlink text isn't searched again
+			s = " "
+		}
+		text.WriteString(s)
+		for i := 0; i < len(s); i++ {
+			cellOf = append(cellOf, x)
+		}
+	}
+	cellOf = append(cellOf, len(line.Cells))
+	s := text.String()
+
+	taken := func(start, end int) bool {
+		for _, l := range links {
+			if start < l.End && l.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+	add := func(link Link) {
+		if !taken(link.Start, link.End) {
+			links = append(links, link)
+		}
+	}
+
+	for _, m := range urlPattern.FindAllStringIndex(s, -1) {
+		end := m[0] + len(trimURL(s[m[0]:m[1]]))
+		add(hyperlinkTarget(s[m[0]:end], cellOf[m[0]], cellOf[end]))
+	}
+	for _, m := range pythonPattern.FindAllStringSubmatchIndex(s, -1) {
+		lineNum, _ := strconv.Atoi(s[m[4]:m[5]])
+		add(Link{Start: cellOf[m[2]], End: cellOf[m[5]], Path: s[m[2]:m[3]], Line: lineNum})
+	}
+	for _, m := range locationPattern.FindAllStringSubmatchIndex(s, -1) {
+		path := s[m[0] : m[2]-1]
+		if !looksLikePath(path) {
+			continue
+		}
+		link := Link{Start: cellOf[m[0]], End: cellOf[m[1]], Path: path}
+		link.Line, _ = strconv.Atoi(s[m[2]:m[3]])
+		if m[4] >= 0 {
+			link.Col, _ = strconv.Atoi(s[m[4]:m[5]])
+		}
+		add(link)
+	}
+
+	sort.Slice(links, func(i, j int) bool { return links[i].Start < links[j].Start })
+	return links
+}
+
+// cellsText returns the text shown by cells.
+func cellsText(cells []Cell) string {
+	var sb strings.Builder
+	for _, c := range cells {
+		if !c.Spacer && c.Rune != 0 {
+			sb.WriteString(c.Grapheme())
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// hyperlinkTarget turns an OSC 8 URI into a link; file URIs become paths.
+func hyperlinkTarget(uri string, start, end int) Link {
+	link := Link{Start: start, End: end, URL: uri}
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" && u.Path != "" {
+		link.URL = ""
+		link.Path = u.Path
+	}
+	return link
+}
+
+// trimURL drops punctuation that ends the sentence around a URL rather than
+// the URL itself.
+func trimURL(s string) string {
+	for len(s) > 0 {
+		last := s[len(s)-1]
+		switch {
+		case strings.IndexByte(".,;:!?'\"", last) >= 0:
+		case last == ')' && strings.Count(s, "(") < strings.Count(s, ")"):
+		case last == ']' && strings.Count(s, "[") < strings.Count(s, "]"):
+		default:
+			return s
+		}
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// looksLikePath filters out location matches such as times or addresses:
+// a path has a directory or an extension and contains a letter.
+func looksLikePath(path string) bool {
+	if !strings.ContainsAny(path, `./\`) {
+		return false
+	}
+	return strings.IndexFunc(path, unicode.IsLetter) >= 0
+}
+
+// LinkAt returns the reference covering a cell of the line.
+func LinkAt(line Line, col int) (Link, bool) {
+	for _, link := range FindLinks(line) {
+		if col >= link.Start && col < link.End {
+			return link, true
+		}
+	}
+	return Link{}, false
+}
@@ -0,0 +1,60 @@
+package terminal
+
+import "testing"
+
+func TestFindLinks(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Link
+	}{
+		{"./main.go:12:5: undefined: x", []Link{{Start: 0, End: 14, Path: "./main.go", Line: 12, Col: 5}}},
+		{"\t/usr/lib/go/src/runtime/panic.go:770 +0x132", []Link{{Start: 1, End: 37, Path: "/usr/lib/go/src/runtime/panic.go", Line: 770}}},
+		{`  File "app/views.py", line 41, in index`, []Link{{Start: 8, End: 30, Path: "app/views.py", Line: 41}}},
+		{"see https://example.com/a_(b). Or http://x.org:8080/y.go:3", []Link{
+			{Start: 4, End: 29, URL: "https://example.com/a_(b)"},
+			{Start: 34, End: 58, URL: "http://x.org:8080/y.go:3"},
+		}},
+		{"at 12:30:45 on 127.0.0.1:8080", nil},
+	}
+	for _, tt := range tests {
+		got := FindLinks(textLine(tt.text))
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got %+v want %+v", tt.text, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: link %d got %+v want %+v", tt.text, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestFeedHyperlink(t *testing.T) {
+	term := newTestTerminal(t, 20, 2)
+	// The sequences arrive split across reads and end with ST and BEL
+	term.feed([]byte("see \x1b]8;;file:///src/ma"))
+	term.feed([]byte("in.go\x1b\\main\x1b]8;;\x07 now\r\n"))
+	term.feed([]byte("\x1b]8;id=1;https://example.com\x07"))
+	term.feed([]byte("docs\x1b]8;;\x1b"))
+	term.feed([]byte("\\\r\n"))
+	assertScreen(t, term, "docs", "")
+	assertHistory(t, term, "see main now")
+
+	first, _ := term.GetScreen().HistoryRange()
+	links := FindLinks(term.GetScreen().ViewLine(first))
+	if len(links) != 1 || links[0] != (Link{Start: 4, End: 8, Path: "/src/main.go", Text: "main"}) {
+		t.Fatalf("history links got %+v", links)
+	}
+	links = FindLinks(term.GetScreen().GetLine(0))
+	if len(links) != 1 || links[0] != (Link{Start: 0, End: 4, URL: "https://example.com", Text: "docs"}) {
+		t.Fatalf("screen links got %+v", links)
+	}
+
+	// Overwriting the text drops the link
+	term.feed([]byte("\x1b[Hhome"))
+	term.updateScreenFromVT10x()
+	if links := FindLinks(term.GetScreen().GetLine(0)); len(links) != 0 {
+		t.Fatalf("overwritten links got %+v", links)
+	}
+}
@@ -0,0 +1,106 @@
+package terminal
+
+import (
+	"fmt"
+
+	"gioui.org/io/key"
+	"github.com/hinshun/vt10x"
+)
+
+// MouseButton is a mouse button as reported to terminal applications.
+type MouseButton int
+
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+	MouseNone // Motion without a button pressed
+	MouseWheelUp
+	MouseWheelDown
+)
+
+// MouseAction is what happened to a mouse button.
+type MouseAction int
+
+const (
+	MousePress MouseAction = iota
+	MouseRelease
+	MouseMotion
+)
+
+// ReportsMouse reports whether the application in the terminal asked for
+// mouse events, in which case the editor should pass them on instead of
+// handling them itself.
+func (t *Terminal) ReportsMouse() bool {
+	return t.vtMode()&vt10x.ModeMouseMask != 0
+}
+
+// SendMouse reports a mouse event at a 0-based screen cell to the
+// application, as far as the mouse mode it set asks for such events.
+func (t *Terminal) SendMouse(action MouseAction, button MouseButton, col, row int, mods key.Modifiers) {
+	seq := encodeMouse(t.vtMode(), action, button, col, row, mods)
+	if seq != "" {
+		// Write errors show up in the read loop
+		_ = t.Write([]byte(seq))
+	}
+}
+
+// encodeMouse encodes a mouse event for the given mouse modes, or returns
+// "" when the modes don't report it. SGR encoding (mode 1006) is used when
+// set, the legacy "CSI M" encoding otherwise.
+func encodeMouse(mode vt10x.ModeFlag, action MouseAction, button MouseButton, col, row int, mods key.Modifiers) string {
+	switch {
+	case mode&vt10x.ModeMouseMask == 0:
+		return ""
+	case mode&vt10x.ModeMouseX10 != 0 && action != MousePress:
+		// X10 mode only reports presses
+		return ""
+	case action == MouseMotion && button == MouseNone && mode&vt10x.ModeMouseMany == 0:
+		return ""
+	case action == MouseMotion && mode&(vt10x.ModeMouseMotion|vt10x.ModeMouseMany) == 0:
+		return ""
+	case action == MouseRelease && (button == MouseWheelUp || button == MouseWheelDown):
+		return ""
+	}
+
+	var code int
+	switch button {
+	case MouseWheelUp:
+		code = 64
+	case MouseWheelDown:
+		code = 65
+	default:
+		code = int(button)
+	}
+	if action == MouseMotion {
+		code += 32
+	}
+	if mode&vt10x.ModeMouseX10 == 0 {
+		if mods.Contain(key.ModShift) {
+			code += 4
+		}
+		if mods.Contain(key.ModAlt) {
+			code += 8
+		}
+		if mods.Contain(key.ModCtrl) {
+			code += 16
+		}
+	}
+
+	if mode&vt10x.ModeMouseSgr != 0 {
+		final := 'M'
+		if action == MouseRelease {
+			final = 'm'
+		}
+		return fmt.Sprintf("\x1b[<%d;%d;%d%c", code, col+1, row+1, final)
+	}
+
+	// The legacy encoding has no release button, and positions end at 223
+	if action == MouseRelease {
+		code = code&^3 | 3
+	}
+	if col > 222 || row > 222 {
+		return ""
+	}
+	return string([]byte{0x1b, '[', 'M', byte(32 + code), byte(33 + col), byte(33 + row)})
+}
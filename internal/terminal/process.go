@@ -0,0 +1,103 @@
+package terminal
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// exitDrainTimeout bounds how long the exit status waits for the output the
+// process wrote before exiting.
+const exitDrainTimeout = 500 * time.Millisecond
+
+// maxCapturedOutput bounds the output kept for CapturedOutput.
+const maxCapturedOutput = 8 << 20
+
+// processExited records the exit status of the terminal's own process and,
+// once the remaining output has been read, prints it below the output.
+func (t *Terminal) processExited(code int) {
+	t.mu.Lock()
+	t.exitCode = code
+	t.exited = true
+	closed := !t.running
+	t.mu.Unlock()
+	if closed {
+		return
+	}
+
+	// Let the read loop drain the output written before the exit
+	select {
+	case <-t.readDone:
+	case <-time.After(exitDrainTimeout):
+	}
+
+	t.feed([]byte(fmt.Sprintf("\r\n[Process exited %d]", code)))
+	select {
+	case t.updateChan <- struct{}{}:
+	default:
+	}
+}
+
+// ExitCode returns the exit status of the terminal's process, and whether
+// it has exited. Sessions don't report one.
+func (t *Terminal) ExitCode() (code int, exited bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.exitCode, t.exited
+}
+
+// captureOutput keeps output for CapturedOutput when capturing is enabled.
+func (t *Terminal) captureOutput(data []byte) {
+	if !t.capture {
+		return
+	}
+	t.captureMu.Lock()
+	defer t.captureMu.Unlock()
+	if room := maxCapturedOutput - len(t.captured); room > 0 {
+		t.captured = append(t.captured, data[:min(len(data), room)]...)
+	}
+}
+
+// CapturedOutput returns the output of a terminal created with
+// Config.CaptureOutput as plain text, without escape sequences.
+func (t *Terminal) CapturedOutput() string {
+	t.captureMu.Lock()
+	defer t.captureMu.Unlock()
+	return stripEscapes(t.captured)
+}
+
+// stripEscapes removes escape sequences and carriage returns from terminal
+// output, leaving the printed text and line feeds.
+func stripEscapes(data []byte) string {
+	var out bytes.Buffer
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == 0x1b && i+1 < len(data):
+			i++
+			switch data[i] {
+			case '[':
+				// CSI: parameters up to a final byte in 0x40-0x7e
+				for i+1 < len(data) && (data[i+1] < 0x40 || data[i+1] > 0x7e) {
+					i++
+				}
+				i++
+			case ']', 'P', '_', '^':
+				// OSC and other strings end with BEL or ESC \
+				for i+1 < len(data) && data[i+1] != 0x07 && data[i+1] != 0x1b {
+					i++
+				}
+				i++
+				if i < len(data) && data[i] == 0x1b {
+					i++
+				}
+			case '(', ')', '*', '+', '#':
+				// Character set selection takes one more byte
+				i++
+			}
+		case c == '\n' || c == '\t' || c >= 0x20 && c != 0x7f:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
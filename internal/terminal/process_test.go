@@ -0,0 +1,49 @@
+//go:build unix
+
+package terminal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStripEscapes(t *testing.T) {
+	got := stripEscapes([]byte("\x1b[1;31mmain.go:3:1:\x1b[0m bad\r\n\x1b]0;title\x07ok\x1b(B\n"))
+	if want := "main.go:3:1: bad\nok\n"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestCommandExitStatus(t *testing.T) {
+	term, err := NewTerminal(Config{
+		Width:         40,
+		Height:        4,
+		Shell:         "/bin/sh",
+		Args:          CommandArgs(`printf 'a\033[31mb\033[0m\n'; exit 3`),
+		CaptureOutput: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := term.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer term.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.HasPrefix(term.GetScreen().GetLine(2).Text(), "[Process exited") {
+		if time.Now().After(deadline) {
+			t.Fatal("exit status not shown")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if code, exited := term.ExitCode(); !exited || code != 3 {
+		t.Fatalf("exit code got %d (%v) want 3", code, exited)
+	}
+	if got := term.CapturedOutput(); got != "ab\n" {
+		t.Fatalf("captured output got %q want %q", got, "ab\n")
+	}
+	assertScreen(t, term, "ab", "", "[Process exited 3]")
+}
@@ -58,7 +58,14 @@ func (t *Terminal) startPTY() error {
 
 	// Wait for process in goroutine
 	go func() {
-		cmd.Wait()
+		// A process that couldn't be waited for or was killed reports -1
+		code := -1
+		if err := cmd.Wait(); err == nil {
+			code = 0
+		} else if exitErr, ok := err.(*exec.ExitError); ok {
+			code = exitErr.ExitCode()
+		}
+		t.processExited(code)
 	}()
 
 	return nil
@@ -70,9 +77,10 @@ func (t *Terminal) Resize(width, height int) error {
 	t.width = width
 	t.height = height
 	ptyFile := t.pty
+	remote := t.conpty
 	t.mu.Unlock()
 
-	if ptyFile == nil {
+	if ptyFile == nil && remote == nil {
 		return fmt.Errorf("PTY not initialized")
 	}
 
@@ -86,6 +94,11 @@ func (t *Terminal) Resize(width, height int) error {
 		t.vt.Resize(width, height)
 	}
 
+	// Session PTYs are resized by the session daemon
+	if remote != nil {
+		return remote.Resize(width, height)
+	}
+
 	return pty.Setsize(ptyFile, &pty.Winsize{
 		Rows: uint16(height),
 		Cols: uint16(width),
@@ -104,3 +117,8 @@ func DefaultShell() string {
 func DefaultArgs() []string {
 	return []string{"-i"} // Interactive shell
 }
+
+// CommandArgs returns the shell args that run command and exit
+func CommandArgs(command string) []string {
+	return []string{"-c", command}
+}
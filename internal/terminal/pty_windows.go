@@ -37,7 +37,11 @@ func (t *Terminal) startPTY() error {
 
 	// Start wait goroutine
 	go func() {
-		_, _ = cpty.Wait(context.Background())
+		code := -1
+		if exitCode, err := cpty.Wait(context.Background()); err == nil {
+			code = int(exitCode)
+		}
+		t.processExited(code)
 
 		// Call onExit callback if set
 		if t.onExit != nil {
@@ -92,3 +96,11 @@ func DefaultShell() string {
 func DefaultArgs() []string {
 	return []string{} // No args needed
 }
+
+// CommandArgs returns the args that make DefaultShell run command and exit
+func CommandArgs(command string) []string {
+	if strings.HasSuffix(strings.ToLower(DefaultShell()), "cmd.exe") {
+		return []string{"/C", command}
+	}
+	return []string{"-NoLogo", "-Command", command}
+}
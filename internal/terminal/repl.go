@@ -0,0 +1,179 @@
+package terminal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// REPL is the kind of program source code is sent to. It decides how the
+// code is wrapped so the program runs it as a whole.
+type REPL int
+
+const (
+	REPLShell   REPL = iota // bash and other shells, and programs reading lines
+	REPLPython              // The standard Python interpreter
+	REPLIPython             // IPython, which always understands bracketed paste
+	REPLNode                // Node.js, fed through its .editor mode
+)
+
+// String returns the REPL's name.
+func (r REPL) String() string {
+	switch r {
+	case REPLPython:
+		return "python"
+	case REPLIPython:
+		return "ipython"
+	case REPLNode:
+		return "node"
+	default:
+		return "shell"
+	}
+}
+
+// ipythonPrompt matches IPython's input prompt
+var ipythonPrompt = regexp.MustCompile(`^In \[\d*\]: `)
+
+// nodeBanner starts the line Node.js greets with when its REPL starts.
+const nodeBanner = "Welcome to Node.js"
+
+// PromptREPL guesses the REPL from the prompt before the cursor, and
+// reports false when it isn't one it recognizes. Node's "> " is also the
+// shell's continuation prompt, so Node is only recognized while its banner
+// is still on the screen above the prompt.
+func (t *Terminal) PromptREPL() (REPL, bool) {
+	t.vt.Lock()
+	cur := t.vt.Cursor()
+	cols, _ := t.vt.Size()
+	row := func(y, width int) string {
+		var line []rune
+		for x := 0; x < width; x++ {
+			line = append(line, t.vt.Cell(x, y).Char)
+		}
+		return string(line)
+	}
+	prompt := row(cur.Y, cur.X)
+	node := false
+	if prompt == "> " {
+		for y := cur.Y - 1; y >= 0 && !node; y-- {
+			node = strings.HasPrefix(row(y, cols), nodeBanner)
+		}
+	}
+	t.vt.Unlock()
+
+	switch {
+	case ipythonPrompt.MatchString(prompt):
+		return REPLIPython, true
+	case strings.HasPrefix(prompt, ">>> "):
+		return REPLPython, true
+	case node:
+		return REPLNode, true
+	default:
+		return REPLShell, false
+	}
+}
+
+// Send sends source code to the program running in the terminal, wrapped
+// so repl runs it as a whole, and runs it.
+func (t *Terminal) Send(code string, repl REPL) error {
+	return t.Write([]byte(wrapForREPL(code, repl, t.bracketedPaste.Load())))
+}
+
+// wrapForREPL returns the input that runs code in repl. bracketed tells
+// whether the program asked for bracketed paste.
+func wrapForREPL(code string, repl REPL, bracketed bool) string {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(code, "\n"), "\n")
+
+	switch repl {
+	case REPLNode:
+		// Everything typed in .editor mode runs at once on Ctrl+D
+		return ".editor\r" + strings.Join(lines, "\r") + "\r\x04"
+	case REPLPython, REPLIPython:
+		lines = dedent(lines)
+		if repl == REPLIPython || bracketed {
+			// A block at the end needs an empty line to finish it
+			return bracketPaste(strings.Join(lines, "\r")) + "\r" + blockEnd(lines)
+		}
+		return strings.Join(pythonLines(lines), "\r") + "\r"
+	default:
+		if bracketed {
+			return bracketPaste(strings.Join(lines, "\r")) + "\r"
+		}
+		return strings.Join(lines, "\r") + "\r"
+	}
+}
+
+// blockEnd returns the extra Enter an indented last line needs.
+func blockEnd(lines []string) string {
+	if indented(lines[len(lines)-1]) {
+		return "\r"
+	}
+	return ""
+}
+
+// pythonLines prepares lines for the interactive interpreter, which reads
+// statements line by line: an empty line ends an indented block, so empty
+// lines inside blocks are dropped and one is added where a block ends.
+func pythonLines(lines []string) []string {
+	var out []string
+	inBlock := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if inBlock && !indented(line) && !continuesBlock(line) {
+			out = append(out, "")
+		}
+		out = append(out, line)
+		inBlock = indented(line)
+	}
+	if inBlock {
+		out = append(out, "")
+	}
+	return out
+}
+
+// continuesBlock reports whether a statement continues the compound
+// statement before it, like else after an if.
+func continuesBlock(line string) bool {
+	for _, keyword := range []string{"else", "elif", "except", "finally"} {
+		if rest, ok := strings.CutPrefix(line, keyword); ok && (rest == "" || strings.ContainsAny(rest[:1], " :")) {
+			return true
+		}
+	}
+	return false
+}
+
+// indented reports whether a line starts with whitespace.
+func indented(line string) bool {
+	return line != "" && (line[0] == ' ' || line[0] == '\t')
+}
+
+// dedent removes the indentation all non-empty lines share, so a block
+// taken from inside a function runs at the top level.
+func dedent(lines []string) []string {
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if prefix == "" {
+		return lines
+	}
+
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = strings.TrimPrefix(line, prefix)
+	}
+	return out
+}
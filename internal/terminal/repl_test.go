@@ -0,0 +1,54 @@
+package terminal
+
+import "testing"
+
+func TestWrapForREPL(t *testing.T) {
+	code := "    def f(x):\n\n        return x\n    print(f(1))\n"
+	tests := []struct {
+		name      string
+		code      string
+		repl      REPL
+		bracketed bool
+		want      string
+	}{
+		{"shell", "echo a\necho b\n", REPLShell, false, "echo a\recho b\r"},
+		{"shell bracketed", "echo a\necho b\n", REPLShell, true, "\x1b[200~echo a\recho b\x1b[201~\r"},
+		{"nested paste end", "echo \x1b[20\x1b[201~1~", REPLShell, true, "\x1b[200~echo [20[201~1~\x1b[201~\r"},
+		{"python", code, REPLPython, false, "def f(x):\r    return x\r\rprint(f(1))\r"},
+		{"python else", "if x:\n  a\nelse:\n  b", REPLPython, false, "if x:\r  a\relse:\r  b\r\r"},
+		{"python bracketed", "for i in x:\n    print(i)", REPLPython, true, "\x1b[200~for i in x:\r    print(i)\x1b[201~\r\r"},
+		{"ipython", code, REPLIPython, false, "\x1b[200~def f(x):\r\r    return x\rprint(f(1))\x1b[201~\r"},
+		{"node", "const a = 1\nconsole.log(a)\n", REPLNode, true, ".editor\rconst a = 1\rconsole.log(a)\r\x04"},
+	}
+	for _, tt := range tests {
+		if got := wrapForREPL(tt.code, tt.repl, tt.bracketed); got != tt.want {
+			t.Errorf("%s: got %q want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPromptREPL(t *testing.T) {
+	term := newTestTerminal(t, 20, 2)
+	if _, ok := term.PromptREPL(); ok {
+		t.Fatalf("empty screen recognized as a REPL")
+	}
+	term.feed([]byte("$ ipython\r\nIn [1]: "))
+	if repl, ok := term.PromptREPL(); !ok || repl != REPLIPython {
+		t.Fatalf("IPython prompt got %v, %v", repl, ok)
+	}
+	term.feed([]byte("\r\n>>> "))
+	if repl, ok := term.PromptREPL(); !ok || repl != REPLPython {
+		t.Fatalf("Python prompt got %v, %v", repl, ok)
+	}
+
+	// A shell's continuation prompt looks like Node's
+	shell := newTestTerminal(t, 30, 4)
+	shell.feed([]byte("$ echo 'a\r\n> "))
+	if repl, ok := shell.PromptREPL(); ok || repl != REPLShell {
+		t.Fatalf("shell continuation prompt got %v, %v", repl, ok)
+	}
+	shell.feed([]byte("'\r\na\r\n$ node\r\nWelcome to Node.js v22.0.0.\r\n> "))
+	if repl, ok := shell.PromptREPL(); !ok || repl != REPLNode {
+		t.Fatalf("Node prompt got %v, %v", repl, ok)
+	}
+}
@@ -0,0 +1,106 @@
+package terminal
+
+import "image/color"
+
+// DefaultScrollbackLines is the number of lines kept in a terminal's
+// scrollback when the configuration doesn't say otherwise.
+const DefaultScrollbackLines = 10000
+
+// scrollback is a bounded ring of lines that scrolled off the top of the
+// screen. Lines are addressed by absolute index: the first line ever pushed
+// is 0, and an index stays valid until the line is dropped from the ring.
+type scrollback struct {
+	lines   []Line
+	start   int // Ring position of the oldest line
+	count   int
+	limit   int
+	dropped int // Lines dropped from the front, i.e. absolute index of the oldest line
+}
+
+func newScrollback(limit int) *scrollback {
+	if limit < 0 {
+		limit = 0
+	}
+	return &scrollback{limit: limit}
+}
+
+// push appends a line, dropping the oldest one when the ring is full.
+func (r *scrollback) push(line Line) {
+	if r.limit == 0 {
+		r.dropped++
+		return
+	}
+	if r.count < r.limit {
+		if len(r.lines) < r.limit {
+			// Grow lazily so idle terminals don't allocate the whole ring
+			r.lines = append(r.lines, line)
+		} else {
+			r.lines[(r.start+r.count)%r.limit] = line
+		}
+		r.count++
+		return
+	}
+	r.lines[r.start] = line
+	r.start = (r.start + 1) % r.limit
+	r.dropped++
+}
+
+// first returns the absolute index of the oldest line.
+func (r *scrollback) first() int {
+	return r.dropped
+}
+
+// end returns the absolute index one past the newest line.
+func (r *scrollback) end() int {
+	return r.dropped + r.count
+}
+
+// line returns the line with absolute index abs.
+func (r *scrollback) line(abs int) (Line, bool) {
+	i := abs - r.dropped
+	if i < 0 || i >= r.count {
+		return Line{}, false
+	}
+	return r.lines[(r.start+i)%len(r.lines)], true
+}
+
+// setLimit changes the capacity, keeping the newest lines.
+func (r *scrollback) setLimit(limit int) {
+	if limit < 0 {
+		limit = 0
+	}
+	keep := min(r.count, limit)
+	lines := make([]Line, 0, keep)
+	for i := r.count - keep; i < r.count; i++ {
+		lines = append(lines, r.lines[(r.start+i)%len(r.lines)])
+	}
+	r.dropped += r.count - keep
+	r.lines = lines
+	r.start = 0
+	r.count = keep
+	r.limit = limit
+}
+
+// clear drops every line.
+func (r *scrollback) clear() {
+	r.dropped += r.count
+	r.lines = nil
+	r.start = 0
+	r.count = 0
+}
+
+// trimLine drops trailing blank cells on the default background bg, which
+// make up most of a typical line, before it is stored.
+func trimLine(line Line, bg color.NRGBA) Line {
+	end := len(line.Cells)
+	for end > 0 {
+		c := line.Cells[end-1]
+		if (c.Rune != ' ' && c.Rune != 0) || c.Spacer || c.BG != bg || c.Reverse || c.Underline {
+			break
+		}
+		end--
+	}
+	cells := make([]Cell, end)
+	copy(cells, line.Cells[:end])
+	return Line{Cells: cells}
+}
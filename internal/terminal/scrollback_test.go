@@ -0,0 +1,168 @@
+package terminal
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestScrollbackRing(t *testing.T) {
+	r := newScrollback(3)
+	for i := 0; i < 5; i++ {
+		r.push(textLine(strconv.Itoa(i)))
+	}
+	if r.first() != 2 || r.end() != 5 {
+		t.Fatalf("range got [%d,%d) want [2,5)", r.first(), r.end())
+	}
+	for abs := 2; abs < 5; abs++ {
+		line, ok := r.line(abs)
+		if !ok || line.Text() != strconv.Itoa(abs) {
+			t.Fatalf("line %d got %q (%v)", abs, line.Text(), ok)
+		}
+	}
+	if _, ok := r.line(1); ok {
+		t.Fatalf("dropped line should not be available")
+	}
+
+	r.setLimit(2)
+	if r.first() != 3 || r.end() != 5 {
+		t.Fatalf("after shrink got [%d,%d) want [3,5)", r.first(), r.end())
+	}
+	r.push(textLine("5"))
+	if line, _ := r.line(5); line.Text() != "5" || r.first() != 4 {
+		t.Fatalf("push after shrink got %q first=%d", line.Text(), r.first())
+	}
+}
+
+func TestFeedKeepsScrolledLines(t *testing.T) {
+	term := newTestTerminal(t, 10, 3)
+	term.feed([]byte("1\r\n2\r\n3\r\n4\r\n5"))
+
+	assertHistory(t, term, "1", "2")
+	assertScreen(t, term, "3", "4", "5")
+}
+
+func TestFeedKeepsWrappedLines(t *testing.T) {
+	term := newTestTerminal(t, 4, 2)
+	term.feed([]byte("abcdefghij"))
+
+	assertHistory(t, term, "abcd")
+	assertScreen(t, term, "efgh", "ij")
+}
+
+func TestFeedKeepsLinesScrolledBySequences(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  []string
+		history []string
+		screen  []string
+	}{
+		{"IND", []string{"1\r\n2\r\n3\x1bD"}, []string{"1"}, []string{"2", "3", ""}},
+		{"NEL", []string{"1\r\n2\r\n3\x1bE4"}, []string{"1"}, []string{"2", "3", "4"}},
+		{"SU", []string{"1\r\n2\r\n3\x1b[2S"}, []string{"1", "2"}, []string{"3", "", ""}},
+		{"SU above the bottom", []string{"1\r\n2\x1b[S"}, []string{"1"}, []string{"2", "", ""}},
+		{"SU split across reads", []string{"1\r\n2\r\n3\x1b[", "2S"}, []string{"1", "2"}, []string{"3", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			term := newTestTerminal(t, 10, 3)
+			for _, output := range tt.output {
+				term.feed([]byte(output))
+			}
+
+			assertHistory(t, term, tt.history...)
+			assertScreen(t, term, tt.screen...)
+		})
+	}
+}
+
+func TestFeedScrollRegion(t *testing.T) {
+	// Lines scrolling under a fixed header don't reach the scrollback
+	term := newTestTerminal(t, 10, 3)
+	term.feed([]byte("\x1b[2;3rH\r\n1\r\n2\r\n3"))
+	assertHistory(t, term)
+	assertScreen(t, term, "H", "2", "3")
+
+	// Above a fixed footer they do
+	term = newTestTerminal(t, 10, 4)
+	term.feed([]byte("\x1b[4;1HF\x1b[1;3r1\r\n2\r\n3\r\n4"))
+	assertHistory(t, term, "1")
+	assertScreen(t, term, "2", "3", "4", "F")
+
+	// Resetting the terminal ends the region
+	term = newTestTerminal(t, 10, 3)
+	term.feed([]byte("\x1b[2;3r\x1bc1\r\n2\r\n3\r\n4"))
+	assertHistory(t, term, "1")
+	assertScreen(t, term, "2", "3", "4")
+}
+
+func TestFeedSplitCharacter(t *testing.T) {
+	term := newTestTerminal(t, 10, 2)
+	term.feed([]byte("x\xc3"))
+	term.feed([]byte("\xa9y"))
+
+	assertScreen(t, term, "xéy", "")
+}
+
+func TestFeedSkipsAlternateScreen(t *testing.T) {
+	term := newTestTerminal(t, 10, 2)
+	term.feed([]byte("\x1b[?1049h"))
+	for i := 0; i < 5; i++ {
+		term.feed([]byte("line\r\n"))
+	}
+
+	assertHistory(t, term)
+}
+
+func newTestTerminal(t *testing.T, cols, rows int) *Terminal {
+	t.Helper()
+	term, err := NewTerminal(Config{Width: cols, Height: rows})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return term
+}
+
+func assertHistory(t *testing.T, term *Terminal, want ...string) {
+	t.Helper()
+	term.updateScreenFromVT10x()
+	first, screenTop := term.GetScreen().HistoryRange()
+	var got []string
+	for abs := first; abs < screenTop; abs++ {
+		got = append(got, term.GetScreen().ViewLine(abs).Text())
+	}
+	if !equalLines(got, want) {
+		t.Fatalf("history got %q want %q", got, want)
+	}
+}
+
+func assertScreen(t *testing.T, term *Terminal, want ...string) {
+	t.Helper()
+	term.updateScreenFromVT10x()
+	var got []string
+	for y := range want {
+		got = append(got, term.GetScreen().GetLine(y).Text())
+	}
+	if !equalLines(got, want) {
+		t.Fatalf("screen got %q want %q", got, want)
+	}
+}
+
+func textLine(text string) Line {
+	var line Line
+	for _, r := range text {
+		line.Cells = append(line.Cells, Cell{Rune: r, FG: DefaultFG, BG: DefaultBG})
+	}
+	return line
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
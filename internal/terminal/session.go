@@ -0,0 +1,318 @@
+package terminal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Terminal sessions are shells owned by a background session daemon
+// instead of by Vem, so they survive closing the editor. Vem attaches to a
+// session by name; the daemon replays the output it kept and then forwards
+// input and output until Vem detaches or the shell exits.
+//
+// The daemon listens on a Unix socket. Every message is a frame: a kind
+// byte, a big-endian uint32 payload length and the payload. A connection
+// starts with a frameRequest holding a JSON sessionRequest.
+
+// SessionDaemonFlag is the command line flag that runs the Vem executable as
+// the session daemon.
+const SessionDaemonFlag = "--session-daemon"
+
+// Frame kinds
+const (
+	frameRequest byte = 'q' // Client → daemon: JSON sessionRequest
+	frameInput   byte = 'i' // Client → daemon: bytes for the shell
+	frameResize  byte = 'r' // Client → daemon: cols and rows as two uint16
+	frameOutput  byte = 'o' // Daemon → client: shell output
+	frameExit    byte = 'x' // Daemon → client: the shell exited
+	frameList    byte = 'l' // Daemon → client: JSON []SessionInfo
+	frameOK      byte = 'k' // Daemon → client: request done
+	frameError   byte = 'e' // Daemon → client: error message
+)
+
+// maxFrameSize bounds frames read from the socket.
+const maxFrameSize = 16 << 20
+
+// errSessionsUnsupported is returned where the platform has no session
+// daemon.
+var errSessionsUnsupported = errors.New("terminal sessions are not supported on this platform")
+
+// errDaemonClosing is the daemon's reply to an attach that comes in after
+// its last session ended, while it is exiting.
+var errDaemonClosing = errors.New("session daemon is exiting")
+
+// Session request operations
+const (
+	sessionAttach = "attach"
+	sessionList   = "list"
+	sessionKill   = "kill"
+)
+
+// sessionRequest is the first frame of a connection.
+type sessionRequest struct {
+	Op    string   `json:"op"`
+	Name  string   `json:"name,omitempty"`
+	Cols  int      `json:"cols,omitempty"`
+	Rows  int      `json:"rows,omitempty"`
+	Shell string   `json:"shell,omitempty"`
+	Args  []string `json:"args,omitempty"`
+	Dir   string   `json:"dir,omitempty"`
+	Env   []string `json:"env,omitempty"`
+}
+
+// SessionInfo describes a running session.
+type SessionInfo struct {
+	Name     string    `json:"name"`
+	Started  time.Time `json:"started"`
+	Attached bool      `json:"attached"`
+}
+
+// writeFrame writes one frame.
+func writeFrame(w io.Writer, kind byte, payload []byte) error {
+	header := make([]byte, 5, 5+len(payload))
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+// readFrame reads one frame.
+func readFrame(r io.Reader) (kind byte, payload []byte, err error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("session frame too large: %d bytes", size)
+	}
+	payload = make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// encodeSize encodes a resize payload.
+func encodeSize(cols, rows int) []byte {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint16(payload, uint16(cols))
+	binary.BigEndian.PutUint16(payload[2:], uint16(rows))
+	return payload
+}
+
+// decodeSize decodes a resize payload.
+func decodeSize(payload []byte) (cols, rows int, ok bool) {
+	if len(payload) != 4 {
+		return 0, 0, false
+	}
+	return int(binary.BigEndian.Uint16(payload)), int(binary.BigEndian.Uint16(payload[2:])), true
+}
+
+// dialSessionDaemon connects to the session daemon, starting it first when
+// start is set and it isn't running.
+func dialSessionDaemon(start bool) (net.Conn, error) {
+	path, err := sessionSocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil && start {
+		if err := startSessionDaemon(); err != nil {
+			return nil, fmt.Errorf("failed to start session daemon: %w", err)
+		}
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			conn, err = net.Dial("unix", path)
+			if err == nil || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSessionPeer(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// sessionRoundTrip sends a request that isn't an attach and reads the reply.
+func sessionRoundTrip(req sessionRequest) (kind byte, payload []byte, err error) {
+	conn, err := dialSessionDaemon(false)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer conn.Close()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := writeFrame(conn, frameRequest, data); err != nil {
+		return 0, nil, err
+	}
+	return readFrame(conn)
+}
+
+// ListSessions returns the sessions of the session daemon. No daemon
+// running means there are no sessions.
+func ListSessions() ([]SessionInfo, error) {
+	kind, payload, err := sessionRoundTrip(sessionRequest{Op: sessionList})
+	if err != nil {
+		if errors.Is(err, errSessionsUnsupported) {
+			return nil, err
+		}
+		// Nothing is listening on the socket
+		return nil, nil
+	}
+	if kind == frameError {
+		return nil, errors.New(string(payload))
+	}
+
+	var sessions []SessionInfo
+	if err := json.Unmarshal(payload, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// KillSession ends the shell of a session.
+func KillSession(name string) error {
+	kind, payload, err := sessionRoundTrip(sessionRequest{Op: sessionKill, Name: name})
+	if err != nil {
+		if errors.Is(err, errSessionsUnsupported) {
+			return err
+		}
+		return fmt.Errorf("no session named %q", name)
+	}
+	if kind == frameError {
+		return errors.New(string(payload))
+	}
+	return nil
+}
+
+// sessionConn is a Terminal's connection to a session. It takes the place
+// of the PTY: reads return shell output and writes send input.
+type sessionConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	pending []byte // Output not yet returned by Read
+	onExit  func() // Called when the shell exits
+
+	writeMu sync.Mutex
+}
+
+// attachSession connects the terminal to its session, creating the session
+// when it doesn't exist yet. A daemon that is exiting refuses to start
+// sessions, so the attach is retried with a new daemon.
+func (t *Terminal) attachSession() error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if err = t.tryAttachSession(); !errors.Is(err, errDaemonClosing) {
+			return err
+		}
+	}
+	return err
+}
+
+// tryAttachSession makes one attempt of attachSession.
+func (t *Terminal) tryAttachSession() error {
+	conn, err := dialSessionDaemon(true)
+	if err != nil {
+		return err
+	}
+
+	req := sessionRequest{
+		Op:    sessionAttach,
+		Name:  t.session,
+		Cols:  t.width,
+		Rows:  t.height,
+		Shell: t.shell,
+		Args:  t.args,
+		Dir:   t.workingDir,
+		Env:   t.getEnvironment(),
+	}
+	data, err := json.Marshal(req)
+	if err == nil {
+		err = writeFrame(conn, frameRequest, data)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	sc := &sessionConn{conn: conn, reader: bufio.NewReader(conn), onExit: t.onExit}
+	kind, payload, err := readFrame(sc.reader)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if kind == frameError {
+		conn.Close()
+		if string(payload) == errDaemonClosing.Error() {
+			return errDaemonClosing
+		}
+		return errors.New(string(payload))
+	}
+
+	t.conpty = sc
+	return nil
+}
+
+// Read returns shell output. The replayed output of the session comes first.
+func (c *sessionConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		kind, payload, err := readFrame(c.reader)
+		if err != nil {
+			return 0, err
+		}
+		switch kind {
+		case frameOutput:
+			c.pending = payload
+		case frameExit:
+			if c.onExit != nil {
+				c.onExit()
+			}
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write sends input to the shell.
+func (c *sessionConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := writeFrame(c.conn, frameInput, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Resize resizes the session's PTY.
+func (c *sessionConn) Resize(width, height int) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return writeFrame(c.conn, frameResize, encodeSize(width, height))
+}
+
+// Close detaches from the session; the shell keeps running.
+func (c *sessionConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,320 @@
+package terminal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// sessionReplayBytes is how much recent output a session keeps for
+// replaying to the next client that attaches.
+const sessionReplayBytes = 2 << 20
+
+// clientWriteTimeout bounds how long a client that stopped reading can hold
+// up its session's output.
+const clientWriteTimeout = 5 * time.Second
+
+// sessionPTY is the shell side of a session.
+type sessionPTY interface {
+	io.ReadWriteCloser
+	Resize(cols, rows int) error
+	Kill() error
+}
+
+// sessionDaemon owns the PTYs of all sessions.
+type sessionDaemon struct {
+	mu       sync.Mutex
+	sessions map[string]*daemonSession
+	closing  bool // The last session ended, so no new ones start
+	listener net.Listener
+	spawn    func(req sessionRequest) (sessionPTY, error)
+	conns    sync.WaitGroup // Connections being served
+}
+
+// daemonSession is one shell kept alive by the daemon.
+type daemonSession struct {
+	name    string
+	started time.Time
+	pty     sessionPTY
+
+	mu     sync.Mutex // Protects replay and client
+	replay []byte     // Recent output, replayed on attach
+	client net.Conn   // Attached client, nil when detached
+}
+
+// RunSessionDaemon serves terminal sessions until the last one ends. It is
+// run by starting the Vem executable with SessionDaemonFlag.
+func RunSessionDaemon() error {
+	path, err := sessionSocketPath()
+	if err != nil {
+		return err
+	}
+
+	// A socket nobody answers on is left over from a daemon that died
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("session daemon already running on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// Closing the listener also removes the socket, before any client is
+	// told this daemon is exiting and starts the next one
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	d := &sessionDaemon{
+		sessions: make(map[string]*daemonSession),
+		listener: listener,
+		spawn:    spawnSessionShell,
+	}
+	err = d.serve()
+	// Clients accepted just before the listener closed get their answer
+	d.conns.Wait()
+	return err
+}
+
+// serve accepts clients until the listener is closed.
+func (d *sessionDaemon) serve() error {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		d.conns.Add(1)
+		go func() {
+			defer d.conns.Done()
+			d.handleConn(conn)
+		}()
+	}
+}
+
+// handleConn serves one client connection.
+func (d *sessionDaemon) handleConn(conn net.Conn) {
+	kind, payload, err := readFrame(conn)
+	if err != nil || kind != frameRequest {
+		conn.Close()
+		return
+	}
+	var req sessionRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		writeFrame(conn, frameError, []byte("invalid session request"))
+		conn.Close()
+		return
+	}
+
+	switch req.Op {
+	case sessionAttach:
+		// The connection stays open while attached
+		d.attach(conn, req)
+		return
+	case sessionList:
+		data, err := json.Marshal(d.list())
+		if err == nil {
+			writeFrame(conn, frameList, data)
+		}
+	case sessionKill:
+		if err := d.kill(req.Name); err != nil {
+			writeFrame(conn, frameError, []byte(err.Error()))
+		} else {
+			writeFrame(conn, frameOK, nil)
+		}
+	default:
+		writeFrame(conn, frameError, []byte(fmt.Sprintf("unknown session request %q", req.Op)))
+	}
+	conn.Close()
+}
+
+// list returns the running sessions sorted by name.
+func (d *sessionDaemon) list() []SessionInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	infos := make([]SessionInfo, 0, len(d.sessions))
+	for _, session := range d.sessions {
+		session.mu.Lock()
+		infos = append(infos, SessionInfo{
+			Name:     session.name,
+			Started:  session.started,
+			Attached: session.client != nil,
+		})
+		session.mu.Unlock()
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// kill ends a session's shell; the session goes away once its output ends.
+func (d *sessionDaemon) kill(name string) error {
+	d.mu.Lock()
+	session, exists := d.sessions[name]
+	d.mu.Unlock()
+	if !exists {
+		return fmt.Errorf("no session named %q", name)
+	}
+	return session.pty.Kill()
+}
+
+// attach connects a client to a session, starting the session first if
+// needed, and forwards its input until it detaches.
+func (d *sessionDaemon) attach(conn net.Conn, req sessionRequest) {
+	defer conn.Close()
+
+	session, err := d.session(req)
+	if err != nil {
+		writeFrame(conn, frameError, []byte(err.Error()))
+		return
+	}
+	if req.Cols > 0 && req.Rows > 0 {
+		// Resize errors only affect line wrapping
+		_ = session.pty.Resize(req.Cols, req.Rows)
+	}
+
+	// Take over from a previous client and replay the kept output
+	session.mu.Lock()
+	if session.client != nil {
+		session.client.Close()
+	}
+	session.client = conn
+	err = writeFrame(conn, frameOK, nil)
+	if err == nil && len(session.replay) > 0 {
+		err = writeFrame(conn, frameOutput, session.replay)
+	}
+	session.mu.Unlock()
+	if err != nil {
+		session.detach(conn)
+		return
+	}
+
+	for {
+		kind, payload, err := readFrame(conn)
+		if err != nil {
+			session.detach(conn)
+			return
+		}
+		switch kind {
+		case frameInput:
+			if _, err := session.pty.Write(payload); err != nil {
+				session.detach(conn)
+				return
+			}
+		case frameResize:
+			if cols, rows, ok := decodeSize(payload); ok {
+				_ = session.pty.Resize(cols, rows)
+			}
+		}
+	}
+}
+
+// session returns the named session, starting its shell if it doesn't
+// exist and the daemon isn't exiting.
+func (d *sessionDaemon) session(req sessionRequest) (*daemonSession, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if session, exists := d.sessions[req.Name]; exists {
+		return session, nil
+	}
+	if d.closing {
+		return nil, errDaemonClosing
+	}
+
+	pty, err := d.spawn(req)
+	if err != nil {
+		// A daemon whose first shell failed to start would otherwise
+		// wait forever with nothing to serve
+		d.closeIfIdle()
+		return nil, err
+	}
+	session := &daemonSession{name: req.Name, started: time.Now(), pty: pty}
+	d.sessions[req.Name] = session
+	go d.pump(session)
+	return session, nil
+}
+
+// pump forwards a session's output to its client until the shell exits,
+// then removes the session. The daemon exits with its last session.
+func (d *sessionDaemon) pump(session *daemonSession) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := session.pty.Read(buf)
+		if n > 0 {
+			session.output(buf[:n])
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	session.mu.Lock()
+	if session.client != nil {
+		writeFrame(session.client, frameExit, nil)
+		session.client.Close()
+		session.client = nil
+	}
+	session.mu.Unlock()
+	session.pty.Close()
+
+	// Deciding to exit under d.mu keeps session from starting a shell the
+	// exiting daemon would take down with it
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.sessions, session.name)
+	d.closeIfIdle()
+}
+
+// closeIfIdle starts the daemon exiting when it has no sessions left. The
+// caller holds d.mu.
+func (d *sessionDaemon) closeIfIdle() {
+	if len(d.sessions) == 0 {
+		d.closing = true
+		d.listener.Close()
+	}
+}
+
+// output keeps shell output for replaying and sends it to the client.
+func (s *daemonSession) output(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replay = append(s.replay, data...)
+	if len(s.replay) > sessionReplayBytes {
+		// Drop the oldest output, cutting at a line start where possible
+		cut := len(s.replay) - sessionReplayBytes
+		if nl := bytes.IndexByte(s.replay[cut:], '\n'); nl >= 0 {
+			cut += nl + 1
+		}
+		s.replay = append([]byte(nil), s.replay[cut:]...)
+	}
+
+	if s.client != nil {
+		s.client.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+		if err := writeFrame(s.client, frameOutput, data); err != nil {
+			s.client.Close()
+			s.client = nil
+		}
+	}
+}
+
+// detach forgets conn if it is still the session's client.
+func (s *daemonSession) detach(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == conn {
+		s.client = nil
+	}
+}
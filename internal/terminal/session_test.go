@@ -0,0 +1,228 @@
+//go:build unix
+
+package terminal
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go writeFrame(client, frameResize, encodeSize(120, 40))
+	kind, payload, err := readFrame(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, rows, ok := decodeSize(payload)
+	if kind != frameResize || !ok || cols != 120 || rows != 40 {
+		t.Fatalf("got kind %q size %dx%d (%v)", kind, cols, rows, ok)
+	}
+}
+
+func TestSessionSocketDirMustBePrivate(t *testing.T) {
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	dir := filepath.Join(runtimeDir, "vem")
+
+	if _, err := sessionSocketPath(); err != nil {
+		t.Fatalf("new directory: %v", err)
+	}
+	if err := os.Chmod(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessionSocketPath(); err == nil {
+		t.Fatal("accepted a directory others can read")
+	}
+
+	// A symlink to a private directory is refused too
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(t.TempDir(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessionSocketPath(); err == nil {
+		t.Fatal("accepted a symlink")
+	}
+}
+
+func TestSessionSurvivesDetach(t *testing.T) {
+	startTestDaemon(t)
+
+	first := attachTestSession(t, "work")
+	if _, err := first.conpty.Write([]byte("hello\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	readSessionOutput(t, first, "hello\r\n")
+	first.conpty.Close()
+
+	// Detaching is noticed by the daemon asynchronously
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sessions, err := ListSessions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) == 1 && sessions[0].Name == "work" && !sessions[0].Attached {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sessions after detach: %+v", sessions)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The output is replayed to the next client
+	second := attachTestSession(t, "work")
+	readSessionOutput(t, second, "hello\r\n")
+	second.feed([]byte("hello\r\n"))
+	assertScreen(t, second, "hello")
+
+	exited := make(chan struct{})
+	second.conpty.(*sessionConn).onExit = func() { close(exited) }
+	if err := KillSession("work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.conpty.Read(make([]byte, 16)); err != io.EOF {
+		t.Fatalf("read after kill got %v want EOF", err)
+	}
+	<-exited
+}
+
+func TestSessionDaemonRefusesSessionsWhenExiting(t *testing.T) {
+	d := startTestDaemon(t)
+
+	term := attachTestSession(t, "last")
+	if err := KillSession("last"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := term.conpty.Read(make([]byte, 16)); err != io.EOF {
+		t.Fatalf("read after kill got %v want EOF", err)
+	}
+
+	// The session is removed after its client is told it exited
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		d.mu.Lock()
+		closing := d.closing
+		d.mu.Unlock()
+		if closing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("daemon didn't start exiting after its last session")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := d.session(sessionRequest{Name: "late"}); !errors.Is(err, errDaemonClosing) {
+		t.Fatalf("session while exiting got %v want %v", err, errDaemonClosing)
+	}
+}
+
+func TestSessionDaemonExitsWhenSpawnFails(t *testing.T) {
+	d := startTestDaemon(t)
+	attachTestSession(t, "running")
+
+	d.mu.Lock()
+	d.spawn = func(req sessionRequest) (sessionPTY, error) {
+		return nil, errors.New("no shell")
+	}
+	d.mu.Unlock()
+
+	// Another session keeps the daemon running
+	if _, err := d.session(sessionRequest{Name: "broken"}); err == nil {
+		t.Fatal("session with a failing shell succeeded")
+	}
+	d.mu.Lock()
+	closing := d.closing
+	d.mu.Unlock()
+	if closing {
+		t.Fatal("daemon started exiting while a session was running")
+	}
+
+	// Without one the daemon exits instead of waiting with nothing to serve
+	d = startTestDaemon(t)
+	d.spawn = func(req sessionRequest) (sessionPTY, error) {
+		return nil, errors.New("no shell")
+	}
+	if _, err := d.session(sessionRequest{Name: "first"}); err == nil {
+		t.Fatal("session with a failing shell succeeded")
+	}
+	if _, err := d.session(sessionRequest{Name: "second"}); !errors.Is(err, errDaemonClosing) {
+		t.Fatalf("session after the failed spawn got %v want %v", err, errDaemonClosing)
+	}
+	if _, err := d.listener.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("accept after the failed spawn got %v want %v", err, net.ErrClosed)
+	}
+}
+
+// startTestDaemon serves sessions whose "shell" echoes its input.
+func startTestDaemon(t *testing.T) *sessionDaemon {
+	t.Helper()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	path, err := sessionSocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	d := &sessionDaemon{
+		sessions: make(map[string]*daemonSession),
+		listener: listener,
+		spawn: func(req sessionRequest) (sessionPTY, error) {
+			r, w := io.Pipe()
+			return &echoPTY{r: r, w: w}, nil
+		},
+	}
+	go d.serve()
+	return d
+}
+
+func attachTestSession(t *testing.T, name string) *Terminal {
+	t.Helper()
+	term, err := NewTerminal(Config{Width: 20, Height: 3, Session: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := term.attachSession(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { term.conpty.Close() })
+	return term
+}
+
+func readSessionOutput(t *testing.T, term *Terminal, want string) {
+	t.Helper()
+	buf := make([]byte, len(want))
+	if _, err := io.ReadFull(term.conpty, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != want {
+		t.Fatalf("output got %q want %q", buf, want)
+	}
+}
+
+// echoPTY stands in for a shell that prints what it is sent.
+type echoPTY struct {
+	r *io.PipeReader
+	w *io.PipeWriter
+}
+
+func (p *echoPTY) Read(b []byte) (int, error)  { return p.r.Read(b) }
+func (p *echoPTY) Write(b []byte) (int, error) { return p.w.Write(b) }
+func (p *echoPTY) Close() error                { return p.r.Close() }
+func (p *echoPTY) Resize(cols, rows int) error { return nil }
+func (p *echoPTY) Kill() error                 { return p.w.Close() }
@@ -0,0 +1,134 @@
+//go:build unix
+
+package terminal
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+// sessionSocketPath returns the path of the session daemon's socket in a
+// directory only the current user can access. The directory may already
+// exist, made by someone else in a shared /tmp, so it is refused unless it
+// is a real directory the current user owns with mode 0700.
+func sessionSocketPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir != "" {
+		dir = filepath.Join(dir, "vem")
+	} else {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("vem-%d", os.Getuid()))
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	if err := checkPrivateDir(dir); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sessions.sock"), nil
+}
+
+// checkPrivateDir returns an error unless dir is a directory, not a
+// symlink, owned by the current user and closed to everyone else.
+func checkPrivateDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("session directory %s is not a directory", dir)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("session directory %s is not owned by the current user", dir)
+	}
+	if info.Mode().Perm() != 0o700 {
+		return fmt.Errorf("session directory %s has mode %#o, want 0700", dir, info.Mode().Perm())
+	}
+	return nil
+}
+
+// checkSessionPeer returns an error unless the process at the other end of
+// conn runs as the current user, so a daemon someone else started on the
+// socket path never sees our keystrokes.
+func checkSessionPeer(conn net.Conn) error {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("session daemon connection is not a unix socket")
+	}
+	uid, err := peerUID(unixConn)
+	if err != nil {
+		return fmt.Errorf("checking session daemon: %w", err)
+	}
+	if uid != os.Getuid() {
+		return fmt.Errorf("session daemon runs as uid %d, not the current user", uid)
+	}
+	return nil
+}
+
+// startSessionDaemon runs the Vem executable as the session daemon in its
+// own session, so it outlives the editor.
+func startSessionDaemon() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exe, SessionDaemonFlag)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// ptySession is a shell started by the session daemon.
+type ptySession struct {
+	*os.File
+	cmd *exec.Cmd
+}
+
+// spawnSessionShell starts the shell of a new session on a PTY.
+func spawnSessionShell(req sessionRequest) (sessionPTY, error) {
+	shell, args := req.Shell, req.Args
+	if shell == "" {
+		shell, args = DefaultShell(), DefaultArgs()
+	}
+
+	cmd := exec.Command(shell, args...)
+	cmd.Dir = req.Dir
+	cmd.Env = req.Env
+
+	size := &pty.Winsize{Rows: 24, Cols: 80}
+	if req.Cols > 0 && req.Rows > 0 {
+		size = &pty.Winsize{Rows: uint16(req.Rows), Cols: uint16(req.Cols)}
+	}
+	// pty.StartWithSize makes the shell a session leader with the PTY as its
+	// controlling terminal
+	ptyFile, err := pty.StartWithSize(cmd, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reap the shell; its exit ends the PTY output
+	go func() {
+		cmd.Wait()
+	}()
+
+	return &ptySession{File: ptyFile, cmd: cmd}, nil
+}
+
+// Resize updates the PTY window size.
+func (p *ptySession) Resize(cols, rows int) error {
+	return pty.Setsize(p.File, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+// Kill hangs up the shell.
+func (p *ptySession) Kill() error {
+	return p.cmd.Process.Signal(syscall.SIGHUP)
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package terminal
+
+import "net"
+
+// sessionSocketPath reports that Windows has no session daemon; ConPTY
+// sessions would need a different process model.
+func sessionSocketPath() (string, error) {
+	return "", errSessionsUnsupported
+}
+
+func checkSessionPeer(conn net.Conn) error {
+	return errSessionsUnsupported
+}
+
+func startSessionDaemon() error {
+	return errSessionsUnsupported
+}
+
+func spawnSessionShell(req sessionRequest) (sessionPTY, error) {
+	return nil, errSessionsUnsupported
+}
@@ -0,0 +1,133 @@
+package terminal
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hinshun/vt10x"
+)
+
+// Shell integration: shells configured for it report their working
+// directory with OSC 7 ("ESC ] 7 ; file://host/path ST") and mark their
+// prompts with OSC 133 ("ESC ] 133 ; A ST" where the prompt starts, "C"
+// where the command's output starts and "D ; status" when it finished).
+// vt10x ignores both, so they are taken out of the output like hyperlinks.
+
+// Intercepted OSC sequences
+var (
+	oscCwd    = []byte("\x1b]7;")
+	oscPrompt = []byte("\x1b]133;")
+)
+
+// Prompt is a shell prompt and the command run from it. Lines are
+// absolute, as returned by ScreenBuffer.HistoryRange.
+type Prompt struct {
+	Line     int // Line the prompt starts on
+	Output   int // First line of the command's output, -1 until it runs
+	End      int // Line after the output, -1 until the command finishes
+	ExitCode int // Exit status, valid once the command finished
+}
+
+// Finished reports whether the command run from the prompt has finished.
+func (p Prompt) Finished() bool {
+	return p.End >= 0
+}
+
+// handleCwd handles the URL of an OSC 7 sequence. Directories on other
+// hosts, e.g. in an ssh session, are ignored.
+func (t *Terminal) handleCwd(uri string) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		if host, err := os.Hostname(); err != nil || !strings.EqualFold(host, u.Host) {
+			return
+		}
+	}
+	t.mu.Lock()
+	t.reportedDir = u.Path
+	t.mu.Unlock()
+}
+
+// handlePromptMark handles the parameters of an OSC 133 sequence.
+func (t *Terminal) handlePromptMark(params string) {
+	kind, rest, _ := strings.Cut(params, ";")
+
+	t.vt.Lock()
+	cur := t.vt.Cursor()
+	altScreen := t.vt.Mode()&vt10x.ModeAltScreen != 0
+	t.vt.Unlock()
+	if altScreen {
+		return
+	}
+
+	// Lines that scrolled off but haven't reached the screen buffer yet
+	// come before the screen rows
+	_, screenTop := t.screen.HistoryRange()
+	line := screenTop + len(t.history) + cur.Y
+
+	switch kind {
+	case "A":
+		t.screen.addPrompt(line)
+	case "C":
+		t.screen.updatePrompt(func(p *Prompt) {
+			p.Output = line
+		})
+	case "D":
+		status, _ := strconv.Atoi(strings.SplitN(rest, ";", 2)[0])
+		if cur.X > 0 {
+			// The output didn't end with a line break
+			line++
+		}
+		t.screen.updatePrompt(func(p *Prompt) {
+			if p.Output >= 0 && p.End < 0 {
+				p.End = max(line, p.Output)
+				p.ExitCode = status
+			}
+		})
+	}
+}
+
+// addPrompt records a prompt starting on line. Prompts from that line on
+// were drawn over, e.g. after the screen was cleared, and are dropped.
+func (sb *ScreenBuffer) addPrompt(line int) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	first := sb.history.first()
+	kept := sb.prompts[:0]
+	for _, p := range sb.prompts {
+		if p.Line >= first && p.Line < line {
+			kept = append(kept, p)
+		}
+	}
+	sb.prompts = append(kept, Prompt{Line: line, Output: -1, End: -1})
+}
+
+// updatePrompt changes the latest prompt, if any.
+func (sb *ScreenBuffer) updatePrompt(update func(*Prompt)) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if len(sb.prompts) > 0 {
+		update(&sb.prompts[len(sb.prompts)-1])
+	}
+}
+
+// Prompts returns the shell prompts still in the scrollback or on screen,
+// oldest first. It is empty unless the shell reports them with OSC 133.
+func (sb *ScreenBuffer) Prompts() []Prompt {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+
+	first := sb.history.first()
+	var prompts []Prompt
+	for _, p := range sb.prompts {
+		if p.Line >= first {
+			prompts = append(prompts, p)
+		}
+	}
+	return prompts
+}
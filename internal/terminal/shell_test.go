@@ -0,0 +1,52 @@
+package terminal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFeedWorkingDir(t *testing.T) {
+	term := newTestTerminal(t, 20, 2)
+	term.workingDir = "/start"
+	term.feed([]byte("\x1b]7;file://localhost/home/u"))
+	if got := term.WorkingDir(); got != "/start" {
+		t.Fatalf("working dir before the sequence ends got %q", got)
+	}
+	term.feed([]byte("ser/my%20src\x1b\\$ "))
+	if got := term.WorkingDir(); got != "/home/user/my src" {
+		t.Fatalf("working dir got %q", got)
+	}
+
+	// Directories on other machines are ignored
+	term.feed([]byte("\x1b]7;file://elsewhere.invalid/srv\x07"))
+	if got := term.WorkingDir(); got != "/home/user/my src" {
+		t.Fatalf("remote working dir got %q", got)
+	}
+	assertScreen(t, term, "$")
+}
+
+func TestFeedPromptMarks(t *testing.T) {
+	term := newTestTerminal(t, 20, 3)
+	prompt := "\x1b]133;A\x07$ \x1b]133;B\x07"
+	term.feed([]byte(prompt + "make\r\n\x1b]133;C\x07"))
+	term.feed([]byte("one\r\ntwo\r\nthree\r\n\x1b]133;D;2\x07"))
+	term.feed([]byte(prompt + "ls\r\n\x1b]133;C\x07"))
+	assertHistory(t, term, "$ make", "one", "two")
+	assertScreen(t, term, "three", "$ ls", "")
+
+	want := []Prompt{
+		{Line: 0, Output: 1, End: 4, ExitCode: 2},
+		{Line: 4, Output: 5, End: -1},
+	}
+	if got := term.GetScreen().Prompts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("prompts got %+v want %+v", got, want)
+	}
+
+	// A prompt drawn over older ones, e.g. after clearing the screen,
+	// replaces them
+	term.feed([]byte("\x1b[H\x1b[2J" + prompt))
+	want = []Prompt{want[0], {Line: 3, Output: -1, End: -1}}
+	if got := term.GetScreen().Prompts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("prompts after clear got %+v want %+v", got, want)
+	}
+}
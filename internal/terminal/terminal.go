@@ -7,7 +7,9 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gioui.org/app"
@@ -26,7 +28,7 @@ type ConPtyIO interface {
 type Terminal struct {
 	// PTY and process
 	pty    *os.File  // PTY master file descriptor (Unix)
-	conpty ConPtyIO  // ConPTY instance (Windows)
+	conpty ConPtyIO  // ConPTY instance (Windows) or session connection
 	cmd    *exec.Cmd // Shell process
 
 	// VT100 emulator
@@ -35,6 +37,35 @@ type Terminal struct {
 	// Screen buffer
 	screen *ScreenBuffer // Current screen content
 
+	// Output parsing. feedMu serialises feeding the emulator with copying
+	// its screen, so scrolled-off lines and screen rows stay in step.
+	feedMu    sync.Mutex
+	partial   []byte          // Incomplete UTF-8 sequence left over from the last read
+	history   []Line          // Lines scrolled off since the last screen update
+	snapshot  [][]vt10x.Glyph // vt10x screen as of the last screen update
+	grid      [][]vt10x.Glyph // Scratch copy of the vt10x screen
+	topRows   [][]vt10x.Glyph // Scratch rows for detecting scrolls
+	bottomRow []vt10x.Glyph
+	link      string       // URI of the open OSC 8 hyperlink, see links.go
+	linkGrid  [][]linkCell // Hyperlinks of the vt10x screen cells
+	linkCells int          // Cells of linkGrid holding a link
+	markGrid  [][]markCell // Combining marks of the vt10x screen cells, see width.go
+	markCells int          // Cells of markGrid holding marks
+	escState  int          // Escape sequence state of the output fed so far
+	modeTail  []byte       // Private mode sequence cut off at the end of a read
+	palette   Palette      // Configured colors, see colors.go
+	colors    colorTable   // palette with the changes programs made
+
+	// Scrolling the escape sequence scanner saw, see feed.go
+	escParams   []byte        // Parameters of the CSI sequence being scanned
+	escLineFeed bool          // An IND or NEL was scanned
+	escScrollUp int           // Lines a scanned SU scrolls
+	margins     scrollMargins // Scroll region set with DECSTBM
+
+	// Input modes vt10x doesn't track, see input.go
+	bracketedPaste atomic.Bool // The application asked for bracketed paste
+	focused        atomic.Bool // Focus state last reported to the application
+
 	// Terminal size
 	width  int // Columns (e.g., 80)
 	height int // Rows (e.g., 24)
@@ -44,6 +75,10 @@ type Terminal struct {
 	args       []string // Shell arguments
 	workingDir string   // Working directory
 	env        []string // Environment variables
+	session    string   // Session daemon session, empty for a shell of our own
+
+	// Directory the shell last reported with OSC 7, see shell.go (protected by mu)
+	reportedDir string
 
 	// Lifecycle
 	ctx     context.Context
@@ -67,6 +102,14 @@ type Terminal struct {
 
 	// Exit callback
 	onExit func() // Called when terminal process exits
+
+	// Process exit and captured output, see process.go
+	exitCode  int           // Exit status, valid once exited is set (protected by mu)
+	exited    bool          // The terminal's own process exited (protected by mu)
+	readDone  chan struct{} // Closed when the read loop ends
+	capture   bool          // Keep output for CapturedOutput
+	captureMu sync.Mutex
+	captured  []byte
 }
 
 // Config holds terminal configuration
@@ -79,6 +122,21 @@ type Config struct {
 	Env        []string
 	Window     *app.Window // For invalidation
 	OnExit     func()      // Called when terminal process exits
+
+	// CaptureOutput keeps the process output for CapturedOutput, e.g. to
+	// parse a build's errors once it exits.
+	CaptureOutput bool
+
+	// Session attaches to (or creates) the named session of the session
+	// daemon instead of starting a shell that ends with the terminal.
+	Session string
+
+	// ScrollbackLines is how many lines that scrolled off the screen are
+	// kept. 0 uses DefaultScrollbackLines, a negative value disables it.
+	ScrollbackLines int
+
+	// Palette sets the colors; nil uses DefaultPalette.
+	Palette *Palette
 }
 
 // NewTerminal creates a new terminal with given config
@@ -108,6 +166,9 @@ func NewTerminal(cfg Config) (*Terminal, error) {
 		args:       cfg.Args,
 		workingDir: cfg.WorkingDir,
 		env:        cfg.Env,
+		session:    cfg.Session,
+		readDone:   make(chan struct{}),
+		capture:    cfg.CaptureOutput,
 		ctx:        ctx,
 		cancel:     cancel,
 		inputChan:  make(chan []byte, 256), // Buffered for responsiveness
@@ -116,8 +177,18 @@ func NewTerminal(cfg Config) (*Terminal, error) {
 		onExit:     cfg.OnExit,
 	}
 
+	t.palette = DefaultPalette
+	if cfg.Palette != nil {
+		t.palette = *cfg.Palette
+	}
+	t.colors = newColorTable(&t.palette)
+
 	// Create screen buffer
 	t.screen = NewScreenBuffer(cfg.Width, cfg.Height)
+	t.screen.setColors(&t.palette)
+	if cfg.ScrollbackLines != 0 {
+		t.screen.SetHistoryLimit(max(cfg.ScrollbackLines, 0))
+	}
 
 	// Create VT100 emulator with size
 	t.vt = vt10x.New(vt10x.WithSize(cfg.Width, cfg.Height))
@@ -135,8 +206,12 @@ func (t *Terminal) Start() error {
 	t.running = true
 	t.mu.Unlock()
 
-	// Create PTY
-	if err := t.startPTY(); err != nil {
+	// Create PTY, or connect to the session that owns it
+	start := t.startPTY
+	if t.session != "" {
+		start = t.attachSession
+	}
+	if err := start(); err != nil {
 		t.setError(err)
 		t.mu.Lock()
 		t.running = false
@@ -145,9 +220,10 @@ func (t *Terminal) Start() error {
 	}
 
 	// Start goroutines
-	t.wg.Add(2)
+	t.wg.Add(3)
 	go t.readLoop()
 	go t.writeLoop()
+	go t.refreshLoop()
 
 	return nil
 }
@@ -155,6 +231,7 @@ func (t *Terminal) Start() error {
 // readLoop reads from PTY and feeds to emulator
 func (t *Terminal) readLoop() {
 	defer t.wg.Done()
+	defer close(t.readDone)
 
 	buf := make([]byte, 4096)
 
@@ -180,23 +257,14 @@ func (t *Terminal) readLoop() {
 
 		if n > 0 {
 			// Write to vt10x parser - it will parse ANSI sequences and update its internal state
-			if _, writeErr := t.vt.Write(buf[:n]); writeErr != nil {
-				// Silently handle vt10x write errors
-			}
+			t.feed(buf[:n])
+			t.captureOutput(buf[:n])
 
-			// Update our screen buffer from vt10x state
-			t.updateScreenFromVT10x()
-
-			// Signal update (non-blocking)
+			// Signal update (non-blocking); refreshLoop copies the screen
 			select {
 			case t.updateChan <- struct{}{}:
 			default:
 			}
-
-			// Invalidate window to trigger redraw
-			if t.window != nil {
-				t.window.Invalidate()
-			}
 		}
 
 		if err != nil {
@@ -213,6 +281,39 @@ func (t *Terminal) readLoop() {
 	}
 }
 
+// refreshInterval is the shortest time between two screen updates, so a
+// burst of output is drawn at most once per frame.
+const refreshInterval = time.Second / 60
+
+// refreshLoop updates the screen buffer and redraws the window after output
+// was parsed. Output arriving while it waits for the next frame is coalesced
+// into a single update.
+func (t *Terminal) refreshLoop() {
+	defer t.wg.Done()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-t.updateChan:
+		}
+
+		// Update our screen buffer from vt10x state
+		t.updateScreenFromVT10x()
+
+		// Invalidate window to trigger redraw
+		if t.window != nil {
+			t.window.Invalidate()
+		}
+
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-time.After(refreshInterval):
+		}
+	}
+}
+
 // writeLoop writes input to PTY
 func (t *Terminal) writeLoop() {
 	defer t.wg.Done()
@@ -253,6 +354,23 @@ func (t *Terminal) Write(data []byte) error {
 	}
 }
 
+// WorkingDir returns the shell's current directory as it last reported it
+// (OSC 7), or the directory the terminal was started in.
+func (t *Terminal) WorkingDir() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.reportedDir != "" {
+		return t.reportedDir
+	}
+	return t.workingDir
+}
+
+// Session returns the name of the terminal's session, or "" when the
+// terminal runs its own shell.
+func (t *Terminal) Session() string {
+	return t.session
+}
+
 // GetScreen returns current screen buffer
 func (t *Terminal) GetScreen() *ScreenBuffer {
 	return t.screen
@@ -344,56 +462,103 @@ func (t *Terminal) getEnvironment() []string {
 	return env
 }
 
-// updateScreenFromVT10x updates our screen buffer from vt10x's parsed state
+// updateScreenFromVT10x updates our screen buffer from vt10x's parsed state.
+// Only rows that changed since the last update are converted and copied,
+// and marked dirty for the renderer. vt10x keeps its own dirty flags to
+// itself, so the changed rows are found by comparing with the last update.
 func (t *Terminal) updateScreenFromVT10x() {
-	// Lock vt10x state while reading
-	t.vt.Lock()
-	defer t.vt.Unlock()
+	t.feedMu.Lock()
+	defer t.feedMu.Unlock()
 
-	// Get terminal dimensions
+	// Only copy the screen under the vt10x lock, so the reader isn't held
+	// up by the comparison
+	t.vt.Lock()
 	cols, rows := t.vt.Size()
+	resized := len(t.snapshot) != rows || (rows > 0 && len(t.snapshot[0]) != cols)
+	if resized || len(t.grid) != rows {
+		t.snapshot = newGlyphGrid(cols, rows)
+		t.grid = newGlyphGrid(cols, rows)
+		resized = true
+	}
+	for y, row := range t.grid {
+		for x := range row {
+			row[x] = t.vt.Cell(x, y)
+		}
+	}
+	cursor := t.vt.Cursor()
+	t.vt.Unlock()
+
+	// The changed rows become the snapshot; the old ones are reused as
+	// scratch next time
+	var damaged []int
+	for y := range t.grid {
+		if resized || !slices.Equal(t.grid[y], t.snapshot[y]) {
+			t.grid[y], t.snapshot[y] = t.snapshot[y], t.grid[y]
+			damaged = append(damaged, y)
+		}
+	}
 
-	// Attribute bit masks (from vt10x source)
-	const (
-		attrBold      = 1 << 0
-		attrDim       = 1 << 1
-		attrItalic    = 1 << 2
-		attrUnderline = 1 << 3
-		attrBlink     = 1 << 4
-		attrReverse   = 1 << 5
-	)
-
-	// Update each cell from vt10x
-	for y := 0; y < rows && y < t.height; y++ {
-		for x := 0; x < cols && x < t.width; x++ {
-			// Get glyph from vt10x
-			glyph := t.vt.Cell(x, y)
-
-			// Convert vt10x.Color to our color format
-			fg := vt10xColorToNRGBA(uint32(glyph.FG))
-			bg := vt10xColorToNRGBA(uint32(glyph.BG))
-
-			// Handle reverse video
-			if glyph.Mode&attrReverse != 0 {
-				fg, bg = bg, fg
+	// Convert outside the vt10x lock so the reader isn't held up
+	updates := make([]rowUpdate, len(damaged))
+	for i, y := range damaged {
+		cells := make([]Cell, cols)
+		for x, glyph := range t.snapshot[y] {
+			cells[x] = glyphToCell(glyph, &t.colors)
+			if t.linkGrid != nil {
+				cells[x].Link = t.cellLink(x, y, glyph.Char)
+			}
+			if t.markGrid != nil {
+				cells[x].Marks = t.cellMarks(x, y, glyph.Char)
 			}
-
-			// Set cell in our buffer
-			t.screen.SetCell(x, y, Cell{
-				Rune:      glyph.Char,
-				FG:        fg,
-				BG:        bg,
-				Bold:      glyph.Mode&attrBold != 0,
-				Dim:       glyph.Mode&attrDim != 0,
-				Italic:    glyph.Mode&attrItalic != 0,
-				Underline: glyph.Mode&attrUnderline != 0,
-				Blink:     glyph.Mode&attrBlink != 0,
-				Reverse:   glyph.Mode&attrReverse != 0,
-			})
 		}
+		applyWidths(cells)
+		updates[i] = rowUpdate{y: y, cells: cells}
 	}
 
-	// Update cursor position
-	cursor := t.vt.Cursor()
-	t.screen.SetCursor(cursor.X, cursor.Y)
+	t.screen.applyUpdate(t.history, updates, cursor.X, cursor.Y)
+	t.history = nil
+}
+
+// newGlyphGrid returns rows rows of cols glyphs.
+func newGlyphGrid(cols, rows int) [][]vt10x.Glyph {
+	grid := make([][]vt10x.Glyph, rows)
+	for y := range grid {
+		grid[y] = make([]vt10x.Glyph, cols)
+	}
+	return grid
+}
+
+// Attribute bit masks (from vt10x source)
+const (
+	attrBold      = 1 << 0
+	attrDim       = 1 << 1
+	attrItalic    = 1 << 2
+	attrUnderline = 1 << 3
+	attrBlink     = 1 << 4
+	attrReverse   = 1 << 5
+	attrWrap      = 1 << 6
+)
+
+// glyphToCell converts a vt10x glyph to a screen cell.
+func glyphToCell(glyph vt10x.Glyph, colors *colorTable) Cell {
+	// Convert vt10x.Color to our color format
+	fg := colors.convert(uint32(glyph.FG))
+	bg := colors.convert(uint32(glyph.BG))
+
+	// Handle reverse video
+	if glyph.Mode&attrReverse != 0 {
+		fg, bg = bg, fg
+	}
+
+	return Cell{
+		Rune:      glyph.Char,
+		FG:        fg,
+		BG:        bg,
+		Bold:      glyph.Mode&attrBold != 0,
+		Dim:       glyph.Mode&attrDim != 0,
+		Italic:    glyph.Mode&attrItalic != 0,
+		Underline: glyph.Mode&attrUnderline != 0,
+		Blink:     glyph.Mode&attrBlink != 0,
+		Reverse:   glyph.Mode&attrReverse != 0,
+	}
 }
@@ -0,0 +1,65 @@
+package terminal
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUpdateCopiesDamagedRows(t *testing.T) {
+	term := newTestTerminal(t, 10, 3)
+	term.feed([]byte("one\r\ntwo\r\nthree"))
+	term.updateScreenFromVT10x()
+	term.GetScreen().MarkClean()
+
+	term.feed([]byte("\x1b[2;1Hxyz"))
+	term.updateScreenFromVT10x()
+
+	screen := term.GetScreen()
+	for y, want := range []bool{false, true, false} {
+		if got := screen.GetLine(y).Dirty; got != want {
+			t.Fatalf("row %d dirty got %v want %v", y, got, want)
+		}
+	}
+	assertScreen(t, term, "one", "xyz", "three")
+}
+
+// BenchmarkReplay feeds recorded shell output (colored ls and grep listings
+// and a progress bar) through the terminal in PTY-sized reads.
+func BenchmarkReplay(b *testing.B) {
+	data, err := os.ReadFile("testdata/replay.vt")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("per-read", func(b *testing.B) {
+		benchmarkReplay(b, data, 1)
+	})
+	b.Run("per-frame", func(b *testing.B) {
+		// Roughly what refreshLoop does for a fast producer
+		benchmarkReplay(b, data, 16)
+	})
+}
+
+// benchmarkReplay replays data in 4 KB reads, updating the screen buffer
+// after every readsPerUpdate reads.
+func benchmarkReplay(b *testing.B, data []byte, readsPerUpdate int) {
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		term, err := NewTerminal(Config{Width: 120, Height: 40})
+		if err != nil {
+			b.Fatal(err)
+		}
+		reads := 0
+		for rest := data; len(rest) > 0; {
+			n := min(len(rest), 4096)
+			term.feed(rest[:n])
+			rest = rest[n:]
+			if reads++; reads%readsPerUpdate == 0 {
+				term.updateScreenFromVT10x()
+			}
+		}
+		term.updateScreenFromVT10x()
+	}
+}
@@ -0,0 +1,190 @@
+package terminal
+
+import (
+	"unicode/utf8"
+
+	"github.com/javanhut/vem/internal/textwidth"
+)
+
+// vt10x gives every character one cell and prints combining marks in cells
+// of their own. Characters that don't take exactly one column are therefore
+// fed on their own: a wide character is followed by wideSpacer, which fills
+// its second cell in the vt10x screen so scrolling and erasing treat both
+// halves alike, and a combining mark isn't fed at all but recorded in a grid
+// like the hyperlink grid, for the cell of the character before it. A wide
+// character that doesn't fit in the last column wraps to the next line, as
+// in xterm. Marks, like links, only count while their cell still holds the
+// character they were recorded for.
+
+// wideSpacer fills the second cell of a wide character in the vt10x screen.
+// It is a noncharacter, so programs don't print it themselves.
+const wideSpacer = '\uFDD0'
+
+// maxMarks bounds the combining marks kept for one cell.
+const maxMarks = 32
+
+// markCell is one cell of the mark grid.
+type markCell struct {
+	marks string
+	char  rune // Character the marks were recorded for
+}
+
+// plainPrefix returns how much of data can be fed as is: everything up to
+// the first printed character that doesn't take one column. The escape
+// sequence state follows the bytes it covers.
+func (t *Terminal) plainPrefix(data []byte) int {
+	for i := 0; i < len(data); {
+		b := data[i]
+		if t.escState == escGround && b >= ' ' && b < 0x7f {
+			// Plain ASCII, the common case
+			i++
+			continue
+		}
+		if !t.escapeStep(b) || b < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r != utf8.RuneError && textwidth.Rune(r) != 1 {
+			return i
+		}
+		i += size
+	}
+	return len(data)
+}
+
+// wideSequence returns what is fed for a wide character: the character
+// and wideSpacer, after a blank to wrap first if the cursor is in the last
+// column. Called with the vt10x lock held.
+func (t *Terminal) wideSequence(char rune, cols int) (seq []byte, wraps bool) {
+	cur := t.vt.Cursor()
+	if cur.X == cols-1 && cur.State&cursorWrapNext == 0 && cols > 1 {
+		seq = append(seq, ' ')
+		wraps = true
+	}
+	seq = utf8.AppendRune(seq, char)
+	return utf8.AppendRune(seq, wideSpacer), wraps
+}
+
+// printedCell returns the cell of the last character printed, which takes
+// width cells. Called with the vt10x lock held.
+func (t *Terminal) printedCell(width int) (x, y int) {
+	cur := t.vt.Cursor()
+	x = cur.X - 1
+	if cur.State&cursorWrapNext != 0 {
+		// Printing in the last column leaves the cursor on it
+		x = cur.X
+	}
+	return x - width + 1, cur.Y
+}
+
+// addMark records a combining mark for the character before the cursor.
+// Called with the vt10x lock held.
+func (t *Terminal) addMark(mark rune, cols, rows int) {
+	x, y := t.printedCell(1)
+	if x >= 0 && x < cols && t.vt.Cell(x, y).Char == wideSpacer {
+		x--
+	}
+	if x < 0 || y < 0 || x >= cols || y >= rows {
+		// Nothing to combine with
+		return
+	}
+
+	if len(t.markGrid) != rows || len(t.markGrid[0]) != cols {
+		t.markGrid = make([][]markCell, rows)
+		for i := range t.markGrid {
+			t.markGrid[i] = make([]markCell, cols)
+		}
+		t.markCells = 0
+	}
+	char := t.vt.Cell(x, y).Char
+	cell := &t.markGrid[y][x]
+	if cell.marks == "" {
+		t.markCells++
+	} else if cell.char != char {
+		cell.marks = ""
+	}
+	if len(cell.marks) < maxMarks {
+		cell.marks += string(mark)
+	}
+	cell.char = char
+
+	// Make the next screen update copy the row even if the glyph is unchanged
+	if y < len(t.snapshot) && x < len(t.snapshot[y]) {
+		t.snapshot[y][x].Char = -1
+	}
+}
+
+// clearMarks drops the marks of a cell that was printed over. Called with
+// the vt10x lock held.
+func (t *Terminal) clearMarks(x, y int) {
+	if y < 0 || y >= len(t.markGrid) || x < 0 || x >= len(t.markGrid[y]) {
+		return
+	}
+	if t.markGrid[y][x].marks != "" {
+		t.markGrid[y][x] = markCell{}
+		t.markCells--
+	}
+}
+
+// scrollMarks moves the mark grid rows down to bottom up with the screen
+// and returns the marks of the row that scrolled off.
+func (t *Terminal) scrollMarks(bottom int) []markCell {
+	if len(t.markGrid) == 0 {
+		return nil
+	}
+	bottom = min(bottom, len(t.markGrid)-1)
+	top := t.markGrid[0]
+	copy(t.markGrid[:bottom], t.markGrid[1:bottom+1])
+	t.markGrid[bottom] = make([]markCell, len(top))
+	for _, cell := range top {
+		if cell.marks != "" {
+			t.markCells--
+		}
+	}
+	return top
+}
+
+// cellMarks returns the combining marks of a screen cell showing char.
+func (t *Terminal) cellMarks(x, y int, char rune) string {
+	if y >= len(t.markGrid) || x >= len(t.markGrid[y]) {
+		return ""
+	}
+	cell := &t.markGrid[y][x]
+	if cell.marks == "" {
+		return ""
+	}
+	if cell.char != char {
+		*cell = markCell{}
+		t.markCells--
+		return ""
+	}
+	return cell.marks
+}
+
+// applyMarks sets Cell.Marks on a converted row from the grid row marks.
+func applyMarks(cells []Cell, marks []markCell) {
+	for x := range cells {
+		if x < len(marks) && marks[x].marks != "" && marks[x].char == cells[x].Rune {
+			cells[x].Marks = marks[x].marks
+		}
+	}
+}
+
+// applyWidths pairs the wide characters of a converted row with the
+// spacers in their second cells. A spacer whose character was printed over
+// shows as a blank, and a wide character that lost its spacer is drawn in
+// its one cell.
+func applyWidths(cells []Cell) {
+	for x := range cells {
+		if cells[x].Rune != wideSpacer {
+			continue
+		}
+		cells[x].Rune = ' '
+		cells[x].Marks = ""
+		if x > 0 && !cells[x-1].Spacer && textwidth.Rune(cells[x-1].Rune) == 2 {
+			cells[x-1].Wide = true
+			cells[x].Spacer = true
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package terminal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFeedMixedWidths(t *testing.T) {
+	term := newTestTerminal(t, 10, 4)
+	// The second character arrives split across reads
+	term.feed([]byte("a日\xe6"))
+	term.feed([]byte("\x9c\xacb e\u0301!\r\n"))
+	term.feed([]byte("123456789語\r\n"))
+	assertScreen(t, term, "a日本b e\u0301!", "123456789", "語")
+
+	line := term.GetScreen().GetLine(0)
+	var widths []string
+	for _, c := range line.Cells[:8] {
+		switch {
+		case c.Wide:
+			widths = append(widths, "wide")
+		case c.Spacer:
+			widths = append(widths, "spacer")
+		default:
+			widths = append(widths, c.Grapheme())
+		}
+	}
+	want := []string{"a", "wide", "spacer", "wide", "spacer", "b", " ", "e\u0301"}
+	if !reflect.DeepEqual(widths, want) {
+		t.Fatalf("cells got %q want %q", widths, want)
+	}
+
+	runes, cells := line.CellText()
+	if string(runes) != "a日本b e\u0301!" || !reflect.DeepEqual(cells, []int{0, 1, 3, 5, 6, 7, 7, 8, 9}) {
+		t.Fatalf("cell text got %q %v", string(runes), cells)
+	}
+
+	// A wide character that doesn't fit in the last column wraps
+	if c := term.GetScreen().GetLine(1).Cells[9]; c.Rune != ' ' || c.Spacer {
+		t.Fatalf("last column got %+v", c)
+	}
+	if c := term.GetScreen().GetLine(2).Cells[0]; !c.Wide || c.Rune != '語' {
+		t.Fatalf("wrapped character got %+v", c)
+	}
+}
+
+func TestFeedWidthsIntoScrollback(t *testing.T) {
+	term := newTestTerminal(t, 10, 2)
+	term.feed([]byte("日x\u0301\r\nsecond\r\nthird"))
+	assertHistory(t, term, "日x\u0301")
+	assertScreen(t, term, "second", "third")
+
+	first, _ := term.GetScreen().HistoryRange()
+	cells := term.GetScreen().ViewLine(first).Cells
+	if !cells[0].Wide || !cells[1].Spacer || cells[2].Marks != "\u0301" {
+		t.Fatalf("history cells got %+v", cells)
+	}
+}
+
+func TestOverwriteMarks(t *testing.T) {
+	term := newTestTerminal(t, 10, 2)
+	term.feed([]byte("e\u0301\u0302 日\u0300"))
+	assertScreen(t, term, "e\u0301\u0302 日\u0300")
+
+	// Printing over a cell drops its marks, even with the same character
+	term.feed([]byte("\x1b[1;1He\x1b[1;3Hx"))
+	assertScreen(t, term, "e x")
+	if cells := term.GetScreen().GetLine(0).Cells; cells[0].Marks != "" || cells[3].Rune != ' ' || cells[3].Spacer {
+		t.Fatalf("overwritten cells got %+v", cells[:4])
+	}
+	if term.markCells != 0 {
+		t.Fatalf("mark cells got %d want 0", term.markCells)
+	}
+}
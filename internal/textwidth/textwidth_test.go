@@ -0,0 +1,46 @@
+package textwidth
+
+import "testing"
+
+func TestRune(t *testing.T) {
+	tests := []struct {
+		r    rune
+		want int
+	}{
+		{'a', 1},
+		{'é', 1},
+		{'\u0301', 0}, // Combining acute accent
+		{'\u200d', 0}, // Zero width joiner
+		{'\u00ad', 1}, // Soft hyphen
+		{'日', 2},
+		{'한', 2},
+		{'\u1161', 0}, // Hangul medial vowel
+		{'Ａ', 2},      // Fullwidth A
+		{'😀', 2},
+		{'❤', 1}, // Heavy heart, text presentation by default
+		{'│', 1},
+	}
+	for _, tt := range tests {
+		if got := Rune(tt.r); got != tt.want {
+			t.Errorf("Rune(%U) = %d, want %d", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"go", 2},
+		{"日本語 text", 11},
+		{"café", 4},
+		{"ok 👍!", 6},
+	}
+	for _, tt := range tests {
+		if got := String(tt.s); got != tt.want {
+			t.Errorf("String(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
@@ -7,9 +7,18 @@ import (
 	"gioui.org/unit"
 
 	"github.com/javanhut/vem/internal/appcore"
+	"github.com/javanhut/vem/internal/terminal"
 )
 
 func main() {
+	// Vem re-runs itself as the background owner of terminal sessions
+	if len(os.Args) > 1 && os.Args[1] == terminal.SessionDaemonFlag {
+		if err := terminal.RunSessionDaemon(); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
 	go func() {
 		w := new(gioapp.Window)
 		w.Option(